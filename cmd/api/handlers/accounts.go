@@ -1,20 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"social_network_project/cmd/api/middlewares"
 	account2 "social_network_project/internal/account"
 	"social_network_project/internal/account/service"
 	"social_network_project/internal/platform/cache"
-	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
-	"social_network_project/internal/utils/validate"
 	"strconv"
 	"time"
 )
@@ -50,182 +48,100 @@ func (a *AccountsHandler) CreateAccount(c *gin.Context) {
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	account := a.fillFields(request)
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(account)
 	if err != nil {
-		mapper["errors"] = validate.RequestAccountValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
+		return
+	}
 
+	err = a.Controller.InsertAccount(c.Request.Context(), account)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	err = a.Controller.InsertAccount(account)
+	accountCreated, err := a.Controller.FindAccountByID(c.Request.Context(), &account.ID)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.ConflictUsernameError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-
-		case *errors.ConflictEmailError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, account.ToResponse())
+	setETag(c, accountCreated.ToResponse())
+	c.JSON(http.StatusOK, accountCreated.ToResponse())
 	return
 }
 
 func (a *AccountsHandler) GetAccount(c *gin.Context) {
 
-	id, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
-
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
-
-		return
-	}
+	id := c.GetString(middlewares.AccountIDKey)
 
-	account, err := a.Controller.FindAccountByID(&id)
-	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
+	account, err := a.RedisClient.GetOrLoad(c.Request, id, func(ctx context.Context) (any, []string, error) {
+		account, err := a.Controller.FindAccountByID(ctx, &id)
+		if err != nil {
+			return nil, []string{cache.AccountTag(id)}, err
 		}
+		return account.ToResponse(), []string{cache.AccountTag(id)}, nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, account.ToResponse())
-
-	c.JSON(http.StatusOK, account.ToResponse())
+	writeConditional(c, account)
 	return
 
 }
 
 func (a *AccountsHandler) UpdateAccount(c *gin.Context) {
 
-	id, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	id := c.GetString(middlewares.AccountIDKey)
 
 	var request account2.AccountRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
-	account, err := a.Controller.FindAccountByID(&id)
+	account, err := a.Controller.FindAccountByID(c.Request.Context(), &id)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	accountChange := a.mergeAccountToUpdatedAccount(account, request)
 
-	mapper := make(map[string]interface{})
-
 	err = a.Validate.Struct(accountChange)
 	if err != nil {
-		mapper["errors"] = validate.RequestAccountValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
-
+		invalidFields(c, err)
 		return
 	}
 
-	err = a.Controller.ChangeAccountDataByID(&id, request)
+	accountUpdated, err := a.Controller.ChangeAccountDataByID(c.Request.Context(), &id, c.GetString(middlewares.SessionIDKey), request, c.GetHeader("If-Match"))
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.ConflictUsernameError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-
-		case *errors.ConflictEmailError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, account.ToResponse())
+	setETag(c, accountUpdated.ToResponse())
+	c.JSON(http.StatusOK, accountUpdated.ToResponse())
 	return
 }
 
 func (a *AccountsHandler) DeleteAccount(c *gin.Context) {
-	id, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	id := c.GetString(middlewares.AccountIDKey)
 
-	account, err := a.Controller.DeleteAccountByID(&id)
+	account, err := a.Controller.DeleteAccountByID(c.Request.Context(), &id)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, account.ToResponse())
@@ -234,52 +150,28 @@ func (a *AccountsHandler) DeleteAccount(c *gin.Context) {
 
 func (a *AccountsHandler) FollowAccount(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request account2.AccountRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	if request.ID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Add ID",
-		})
+		c.Error(&errors.BadRequestError{Message: "Add ID"})
 		return
 	}
 
 	accountToFollow := request.ID
 
-	account, err := a.Controller.CreateFollow(&accountID, &accountToFollow)
+	account, err := a.Controller.CreateFollow(c.Request.Context(), &accountID, &accountToFollow)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.ConflictAlreadyFollowError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, account.ToResponse())
@@ -288,145 +180,67 @@ func (a *AccountsHandler) FollowAccount(c *gin.Context) {
 
 func (a *AccountsHandler) SearchFollowing(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
-
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
-
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	page := c.DefaultQuery("page", "1")
-	if _, err = strconv.ParseInt(page, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Page is not a number",
-		})
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
 		return
 	}
-	listOfAccounts, err := a.Controller.FindAccountsFollowing(&accountID, &page)
+	listOfAccounts, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		listOfAccounts, err := a.Controller.FindAccountsFollowing(ctx, &accountID, &page)
+		return listOfAccounts, listTags(listOfAccounts, cache.FollowsTag(accountID)), err
+	})
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, listOfAccounts)
-
-	c.JSON(http.StatusOK, listOfAccounts)
+	writeConditional(c, listOfAccounts)
 	return
 }
 
 func (a *AccountsHandler) SearchFollowers(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
-
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
-
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	page := c.DefaultQuery("page", "1")
-	if _, err = strconv.ParseInt(page, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Page is not a number",
-		})
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
 		return
 	}
-	listOfAccounts, err := a.Controller.FindAccountsFollowers(&accountID, &page)
+	listOfAccounts, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		listOfAccounts, err := a.Controller.FindAccountsFollowers(ctx, &accountID, &page)
+		return listOfAccounts, listTags(listOfAccounts, cache.FollowsTag(accountID)), err
+	})
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, listOfAccounts)
-
-	c.JSON(http.StatusOK, listOfAccounts)
+	writeConditional(c, listOfAccounts)
 	return
 }
 
 func (a *AccountsHandler) UnfollowAccount(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request account2.AccountRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	accountToFollow := request.ID
 
-	account, err := a.Controller.DeleteFollow(&accountID, &accountToFollow)
+	account, err := a.Controller.DeleteFollow(c.Request.Context(), &accountID, &accountToFollow)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.ConflictAlreadyUnfollowError:
-			log.Println(e)
-			c.JSON(http.StatusConflict, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, account.ToResponse())
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"net/http"
+	"social_network_project/cmd/api/middlewares"
+	account2 "social_network_project/internal/account"
+	"social_network_project/internal/account/service"
+	service2 "social_network_project/internal/audit/service"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils/errors"
+	"strconv"
+)
+
+type AdminHandlerClient interface {
+	ChangeRole(c *gin.Context)
+	GetAuditLog(c *gin.Context)
+}
+
+type AdminHandler struct {
+	Accounts service.AccountsServiceClient
+	Audits   service2.AuditServiceClient
+}
+
+func RegisterAdminHandlers(accountsController service.AccountsServiceClient, auditsController service2.AuditServiceClient) AdminHandlerClient {
+	return &AdminHandler{
+		Accounts: accountsController,
+		Audits:   auditsController,
+	}
+}
+
+func (a *AdminHandler) ChangeRole(c *gin.Context) {
+	actorID := c.GetString(middlewares.AccountIDKey)
+
+	var request account2.RoleRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	if request.ID == "" {
+		c.Error(&errors.BadRequestError{Message: "Add ID"})
+		return
+	}
+	if !rbac.ValidRole(request.Role) {
+		c.Error(&errors.BadRequestError{Message: "Incorrect role, insert user, moderator or admin"})
+		return
+	}
+
+	account, err := a.Accounts.ChangeAccountRoleByID(c.Request.Context(), &actorID, &request.ID, request.Role)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account.ToResponse())
+	return
+}
+
+func (a *AdminHandler) GetAuditLog(c *gin.Context) {
+	page := c.DefaultQuery("page", "1")
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
+		return
+	}
+
+	audits, err := a.Audits.FindAudits(c.Request.Context(), &page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, audits)
+	return
+}
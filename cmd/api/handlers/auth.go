@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"social_network_project/cmd/api/middlewares"
 	"social_network_project/internal/auth"
 	"social_network_project/internal/auth/service"
 	"social_network_project/internal/utils/errors"
@@ -13,8 +13,21 @@ import (
 
 type AuthHandlerClient interface {
 	CreateToken(c *gin.Context)
+	Unlock(c *gin.Context)
+	VerifyTwoFactor(c *gin.Context)
+	EnrollTwoFactor(c *gin.Context)
+	ConfirmTwoFactor(c *gin.Context)
+	DisableTwoFactor(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	StartOIDC(c *gin.Context)
+	LinkOIDC(c *gin.Context)
+	FinishOIDC(c *gin.Context)
 }
 
+// oidcCookie carries the login token of a sign in with a provider from its
+// start to its callback.
+const oidcCookie = "oidc_login"
+
 type AuthHandler struct {
 	service service.AuthServiceClient
 }
@@ -30,32 +43,198 @@ func (a *AuthHandler) CreateToken(c *gin.Context) {
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
-	token, err := a.service.CreateToken(request.Email, request.Password)
+	token, err := a.service.CreateToken(c.Request.Context(), request.Email, request.Password, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundEmailError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.UnauthorizedPasswordError:
-			log.Println(e)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, token)
 	return
-}
\ No newline at end of file
+}
+
+func (a *AuthHandler) Unlock(c *gin.Context) {
+	var request auth.UnlockRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil || request.Token == "" {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	err = a.service.Unlock(c.Request.Context(), request.Token)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account unlocked",
+	})
+	return
+}
+
+func (a *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var request auth.TwoFactorRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	token, err := a.service.VerifyTwoFactor(c.Request.Context(), request.ChallengeToken, request.Code, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+	return
+}
+
+func (a *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	enrollment, err := a.service.EnrollTwoFactor(c.Request.Context(), accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+	return
+}
+
+func (a *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request auth.TwoFactorRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	recoveryCodes, err := a.service.ConfirmTwoFactor(c.Request.Context(), accountID, request.Code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodes)
+	return
+}
+
+func (a *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request auth.TwoFactorRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	err = a.service.DisableTwoFactor(c.Request.Context(), accountID, request.Password, request.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Two-factor authentication disabled",
+	})
+	return
+}
+
+func (a *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request auth.TwoFactorRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	recoveryCodes, err := a.service.RegenerateRecoveryCodes(c.Request.Context(), accountID, request.Password, request.Code, c.ClientIP())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, recoveryCodes)
+	return
+}
+
+func (a *AuthHandler) StartOIDC(c *gin.Context) {
+	authURL, loginToken, err := a.service.StartOIDC(c.Request.Context(), c.Param("provider"), "")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, loginToken, int(service.OIDCLoginTTL.Seconds()), "/auth/oidc", "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, authURL)
+	return
+}
+
+// LinkOIDC starts linking the provider to the signed in account. It answers
+// the URL of the provider rather than redirecting to it, as it is called with
+// the session token; the callback then signs in to this account.
+func (a *AuthHandler) LinkOIDC(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	authURL, loginToken, err := a.service.StartOIDC(c.Request.Context(), c.Param("provider"), accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, loginToken, int(service.OIDCLoginTTL.Seconds()), "/auth/oidc", "", secureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{
+		"auth_url": authURL,
+	})
+	return
+}
+
+func (a *AuthHandler) FinishOIDC(c *gin.Context) {
+	loginToken, _ := c.Cookie(oidcCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, "", -1, "/auth/oidc", "", secureRequest(c), true)
+
+	// The provider redirects back with an error when the user refuses or the
+	// request is invalid.
+	if c.Query("error") != "" || loginToken == "" {
+		c.Error(&errors.InvalidOIDCLoginError{})
+		return
+	}
+
+	token, err := a.service.FinishOIDC(c.Request.Context(), c.Param("provider"), loginToken, c.Query("state"), c.Query("code"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+	return
+}
+
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
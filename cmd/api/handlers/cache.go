@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"social_network_project/internal/platform/cache"
+)
+
+type CacheHandlerClient interface {
+	GetStats(c *gin.Context)
+}
+
+type CacheHandler struct {
+	Cache cache.RedisServiceClient
+}
+
+func RegisterCacheHandler(_cache cache.RedisServiceClient) CacheHandlerClient {
+	return &CacheHandler{
+		Cache: _cache,
+	}
+}
+
+func (a *CacheHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, a.Cache.Stats())
+	return
+}
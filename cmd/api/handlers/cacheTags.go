@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"social_network_project/internal/account"
+	"social_network_project/internal/comment"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/post"
+)
+
+// listTags returns the entity tags of the accounts, posts and comments of a
+// list response, so the cached list is dropped when any of them changes.
+func listTags(list []interface{}, tags ...string) []string {
+	for _, item := range list {
+		switch i := item.(type) {
+		case account.AccountResponse:
+			tags = append(tags, cache.AccountTag(i.ID))
+		case post.PostResponse:
+			tags = append(tags, cache.PostTag(i.ID))
+		case *comment.CommentResponse:
+			tags = append(tags, cache.CommentTag(i.ID))
+		}
+	}
+	return tags
+}
+
+// commentsListTag returns the collection tag of the comment list selected by
+// the query, following CommentsService.FindCommentsByAccountID.
+func commentsListTag(accountID, idToGet, postID, commentID string) string {
+	if postID != "" {
+		return cache.CommentsOfPostTag(postID)
+	}
+	if commentID != "" {
+		return cache.CommentsOfCommentTag(commentID)
+	}
+	if idToGet != "" {
+		return cache.CommentsOfAccountTag(idToGet)
+	}
+	return cache.CommentsOfAccountTag(accountID)
+}
+
+// postsListTag returns the collection tag of the post list selected by the
+// query, following PostsService.FindPostsByAccountID.
+func postsListTag(accountID, idToGet string) string {
+	if idToGet != "" {
+		return cache.PostsTag(idToGet)
+	}
+	return cache.PostsTag(accountID)
+}
@@ -1,21 +1,20 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"social_network_project/cmd/api/middlewares"
 	"social_network_project/internal/comment"
 	"social_network_project/internal/comment/service"
 	"social_network_project/internal/platform/cache"
 	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
-	"social_network_project/internal/utils/validate"
 	"strconv"
 	"time"
 )
@@ -23,6 +22,7 @@ import (
 type CommentsHandlerClient interface {
 	CreateComment(c *gin.Context)
 	GetComment(c *gin.Context)
+	GetCommentByID(c *gin.Context)
 	UpdateComment(c *gin.Context)
 	DeleteComment(c *gin.Context)
 }
@@ -42,13 +42,7 @@ func RegisterCommentsHandlers(commentsController service.CommentsServiceClient,
 }
 
 func (a *CommentsHandler) CreateComment(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 	postID := c.Param("post")
 	commentID := c.DefaultQuery("comment_id", "")
 
@@ -57,186 +51,120 @@ func (a *CommentsHandler) CreateComment(c *gin.Context) {
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	comment := a.fillFields(request, accountID, postID, utils.NewNullString(commentID))
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(comment)
 	if err != nil {
-		mapper["errors"] = validate.RequestCommentValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	err = a.Controller.InsertComment(comment)
+	err = a.Controller.InsertComment(c.Request.Context(), comment)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundPostIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundCommentIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
-	c.JSON(http.StatusOK, comment.ToResponse())
-	return
-}
-
-func (a *CommentsHandler) GetComment(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
+	commentCreated, err := a.Controller.FindCommentByID(c.Request.Context(), &comment.ID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
+		c.Error(err)
 		return
 	}
 
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
+	setETag(c, commentCreated)
+	c.JSON(http.StatusOK, commentCreated)
+	return
+}
 
-		return
-	}
+func (a *CommentsHandler) GetComment(c *gin.Context) {
+
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	idToGet := c.DefaultQuery("account_id", "")
 	postID := c.DefaultQuery("post_id", "")
 	commentID := c.DefaultQuery("comment_id", "")
 	page := c.DefaultQuery("page", "1")
-	if _, err = strconv.ParseInt(page, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Page is not a number",
-		})
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
 		return
 	}
 
-	comments, err := a.Controller.FindCommentsByAccountID(&accountID, &idToGet, &postID, &commentID, &page)
+	comments, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		comments, err := a.Controller.FindCommentsByAccountID(ctx, &accountID, &idToGet, &postID, &commentID, &page)
+		return comments, listTags(comments, commentsListTag(accountID, idToGet, postID, commentID)), err
+	})
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundPostIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundCommentIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, comments)
-	c.JSON(http.StatusOK, comments)
+	writeConditional(c, comments)
 	return
 }
 
-func (a *CommentsHandler) UpdateComment(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
+func (a *CommentsHandler) GetCommentByID(c *gin.Context) {
+
+	accountID := c.GetString(middlewares.AccountIDKey)
+	id := c.Param("id")
+
+	comment, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		comment, err := a.Controller.FindCommentByID(ctx, &id)
+		return comment, []string{cache.CommentTag(id)}, err
+	})
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
+		c.Error(err)
 		return
 	}
 
+	writeConditional(c, comment)
+	return
+}
+
+func (a *CommentsHandler) UpdateComment(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
 	var request comment.CommentRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	comment := a.fillFields(request, accountID, "", utils.NewNullString(""))
 	comment.ID = utils.StringNullable(request.Id)
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(comment)
 	if err != nil {
-		mapper["errors"] = validate.RequestCommentValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	commentUpdated, err := a.Controller.UpdateCommentDataByID(comment)
+	commentUpdated, err := a.Controller.UpdateCommentDataByID(c.Request.Context(), comment, c.GetHeader("If-Match"))
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundCommentIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
+	setETag(c, commentUpdated)
 	c.JSON(http.StatusOK, commentUpdated)
 	return
 }
 
 func (a *CommentsHandler) DeleteComment(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request comment.CommentRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
@@ -244,32 +172,16 @@ func (a *CommentsHandler) DeleteComment(c *gin.Context) {
 	comment.ID = utils.StringNullable(request.Id)
 	comment.Content = "--"
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(comment)
 	if err != nil {
-		mapper["errors"] = validate.RequestCommentValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	commentToRemoved, err := a.Controller.RemoveCommentByID(comment)
+	commentToRemoved, err := a.Controller.RemoveCommentByID(c.Request.Context(), comment)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundCommentIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, commentToRemoved)
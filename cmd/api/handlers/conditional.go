@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/utils"
+	"time"
+)
+
+// writeConditional writes a cached response with its ETag and Last-Modified
+// headers, or 304 Not Modified when the client already holds it. If-None-Match
+// takes precedence over If-Modified-Since.
+func writeConditional(c *gin.Context, res *cache.Response) {
+	lastModified := res.LastModified.UTC().Truncate(time.Second)
+	c.Header("ETag", res.ETag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		if utils.MatchETag(ifNoneMatch, res.ETag, true) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	} else if ifModifiedSince := c.GetHeader("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err == nil && !lastModified.After(since) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", res.Body)
+}
+
+// setETag sets the ETag header of obj, so a client can send it back in
+// If-Match on its next update.
+func setETag(c *gin.Context, obj any) {
+	etag, err := utils.ETagOf(obj)
+	if err == nil {
+		c.Header("ETag", etag)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"social_network_project/internal/platform/health"
+)
+
+type HealthHandlerClient interface {
+	GetHealthz(c *gin.Context)
+	GetReadyz(c *gin.Context)
+	GetStatus(c *gin.Context)
+}
+
+type HealthHandler struct {
+	Health *health.Health
+}
+
+func RegisterHealthHandler(_health *health.Health) HealthHandlerClient {
+	return &HealthHandler{
+		Health: _health,
+	}
+}
+
+// GetHealthz tells the process is alive, without looking at its
+// dependencies, so that an outage of one does not get the API restarted.
+func (h *HealthHandler) GetHealthz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"status": health.STATUS_UP,
+	})
+	return
+}
+
+// GetReadyz tells whether the API can serve requests: every dependency
+// answers and it is not shutting down.
+func (h *HealthHandler) GetReadyz(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	ready, report := h.Health.Ready(c.Request.Context())
+	failing := []string{}
+	for _, check := range report.Checks {
+		if check.Status != health.STATUS_UP {
+			failing = append(failing, check.Name)
+		}
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"status":  report.Status,
+		"failing": failing,
+	})
+	return
+}
+
+// GetStatus shows operators the latency and pool stats of each dependency.
+func (h *HealthHandler) GetStatus(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, h.Health.Status(c.Request.Context()))
+	return
+}
@@ -6,14 +6,12 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"social_network_project/cmd/api/middlewares"
 	interaction2 "social_network_project/internal/interaction"
 	"social_network_project/internal/interaction/service"
 	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
-	"social_network_project/internal/utils/validate"
 	"time"
 )
 
@@ -36,72 +34,34 @@ func RegisterInteractionsHandlers(interactionsController service.InteractionsSer
 }
 
 func (i InteractionsHandler) CreateInteraction(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request interaction2.InteractionRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	if request.PostId == "" && request.CommentId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Add post_id or comment_id",
-		})
+		c.Error(&errors.BadRequestError{Message: "Add post_id or comment_id"})
 		return
 	}
 
 	interaction := i.fillFields(request, &accountID)
 
-	mapper := make(map[string]interface{})
 	err = i.Validate.Struct(interaction)
 	if err != nil {
-		mapper["errors"] = validate.RequestInteractionValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	err = i.Controller.InsertInteraction(interaction)
+	err = i.Controller.InsertInteraction(c.Request.Context(), interaction)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundPostIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundCommentIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.ConflictAlreadyWriteError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, interaction.ToResponse())
@@ -109,54 +69,30 @@ func (i InteractionsHandler) CreateInteraction(c *gin.Context) {
 }
 
 func (i *InteractionsHandler) UpdateInteraction(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request interaction2.InteractionRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
 	interaction := i.fillFields(request, &accountID)
 	interaction.ID = utils.StringNullable(request.Id)
 
-	mapper := make(map[string]interface{})
 	err = i.Validate.Struct(interaction)
 	if err != nil {
-		mapper["errors"] = validate.RequestInteractionValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	interactionUpdated, err := i.Controller.UpdateInteractonDataByID(interaction)
+	interactionUpdated, err := i.Controller.UpdateInteractonDataByID(c.Request.Context(), interaction)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundInteractionIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, interactionUpdated)
@@ -164,22 +100,14 @@ func (i *InteractionsHandler) UpdateInteraction(c *gin.Context) {
 }
 
 func (i *InteractionsHandler) DeleteInteraction(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request interaction2.InteractionRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 
@@ -187,32 +115,16 @@ func (i *InteractionsHandler) DeleteInteraction(c *gin.Context) {
 	interaction.ID = utils.StringNullable(request.Id)
 	interaction.Type = 0
 
-	mapper := make(map[string]interface{})
 	err = i.Validate.Struct(interaction)
 	if err != nil {
-		mapper["errors"] = validate.RequestInteractionValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	interactionRemoved, err := i.Controller.RemoveInteractionByID(interaction)
+	interactionRemoved, err := i.Controller.RemoveInteractionByID(c.Request.Context(), interaction)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundInteractionIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, interactionRemoved)
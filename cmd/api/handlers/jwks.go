@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"social_network_project/internal/platform/keyring"
+)
+
+type JWKSHandlerClient interface {
+	GetJWKS(c *gin.Context)
+}
+
+type JWKSHandler struct {
+	Keys *keyring.Keyring
+}
+
+func RegisterJWKSHandler(keys *keyring.Keyring) JWKSHandlerClient {
+	return &JWKSHandler{
+		Keys: keys,
+	}
+}
+
+// GetJWKS lets other services verify tokens. It may be cached for a few
+// minutes, as a new key is generated long before it starts signing tokens
+// elsewhere than in this instance.
+func (j *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, j.Keys.JWKS())
+	return
+}
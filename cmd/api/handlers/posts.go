@@ -1,20 +1,19 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"io/ioutil"
-	"log"
 	"net/http"
-	"os"
+	"social_network_project/cmd/api/middlewares"
 	"social_network_project/internal/platform/cache"
 	post2 "social_network_project/internal/post"
 	"social_network_project/internal/post/service"
 	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
-	"social_network_project/internal/utils/validate"
 	"strconv"
 	"time"
 )
@@ -22,6 +21,7 @@ import (
 type PostHandlerClient interface {
 	CreatePost(c *gin.Context)
 	GetPost(c *gin.Context)
+	GetPostByID(c *gin.Context)
 	UpdatePost(c *gin.Context)
 	DeletePost(c *gin.Context)
 	SearchPostByAccountFollowing(c *gin.Context)
@@ -43,204 +43,139 @@ func RegisterPostsHandlers(postsController service.PostsServiceClient, _redis ca
 
 func (a *PostsAPI) CreatePost(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request post2.PostRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 	post := a.fillFields(request, &accountID)
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(post)
 	if err != nil {
-		mapper["errors"] = validate.RequestPostValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	err = a.Controller.InsertPost(post)
+	err = a.Controller.InsertPost(c.Request.Context(), post)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, post.ToResponse())
-	return
-}
-
-func (a *PostsAPI) GetPost(c *gin.Context) {
-
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
+	postCreated, err := a.Controller.FindPostByID(c.Request.Context(), &post.ID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
+		c.Error(err)
 		return
 	}
 
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
+	setETag(c, postCreated)
+	c.JSON(http.StatusOK, postCreated)
+	return
+}
 
-		return
-	}
+func (a *PostsAPI) GetPost(c *gin.Context) {
+
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	idToGet := c.DefaultQuery("account_id", accountID)
 	page := c.DefaultQuery("page", "1")
-	if _, err = strconv.ParseInt(page, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Page is not a number",
-		})
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
 		return
 	}
 
-	postsOfAccount, err := a.Controller.FindPostsByAccountID(&accountID, &idToGet, &page)
+	postsOfAccount, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		postsOfAccount, err := a.Controller.FindPostsByAccountID(ctx, &accountID, &idToGet, &page)
+		return postsOfAccount, listTags(postsOfAccount, postsListTag(accountID, idToGet)), err
+	})
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, postsOfAccount)
-	c.JSON(http.StatusOK, postsOfAccount)
+	writeConditional(c, postsOfAccount)
 	return
 }
 
-func (a *PostsAPI) UpdatePost(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
+func (a *PostsAPI) GetPostByID(c *gin.Context) {
+
+	accountID := c.GetString(middlewares.AccountIDKey)
+	id := c.Param("id")
+
+	post, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		post, err := a.Controller.FindPostByID(ctx, &id)
+		return post, []string{cache.PostTag(id)}, err
+	})
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
+		c.Error(err)
 		return
 	}
 
+	writeConditional(c, post)
+	return
+}
+
+func (a *PostsAPI) UpdatePost(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
 	var request post2.PostRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 	post := a.fillFields(request, &accountID)
 	post.ID = utils.StringNullable(request.Id)
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(post)
 	if err != nil {
-		mapper["errors"] = validate.RequestPostValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	postUpdated, err := a.Controller.UpdatePostDataByID(post)
+	postUpdated, err := a.Controller.UpdatePostDataByID(c.Request.Context(), post, c.GetHeader("If-Match"))
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundPostIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
+	setETag(c, postUpdated)
 	c.JSON(http.StatusOK, postUpdated)
 	return
 }
 
 func (a *PostsAPI) DeletePost(c *gin.Context) {
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	var request post2.PostRequest
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	err = json.Unmarshal(body, &request)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
-			"message": "Unprocessable Entity",
-		})
+		c.Error(&errors.UnprocessableEntityError{})
 		return
 	}
 	post := a.fillFields(request, &accountID)
 	post.ID = utils.StringNullable(request.Id)
 	post.Content = "--"
 
-	mapper := make(map[string]interface{})
 	err = a.Validate.Struct(post)
 	if err != nil {
-		mapper["errors"] = validate.RequestPostValidate(err)
-		c.JSON(http.StatusBadRequest, mapper)
+		invalidFields(c, err)
 		return
 	}
 
-	postToRemoved, err := a.Controller.RemovePostByID(post)
+	postToRemoved, err := a.Controller.RemovePostByID(c.Request.Context(), post)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.UnauthorizedAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"message": err.Error(),
-			})
-			return
-		case *errors.NotFoundPostIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
 	c.JSON(http.StatusOK, postToRemoved)
@@ -249,48 +184,23 @@ func (a *PostsAPI) DeletePost(c *gin.Context) {
 
 func (a *PostsAPI) SearchPostByAccountFollowing(c *gin.Context) {
 
-	accountID, err := utils.DecodeTokenAndReturnID(c.Request.Header.Get(os.Getenv("JWT_TOKEN_HEADER")))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"message": "Token Invalid",
-		})
-		return
-	}
-
-	resCache, err := a.RedisClient.FindInCache(c.Request)
-	switch e := err.(type) {
-	case *errors.CacheNotFoundError:
-		log.Println(e.Error())
-	default:
-		c.JSON(http.StatusOK, resCache)
-		log.Println("Cache")
-
-		return
-	}
+	accountID := c.GetString(middlewares.AccountIDKey)
 
 	page := c.DefaultQuery("page", "1")
-	if _, err = strconv.ParseInt(page, 10, 64); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"message": "Page is not a number",
-		})
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
 		return
 	}
-	postsOfAccount, err := a.Controller.FindPostByAccountFollowingByAccountID(&accountID, &page)
+	postsOfAccount, err := a.RedisClient.GetOrLoad(c.Request, accountID, func(ctx context.Context) (any, []string, error) {
+		postsOfAccount, err := a.Controller.FindPostByAccountFollowingByAccountID(ctx, &accountID, &page)
+		return postsOfAccount, listTags(postsOfAccount, cache.FeedTag(accountID)), err
+	})
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.NotFoundAccountIDError:
-			log.Println(e)
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": err.Error(),
-			})
-			return
-		default:
-			log.Fatal(err)
-		}
+		c.Error(err)
+		return
 	}
 
-	a.RedisClient.InsertCache(c.Request, postsOfAccount)
-	c.JSON(http.StatusOK, postsOfAccount)
+	writeConditional(c, postsOfAccount)
 	return
 }
 
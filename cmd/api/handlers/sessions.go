@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"social_network_project/cmd/api/middlewares"
+	"social_network_project/internal/session/service"
+)
+
+type SessionsHandlerClient interface {
+	GetSessions(c *gin.Context)
+	DeleteSession(c *gin.Context)
+	DeleteOtherSessions(c *gin.Context)
+}
+
+type SessionsHandler struct {
+	Controller service.SessionServiceClient
+}
+
+func RegisterSessionsHandlers(sessionsController service.SessionServiceClient) SessionsHandlerClient {
+	return &SessionsHandler{
+		Controller: sessionsController,
+	}
+}
+
+func (s *SessionsHandler) GetSessions(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	sessions, err := s.Controller.FindSessions(c.Request.Context(), accountID, c.GetString(middlewares.SessionIDKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+	return
+}
+
+func (s *SessionsHandler) DeleteSession(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	err := s.Controller.DeleteSession(c.Request.Context(), accountID, c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Signed out of the session",
+	})
+	return
+}
+
+// DeleteOtherSessions signs out everywhere but the session of the request.
+func (s *SessionsHandler) DeleteOtherSessions(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	err := s.Controller.DeleteOtherSessions(c.Request.Context(), accountID, c.GetString(middlewares.SessionIDKey))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Signed out of the other sessions",
+	})
+	return
+}
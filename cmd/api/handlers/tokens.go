@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"social_network_project/cmd/api/middlewares"
+	token2 "social_network_project/internal/token"
+	"social_network_project/internal/token/service"
+	"social_network_project/internal/utils/errors"
+)
+
+type TokensHandlerClient interface {
+	CreateToken(c *gin.Context)
+	GetTokens(c *gin.Context)
+	DeleteToken(c *gin.Context)
+}
+
+type TokensHandler struct {
+	Controller service.TokenServiceClient
+	Validate   *validator.Validate
+}
+
+func RegisterTokensHandlers(tokensController service.TokenServiceClient) TokensHandlerClient {
+	return &TokensHandler{
+		Controller: tokensController,
+		Validate:   validator.New(),
+	}
+}
+
+func (t *TokensHandler) CreateToken(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request token2.TokenRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	if request.ExpiresInDays < 0 {
+		c.Error(&errors.BadRequestError{Message: "Expires in days must not be negative"})
+		return
+	}
+
+	token := &token2.Token{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+	}
+
+	err = t.Validate.Struct(token)
+	if err != nil {
+		invalidFields(c, err)
+		return
+	}
+
+	tokenCreated, err := t.Controller.CreateToken(c.Request.Context(), token, request.ExpiresInDays)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenCreated)
+	return
+}
+
+func (t *TokensHandler) GetTokens(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	tokens, err := t.Controller.FindTokensByAccountID(c.Request.Context(), &accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+	return
+}
+
+func (t *TokensHandler) DeleteToken(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request token2.TokenRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	if request.Id == "" {
+		c.Error(&errors.BadRequestError{Message: "Add ID"})
+		return
+	}
+
+	tokenRevoked, err := t.Controller.RevokeTokenByID(c.Request.Context(), &request.Id, &accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokenRevoked)
+	return
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"social_network_project/internal/utils/errors"
+	"social_network_project/internal/utils/validate"
+)
+
+// invalidFields reports the failures of validator.Struct in err, with
+// messages in the language the request accepts.
+func invalidFields(c *gin.Context, err error) {
+	language := validate.Language(c.GetHeader("Accept-Language"))
+	c.Error(&errors.ValidationError{Fields: validate.FieldErrors(err, language)})
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"social_network_project/cmd/api/middlewares"
+	"social_network_project/internal/utils/errors"
+	webhook2 "social_network_project/internal/webhook"
+	"social_network_project/internal/webhook/service"
+	"strconv"
+	"time"
+)
+
+type WebhooksHandlerClient interface {
+	CreateWebhook(c *gin.Context)
+	GetWebhooks(c *gin.Context)
+	UpdateWebhook(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	GetDeliveries(c *gin.Context)
+	PingWebhook(c *gin.Context)
+}
+
+type WebhooksHandler struct {
+	Controller service.WebhookServiceClient
+	Validate   *validator.Validate
+}
+
+func RegisterWebhooksHandlers(webhooksController service.WebhookServiceClient) WebhooksHandlerClient {
+	return &WebhooksHandler{
+		Controller: webhooksController,
+		Validate:   validator.New(),
+	}
+}
+
+func (w *WebhooksHandler) CreateWebhook(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request webhook2.WebhookRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	webhook := w.fillFields(request, accountID)
+
+	err = w.Validate.Struct(webhook)
+	if err != nil {
+		invalidFields(c, err)
+		return
+	}
+
+	webhookCreated, err := w.Controller.CreateWebhook(c.Request.Context(), webhook)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookCreated)
+	return
+}
+
+func (w *WebhooksHandler) GetWebhooks(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	webhooks, err := w.Controller.FindWebhooksByAccountID(c.Request.Context(), &accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+	return
+}
+
+func (w *WebhooksHandler) UpdateWebhook(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request webhook2.WebhookRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	webhook := w.fillFields(request, accountID)
+	webhook.ID = request.Id
+
+	err = w.Validate.Struct(webhook)
+	if err != nil {
+		invalidFields(c, err)
+		return
+	}
+
+	webhookUpdated, err := w.Controller.UpdateWebhook(c.Request.Context(), webhook)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookUpdated)
+	return
+}
+
+func (w *WebhooksHandler) DeleteWebhook(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request webhook2.WebhookRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	if request.Id == "" {
+		c.Error(&errors.BadRequestError{Message: "Add ID"})
+		return
+	}
+
+	webhookRemoved, err := w.Controller.RemoveWebhookByID(c.Request.Context(), &request.Id, &accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookRemoved)
+	return
+}
+
+func (w *WebhooksHandler) GetDeliveries(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	webhookID := c.DefaultQuery("webhook_id", "")
+	if webhookID == "" {
+		c.Error(&errors.BadRequestError{Message: "Add webhook_id"})
+		return
+	}
+	page := c.DefaultQuery("page", "1")
+	if _, err := strconv.ParseInt(page, 10, 64); err != nil {
+		c.Error(&errors.BadRequestError{Message: "Page is not a number"})
+		return
+	}
+
+	deliveries, err := w.Controller.FindDeliveriesByWebhookID(c.Request.Context(), &webhookID, &accountID, &page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+	return
+}
+
+func (w *WebhooksHandler) PingWebhook(c *gin.Context) {
+	accountID := c.GetString(middlewares.AccountIDKey)
+
+	var request webhook2.WebhookRequest
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	err = json.Unmarshal(body, &request)
+	if err != nil {
+		c.Error(&errors.UnprocessableEntityError{})
+		return
+	}
+
+	if request.Id == "" {
+		c.Error(&errors.BadRequestError{Message: "Add ID"})
+		return
+	}
+
+	delivery, err := w.Controller.PingWebhook(c.Request.Context(), &request.Id, &accountID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+	return
+}
+
+func (w *WebhooksHandler) fillFields(req webhook2.WebhookRequest, accountID string) *webhook2.Webhook {
+
+	return &webhook2.Webhook{
+		ID:         uuid.New().String(),
+		AccountID:  accountID,
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Active:     true,
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}
+}
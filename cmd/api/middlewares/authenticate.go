@@ -0,0 +1,98 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"social_network_project/internal/platform/keyring"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/token"
+	"social_network_project/internal/token/service"
+	"social_network_project/internal/utils/errors"
+	"strings"
+)
+
+// AccountIDKey holds the id of the authenticated account in the context.
+const AccountIDKey = "account_id"
+
+// SessionIDKey holds the id of the session of the request in the context. It
+// is empty for personal access tokens.
+const SessionIDKey = "session_id"
+
+// AccessTokenKey holds the personal access token of the request in the
+// context once looked up, nil when it is unknown, so that the token is looked
+// up and its use recorded once per request.
+const AccessTokenKey = "access_token"
+
+// Authenticate accepts the JWT of a signed in session sent in tokenHeader,
+// verified with keys and still open in sessions, or a personal access token
+// granting scope, and sets the account it belongs to under AccountIDKey. Routes without a scope only
+// accept sessions.
+func Authenticate(keys *keyring.Keyring, tokenHeader string, sessions service2.SessionServiceClient, tokens service.TokenServiceClient, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Request.Header.Get(tokenHeader)
+		rawToken := strings.TrimPrefix(header, "Bearer ")
+
+		if !strings.HasPrefix(rawToken, token.Prefix) {
+			accountID, sessionID, err := keys.DecodeSessionToken(header)
+			if err != nil || sessionID == "" {
+				abortInvalidToken(c)
+				return
+			}
+
+			valid, err := sessions.ValidateSession(c.Request.Context(), accountID, sessionID)
+			if err != nil {
+				abortUnavailable(c, err)
+				return
+			}
+			if !valid {
+				abortInvalidToken(c)
+				return
+			}
+
+			c.Set(AccountIDKey, accountID)
+			c.Set(SessionIDKey, sessionID)
+			c.Next()
+			return
+		}
+
+		accessToken, err := findAccessToken(c, tokens, rawToken)
+		if err != nil {
+			abortUnavailable(c, err)
+			return
+		}
+		if accessToken == nil {
+			abortInvalidToken(c)
+			return
+		}
+		if scope == "" || !accessToken.HasScope(scope) {
+			WriteError(c, &errors.ForbiddenScopeError{Path: scope})
+			return
+		}
+
+		c.Set(AccountIDKey, accessToken.AccountID)
+		c.Next()
+	}
+}
+
+// findAccessToken returns the personal access token of rawToken, asking tokens
+// only the first time in a request.
+func findAccessToken(c *gin.Context, tokens service.TokenServiceClient, rawToken string) (*token.Token, error) {
+	if found, exists := c.Get(AccessTokenKey); exists {
+		return found.(*token.Token), nil
+	}
+
+	found, err := tokens.Authenticate(c.Request.Context(), rawToken)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(AccessTokenKey, found)
+	return found, nil
+}
+
+func abortUnavailable(c *gin.Context, err error) {
+	requestLogger(c).ErrorContext(c.Request.Context(), "authenticating", "error", err)
+	WriteError(c, &errors.ServiceUnavailableError{})
+}
+
+func abortInvalidToken(c *gin.Context) {
+	WriteError(c, &errors.InvalidTokenError{})
+}
@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/session"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/token"
+	"social_network_project/internal/token/service"
+	"testing"
+	"time"
+)
+
+type tokenServiceFake struct {
+	service.TokenServiceClient
+	tokens map[string]*token.Token
+	err    error
+	calls  int
+}
+
+func (f *tokenServiceFake) Authenticate(ctx context.Context, rawToken string) (*token.Token, error) {
+	f.calls++
+	return f.tokens[rawToken], f.err
+}
+
+func newKeys(t *testing.T) *keyring.Keyring {
+	keys, err := keyring.New(keyring.Config{
+		Dir:       t.TempDir(),
+		Algorithm: keyring.ALGORITHM_EDDSA,
+		Issuer:    "social_network",
+		Audience:  "social_network_api",
+		TokenTTL:  time.Hour,
+	})
+	assert.Nil(t, err)
+	return keys
+}
+
+func newSessions() service2.SessionServiceClient {
+	return service2.NewSessionService(session.NewSessionRepositoryMemory(time.Now), time.Hour)
+}
+
+// newSession signs the account in, returning its token.
+func newSession(t *testing.T, keys *keyring.Keyring, sessions service2.SessionServiceClient, accountID string) (string, string) {
+	created, err := sessions.CreateSession(context.Background(), accountID, "10.0.0.1", "curl/7.84.0")
+	assert.Nil(t, err)
+	token, err := keys.IssueToken(accountID, time.Hour, jwt.MapClaims{"sid": created.ID})
+	assert.Nil(t, err)
+	return token, created.ID
+}
+
+func authenticated(keys *keyring.Keyring, sessions service2.SessionServiceClient, tokens service.TokenServiceClient, scope string, header string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/posts", Authenticate(keys, "Authorization", sessions, tokens, scope), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(AccountIDKey))
+	})
+
+	req := httptest.NewRequest("GET", "/posts", nil)
+	if header != "" {
+		req.Header.Set("Authorization", "Bearer "+header)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := newKeys(t)
+	sessions := newSessions()
+
+	tokens := &tokenServiceFake{tokens: map[string]*token.Token{
+		"snp_readposts": {AccountID: "a2", Scopes: []string{token.SCOPE_READ_POSTS}},
+	}}
+
+	t.Run("accepts a session", func(t *testing.T) {
+		token, _ := newSession(t, keys, sessions, "a1")
+
+		res := authenticated(keys, sessions, tokens, "", token)
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "a1", res.Body.String())
+	})
+
+	t.Run("rejects tokens of deleted sessions or naming none", func(t *testing.T) {
+		token, sessionID := newSession(t, keys, sessions, "a1")
+		assert.Nil(t, sessions.DeleteSession(context.Background(), "a1", sessionID))
+
+		res := authenticated(keys, sessions, tokens, "", token)
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+		token, err := keys.IssueToken("a1", time.Hour, nil)
+		assert.Nil(t, err)
+		res = authenticated(keys, sessions, tokens, "", token)
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+
+	t.Run("accepts a personal access token granting the scope", func(t *testing.T) {
+		res := authenticated(keys, sessions, tokens, token.SCOPE_READ_POSTS, "snp_readposts")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "a2", res.Body.String())
+	})
+
+	t.Run("rejects a personal access token without the scope", func(t *testing.T) {
+		res := authenticated(keys, sessions, tokens, token.SCOPE_WRITE_POSTS, "snp_readposts")
+		assert.Equal(t, http.StatusForbidden, res.Code)
+	})
+
+	t.Run("rejects personal access tokens on session only routes", func(t *testing.T) {
+		res := authenticated(keys, sessions, tokens, "", "snp_readposts")
+		assert.Equal(t, http.StatusForbidden, res.Code)
+	})
+
+	t.Run("rejects unknown tokens", func(t *testing.T) {
+		res := authenticated(keys, sessions, tokens, token.SCOPE_READ_POSTS, "snp_unknown")
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+		res = authenticated(keys, sessions, tokens, token.SCOPE_READ_POSTS, "")
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+}
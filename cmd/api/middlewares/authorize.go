@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	errors2 "errors"
+	"github.com/gin-gonic/gin"
+	"social_network_project/internal/account/service"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils/errors"
+)
+
+// Authorize lets through the accounts whose role grants permission. It runs
+// after Authenticate and reads the role on every request, so a role taken
+// away applies at once.
+func Authorize(accounts service.AccountsServiceClient, permission rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountID := c.GetString(AccountIDKey)
+
+		account, err := accounts.FindAccountByID(c.Request.Context(), &accountID)
+		if err != nil {
+			var notFound *errors.NotFoundAccountIDError
+			if errors2.As(err, &notFound) {
+				abortInvalidToken(c)
+				return
+			}
+			WriteError(c, err)
+			return
+		}
+		if !rbac.Can(account.Role, permission) {
+			WriteError(c, &errors.ForbiddenPermissionError{Path: string(permission)})
+			return
+		}
+
+		c.Next()
+	}
+}
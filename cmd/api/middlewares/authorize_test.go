@@ -0,0 +1,74 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"social_network_project/internal/account"
+	"social_network_project/internal/account/service"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils/errors"
+	"testing"
+)
+
+type accountServiceFake struct {
+	service.AccountsServiceClient
+	accounts map[string]*account.Account
+	err      error
+}
+
+func (f *accountServiceFake) FindAccountByID(ctx context.Context, id *string) (*account.Account, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	found, ok := f.accounts[*id]
+	if !ok {
+		return nil, &errors.NotFoundAccountIDError{}
+	}
+	return found, nil
+}
+
+func authorized(accounts service.AccountsServiceClient, accountID string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/admin/accounts/role", func(c *gin.Context) {
+		c.Set(AccountIDKey, accountID)
+	}, Authorize(accounts, rbac.PERMISSION_ASSIGN_ROLES), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest("PUT", "/admin/accounts/role", nil))
+	return res
+}
+
+func TestAuthorize(t *testing.T) {
+
+	accounts := &accountServiceFake{accounts: map[string]*account.Account{
+		"a1": {ID: "a1", Role: rbac.ROLE_ADMIN},
+		"a2": {ID: "a2", Role: rbac.ROLE_MODERATOR},
+	}}
+
+	t.Run("lets through roles granting the permission", func(t *testing.T) {
+		res := authorized(accounts, "a1")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("rejects roles without the permission", func(t *testing.T) {
+		res := authorized(accounts, "a2")
+		assert.Equal(t, http.StatusForbidden, res.Code)
+	})
+
+	t.Run("rejects accounts that no longer exist", func(t *testing.T) {
+		res := authorized(accounts, "a3")
+		assert.Equal(t, http.StatusUnauthorized, res.Code)
+	})
+
+	t.Run("answers a failing lookup as an internal error", func(t *testing.T) {
+		res := authorized(&accountServiceFake{err: fmt.Errorf("connection refused")}, "a1")
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+	})
+}
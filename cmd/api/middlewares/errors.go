@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	errors2 "errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"runtime/debug"
+	"social_network_project/internal/utils/errors"
+)
+
+// ErrorResponse is the body of every error answered by the API.
+type ErrorResponse struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id"`
+}
+
+// Errors answers the last error a handler added to the context with
+// c.Error. Domain errors are answered with their status and code, any other
+// error is logged and answered as an internal error, so its message does not
+// leak.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		WriteError(c, c.Errors.Last().Err)
+	}
+}
+
+// Recovery answers a panic of a handler as an internal error instead of
+// dropping the connection.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		requestLogger(c).ErrorContext(c.Request.Context(), "panic", "recovered", recovered, "stack", string(debug.Stack()))
+		writeInternalError(c)
+	})
+}
+
+// NoRoute answers the requests to routes that do not exist.
+func NoRoute(c *gin.Context) {
+	WriteError(c, &errors.NotFoundRouteError{})
+}
+
+// WriteError aborts the request answering err in the error envelope. A
+// domain error wrapped in err is answered as if it were returned as is.
+func WriteError(c *gin.Context, err error) {
+	var domainError errors.Error
+	if !errors2.As(err, &domainError) {
+		requestLogger(c).ErrorContext(c.Request.Context(), "internal error", "error", err)
+		writeInternalError(c)
+		return
+	}
+
+	response := ErrorResponse{
+		Code:      domainError.Code(),
+		Message:   domainError.Error(),
+		RequestID: c.GetString(RequestIDKey),
+	}
+	var detailed errors.Detailed
+	if errors2.As(err, &detailed) {
+		response.Details = detailed.Details()
+	}
+	var retryable errors.Retryable
+	if errors2.As(err, &retryable) && retryable.RetryIn() > 0 {
+		c.Header("Retry-After", seconds(retryable.RetryIn()))
+	}
+
+	c.AbortWithStatusJSON(domainError.StatusCode(), response)
+}
+
+func writeInternalError(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+		Code:      "internal_error",
+		Message:   "Internal Server Error",
+		RequestID: c.GetString(RequestIDKey),
+	})
+}
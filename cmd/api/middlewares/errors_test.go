@@ -0,0 +1,109 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"social_network_project/internal/utils/errors"
+	"testing"
+	"time"
+)
+
+func answered(handler gin.HandlerFunc, requestID string) (*httptest.ResponseRecorder, ErrorResponse) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), Recovery(), Errors())
+	router.NoRoute(NoRoute)
+	router.GET("/posts", handler)
+
+	req := httptest.NewRequest("GET", "/posts", nil)
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	var body ErrorResponse
+	json.Unmarshal(res.Body.Bytes(), &body)
+	return res, body
+}
+
+func TestErrors(t *testing.T) {
+
+	t.Run("answers domain errors with their status and code", func(t *testing.T) {
+		res, body := answered(func(c *gin.Context) {
+			c.Error(&errors.NotFoundPostIDError{})
+		}, "req-1")
+
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		assert.Equal(t, ErrorResponse{
+			Code:      "post_not_found",
+			Message:   "Post ID does not exist",
+			RequestID: "req-1",
+		}, body)
+		assert.Equal(t, "req-1", res.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("adds details and Retry-After", func(t *testing.T) {
+		res, body := answered(func(c *gin.Context) {
+			c.Error(&errors.ValidationError{Fields: []string{"Add content"}})
+		}, "")
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		assert.Equal(t, "validation_failed", body.Code)
+		assert.Equal(t, []interface{}{"Add content"}, body.Details)
+
+		res, body = answered(func(c *gin.Context) {
+			c.Error(&errors.AccountLockedError{RetryAfter: 1500 * time.Millisecond})
+		}, "")
+		assert.Equal(t, http.StatusLocked, res.Code)
+		assert.Equal(t, "2", res.Header().Get("Retry-After"))
+	})
+
+	t.Run("answers wrapped domain errors as they are", func(t *testing.T) {
+		res, body := answered(func(c *gin.Context) {
+			c.Error(fmt.Errorf("finding post: %w", &errors.NotFoundPostIDError{}))
+		}, "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		assert.Equal(t, "post_not_found", body.Code)
+		assert.Equal(t, "Post ID does not exist", body.Message)
+
+		res, _ = answered(func(c *gin.Context) {
+			c.Error(fmt.Errorf("signing in: %w", &errors.AccountLockedError{RetryAfter: 1500 * time.Millisecond}))
+		}, "")
+		assert.Equal(t, "2", res.Header().Get("Retry-After"))
+	})
+
+	t.Run("hides other errors behind an internal error", func(t *testing.T) {
+		res, body := answered(func(c *gin.Context) {
+			c.Error(fmt.Errorf("pq: connection refused"))
+		}, "")
+
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Equal(t, "internal_error", body.Code)
+		assert.NotContains(t, res.Body.String(), "connection refused")
+		assert.NotEmpty(t, body.RequestID)
+		assert.Equal(t, body.RequestID, res.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("recovers panics into internal errors", func(t *testing.T) {
+		res, body := answered(func(c *gin.Context) {
+			panic("nil map")
+		}, "req-2")
+
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Equal(t, "internal_error", body.Code)
+		assert.Equal(t, "req-2", body.RequestID)
+	})
+
+	t.Run("replaces request ids it does not accept", func(t *testing.T) {
+		_, body := answered(func(c *gin.Context) {
+			c.Error(&errors.NotFoundPostIDError{})
+		}, "bad id\nwith newline")
+
+		assert.NotEqual(t, "bad id\nwith newline", body.RequestID)
+		assert.Len(t, body.RequestID, 36)
+	})
+}
@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"time"
+)
+
+// LoggerKey holds the logger of the request in the context.
+const LoggerKey = "logger"
+
+// Logger logs every request once answered, with its method, route, status
+// and latency, and sets logger under LoggerKey for the middlewares after it.
+// Lines are logged with the context of the request, so they carry its id.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Set(LoggerKey, logger)
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency", time.Since(start),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// requestLogger returns the logger set by Logger, or the default one for
+// routers without it.
+func requestLogger(c *gin.Context) *slog.Logger {
+	if logger, ok := c.Value(LoggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
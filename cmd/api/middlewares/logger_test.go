@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"social_network_project/internal/platform/logging"
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	logger, err := logging.New(&out, logging.Config{Level: "info", Format: logging.FORMAT_JSON})
+	assert.Nil(t, err)
+
+	router := gin.New()
+	router.Use(RequestID(), Logger(logger), Recovery(), Errors())
+	router.GET("/posts/:id", func(c *gin.Context) {
+		c.Error(errors.New("connection refused"))
+	})
+
+	req := httptest.NewRequest("GET", "/posts/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &record))
+		lines = append(lines, record)
+	}
+
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "internal error", lines[0]["msg"])
+	assert.Equal(t, "connection refused", lines[0]["error"])
+	assert.Equal(t, "request", lines[1]["msg"])
+	assert.Equal(t, "ERROR", lines[1]["level"])
+	assert.Equal(t, "/posts/:id", lines[1]["route"])
+	assert.Equal(t, float64(500), lines[1]["status"])
+	for _, line := range lines {
+		assert.Equal(t, "req-1", line["request_id"])
+	}
+}
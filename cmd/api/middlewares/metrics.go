@@ -0,0 +1,27 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"social_network_project/internal/platform/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics counts and times requests by method, route and status. Routes are
+// their patterns, such as /comments/:post, so that ids do not each get a
+// series; requests matching no route share one.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := []string{c.Request.Method, route, strconv.Itoa(c.Writer.Status())}
+
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	}
+}
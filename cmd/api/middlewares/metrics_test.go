@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"social_network_project/internal/platform/metrics"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Metrics())
+	router.GET("/comments/:post", func(c *gin.Context) {
+		c.Status(204)
+	})
+
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/comments/:post", "204"))
+	unmatched := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404"))
+
+	for _, path := range []string{"/comments/1", "/comments/2", "/posts/3"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "/comments/:post", "204")))
+	assert.Equal(t, unmatched+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("GET", "unmatched", "404")))
+}
@@ -0,0 +1,69 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"math"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/ratelimit"
+	"social_network_project/internal/token"
+	"social_network_project/internal/token/service"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit limits the requests of each account under policy, or of each
+// client IP when no valid token is sent in tokenHeader. Personal access tokens
+// found in tokens are limited one by one; unknown ones are limited by IP, so
+// that sending a new made up token does not get a new bucket. The token looked
+// up is kept under AccessTokenKey for Authenticate. Requests go
+// through when the limiter fails, so that an unavailable store does not take
+// the API down with it. A policy without a limit disables limiting.
+func RateLimit(limiter ratelimit.Limiter, keys *keyring.Keyring, tokens service.TokenServiceClient, tokenHeader string, policy ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(c.Request.Context(), policy.Name+"-"+clientKey(c, keys, tokens, tokenHeader), policy)
+		if err != nil {
+			requestLogger(c).WarnContext(c.Request.Context(), "rate limiting", "policy", policy.Name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Policy", strconv.Itoa(policy.Limit)+";w="+seconds(policy.Window))
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", seconds(result.Reset))
+
+		if !result.Allowed {
+			WriteError(c, &errors.TooManyRequestsError{RetryAfter: result.RetryAfter})
+			return
+		}
+		c.Next()
+	}
+}
+
+func clientKey(c *gin.Context, keys *keyring.Keyring, tokens service.TokenServiceClient, tokenHeader string) string {
+	header := c.Request.Header.Get(tokenHeader)
+	if rawToken := strings.TrimPrefix(header, "Bearer "); strings.HasPrefix(rawToken, token.Prefix) {
+		accessToken, err := findAccessToken(c, tokens, rawToken)
+		if err == nil && accessToken != nil {
+			return "token-" + crypto.HashToken(rawToken)
+		}
+		return "ip-" + c.ClientIP()
+	}
+	id, err := keys.DecodeTokenAndReturnID(header)
+	if err == nil {
+		return "account-" + id
+	}
+	return "ip-" + c.ClientIP()
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,121 @@
+package middlewares
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/ratelimit"
+	"social_network_project/internal/platform/ratelimit/memoryLimiter"
+	"social_network_project/internal/token"
+	"testing"
+	"time"
+)
+
+type failingLimiter struct{}
+
+func (f failingLimiter) Allow(ctx context.Context, key string, policy ratelimit.Policy) (*ratelimit.Result, error) {
+	return nil, assert.AnError
+}
+
+func newRouter(limiter ratelimit.Limiter, keys *keyring.Keyring, policy ratelimit.Policy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	tokens := &tokenServiceFake{tokens: map[string]*token.Token{
+		"snp_readposts": {AccountID: "a2", Scopes: []string{token.SCOPE_READ_POSTS}},
+	}}
+	router.POST("/auth", RateLimit(limiter, keys, tokens, "Authorization", policy), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+func request(router *gin.Engine, ip string, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/auth", nil)
+	req.RemoteAddr = ip + ":1234"
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestRateLimit(t *testing.T) {
+	keys := newKeys(t)
+	policy := ratelimit.Policy{Name: "auth", Limit: 2, Window: time.Minute}
+
+	t.Run("limits by client ip", func(t *testing.T) {
+		router := newRouter(memoryLimiter.NewMemory(time.Now), keys, policy)
+
+		res := request(router, "10.0.0.1", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "2", res.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", res.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "2;w=60", res.Header().Get("RateLimit-Policy"))
+
+		request(router, "10.0.0.1", "")
+		res = request(router, "10.0.0.1", "")
+		assert.Equal(t, http.StatusTooManyRequests, res.Code)
+		assert.NotEmpty(t, res.Header().Get("Retry-After"))
+
+		res = request(router, "10.0.0.2", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("limits by account when authenticated", func(t *testing.T) {
+		router := newRouter(memoryLimiter.NewMemory(time.Now), keys, policy)
+		token, err := keys.IssueToken("a1", time.Hour, nil)
+		assert.Nil(t, err)
+
+		request(router, "10.0.0.1", token)
+		request(router, "10.0.0.2", token)
+		res := request(router, "10.0.0.3", token)
+		assert.Equal(t, http.StatusTooManyRequests, res.Code)
+
+		res = request(router, "10.0.0.3", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("limits by token only when it is valid", func(t *testing.T) {
+		router := newRouter(memoryLimiter.NewMemory(time.Now), keys, policy)
+
+		request(router, "10.0.0.1", "snp_made-up-1")
+		request(router, "10.0.0.1", "snp_made-up-2")
+		res := request(router, "10.0.0.1", "snp_made-up-3")
+		assert.Equal(t, http.StatusTooManyRequests, res.Code)
+
+		res = request(router, "10.0.0.1", "snp_readposts")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+
+	t.Run("looks a personal access token up once per request", func(t *testing.T) {
+		tokens := &tokenServiceFake{tokens: map[string]*token.Token{
+			"snp_readposts": {AccountID: "a2", Scopes: []string{token.SCOPE_READ_POSTS}},
+		}}
+		router := gin.New()
+		router.GET("/posts", RateLimit(memoryLimiter.NewMemory(time.Now), keys, tokens, "Authorization", policy),
+			Authenticate(keys, "Authorization", newSessions(), tokens, token.SCOPE_READ_POSTS), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString(AccountIDKey))
+			})
+
+		req := httptest.NewRequest("GET", "/posts", nil)
+		req.Header.Set("Authorization", "Bearer snp_readposts")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, "a2", res.Body.String())
+		assert.Equal(t, 1, tokens.calls)
+	})
+
+	t.Run("lets requests through when the limiter fails", func(t *testing.T) {
+		router := newRouter(failingLimiter{}, keys, policy)
+
+		res := request(router, "10.0.0.1", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Empty(t, res.Header().Get("RateLimit-Limit"))
+	})
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"regexp"
+	"social_network_project/internal/platform/logging"
+)
+
+// RequestIDKey holds the id of the request in the context.
+const RequestIDKey = "request_id"
+
+// validRequestID limits the ids accepted from clients and proxies, as they
+// end up in logs and responses.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID names every request with the X-Request-ID header it was sent
+// with, or a new id, and answers it in the same header. The id is also set in
+// the context of the request, for the lines logged and messages published
+// while handling it.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logging.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(logging.RequestIDHeader, id)
+		c.Next()
+	}
+}
@@ -2,43 +2,148 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log/slog"
 	"social_network_project/cmd/api/handlers"
+	"social_network_project/cmd/api/middlewares"
+	service2 "social_network_project/internal/account/service"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/ratelimit"
+	"social_network_project/internal/platform/tracing"
+	"social_network_project/internal/rbac"
+	service3 "social_network_project/internal/session/service"
+	"social_network_project/internal/token"
+	"social_network_project/internal/token/service"
 )
 
+// RateLimits are the policies of each group of routes: Auth for sign in,
+// Write for the routes creating or changing content and Read for the rest.
+// Health probes are not limited.
+type RateLimits struct {
+	Limiter ratelimit.Limiter
+	Auth    ratelimit.Policy
+	Write   ratelimit.Policy
+	Read    ratelimit.Policy
+}
+
+// newEngine returns an engine taking the client IP from X-Forwarded-For only
+// when the request comes from one of trustedProxies.
+func newEngine(trustedProxies []string) (*gin.Engine, error) {
+	app := gin.New()
+	err := app.SetTrustedProxies(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
 func Init(
 	auth handlers.AuthHandlerClient,
 	accounts handlers.AccountsHandlerClient,
 	posts handlers.PostHandlerClient,
 	comments handlers.CommentsHandlerClient,
 	intercations handlers.IntercationsHandlerClient,
-	) *gin.Engine {
-	app := gin.Default()
-
-	app.POST("/auth", auth.CreateToken)
-
-	app.POST("/accounts", accounts.CreateAccount)
-	app.GET("/accounts", accounts.GetAccount)
-	app.PUT("/accounts", accounts.UpdateAccount)
-	app.DELETE("/accounts", accounts.DeleteAccount)
-	app.POST("/accounts/follows", accounts.FollowAccount)
-	app.GET("/accounts/following", accounts.SearchFollowing)
-	app.GET("/accounts/follower", accounts.SearchFollowers)
-	app.DELETE("/accounts/unfollow", accounts.UnfollowAccount)
-
-	app.POST("/comments/:post", comments.CreateComment)
-	app.GET("/accounts/comments", comments.GetComment)
-	app.PUT("/comments", comments.UpdateComment)
-	app.DELETE("/comments", comments.DeleteComment)
-
-	app.POST("/interaction", intercations.CreateInteraction)
-	app.PUT("/interaction", intercations.UpdateInteraction)
-	app.DELETE("/interaction", intercations.DeleteInteraction)
-
-	app.POST("/posts", posts.CreatePost)
-	app.GET("/accounts/posts", posts.GetPost)
-	app.PUT("/posts", posts.UpdatePost)
-	app.DELETE("/posts", posts.DeletePost)
-	app.GET("/accounts/follows/posts", posts.SearchPostByAccountFollowing)
-
-	return app
+	webhooks handlers.WebhooksHandlerClient,
+	tokens handlers.TokensHandlerClient,
+	sessions handlers.SessionsHandlerClient,
+	admin handlers.AdminHandlerClient,
+	cache handlers.CacheHandlerClient,
+	jwks handlers.JWKSHandlerClient,
+	health handlers.HealthHandlerClient,
+	limits RateLimits,
+	trustedProxies []string,
+	keys *keyring.Keyring,
+	tokenHeader string,
+	sessionService service3.SessionServiceClient,
+	tokenService service.TokenServiceClient,
+	accountService service2.AccountsServiceClient,
+	logger *slog.Logger,
+) (*gin.Engine, error) {
+	app, err := newEngine(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	app.Use(middlewares.RequestID(), otelgin.Middleware(tracing.ServiceName), middlewares.Metrics(), middlewares.Logger(logger), middlewares.Recovery(), middlewares.Errors())
+	app.NoRoute(middlewares.NoRoute)
+
+	authLimit := middlewares.RateLimit(limits.Limiter, keys, tokenService, tokenHeader, limits.Auth)
+	writeLimit := middlewares.RateLimit(limits.Limiter, keys, tokenService, tokenHeader, limits.Write)
+	readLimit := middlewares.RateLimit(limits.Limiter, keys, tokenService, tokenHeader, limits.Read)
+
+	// authenticate accepts sessions and the personal access tokens granted
+	// scope; session only routes pass no scope.
+	authenticate := func(scope string) gin.HandlerFunc {
+		return middlewares.Authenticate(keys, tokenHeader, sessionService, tokenService, scope)
+	}
+	session := authenticate("")
+	authorize := func(permission rbac.Permission) gin.HandlerFunc {
+		return middlewares.Authorize(accountService, permission)
+	}
+
+	app.POST("/auth", authLimit, auth.CreateToken)
+	app.POST("/auth/unlock", authLimit, auth.Unlock)
+	app.POST("/auth/2fa/verify", authLimit, auth.VerifyTwoFactor)
+	app.POST("/auth/2fa", writeLimit, session, auth.EnrollTwoFactor)
+	app.POST("/auth/2fa/confirm", authLimit, session, auth.ConfirmTwoFactor)
+	app.DELETE("/auth/2fa", authLimit, session, auth.DisableTwoFactor)
+	app.POST("/auth/2fa/recovery-codes", authLimit, session, auth.RegenerateRecoveryCodes)
+	app.GET("/auth/oidc/:provider", authLimit, auth.StartOIDC)
+	app.GET("/auth/oidc/:provider/callback", authLimit, auth.FinishOIDC)
+	app.POST("/auth/oidc/:provider/link", authLimit, session, auth.LinkOIDC)
+	app.GET("/auth/sessions", readLimit, session, sessions.GetSessions)
+	app.DELETE("/auth/sessions", writeLimit, session, sessions.DeleteOtherSessions)
+	app.DELETE("/auth/sessions/:id", writeLimit, session, sessions.DeleteSession)
+
+	app.POST("/accounts", writeLimit, accounts.CreateAccount)
+	app.GET("/accounts", readLimit, authenticate(token.SCOPE_READ_PROFILE), accounts.GetAccount)
+	app.PUT("/accounts", writeLimit, authenticate(token.SCOPE_WRITE_PROFILE), accounts.UpdateAccount)
+	app.DELETE("/accounts", writeLimit, session, accounts.DeleteAccount)
+	app.POST("/accounts/follows", writeLimit, authenticate(token.SCOPE_WRITE_FOLLOWS), accounts.FollowAccount)
+	app.GET("/accounts/following", readLimit, authenticate(token.SCOPE_READ_FOLLOWS), accounts.SearchFollowing)
+	app.GET("/accounts/follower", readLimit, authenticate(token.SCOPE_READ_FOLLOWS), accounts.SearchFollowers)
+	app.DELETE("/accounts/unfollow", writeLimit, authenticate(token.SCOPE_WRITE_FOLLOWS), accounts.UnfollowAccount)
+
+	app.POST("/comments/:post", writeLimit, authenticate(token.SCOPE_WRITE_COMMENTS), comments.CreateComment)
+	app.GET("/accounts/comments", readLimit, authenticate(token.SCOPE_READ_COMMENTS), comments.GetComment)
+	app.GET("/comments/:id", readLimit, authenticate(token.SCOPE_READ_COMMENTS), comments.GetCommentByID)
+	app.PUT("/comments", writeLimit, authenticate(token.SCOPE_WRITE_COMMENTS), comments.UpdateComment)
+	app.DELETE("/comments", writeLimit, authenticate(token.SCOPE_WRITE_COMMENTS), comments.DeleteComment)
+
+	app.POST("/interaction", writeLimit, authenticate(token.SCOPE_WRITE_INTERACTIONS), intercations.CreateInteraction)
+	app.PUT("/interaction", writeLimit, authenticate(token.SCOPE_WRITE_INTERACTIONS), intercations.UpdateInteraction)
+	app.DELETE("/interaction", writeLimit, authenticate(token.SCOPE_WRITE_INTERACTIONS), intercations.DeleteInteraction)
+
+	app.POST("/posts", writeLimit, authenticate(token.SCOPE_WRITE_POSTS), posts.CreatePost)
+	app.GET("/accounts/posts", readLimit, authenticate(token.SCOPE_READ_POSTS), posts.GetPost)
+	app.GET("/posts/:id", readLimit, authenticate(token.SCOPE_READ_POSTS), posts.GetPostByID)
+	app.PUT("/posts", writeLimit, authenticate(token.SCOPE_WRITE_POSTS), posts.UpdatePost)
+	app.DELETE("/posts", writeLimit, authenticate(token.SCOPE_WRITE_POSTS), posts.DeletePost)
+	app.GET("/accounts/follows/posts", readLimit, authenticate(token.SCOPE_READ_POSTS), posts.SearchPostByAccountFollowing)
+
+	app.POST("/webhooks", writeLimit, authenticate(token.SCOPE_WRITE_WEBHOOKS), webhooks.CreateWebhook)
+	app.GET("/webhooks", readLimit, authenticate(token.SCOPE_READ_WEBHOOKS), webhooks.GetWebhooks)
+	app.PUT("/webhooks", writeLimit, authenticate(token.SCOPE_WRITE_WEBHOOKS), webhooks.UpdateWebhook)
+	app.DELETE("/webhooks", writeLimit, authenticate(token.SCOPE_WRITE_WEBHOOKS), webhooks.DeleteWebhook)
+	app.GET("/webhooks/deliveries", readLimit, authenticate(token.SCOPE_READ_WEBHOOKS), webhooks.GetDeliveries)
+	app.POST("/webhooks/ping", writeLimit, authenticate(token.SCOPE_WRITE_WEBHOOKS), webhooks.PingWebhook)
+
+	app.POST("/tokens", writeLimit, session, tokens.CreateToken)
+	app.GET("/tokens", readLimit, session, tokens.GetTokens)
+	app.DELETE("/tokens", writeLimit, session, tokens.DeleteToken)
+
+	app.PUT("/admin/accounts/role", writeLimit, session, authorize(rbac.PERMISSION_ASSIGN_ROLES), admin.ChangeRole)
+	app.GET("/admin/audit-log", readLimit, session, authorize(rbac.PERMISSION_READ_AUDIT_LOG), admin.GetAuditLog)
+
+	// Probes are not limited: a rejected probe would take the instance out of
+	// the load balancer, and the readiness results are cached, so frequent
+	// probes do not reach the dependencies. What operators read about the
+	// API's internals takes an admin; metrics are served on their own port.
+	app.GET("/healthz", health.GetHealthz)
+	app.GET("/readyz", health.GetReadyz)
+	app.GET("/status", readLimit, authenticate(token.SCOPE_READ_STATUS), authorize(rbac.PERMISSION_READ_STATUS), health.GetStatus)
+
+	app.GET("/cache/stats", readLimit, authenticate(token.SCOPE_READ_STATUS), authorize(rbac.PERMISSION_READ_STATUS), cache.GetStats)
+	app.GET("/.well-known/jwks.json", readLimit, jwks.GetJWKS)
+
+	return app, nil
 }
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"social_network_project/cmd/api/middlewares"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/ratelimit"
+	"social_network_project/internal/platform/ratelimit/memoryLimiter"
+	"testing"
+	"time"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys, err := keyring.New(keyring.Config{
+		Dir:       t.TempDir(),
+		Algorithm: keyring.ALGORITHM_EDDSA,
+		Issuer:    "social_network",
+		Audience:  "social_network_api",
+		TokenTTL:  time.Hour,
+	})
+	assert.Nil(t, err)
+	policy := ratelimit.Policy{Name: "auth", Limit: 1, Window: time.Minute}
+
+	newRouter := func(trustedProxies []string) *gin.Engine {
+		app, err := newEngine(trustedProxies)
+		assert.Nil(t, err)
+		app.POST("/auth", middlewares.RateLimit(memoryLimiter.NewMemory(time.Now), keys, nil, "Authorization", policy), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		return app
+	}
+	request := func(router *gin.Engine, remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest("POST", "/auth", nil)
+		req.RemoteAddr = remoteAddr + ":1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	t.Run("a spoofed X-Forwarded-For does not change the bucket", func(t *testing.T) {
+		router := newRouter(nil)
+
+		assert.Equal(t, http.StatusOK, request(router, "203.0.113.7", "10.0.0.1"))
+		assert.Equal(t, http.StatusTooManyRequests, request(router, "203.0.113.7", "10.0.0.2"))
+	})
+	t.Run("X-Forwarded-For of a trusted proxy names the client", func(t *testing.T) {
+		router := newRouter([]string{"10.0.0.0/8"})
+
+		assert.Equal(t, http.StatusOK, request(router, "10.1.1.1", "203.0.113.7"))
+		assert.Equal(t, http.StatusOK, request(router, "10.1.1.1", "203.0.113.8"))
+		assert.Equal(t, http.StatusTooManyRequests, request(router, "10.1.1.1", "203.0.113.7"))
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"github.com/go-redis/redis/v8"
+	"social_network_project/internal/platform/health"
+	messagebroker "social_network_project/internal/platform/message-broker"
+)
+
+func postgresCheck(db *sql.DB) health.Check {
+	return health.Check{
+		Name: "postgres",
+		Ping: db.PingContext,
+		Stats: func() interface{} {
+			stats := db.Stats()
+			return map[string]interface{}{
+				"max_open_connections": stats.MaxOpenConnections,
+				"open_connections":     stats.OpenConnections,
+				"in_use":               stats.InUse,
+				"idle":                 stats.Idle,
+				"wait_count":           stats.WaitCount,
+				"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			}
+		},
+	}
+}
+
+func redisCheck(client *redis.Client) health.Check {
+	return health.Check{
+		Name: "redis",
+		Ping: func(ctx context.Context) error {
+			return client.Ping(ctx).Err()
+		},
+		Stats: func() interface{} {
+			stats := client.PoolStats()
+			return map[string]interface{}{
+				"total_connections": stats.TotalConns,
+				"idle_connections":  stats.IdleConns,
+				"stale_connections": stats.StaleConns,
+				"hits":              stats.Hits,
+				"misses":            stats.Misses,
+				"timeouts":          stats.Timeouts,
+			}
+		},
+	}
+}
+
+func brokerCheck(broker messagebroker.Broker) health.Check {
+	return health.Check{
+		Name: "message_broker",
+		Ping: broker.Ping,
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
-	"github.com/joho/godotenv"
+	"context"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
 	"social_network_project/cmd/api"
 	"social_network_project/cmd/api/handlers"
 	"social_network_project/internal/account"
 	service9 "social_network_project/internal/account/service"
+	"social_network_project/internal/audit"
+	service13 "social_network_project/internal/audit/service"
+	"social_network_project/internal/auth"
 	service4 "social_network_project/internal/auth/service"
 	service3 "social_network_project/internal/comment"
 	"social_network_project/internal/comment/service"
@@ -15,58 +23,379 @@ import (
 	service6 "social_network_project/internal/interaction/service"
 	"social_network_project/internal/notification"
 	service7 "social_network_project/internal/notification/service"
+	"social_network_project/internal/outbox"
+	service10 "social_network_project/internal/outbox/service"
 	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/platform/cache/memoryDB"
 	"social_network_project/internal/platform/cache/redisDB"
+	"social_network_project/internal/platform/cache/tieredDB"
+	"social_network_project/internal/platform/config"
 	"social_network_project/internal/platform/database/postgresql"
+	"social_network_project/internal/platform/health"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/lifecycle"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/platform/mail"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"social_network_project/internal/platform/message-broker/memory"
 	"social_network_project/internal/platform/message-broker/rabbitmq"
+	"social_network_project/internal/platform/metrics"
+	"social_network_project/internal/platform/oidc"
+	"social_network_project/internal/platform/ratelimit"
+	"social_network_project/internal/platform/ratelimit/memoryLimiter"
+	"social_network_project/internal/platform/ratelimit/redisLimiter"
+	"social_network_project/internal/platform/tracing"
 	service5 "social_network_project/internal/post"
 	service8 "social_network_project/internal/post/service"
+	"social_network_project/internal/session"
+	service14 "social_network_project/internal/session/service"
+	"social_network_project/internal/token"
+	service12 "social_network_project/internal/token/service"
+	"social_network_project/internal/webhook"
+	service11 "social_network_project/internal/webhook/service"
+	"strconv"
+	"syscall"
+	"time"
 )
 
 func main() {
-	err := godotenv.Load()
+	err := config.LoadDotEnv(".env")
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatal("Error loading .env file: ", err)
 	}
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stdout, logging.Config{Level: cfg.Log.Level, Format: cfg.Log.Format})
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	// The first signal drains the API and stops; a second one kills it.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	postgresqlDB, err := postgresql.ConnectDatabase()
+	// Connections are retried until connectCtx is done, so the API can start
+	// before the services it depends on.
+	connectCtx, cancelConnect := context.WithTimeout(ctx, cfg.Lifecycle.ConnectTimeout)
+	defer cancelConnect()
+	backoff := lifecycle.Backoff{Initial: 500 * time.Millisecond, Max: 10 * time.Second}
+
+	closers := lifecycle.Closers{Logger: logger}
+	fatal := func(err error) {
+		logger.Error("starting", "error", err)
+		closers.Close()
+		os.Exit(1)
+	}
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter: cfg.Tracing.Exporter,
+		Endpoint: cfg.Tracing.Endpoint,
+		Insecure: cfg.Tracing.Insecure,
+	})
 	if err != nil {
-		log.Fatal("Error connecting database postgres")
+		fatal(err)
 	}
+	// Closed last, so the spans of the shutdown are exported too.
+	closers.Add("tracing", func() error {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), cfg.Lifecycle.ShutdownTimeout)
+		defer cancelFlush()
+		return shutdownTracing(flushCtx)
+	})
 
-	redisDB := redisDB.NewRedis()
-	err = redisDB.ConnectToDatabase()
+	postgresqlDB, err := postgresql.ConnectDatabase(postgresql.Config{
+		Host:     cfg.Postgres.Host,
+		Port:     cfg.Postgres.Port,
+		User:     cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Name:     cfg.Postgres.Name,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	closers.Add("postgres", postgresqlDB.Close)
+	err = lifecycle.Retry(connectCtx, logger, "postgres", backoff, postgresqlDB.Ping)
+	if err != nil {
+		fatal(err)
+	}
+	checks := []health.Check{postgresCheck(postgresqlDB)}
+	err = metrics.RegisterDB(postgresqlDB, cfg.Postgres.Name)
 	if err != nil {
-		log.Fatal("Error connecting database redis")
+		fatal(err)
+	}
+
+	var redisClient redisDB.RedisClient
+	if cfg.Cache.Backend != "memory" || cfg.RateLimit.Backend != "memory" || cfg.Session.Backend != "memory" {
+		redisClient = redisDB.NewRedis(redisDB.Config{
+			Addr:     net.JoinHostPort(cfg.Redis.Host, strconv.Itoa(cfg.Redis.Port)),
+			Password: cfg.Redis.Password,
+		})
+		err = lifecycle.Retry(connectCtx, logger, "redis", backoff, redisClient.ConnectToDatabase)
+		if err != nil {
+			fatal(err)
+		}
+		closers.Add("redis", redisClient.Close)
+		checks = append(checks, redisCheck(redisClient.Conn()))
 	}
 
-	redisService := cache.NewRedisService(redisDB)
+	var cacheBackend cache.Backend
+	switch cfg.Cache.Backend {
+	case "memory":
+		cacheBackend = memoryDB.NewMemory(cfg.Cache.MemoryCapacity, time.Now)
+	case "tiered":
+		cacheBackend = tieredDB.NewTiered(
+			memoryDB.NewMemory(cfg.Cache.MemoryCapacity, time.Now),
+			redisClient,
+			cfg.Cache.LocalTTL,
+		)
+	default:
+		cacheBackend = redisClient
+	}
 
-	rabbitConn, err := rabbitmq.ConnectToMessageBroker()
+	redisService := cache.NewRedisService(cacheBackend, cfg.Cache.TTL, map[string]time.Duration{
+		"/accounts":               cfg.Cache.RouteTTL(cfg.Cache.TTLAccounts),
+		"/accounts/following":     cfg.Cache.RouteTTL(cfg.Cache.TTLFollows),
+		"/accounts/follower":      cfg.Cache.RouteTTL(cfg.Cache.TTLFollows),
+		"/accounts/posts":         cfg.Cache.RouteTTL(cfg.Cache.TTLPosts),
+		"/accounts/follows/posts": cfg.Cache.RouteTTL(cfg.Cache.TTLFeed),
+		"/accounts/comments":      cfg.Cache.RouteTTL(cfg.Cache.TTLComments),
+	}, cfg.Cache.NegativeTTL, cfg.Server.WriteTimeout, logger)
+	err = metrics.RegisterCache(redisService)
 	if err != nil {
-		log.Fatal("Error connecting message-broker rabbitMQ")
+		fatal(err)
+	}
+
+	var limiter ratelimit.Limiter
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		limiter = memoryLimiter.NewMemory(time.Now)
+	default:
+		limiter = redisLimiter.NewRedis(redisClient.Conn(), time.Now)
 	}
-	notificationRepository := notification.NewNotificationRepository(postgresqlDB)
-	notificationService := service7.NewNotificationService(rabbitConn, notificationRepository)
-	go notificationService.ConsumerMessage()
+	rateLimits := api.RateLimits{
+		Limiter: limiter,
+		Auth: ratelimit.Policy{
+			Name:   "auth",
+			Limit:  cfg.RateLimit.AuthLimit,
+			Window: cfg.RateLimit.AuthWindow,
+		},
+		Write: ratelimit.Policy{
+			Name:   "write",
+			Limit:  cfg.RateLimit.WriteLimit,
+			Window: cfg.RateLimit.WriteWindow,
+		},
+		Read: ratelimit.Policy{
+			Name:   "read",
+			Limit:  cfg.RateLimit.ReadLimit,
+			Window: cfg.RateLimit.ReadWindow,
+		},
+	}
+
+	var broker messagebroker.Broker
+	switch cfg.MessageBroker.Backend {
+	case "memory":
+		broker = memory.NewMemory()
+	default:
+		err = lifecycle.Retry(connectCtx, logger, "rabbitmq", backoff, func() error {
+			broker, err = rabbitmq.ConnectToMessageBroker(cfg.MessageBroker.RabbitMQURL, backoff, logger)
+			return err
+		})
+		if err != nil {
+			fatal(err)
+		}
+	}
+	closers.Add("message broker", broker.Close)
+	checks = append(checks, brokerCheck(broker))
+	cancelConnect()
+	apiHealth := health.NewHealth(cfg.Lifecycle.HealthTimeout, cfg.Lifecycle.HealthCacheTTL, time.Now, checks...)
+
+	// Workers stop once the API no longer takes requests, before the
+	// connections they use are closed.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers lifecycle.Workers
+
+	mailer := mail.New(mail.Config{
+		Host:     cfg.Mail.Host,
+		Port:     cfg.Mail.Port,
+		Username: cfg.Mail.Username,
+		Password: cfg.Mail.Password,
+		From:     cfg.Mail.From,
+	}, logger)
+	notificationRepository := notification.NewNotificationRepository(postgresqlDB, mailer, logger)
+	notificationService := service7.NewNotificationService(
+		broker,
+		notificationRepository,
+		cfg.Notification.AggregationWindow,
+		cfg.Notification.DedupeWindow,
+		cfg.Notification.FlushInterval,
+		time.Now,
+		logger,
+	)
+	workers.Go(workersCtx, notificationService.ConsumerMessage)
+	workers.Go(workersCtx, notificationService.DeliverNotifications)
+
+	outboxRepository := outbox.NewOutboxRepository(postgresqlDB)
+	outboxService := service10.NewOutboxService(outboxRepository, broker, []string{service7.NotificationQueue, service11.WebhookQueue}, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize, cfg.Outbox.Retention, logger)
+	workers.Go(workersCtx, outboxService.RelayMessages)
 
 	accountsRepository := account.NewAccountRepository(postgresqlDB)
 	postsRepository :=    service5.NewPostRepository(postgresqlDB)
 	commentsRepository := service3.NewComentRepository(postgresqlDB)
+	webhooksRepository := webhook.NewWebhookRepository(postgresqlDB)
+
+	webhooksService := service11.NewWebhookService(webhooksRepository, postsRepository, commentsRepository, broker, service11.DeliveryPolicy{
+		Interval:               cfg.Webhook.Interval,
+		BatchSize:              cfg.Webhook.BatchSize,
+		Timeout:                cfg.Webhook.Timeout,
+		MaxAttempts:            cfg.Webhook.MaxAttempts,
+		MaxConsecutiveFailures: cfg.Webhook.MaxConsecutiveFailures,
+		BaseBackoff:            cfg.Webhook.BaseBackoff,
+		MaxBackoff:             cfg.Webhook.MaxBackoff,
+	}, logger)
+	workers.Go(workersCtx, webhooksService.ConsumerMessage)
+	workers.Go(workersCtx, webhooksService.DeliverMessages)
 	interactionsRepository := service2.NewInteractionRepository(postgresqlDB)
+	tokensRepository := token.NewTokenRepository(postgresqlDB)
+	auditRepository := audit.NewAuditRepository(postgresqlDB)
+
+	keys, err := keyring.New(keyring.Config{
+		Dir:         cfg.JWT.KeysDir,
+		Algorithm:   cfg.JWT.Algorithm,
+		Issuer:      cfg.JWT.Issuer,
+		Audience:    cfg.JWT.Audience,
+		TokenTTL:    cfg.JWT.TokenTTL,
+		RotateEvery: cfg.JWT.KeyRotation,
+		Logger:      logger,
+	})
+	if err != nil {
+		fatal(err)
+	}
+	workers.Go(workersCtx, keys.RotateKeys)
+
+	var sessionsRepository session.SessionRepository
+	switch cfg.Session.Backend {
+	case "memory":
+		sessionsRepository = session.NewSessionRepositoryMemory(time.Now)
+	default:
+		sessionsRepository = session.NewSessionRepositoryRedis(redisClient.Conn(), time.Now)
+	}
+	sessionsService := service14.NewSessionService(sessionsRepository, keys.TokenTTL())
 
-	authService := service4.NewAuthService(accountsRepository)
-	accountsService := service9.NewAccountsService(accountsRepository, notificationService)
-	postsService := service8.NewPostsService(postsRepository, accountsRepository, notificationService)
-	commentsService := service.NewCommentsService(commentsRepository, accountsRepository, postsRepository, notificationService)
-	interactionsService := service6.NewInteractionsService(accountsRepository, commentsRepository, interactionsRepository, notificationService)
+	attemptRepository := auth.NewAttemptRepository(postgresqlDB)
+	twoFactorRepository := auth.NewTwoFactorRepository(postgresqlDB)
+	identityRepository := auth.NewIdentityRepository(postgresqlDB)
+
+	providers := map[string]*oidc.Provider{}
+	for name, provider := range cfg.OIDC {
+		providers[name] = oidc.NewProvider(oidc.Config{
+			Name:         name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		}, &http.Client{Timeout: 10 * time.Second})
+	}
+
+	authService := service4.NewAuthService(accountsRepository, attemptRepository, twoFactorRepository, identityRepository, sessionsService, keys, providers, service4.LoginPolicy{
+		Window:          cfg.Login.FailureWindow,
+		DelayAfter:      cfg.Login.DelayAfter,
+		IPDelayAfter:    cfg.Login.IPDelayAfter,
+		BaseDelay:       cfg.Login.BaseDelay,
+		MaxDelay:        cfg.Login.MaxDelay,
+		LockoutAfter:    cfg.Login.LockoutAfter,
+		LockoutDuration: cfg.Login.LockoutDuration,
+	}, cfg.TOTP.Issuer, logger)
+	accountsService := service9.NewAccountsService(accountsRepository, redisService, sessionsService, logger)
+	postsService := service8.NewPostsService(postsRepository, accountsRepository, redisService, logger)
+	commentsService := service.NewCommentsService(commentsRepository, accountsRepository, postsRepository, redisService, logger)
+	interactionsService := service6.NewInteractionsService(accountsRepository, commentsRepository, interactionsRepository, redisService, logger)
+	tokensService := service12.NewTokenService(tokensRepository)
+	auditService := service13.NewAuditService(auditRepository)
 
 	authHandler := handlers.RegisterAuthHandler(authService)
 	accountsHandler := handlers.RegisterAccountsHandlers(accountsService, redisService)
 	postsHandler := handlers.RegisterPostsHandlers(postsService, redisService)
 	commentsHandler := handlers.RegisterCommentsHandlers(commentsService, redisService)
 	interactionsHandler := handlers.RegisterInteractionsHandlers(interactionsService)
+	webhooksHandler := handlers.RegisterWebhooksHandlers(webhooksService)
+	tokensHandler := handlers.RegisterTokensHandlers(tokensService)
+	sessionsHandler := handlers.RegisterSessionsHandlers(sessionsService)
+	adminHandler := handlers.RegisterAdminHandlers(accountsService, auditService)
+	cacheHandler := handlers.RegisterCacheHandler(redisService)
+	jwksHandler := handlers.RegisterJWKSHandler(keys)
+	healthHandler := handlers.RegisterHealthHandler(apiHealth)
+
+	api, err := api.Init(authHandler, accountsHandler, postsHandler, commentsHandler, interactionsHandler, webhooksHandler, tokensHandler, sessionsHandler, adminHandler, cacheHandler, jwksHandler, healthHandler, rateLimits, cfg.Server.TrustedProxies, keys, cfg.Server.TokenHeader, sessionsService, tokensService, accountsService, logger)
+	if err != nil {
+		fatal(err)
+	}
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           api,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	// Metrics are served on their own port, reached by Prometheus from the
+	// internal network and not exposed with the API.
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.MetricsPort),
+		Handler:           metricsMux,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	go func() {
+		serverErr <- metricsServer.ListenAndServe()
+	}()
+	logger.Info("listening", "addr", server.Addr, "metrics_addr", metricsServer.Addr)
+
+	exitCode := 0
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down")
+	case err = <-serverErr:
+		logger.Error("serving", "error", err)
+		exitCode = 1
+	}
+	stop()
+
+	// Readiness fails first, so that no new traffic is sent here while
+	// requests still being routed here are served.
+	apiHealth.Drain()
+	time.Sleep(cfg.Lifecycle.DrainDelay)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), cfg.Lifecycle.ShutdownTimeout)
+	defer cancelShutdown()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Warn("requests still in flight", "timeout", cfg.Lifecycle.ShutdownTimeout, "error", err)
+	}
+	err = metricsServer.Shutdown(shutdownCtx)
+	if err != nil {
+		logger.Warn("metrics requests still in flight", "timeout", cfg.Lifecycle.ShutdownTimeout, "error", err)
+	}
+	stopWorkers()
+	if !workers.Wait(shutdownCtx) {
+		logger.Warn("workers still running", "timeout", cfg.Lifecycle.ShutdownTimeout)
+	}
+	closers.Close()
 
-	api := api.Init(authHandler, accountsHandler, postsHandler, commentsHandler, interactionsHandler)
-	api.Run(":" + os.Getenv("API_PORT"))
+	os.Exit(exitCode)
 }
@@ -10,6 +10,8 @@ type Account struct {
 	CreatedAt   string
 	UpdatedAt   string
 	Deleted     bool
+	Role        string
+	Version     int
 }
 
 func (a *Account) ToResponse() AccountResponse {
@@ -21,5 +23,6 @@ func (a *Account) ToResponse() AccountResponse {
 		Email:       a.Email,
 		CreatedAt:   a.CreatedAt,
 		UpdatedAt:   a.UpdatedAt,
+		Role:        a.Role,
 	}
 }
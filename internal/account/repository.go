@@ -1,30 +1,36 @@
 package account
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/database/postgresql"
+	"social_network_project/internal/rbac"
 	"strings"
 	"time"
 )
 
 type AccountRepository interface {
-	InsertAccount(account *Account) error
-	FindAccountPasswordByEmail(email string) (*string, error)
-	FindAccountIDbyEmail(email string) (*string, error)
-	FindAccountByID(id *string) (*Account, error)
-	ChangeAccountDataByID(id *string, req AccountRequest) error
-	DeleteAccountByID(id *string) error
-	ExistsAccountByID(id *string) (*bool, error)
-	ExistsAccountByUsername(username *string) (*bool, error)
-	ExistsAccountByEmail(email *string) (*bool, error)
-	InsertAccountFollow(accountID, accountFollow *string) error
-	FindAccountFollowingByAccountID(accountID, page *string) ([]interface{}, error)
-	FindAccountFollowersByAccountID(accountID, page *string) ([]interface{}, error)
-	ExistsFollowByAccountIDAndAccountFollowedID(accountID, accountToFollow *string) (*bool, error)
-	DeleteAccountFollow(accountID, accountFollow *string) error
-	FindAccountEmailFollowersByAccountID(id *string) ([]interface{}, error)
-	FindAccountEmailByID(id *string) ([]interface{}, error)
+	InsertAccount(ctx context.Context, account *Account) error
+	FindAccountPasswordByEmail(ctx context.Context, email string) (*string, error)
+	FindAccountIDbyEmail(ctx context.Context, email string) (*string, error)
+	FindAccountByID(ctx context.Context, id *string) (*Account, error)
+	ChangeAccountDataByID(ctx context.Context, id *string, req AccountRequest, version *int) (bool, error)
+	DeleteAccountByID(ctx context.Context, id *string) error
+	ExistsAccountByID(ctx context.Context, id *string) (*bool, error)
+	ExistsAccountByUsername(ctx context.Context, username *string) (*bool, error)
+	ExistsAccountByEmail(ctx context.Context, email *string) (*bool, error)
+	InsertAccountFollow(ctx context.Context, accountID, accountFollow *string, message *outbox.Outbox) error
+	FindAccountFollowingByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	FindAccountFollowersByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	ExistsFollowByAccountIDAndAccountFollowedID(ctx context.Context, accountID, accountToFollow *string) (*bool, error)
+	DeleteAccountFollow(ctx context.Context, accountID, accountFollow *string, message *outbox.Outbox) error
+	FindAccountEmailFollowersByAccountID(ctx context.Context, id *string) ([]interface{}, error)
+	FindAccountEmailByID(ctx context.Context, id *string) ([]interface{}, error)
+	FindAccountFollowerIDsByAccountID(ctx context.Context, id *string) ([]string, error)
+	ChangeAccountRoleByID(ctx context.Context, id, role *string, entry *audit.Audit) (bool, error)
 }
 
 type AccountRepositoryStruct struct {
@@ -35,12 +41,14 @@ func NewAccountRepository(postgresDB *sql.DB) AccountRepository {
 	return &AccountRepositoryStruct{postgresDB}
 }
 
-func (p *AccountRepositoryStruct) InsertAccount(account *Account) error {
+func (p *AccountRepositoryStruct) InsertAccount(ctx context.Context, account *Account) error {
+	ctx, end := postgresql.Observe(ctx, "account", "InsertAccount")
+	defer end()
 	sqlStatement := `
 		INSERT INTO account (id, username, name, description, email, password, created_at, updated_at, deleted)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
-	_, err := p.Db.Exec(sqlStatement, account.ID, account.Username, account.Name, account.Description,
+	_, err := p.Db.ExecContext(ctx, sqlStatement, account.ID, account.Username, account.Name, account.Description,
 		account.Email, account.Password, account.CreatedAt, account.UpdatedAt, account.Deleted)
 	if err != nil {
 		return err
@@ -49,13 +57,15 @@ func (p *AccountRepositoryStruct) InsertAccount(account *Account) error {
 	return nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountPasswordByEmail(email string) (*string, error) {
+func (p *AccountRepositoryStruct) FindAccountPasswordByEmail(ctx context.Context, email string) (*string, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountPasswordByEmail")
+	defer end()
 	sqlStatement := `
 		SELECT password 
 		FROM account
 		WHERE email = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, email)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, email)
 	if err != nil {
 		return nil, err
 	}
@@ -67,13 +77,15 @@ func (p *AccountRepositoryStruct) FindAccountPasswordByEmail(email string) (*str
 	return password, nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountIDbyEmail(email string) (*string, error) {
+func (p *AccountRepositoryStruct) FindAccountIDbyEmail(ctx context.Context, email string) (*string, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountIDbyEmail")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM account
 		WHERE email = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, email)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, email)
 	if err != nil {
 		return nil, err
 	}
@@ -88,20 +100,16 @@ func (p *AccountRepositoryStruct) FindAccountIDbyEmail(email string) (*string, e
 	return id, nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountByID(id *string) (*Account, error) {
+func (p *AccountRepositoryStruct) FindAccountByID(ctx context.Context, id *string) (*Account, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountByID")
+	defer end()
 	sqlStatement := `
-		SELECT id, username, name, description, email, password, created_at, updated_at, deleted
+		SELECT id, username, name, description, email, password, created_at, updated_at, deleted, role, version
 		FROM account
 		WHERE id = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, id)
-	if err != nil {
-		return nil, err
-	}
-
-	rows.Next()
 	var account Account
-	err = rows.Scan(
+	err := p.Db.QueryRowContext(ctx, sqlStatement, id).Scan(
 		&account.ID,
 		&account.Username,
 		&account.Name,
@@ -111,6 +119,8 @@ func (p *AccountRepositoryStruct) FindAccountByID(id *string) (*Account, error)
 		&account.CreatedAt,
 		&account.UpdatedAt,
 		&account.Deleted,
+		&account.Role,
+		&account.Version,
 	)
 	if err != nil {
 		return nil, err
@@ -122,57 +132,97 @@ func (p *AccountRepositoryStruct) FindAccountByID(id *string) (*Account, error)
 	return &account, nil
 }
 
-func (p *AccountRepositoryStruct) ChangeAccountDataByID(id *string, req AccountRequest) error {
-	var reqMap map[string]interface{}
-	data, _ := json.Marshal(req)
-	json.Unmarshal(data, &reqMap)
+// ChangeAccountDataByID reports whether the account was changed. With a
+// version, only an account still at that version is.
+func (p *AccountRepositoryStruct) ChangeAccountDataByID(ctx context.Context, id *string, req AccountRequest, version *int) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ChangeAccountDataByID")
+	defer end()
+	sqlStatement, values := dinamicQueryChangeAccountDataByID(req)
 
-	sqlStatement := dinamicQueryChangeAccountDataByID(reqMap)
+	result, err := p.Db.ExecContext(ctx, sqlStatement, append([]interface{}{id, version}, values...)...)
+	if err != nil {
+		return false, err
+	}
 
-	row := p.Db.QueryRow(sqlStatement, id)
-	if row.Err() != nil {
-		return row.Err()
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	return affected > 0, nil
 }
 
-func dinamicQueryChangeAccountDataByID(mapBody map[string]interface{}) string {
+// dinamicQueryChangeAccountDataByID sets the columns of the fields given in
+// req, passing their values as parameters after the id and the version. The
+// role is not among them: only ChangeAccountRoleByID changes it.
+func dinamicQueryChangeAccountDataByID(req AccountRequest) (string, []interface{}) {
+	columns := []struct {
+		name  string
+		value string
+	}{
+		{"username", req.Username},
+		{"name", req.Name},
+		{"description", req.Description},
+		{"email", req.Email},
+		{"password", req.Password},
+	}
 
 	var values []interface{}
-	var where []string
-
-	for key, value := range mapBody {
-		values = append(values, value)
-		where = append(where, fmt.Sprintf(`"%s" = '%s'`, key, value))
+	var set []string
+	for _, column := range columns {
+		if column.value == "" {
+			continue
+		}
+		values = append(values, column.value)
+		set = append(set, fmt.Sprintf(`"%s" = $%d`, column.name, len(values)+2))
 	}
-	stringQuery := "UPDATE account SET " + strings.Join(where, ", ") + " WHERE id = $1 AND deleted = false"
+	set = append(set, "version = version + 1")
+	stringQuery := "UPDATE account SET " + strings.Join(set, ", ") + " WHERE id = $1 AND deleted = false AND ($2::INT IS NULL OR version = $2)"
 
-	return stringQuery
+	return stringQuery, values
 }
 
-func (p *AccountRepositoryStruct) DeleteAccountByID(id *string) error {
-	sqlStatement := `
+// DeleteAccountByID deletes the account and revokes its personal access
+// tokens in one transaction.
+func (p *AccountRepositoryStruct) DeleteAccountByID(ctx context.Context, id *string) error {
+	ctx, end := postgresql.Observe(ctx, "account", "DeleteAccountByID")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
 		UPDATE account 
 		SET deleted = true 
 		WHERE id = $1
-		AND deleted = false`
+		AND deleted = false`, id)
+	if err != nil {
+		return err
+	}
 
-	row := p.Db.QueryRow(sqlStatement, id)
-	if row.Err() != nil {
-		return row.Err()
+	_, err = tx.ExecContext(ctx, `
+		UPDATE access_token
+		SET revoked = true
+		WHERE account_id = $1
+		AND revoked = false`, id)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (p *AccountRepositoryStruct) ExistsAccountByID(id *string) (*bool, error) {
+func (p *AccountRepositoryStruct) ExistsAccountByID(ctx context.Context, id *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ExistsAccountByID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM account
 		WHERE id = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -181,13 +231,15 @@ func (p *AccountRepositoryStruct) ExistsAccountByID(id *string) (*bool, error) {
 	return &next, nil
 }
 
-func (p *AccountRepositoryStruct) ExistsAccountByUsername(username *string) (*bool, error) {
+func (p *AccountRepositoryStruct) ExistsAccountByUsername(ctx context.Context, username *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ExistsAccountByUsername")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM account
 		WHERE username = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, username)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, username)
 	if err != nil {
 		return nil, err
 	}
@@ -196,13 +248,15 @@ func (p *AccountRepositoryStruct) ExistsAccountByUsername(username *string) (*bo
 	return &next, nil
 }
 
-func (p *AccountRepositoryStruct) ExistsAccountByEmail(email *string) (*bool, error) {
+func (p *AccountRepositoryStruct) ExistsAccountByEmail(ctx context.Context, email *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ExistsAccountByEmail")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM account
 		WHERE email = $1
 		AND deleted = false`
-	rows, err := p.Db.Query(sqlStatement, email)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, email)
 	if err != nil {
 		return nil, err
 	}
@@ -211,21 +265,36 @@ func (p *AccountRepositoryStruct) ExistsAccountByEmail(email *string) (*bool, er
 	return &next, nil
 }
 
-func (p *AccountRepositoryStruct) InsertAccountFollow(accountID, accountFollow *string) error {
+func (p *AccountRepositoryStruct) InsertAccountFollow(ctx context.Context, accountID, accountFollow *string, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "account", "InsertAccountFollow")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	followedAt := time.Now().UTC().Format("2006-01-02")
 	sqlStatement := `
 		INSERT INTO account_follow (account_id, account_id_followed, followed_at, unfollowed)
 		VALUES ($1, $2, $3, false)`
 
-	row := p.Db.QueryRow(sqlStatement, accountID, accountFollow, followedAt)
-	if row.Err() != nil {
-		return row.Err()
+	_, err = tx.ExecContext(ctx, sqlStatement, accountID, accountFollow, followedAt)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (p *AccountRepositoryStruct) FindAccountFollowingByAccountID(accountID, page *string) ([]interface{}, error) {
+func (p *AccountRepositoryStruct) FindAccountFollowingByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountFollowingByAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT account.id, account.username, account.name, account.description, account.email,
 		account.password, account.created_at , account.updated_at, account.deleted 
@@ -237,7 +306,7 @@ func (p *AccountRepositoryStruct) FindAccountFollowingByAccountID(accountID, pag
 		OFFSET ($2 - 1) * 10
 		FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(sqlStatement, accountID, page)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID, page)
 	if err != nil {
 		return nil, err
 	}
@@ -268,7 +337,9 @@ func (p *AccountRepositoryStruct) FindAccountFollowingByAccountID(accountID, pag
 	return list, nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountFollowersByAccountID(accountID, page *string) ([]interface{}, error) {
+func (p *AccountRepositoryStruct) FindAccountFollowersByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountFollowersByAccountID")
+	defer end()
 	sqlStatement := `
 	SELECT account.id, account.username, account.name, account.description, account.email,
 	account.password, account.created_at , account.updated_at, account.deleted
@@ -280,7 +351,7 @@ func (p *AccountRepositoryStruct) FindAccountFollowersByAccountID(accountID, pag
 	OFFSET ($2 - 1) * 10
 	FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(sqlStatement, accountID, page)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID, page)
 	if err != nil {
 		return nil, err
 	}
@@ -311,7 +382,15 @@ func (p *AccountRepositoryStruct) FindAccountFollowersByAccountID(accountID, pag
 	return list, nil
 }
 
-func (p *AccountRepositoryStruct) DeleteAccountFollow(accountID, accountFollow *string) error {
+func (p *AccountRepositoryStruct) DeleteAccountFollow(ctx context.Context, accountID, accountFollow *string, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "account", "DeleteAccountFollow")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		UPDATE account_follow 
 		SET unfollowed = true 
@@ -319,22 +398,29 @@ func (p *AccountRepositoryStruct) DeleteAccountFollow(accountID, accountFollow *
 		AND account_id_followed = $2
 		AND unfollowed = false`
 
-	row := p.Db.QueryRow(sqlStatement, accountID, accountFollow)
-	if row.Err() != nil {
-		return row.Err()
+	_, err = tx.ExecContext(ctx, sqlStatement, accountID, accountFollow)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (p *AccountRepositoryStruct) ExistsFollowByAccountIDAndAccountFollowedID(accountID, accountToFollow *string) (*bool, error) {
+func (p *AccountRepositoryStruct) ExistsFollowByAccountIDAndAccountFollowedID(ctx context.Context, accountID, accountToFollow *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ExistsFollowByAccountIDAndAccountFollowedID")
+	defer end()
 	sqlStatement := `
 		SELECT account_id
 		FROM account_follow
 		WHERE account_id = $1
 		AND account_id_followed = $2
 		AND unfollowed = false`
-	rows, err := p.Db.Query(sqlStatement, accountID, accountToFollow)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID, accountToFollow)
 	if err != nil {
 		return nil, err
 	}
@@ -343,7 +429,9 @@ func (p *AccountRepositoryStruct) ExistsFollowByAccountIDAndAccountFollowedID(ac
 	return &next, nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountEmailFollowersByAccountID(id *string) ([]interface{}, error) {
+func (p *AccountRepositoryStruct) FindAccountEmailFollowersByAccountID(ctx context.Context, id *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountEmailFollowersByAccountID")
+	defer end()
 	sqlStatement := `
 	SELECT account.email
 	FROM account_follow
@@ -351,7 +439,7 @@ func (p *AccountRepositoryStruct) FindAccountEmailFollowersByAccountID(id *strin
 	WHERE account_follow.account_id_followed = $1
 	AND account_follow.unfollowed = false;`
 
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -371,12 +459,14 @@ func (p *AccountRepositoryStruct) FindAccountEmailFollowersByAccountID(id *strin
 	return list, nil
 }
 
-func (p *AccountRepositoryStruct) FindAccountEmailByID(id *string) ([]interface{}, error) {
+func (p *AccountRepositoryStruct) FindAccountEmailByID(ctx context.Context, id *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountEmailByID")
+	defer end()
 	sqlStatement := `
 	SELECT account.email
 	FROM account
 	WHERE account.id = $1`
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -395,3 +485,96 @@ func (p *AccountRepositoryStruct) FindAccountEmailByID(id *string) ([]interface{
 
 	return list, nil
 }
+
+func (p *AccountRepositoryStruct) FindAccountFollowerIDsByAccountID(ctx context.Context, id *string) ([]string, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "FindAccountFollowerIDsByAccountID")
+	defer end()
+	sqlStatement := `
+	SELECT account_follow.account_id
+	FROM account_follow
+	WHERE account_follow.account_id_followed = $1
+	AND account_follow.unfollowed = false`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []string
+	var followerID string
+	for rows.Next() {
+		err = rows.Scan(
+			&followerID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, followerID)
+	}
+
+	return list, rows.Err()
+}
+
+// ChangeAccountRoleByID gives role to the account with an audit entry, in one
+// transaction, and reports false without changing anything when it would
+// leave no admin. The admins are locked while counted, so that two admins
+// demoting each other at once cannot both succeed.
+func (p *AccountRepositoryStruct) ChangeAccountRoleByID(ctx context.Context, id, role *string, entry *audit.Audit) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "account", "ChangeAccountRoleByID")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if *role != rbac.ROLE_ADMIN {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id
+			FROM account
+			WHERE role = $1
+			AND deleted = false
+			FOR UPDATE`, rbac.ROLE_ADMIN)
+		if err != nil {
+			return false, err
+		}
+		others := 0
+		for rows.Next() {
+			var adminID string
+			err = rows.Scan(&adminID)
+			if err != nil {
+				rows.Close()
+				return false, err
+			}
+			if adminID != *id {
+				others++
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return false, err
+		}
+		if others == 0 {
+			return false, nil
+		}
+	}
+
+	sqlStatement := `
+		UPDATE account
+		SET role = $2
+		WHERE id = $1
+		AND deleted = false`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, id, role)
+	if err != nil {
+		return false, err
+	}
+
+	err = audit.InsertAudit(ctx, tx, entry)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,128 @@
+package account
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/rbac"
+	"testing"
+)
+
+func TestChangeAccountDataByID(t *testing.T) {
+	t.Run("passes the values as parameters", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		id := "a1"
+		version := 3
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE account SET "name" = $3, "email" = $4, version = version + 1 WHERE id = $1 AND deleted = false AND ($2::INT IS NULL OR version = $2)`)).
+			WithArgs(id, &version, "Ana", "ana@example.com").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repository := NewAccountRepository(db)
+		changed, err := repository.ChangeAccountDataByID(context.Background(), &id, AccountRequest{Name: "Ana", Email: "ana@example.com"}, &version)
+
+		assert.Nil(t, err)
+		assert.True(t, changed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("leaves out the id of the request", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		id := "a1"
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE account SET "description" = $3, version = version + 1 WHERE`)).
+			WithArgs(id, nil, "bio").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		repository := NewAccountRepository(db)
+		changed, err := repository.ChangeAccountDataByID(context.Background(), &id, AccountRequest{ID: "a2", Description: "bio"}, nil)
+
+		assert.Nil(t, err)
+		assert.False(t, changed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("a quote in a value does not change the role", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		id := "a1"
+		name := `Ana', "role" = 'admin`
+		description := `x', "role" = 'admin`
+		mock.ExpectExec(`^UPDATE account SET "name" = \$3, "description" = \$4, version = version \+ 1 WHERE id = \$1 AND deleted = false AND \(\$2::INT IS NULL OR version = \$2\)$`).
+			WithArgs(id, nil, name, description).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		repository := NewAccountRepository(db)
+		_, err = repository.ChangeAccountDataByID(context.Background(), &id, AccountRequest{Name: name, Description: description}, nil)
+
+		assert.Nil(t, err)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeleteAccountByID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	id := "a1"
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET deleted = true`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE access_token`)).WithArgs(id).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	repository := NewAccountRepository(db)
+	err = repository.DeleteAccountByID(context.Background(), &id)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeAccountRoleByID(t *testing.T) {
+	id, role := "a1", rbac.ROLE_USER
+	entry := audit.NewAudit("a2", audit.ACTION_CHANGE_ROLE, audit.TARGET_ACCOUNT, id, nil)
+
+	t.Run("demotes an admin while another remains", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).WithArgs(rbac.ROLE_ADMIN).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id).AddRow("a2"))
+		mock.ExpectExec(regexp.QuoteMeta(`SET role = $2`)).WithArgs(id, &role).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO audit_log`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		repository := NewAccountRepository(db)
+		changed, err := repository.ChangeAccountRoleByID(context.Background(), &id, &role, entry)
+
+		assert.Nil(t, err)
+		assert.True(t, changed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("never demotes the last admin", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`FOR UPDATE`)).WithArgs(rbac.ROLE_ADMIN).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id))
+		mock.ExpectRollback()
+
+		repository := NewAccountRepository(db)
+		changed, err := repository.ChangeAccountRoleByID(context.Background(), &id, &role, entry)
+
+		assert.Nil(t, err)
+		assert.False(t, changed)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -8,3 +8,8 @@ type AccountRequest struct {
 	Email       string `json:"email,omitempty"`
 	Password    string `json:"password,omitempty"`
 }
+
+type RoleRequest struct {
+	ID   string `json:"id,omitempty"`
+	Role string `json:"role,omitempty"`
+}
@@ -8,4 +8,5 @@ type AccountResponse struct {
 	Email       string `json:"email,omitempty"`
 	CreatedAt   string `json:"created_at,omitempty"`
 	UpdatedAt   string `json:"updated_at,omitempty"`
+	Role        string `json:"role,omitempty"`
 }
@@ -1,40 +1,54 @@
 package service
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"social_network_project/internal/account"
-	"social_network_project/internal/notification"
-	"social_network_project/internal/notification/service"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/event"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/platform/metrics"
+	"social_network_project/internal/rbac"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/crypto"
 	"social_network_project/internal/utils/errors"
 )
 
 type AccountsServiceClient interface {
-	InsertAccount(account *account.Account) error
-	FindAccountByID(id *string) (*account.Account, error)
-	ChangeAccountDataByID(id *string, req account.AccountRequest) error
-	DeleteAccountByID(id *string) (*account.Account, error)
-	CreateFollow(accountID, accountToFollow *string) (*account.Account, error)
-	FindAccountsFollowing(accountID, page *string) ([]interface{}, error)
-	FindAccountsFollowers(accountID, page *string) ([]interface{}, error)
-	DeleteFollow(accountID, accountToFollow *string) (*account.Account, error)
+	InsertAccount(ctx context.Context, account *account.Account) error
+	FindAccountByID(ctx context.Context, id *string) (*account.Account, error)
+	ChangeAccountDataByID(ctx context.Context, id *string, sessionID string, req account.AccountRequest, ifMatch string) (*account.Account, error)
+	DeleteAccountByID(ctx context.Context, id *string) (*account.Account, error)
+	CreateFollow(ctx context.Context, accountID, accountToFollow *string) (*account.Account, error)
+	FindAccountsFollowing(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	FindAccountsFollowers(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	DeleteFollow(ctx context.Context, accountID, accountToFollow *string) (*account.Account, error)
+	ChangeAccountRoleByID(ctx context.Context, actorID, id *string, role string) (*account.Account, error)
 }
 
 type AccountsService struct {
-	repository    account.AccountRepository
-	rabbitControl service.NotificationServiceClient
+	repository account.AccountRepository
+	cache      cache.Invalidator
+	sessions   service2.SessionServiceClient
+	logger     *slog.Logger
 }
 
-func NewAccountsService(accountsRepository account.AccountRepository, rabbitmq service.NotificationServiceClient) AccountsServiceClient {
+// NewAccountsService signs accounts out of sessions when they are deleted or
+// their password changes.
+func NewAccountsService(accountsRepository account.AccountRepository, _cache cache.Invalidator, sessions service2.SessionServiceClient, logger *slog.Logger) AccountsServiceClient {
 	return &AccountsService{
-		repository:    accountsRepository,
-		rabbitControl: rabbitmq,
+		repository: accountsRepository,
+		cache:      _cache,
+		sessions:   sessions,
+		logger:     logger,
 	}
 }
 
-func (s *AccountsService) InsertAccount(account *account.Account) error {
+func (s *AccountsService) InsertAccount(ctx context.Context, account *account.Account) error {
 
-	existUsername, err := s.repository.ExistsAccountByUsername(&account.Username)
+	existUsername, err := s.repository.ExistsAccountByUsername(ctx, &account.Username)
 	if err != nil {
 		return err
 	}
@@ -42,9 +56,9 @@ func (s *AccountsService) InsertAccount(account *account.Account) error {
 		return &errors.ConflictUsernameError{}
 	}
 
-	existEmail, err := s.repository.ExistsAccountByEmail(&account.Email)
+	existEmail, err := s.repository.ExistsAccountByEmail(ctx, &account.Email)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	if *existEmail {
 		return &errors.ConflictEmailError{}
@@ -56,76 +70,135 @@ func (s *AccountsService) InsertAccount(account *account.Account) error {
 	}
 	account.Password = *hashedPassword
 
-	return s.repository.InsertAccount(account)
+	return s.repository.InsertAccount(ctx, account)
 }
 
-func (s *AccountsService) FindAccountByID(id *string) (*account.Account, error) {
-	account, err := s.repository.FindAccountByID(id)
+func (s *AccountsService) FindAccountByID(ctx context.Context, id *string) (*account.Account, error) {
+	account, err := s.repository.FindAccountByID(ctx, id)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
 	}
 
 	return account, nil
 }
 
-func (s *AccountsService) ChangeAccountDataByID(id *string, req account.AccountRequest) error {
+func (s *AccountsService) ChangeAccountDataByID(ctx context.Context, id *string, sessionID string, req account.AccountRequest, ifMatch string) (*account.Account, error) {
+
+	var version *int
+	if ifMatch != "" {
+		current, err := s.repository.FindAccountByID(ctx, id)
+		if err != nil {
+			return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
+		}
+		err = utils.CheckIfMatch(ifMatch, current.ToResponse())
+		if err != nil {
+			return nil, err
+		}
+		version = &current.Version
+	}
 
 	if req.Username != "" {
 		username := req.Username
-		exist, err := s.repository.ExistsAccountByUsername(&username)
+		exist, err := s.repository.ExistsAccountByUsername(ctx, &username)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		if *exist {
-			return &errors.ConflictUsernameError{}
+			return nil, &errors.ConflictUsernameError{}
 		}
 	}
 
 	if req.Email != "" {
 		email := req.Email
-		exist, err := s.repository.ExistsAccountByEmail(&email)
+		exist, err := s.repository.ExistsAccountByEmail(ctx, &email)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if *exist {
-			return &errors.ConflictEmailError{}
+			return nil, &errors.ConflictEmailError{}
 		}
 	}
 
 	if req.Password != "" {
 		hashedPassword, err := crypto.EncryptPassword(req.Password)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		req.Password = *hashedPassword
 	}
 
-	return s.repository.ChangeAccountDataByID(id, req)
+	updated, err := s.repository.ChangeAccountDataByID(ctx, id, req, version)
+	if err != nil {
+		return nil, err
+	}
+	if !updated && version != nil {
+		return nil, &errors.PreconditionFailedError{}
+	}
+
+	s.invalidate(ctx, cache.AccountTag(*id))
+
+	// Whoever signed in with the old password is signed out.
+	if req.Password != "" {
+		err = s.sessions.DeleteOtherSessions(ctx, *id, sessionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	accountUpdated, err := s.repository.FindAccountByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
+	}
+
+	return accountUpdated, nil
 }
 
-func (s *AccountsService) DeleteAccountByID(id *string) (*account.Account, error) {
+func (s *AccountsService) DeleteAccountByID(ctx context.Context, id *string) (*account.Account, error) {
 
-	account, err := s.repository.FindAccountByID(id)
+	account, err := s.repository.FindAccountByID(ctx, id)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
+	}
+
+	tags := []string{
+		cache.AccountTag(*id),
+		cache.FollowsTag(*id),
+		cache.PostsTag(*id),
+		cache.FeedTag(*id),
+		cache.CommentsOfAccountTag(*id),
+	}
+	followerIDs, err := s.repository.FindAccountFollowerIDsByAccountID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, followerID := range followerIDs {
+		tags = append(tags, cache.FeedTag(followerID))
 	}
 
-	err = s.repository.DeleteAccountByID(id)
+	// The sessions are deleted first, so that a failure leaves the account
+	// signed out rather than deleted and signed in. Its personal access tokens
+	// are revoked along with it.
+	err = s.sessions.DeleteAllSessions(ctx, *id)
 	if err != nil {
 		return nil, err
 	}
+	err = s.repository.DeleteAccountByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidate(ctx, tags...)
 
 	return account, nil
 }
 
-func (s *AccountsService) CreateFollow(accountID, accountToFollow *string) (*account.Account, error) {
+func (s *AccountsService) CreateFollow(ctx context.Context, accountID, accountToFollow *string) (*account.Account, error) {
 
-	accountFollow, err := s.repository.FindAccountByID(accountToFollow)
+	accountFollow, err := s.repository.FindAccountByID(ctx, accountToFollow)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
 	}
 
-	exist, err := s.repository.ExistsAccountByID(accountID)
+	exist, err := s.repository.ExistsAccountByID(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -133,7 +206,7 @@ func (s *AccountsService) CreateFollow(accountID, accountToFollow *string) (*acc
 		return nil, &errors.NotFoundAccountIDError{}
 	}
 
-	exist, err = s.repository.ExistsFollowByAccountIDAndAccountFollowedID(accountID, accountToFollow)
+	exist, err = s.repository.ExistsFollowByAccountIDAndAccountFollowedID(ctx, accountID, accountToFollow)
 	if err != nil {
 		return nil, err
 	}
@@ -141,43 +214,52 @@ func (s *AccountsService) CreateFollow(accountID, accountToFollow *string) (*acc
 		return nil, &errors.ConflictAlreadyFollowError{}
 	}
 
-	err = s.repository.InsertAccountFollow(accountID, accountToFollow)
+	message, err := outbox.NewOutbox(ctx, event.New(*accountID, &event.AccountFollowed{
+		AccountID:         *accountID,
+		FollowedAccountID: *accountToFollow,
+	}))
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, err
 	}
 
-	s.rabbitControl.SendMessage(notification.CreateNotificationJson("FollowAccount", *accountToFollow))
+	err = s.repository.InsertAccountFollow(ctx, accountID, accountToFollow, message)
+	if err != nil {
+		return nil, err
+	}
+	metrics.FollowsCreated.Inc()
+	s.invalidate(ctx, followTags(*accountID, *accountToFollow)...)
+
 	return accountFollow, nil
 }
 
-func (s *AccountsService) FindAccountsFollowing(accountID, page *string) ([]interface{}, error) {
+func (s *AccountsService) FindAccountsFollowing(ctx context.Context, accountID, page *string) ([]interface{}, error) {
 
-	listOfAccounts, err := s.repository.FindAccountFollowingByAccountID(accountID, page)
+	listOfAccounts, err := s.repository.FindAccountFollowingByAccountID(ctx, accountID, page)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, err
 	}
 
 	return listOfAccounts, nil
 }
 
-func (s *AccountsService) FindAccountsFollowers(accountID, page *string) ([]interface{}, error) {
+func (s *AccountsService) FindAccountsFollowers(ctx context.Context, accountID, page *string) ([]interface{}, error) {
 
-	listOfAccounts, err := s.repository.FindAccountFollowersByAccountID(accountID, page)
+	listOfAccounts, err := s.repository.FindAccountFollowersByAccountID(ctx, accountID, page)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, err
 	}
 
 	return listOfAccounts, nil
 }
 
-func (s *AccountsService) DeleteFollow(accountID, accountToFollow *string) (*account.Account, error) {
+func (s *AccountsService) DeleteFollow(ctx context.Context, accountID, accountToFollow *string) (*account.Account, error) {
 
-	accountFollow, err := s.repository.FindAccountByID(accountToFollow)
+	accountFollow, err := s.repository.FindAccountByID(ctx, accountToFollow)
 	if err != nil {
-		return nil, &errors.NotFoundAccountIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
 	}
 
-	exist, err := s.repository.ExistsFollowByAccountIDAndAccountFollowedID(accountID, accountToFollow)
+	exist, err := s.repository.ExistsFollowByAccountIDAndAccountFollowedID(ctx, accountID, accountToFollow)
 	if err != nil {
 		return nil, err
 	}
@@ -185,9 +267,77 @@ func (s *AccountsService) DeleteFollow(accountID, accountToFollow *string) (*acc
 		return nil, &errors.UnauthorizedAccountIDError{}
 	}
 
-	err = s.repository.DeleteAccountFollow(accountID, accountToFollow)
+	message, err := outbox.NewOutbox(ctx, event.New(*accountID, &event.AccountUnfollowed{
+		AccountID:           *accountID,
+		UnfollowedAccountID: *accountToFollow,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repository.DeleteAccountFollow(ctx, accountID, accountToFollow, message)
 	if err != nil {
-		return nil, &errors.ConflictAlreadyUnfollowError{}
+		return nil, err
 	}
+	s.invalidate(ctx, followTags(*accountID, *accountToFollow)...)
 	return accountFollow, nil
 }
+
+// ChangeAccountRoleByID gives role to the account id on behalf of actorID,
+// who must be allowed to assign roles and cannot change their own. The last
+// admin is never demoted, which the repository checks in the transaction
+// changing the role.
+func (s *AccountsService) ChangeAccountRoleByID(ctx context.Context, actorID, id *string, role string) (*account.Account, error) {
+
+	actor, err := s.repository.FindAccountByID(ctx, actorID)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
+	}
+	if !rbac.Can(actor.Role, rbac.PERMISSION_ASSIGN_ROLES) {
+		return nil, &errors.ForbiddenPermissionError{Path: string(rbac.PERMISSION_ASSIGN_ROLES)}
+	}
+	if *actorID == *id {
+		return nil, &errors.ConflictOwnRoleError{}
+	}
+
+	accountToChange, err := s.repository.FindAccountByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.NotFoundAccountIDError{})
+	}
+	if accountToChange.Role == role {
+		return accountToChange, nil
+	}
+
+	entry := audit.NewAudit(*actorID, audit.ACTION_CHANGE_ROLE, audit.TARGET_ACCOUNT, *id, map[string]string{
+		"from": accountToChange.Role,
+		"to":   role,
+	})
+	changed, err := s.repository.ChangeAccountRoleByID(ctx, id, &role, entry)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, &errors.ConflictLastAdminError{}
+	}
+	s.invalidate(ctx, cache.AccountTag(*id))
+
+	accountToChange.Role = role
+	return accountToChange, nil
+}
+
+func (s *AccountsService) invalidate(ctx context.Context, tags ...string) {
+	err := s.cache.Invalidate(ctx, tags...)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "invalidating cache", "tags", tags, "error", err)
+	}
+}
+
+// followTags lists the tags changed when accountID follows or unfollows
+// accountFollowed: both follow lists and the feed of accountID.
+func followTags(accountID, accountFollowed string) []string {
+	return []string{
+		cache.FollowsTag(accountID),
+		cache.FollowsTag(accountFollowed),
+		cache.FeedTag(accountID),
+	}
+}
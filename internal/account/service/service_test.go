@@ -1,43 +1,245 @@
 package service
 
 import (
-	"github.com/golang-jwt/jwt/v4"
+	"context"
+	"database/sql"
+	errors2 "errors"
 	"github.com/stretchr/testify/assert"
-	"os"
-	"social_network_project/internal/auth"
+	"social_network_project/internal/account"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/session"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/utils"
+	"social_network_project/internal/utils/errors"
 	"testing"
 	"time"
 )
 
-func TestAccountsControllerStruct_CreateToken(t *testing.T) {
+type accountRepositoryFake struct {
+	account.AccountRepository
+	accounts map[string]*account.Account
+	entries  []*audit.Audit
+	// err fails every lookup, as a database that cannot be reached does.
+	err error
+	// raced is the number of changes landing between the If-Match check and
+	// the next change.
+	raced int
+	// demoted are the admins demoted by another change landing between the
+	// role check and the next role change.
+	demoted []string
+}
+
+func (a *accountRepositoryFake) FindAccountByID(ctx context.Context, id *string) (*account.Account, error) {
+	if a.err != nil {
+		return nil, a.err
+	}
+	found, ok := a.accounts[*id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *found
+	return &copied, nil
+}
 
-	id := "6c08496b-b721-4e06-b0b7-1905524c9da2"
+func (a *accountRepositoryFake) ChangeAccountDataByID(ctx context.Context, id *string, req account.AccountRequest, version *int) (bool, error) {
+	found := a.accounts[*id]
+	found.Version += a.raced
+	if version != nil && *version != found.Version {
+		return false, nil
+	}
+	found.Name = req.Name
+	found.Version++
+	return true, nil
+}
 
-	tokenExpected := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
-	})
+func (a *accountRepositoryFake) FindAccountFollowerIDsByAccountID(ctx context.Context, id *string) ([]string, error) {
+	return nil, nil
+}
+
+func (a *accountRepositoryFake) DeleteAccountByID(ctx context.Context, id *string) error {
+	delete(a.accounts, *id)
+	return nil
+}
+
+func (a *accountRepositoryFake) ChangeAccountRoleByID(ctx context.Context, id, role *string, entry *audit.Audit) (bool, error) {
+	for _, demoted := range a.demoted {
+		a.accounts[demoted].Role = rbac.ROLE_USER
+	}
+	if *role != rbac.ROLE_ADMIN {
+		others := 0
+		for _, found := range a.accounts {
+			if found.ID != *id && found.Role == rbac.ROLE_ADMIN {
+				others++
+			}
+		}
+		if others == 0 {
+			return false, nil
+		}
+	}
+	a.accounts[*id].Role = *role
+	a.entries = append(a.entries, entry)
+	return true, nil
+}
 
-	tokenString, err := tokenExpected.SignedString([]byte(os.Getenv("JWT_TOKEN_KEY")))
+func newSessions() service2.SessionServiceClient {
+	return service2.NewSessionService(session.NewSessionRepositoryMemory(time.Now), time.Hour)
+}
+
+// signIn opens a session of the account and returns its id.
+func signIn(t *testing.T, sessions service2.SessionServiceClient, accountID string) string {
+	created, err := sessions.CreateSession(context.Background(), accountID, "10.0.0.1", "curl/7.84.0")
 	assert.Nil(t, err)
+	return created.ID
+}
+
+type invalidatorFake struct{}
+
+func (i invalidatorFake) Invalidate(ctx context.Context, tags ...string) error {
+	return nil
+}
+
+func TestAccountsService_FindAccountByID(t *testing.T) {
+	user := "user"
+
+	t.Run("a missing account is not found", func(t *testing.T) {
+		accountsService := NewAccountsService(&accountRepositoryFake{accounts: map[string]*account.Account{}}, invalidatorFake{}, newSessions(), logging.Discard())
+
+		_, err := accountsService.FindAccountByID(context.Background(), &user)
+		assert.IsType(t, &errors.NotFoundAccountIDError{}, err)
+	})
+
+	t.Run("a failing database is not answered as not found", func(t *testing.T) {
+		failure := errors2.New("connection refused")
+		accountsService := NewAccountsService(&accountRepositoryFake{err: failure}, invalidatorFake{}, newSessions(), logging.Discard())
+
+		_, err := accountsService.FindAccountByID(context.Background(), &user)
+		assert.Equal(t, failure, err)
+	})
+}
+
+func TestAccountsService_ChangeAccountRoleByID(t *testing.T) {
+
+	newService := func() (*accountRepositoryFake, AccountsServiceClient) {
+		accounts := &accountRepositoryFake{accounts: map[string]*account.Account{
+			"admin":     {ID: "admin", Role: rbac.ROLE_ADMIN},
+			"moderator": {ID: "moderator", Role: rbac.ROLE_MODERATOR},
+			"user":      {ID: "user", Role: rbac.ROLE_USER},
+		}}
+		return accounts, NewAccountsService(accounts, invalidatorFake{}, newSessions(), logging.Discard())
+	}
+	admin, moderator, user := "admin", "moderator", "user"
+
+	t.Run("admin assigns a role with an audit entry", func(t *testing.T) {
+		accounts, accountsService := newService()
+
+		changed, err := accountsService.ChangeAccountRoleByID(context.Background(), &admin, &user, rbac.ROLE_MODERATOR)
+		assert.Nil(t, err)
+		assert.Equal(t, rbac.ROLE_MODERATOR, changed.Role)
+		assert.Len(t, accounts.entries, 1)
+		assert.Equal(t, audit.ACTION_CHANGE_ROLE, accounts.entries[0].Action)
+		assert.Equal(t, map[string]string{"from": rbac.ROLE_USER, "to": rbac.ROLE_MODERATOR}, accounts.entries[0].Details)
+	})
+
+	t.Run("moderator cannot assign roles", func(t *testing.T) {
+		accounts, accountsService := newService()
+
+		_, err := accountsService.ChangeAccountRoleByID(context.Background(), &moderator, &user, rbac.ROLE_ADMIN)
+		assert.IsType(t, &errors.ForbiddenPermissionError{}, err)
+		assert.Empty(t, accounts.entries)
+	})
+
+	t.Run("admin cannot change their own role", func(t *testing.T) {
+		_, accountsService := newService()
 
-	tokenStructExpected := auth.AuthResponse{
-		Token: tokenString,
+		_, err := accountsService.ChangeAccountRoleByID(context.Background(), &admin, &admin, rbac.ROLE_USER)
+		assert.IsType(t, &errors.ConflictOwnRoleError{}, err)
+	})
+
+	t.Run("never demotes the last admin", func(t *testing.T) {
+		accounts, accountsService := newService()
+		accounts.accounts["moderator"].Role = rbac.ROLE_ADMIN
+		accounts.demoted = []string{admin}
+
+		_, err := accountsService.ChangeAccountRoleByID(context.Background(), &admin, &moderator, rbac.ROLE_USER)
+		assert.IsType(t, &errors.ConflictLastAdminError{}, err)
+		assert.Equal(t, rbac.ROLE_ADMIN, accounts.accounts["moderator"].Role)
+		assert.Empty(t, accounts.entries)
+	})
+}
+
+func TestAccountsService_ChangeAccountDataByID(t *testing.T) {
+
+	newService := func() (*accountRepositoryFake, AccountsServiceClient, service2.SessionServiceClient, string) {
+		current := &account.Account{ID: "user", Name: "first"}
+		etag, err := utils.ETagOf(current.ToResponse())
+		assert.Nil(t, err)
+		accounts := &accountRepositoryFake{accounts: map[string]*account.Account{"user": current}}
+		sessions := newSessions()
+		return accounts, NewAccountsService(accounts, invalidatorFake{}, sessions, logging.Discard()), sessions, etag
 	}
+	user := "user"
+
+	t.Run("changes the account matching If-Match", func(t *testing.T) {
+		accounts, accountsService, _, etag := newService()
 
-	tokenDecodeExpected := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenStructExpected.Token, tokenDecodeExpected, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
+		changed, err := accountsService.ChangeAccountDataByID(context.Background(), &user, "", account.AccountRequest{Name: "second"}, etag)
+		assert.Nil(t, err)
+		assert.Equal(t, "second", changed.Name)
+		assert.Equal(t, 1, accounts.accounts["user"].Version)
 	})
-	assert.Nil(t, err)
 
-	tokenStruct, err := CreateTokenByID(id)
-	assert.Nil(t, err)
+	t.Run("refuses a change racing another one after the check", func(t *testing.T) {
+		accounts, accountsService, _, etag := newService()
+		accounts.raced = 1
+
+		_, err := accountsService.ChangeAccountDataByID(context.Background(), &user, "", account.AccountRequest{Name: "second"}, etag)
+		assert.IsType(t, &errors.PreconditionFailedError{}, err)
+		assert.Equal(t, "first", accounts.accounts["user"].Name)
+	})
 
-	tokenDecode := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenStruct.Token, tokenDecode, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
+	t.Run("a new password signs out the other sessions", func(t *testing.T) {
+		_, accountsService, sessions, _ := newService()
+		current := signIn(t, sessions, user)
+		signIn(t, sessions, user)
+
+		_, err := accountsService.ChangeAccountDataByID(context.Background(), &user, current, account.AccountRequest{Password: "n3w-Password"}, "")
+		assert.Nil(t, err)
+
+		found, err := sessions.FindSessions(context.Background(), user, current)
+		assert.Nil(t, err)
+		assert.Len(t, found, 1)
+		assert.Equal(t, current, found[0].ID)
 	})
 
-	assert.Equal(t, tokenDecodeExpected["id"], tokenDecode["id"])
+	t.Run("other changes keep the sessions", func(t *testing.T) {
+		_, accountsService, sessions, _ := newService()
+		current := signIn(t, sessions, user)
+		signIn(t, sessions, user)
+
+		_, err := accountsService.ChangeAccountDataByID(context.Background(), &user, current, account.AccountRequest{Name: "second"}, "")
+		assert.Nil(t, err)
+
+		found, err := sessions.FindSessions(context.Background(), user, current)
+		assert.Nil(t, err)
+		assert.Len(t, found, 2)
+	})
+}
+
+func TestAccountsService_DeleteAccountByID(t *testing.T) {
+	user := "user"
+	accounts := &accountRepositoryFake{accounts: map[string]*account.Account{"user": {ID: "user"}}}
+	sessions := newSessions()
+	accountsService := NewAccountsService(accounts, invalidatorFake{}, sessions, logging.Discard())
+	current := signIn(t, sessions, user)
+	signIn(t, sessions, user)
+
+	_, err := accountsService.DeleteAccountByID(context.Background(), &user)
+	assert.Nil(t, err)
+
+	found, err := sessions.FindSessions(context.Background(), user, current)
+	assert.Nil(t, err)
+	assert.Empty(t, found)
+	assert.Empty(t, accounts.accounts)
 }
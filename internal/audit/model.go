@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	ACTION_REMOVE_POST    = "post.removed"
+	ACTION_REMOVE_COMMENT = "comment.removed"
+	ACTION_CHANGE_ROLE    = "account.role_changed"
+)
+
+const (
+	TARGET_POST    = "post"
+	TARGET_COMMENT = "comment"
+	TARGET_ACCOUNT = "account"
+)
+
+// Audit records an action taken by staff on something that is not theirs.
+type Audit struct {
+	ID         string
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Details    map[string]string
+	CreatedAt  time.Time
+}
+
+func NewAudit(actorID, action, targetType, targetID string, details map[string]string) *Audit {
+	return &Audit{
+		ID:         uuid.New().String(),
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		CreatedAt:  time.Now().UTC(),
+	}
+}
+
+func (a *Audit) ToResponse() AuditResponse {
+	return AuditResponse{
+		ID:         a.ID,
+		ActorID:    a.ActorID,
+		Action:     a.Action,
+		TargetType: a.TargetType,
+		TargetID:   a.TargetID,
+		Details:    a.Details,
+		CreatedAt:  a.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func encodeDetails(details map[string]string) (string, error) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	data, err := json.Marshal(details)
+	return string(data), err
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"social_network_project/internal/platform/database/postgresql"
+)
+
+type AuditRepository interface {
+	FindAudits(ctx context.Context, page *string) ([]interface{}, error)
+}
+
+type AuditRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewAuditRepository(postgresDB *sql.DB) AuditRepository {
+	return &AuditRepositoryStruct{postgresDB}
+}
+
+// InsertAudit writes the entry inside the caller's transaction, so an action
+// is never taken without leaving its trail.
+func InsertAudit(ctx context.Context, tx *sql.Tx, audit *Audit) error {
+	details, err := encodeDetails(audit.Details)
+	if err != nil {
+		return err
+	}
+
+	sqlStatement := `
+		INSERT INTO audit_log (id, actor_id, action, target_type, target_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, audit.ID, audit.ActorID, audit.Action, audit.TargetType, audit.TargetID,
+		details, audit.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *AuditRepositoryStruct) FindAudits(ctx context.Context, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "audit", "FindAudits")
+	defer end()
+	sqlStatement := `
+		SELECT id, actor_id, action, target_type, target_id, details, created_at
+		FROM audit_log
+		Order By created_at DESC
+		OFFSET ($1 - 1) * 10
+		FETCH NEXT 10 ROWS ONLY;`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, page)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []interface{}{}
+	for rows.Next() {
+		var audit Audit
+		var details string
+		err = rows.Scan(
+			&audit.ID,
+			&audit.ActorID,
+			&audit.Action,
+			&audit.TargetType,
+			&audit.TargetID,
+			&details,
+			&audit.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(details), &audit.Details)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, audit.ToResponse())
+	}
+
+	return list, rows.Err()
+}
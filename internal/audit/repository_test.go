@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAuditRepository(t *testing.T) {
+
+	t.Run("inserts the entry in the transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		entry := NewAudit("a1", ACTION_REMOVE_POST, TARGET_POST, "p1", map[string]string{"account_id": "a2"})
+
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO audit_log").
+			WithArgs(entry.ID, "a1", ACTION_REMOVE_POST, TARGET_POST, "p1", `{"account_id":"a2"}`, entry.CreatedAt).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		tx, err := db.Begin()
+		assert.Nil(t, err)
+		assert.Nil(t, InsertAudit(context.Background(), tx, entry))
+		assert.Nil(t, tx.Commit())
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("finds entries with their details", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		createdAt := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		mock.ExpectQuery("SELECT id, actor_id, action, target_type, target_id, details, created_at").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "action", "target_type", "target_id", "details", "created_at"}).
+				AddRow("e1", "a1", ACTION_CHANGE_ROLE, TARGET_ACCOUNT, "a2", `{"from":"user","to":"moderator"}`, createdAt))
+
+		page := "1"
+		audits, err := NewAuditRepository(db).FindAudits(context.Background(), &page)
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{AuditResponse{
+			ID:         "e1",
+			ActorID:    "a1",
+			Action:     ACTION_CHANGE_ROLE,
+			TargetType: TARGET_ACCOUNT,
+			TargetID:   "a2",
+			Details:    map[string]string{"from": "user", "to": "moderator"},
+			CreatedAt:  "2022-08-01T12:00:00Z",
+		}}, audits)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,11 @@
+package audit
+
+type AuditResponse struct {
+	ID         string            `json:"id"`
+	ActorID    string            `json:"actor_id"`
+	Action     string            `json:"action"`
+	TargetType string            `json:"target_type"`
+	TargetID   string            `json:"target_id"`
+	Details    map[string]string `json:"details"`
+	CreatedAt  string            `json:"created_at"`
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"social_network_project/internal/audit"
+)
+
+type AuditServiceClient interface {
+	FindAudits(ctx context.Context, page *string) ([]interface{}, error)
+}
+
+type AuditService struct {
+	repository audit.AuditRepository
+}
+
+func NewAuditService(_repository audit.AuditRepository) AuditServiceClient {
+	return &AuditService{
+		repository: _repository,
+	}
+}
+
+func (a *AuditService) FindAudits(ctx context.Context, page *string) ([]interface{}, error) {
+	return a.repository.FindAudits(ctx, page)
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"social_network_project/internal/account"
+	"social_network_project/internal/platform/database/postgresql"
+)
+
+type IdentityRepository interface {
+	FindAccountIDByIdentity(ctx context.Context, provider, subject *string) (*string, error)
+	InsertIdentity(ctx context.Context, identity *Identity) error
+	InsertAccountWithIdentity(ctx context.Context, account *account.Account, identity *Identity) error
+}
+
+type IdentityRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewIdentityRepository(postgresDB *sql.DB) IdentityRepository {
+	return &IdentityRepositoryStruct{postgresDB}
+}
+
+// FindAccountIDByIdentity returns nil when the identity is linked to no
+// account, or to a deleted one.
+func (p *IdentityRepositoryStruct) FindAccountIDByIdentity(ctx context.Context, provider, subject *string) (*string, error) {
+	ctx, end := postgresql.Observe(ctx, "identity", "FindAccountIDByIdentity")
+	defer end()
+	sqlStatement := `
+		SELECT account.id
+		FROM account_identity
+		JOIN account ON account.id = account_identity.account_id
+		WHERE account_identity.provider = $1
+		AND account_identity.subject = $2
+		AND account.deleted = false`
+
+	var id string
+	err := p.Db.QueryRowContext(ctx, sqlStatement, provider, subject).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &id, nil
+}
+
+// InsertIdentity links the identity, moving it from a deleted account it was
+// linked to.
+func (p *IdentityRepositoryStruct) InsertIdentity(ctx context.Context, identity *Identity) error {
+	ctx, end := postgresql.Observe(ctx, "identity", "InsertIdentity")
+	defer end()
+	return insertIdentity(ctx, p.Db, identity)
+}
+
+// InsertAccountWithIdentity creates the account of a first sign in together
+// with its identity.
+func (p *IdentityRepositoryStruct) InsertAccountWithIdentity(ctx context.Context, account *account.Account, identity *Identity) error {
+	ctx, end := postgresql.Observe(ctx, "identity", "InsertAccountWithIdentity")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+		INSERT INTO account (id, username, name, description, email, password, created_at, updated_at, deleted)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, account.ID, account.Username, account.Name, account.Description,
+		account.Email, account.Password, account.CreatedAt, account.UpdatedAt, account.Deleted)
+	if err != nil {
+		return err
+	}
+
+	err = insertIdentity(ctx, tx, identity)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertIdentity(ctx context.Context, db execer, identity *Identity) error {
+	sqlStatement := `
+		INSERT INTO account_identity (provider, subject, account_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, subject) DO UPDATE
+		SET account_id = $3, email = $4, created_at = $5`
+
+	_, err := db.ExecContext(ctx, sqlStatement, identity.Provider, identity.Subject, identity.AccountID, identity.Email, identity.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
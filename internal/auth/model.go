@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Attempt is one sign in. AccountID is empty when the email is unknown.
+type Attempt struct {
+	ID        string
+	AccountID sql.NullString
+	IP        string
+	Succeeded bool
+	CreatedAt time.Time
+}
+
+// Failures are the failed sign ins of an account or an IP since its last
+// successful one, its last lockout or unlock.
+type Failures struct {
+	Count  int
+	LastAt time.Time
+}
+
+type Lockout struct {
+	AccountID       string
+	LockedUntil     time.Time
+	UnlockTokenHash string
+	CreatedAt       time.Time
+}
+
+// TwoFactor is the TOTP secret of an account. It is enrolled disabled and
+// enabled once a first code confirms the authenticator app holds it.
+type TwoFactor struct {
+	AccountID    string
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// Identity links the account of an OpenID Connect provider, named by its
+// subject, to an account.
+type Identity struct {
+	Provider  string
+	Subject   string
+	AccountID string
+	Email     string
+	CreatedAt time.Time
+}
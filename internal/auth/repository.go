@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/database/postgresql"
+	"time"
+)
+
+type AttemptRepository interface {
+	InsertAttempt(ctx context.Context, attempt *Attempt) error
+	FindFailuresByAccountID(ctx context.Context, accountID *string, since time.Time) (*Failures, error)
+	FindFailuresByIP(ctx context.Context, ip *string, since time.Time) (*Failures, error)
+	FindLockoutByAccountID(ctx context.Context, accountID *string) (*Lockout, error)
+	InsertLockout(ctx context.Context, lockout *Lockout, message *outbox.Outbox) error
+	UnlockByTokenHash(ctx context.Context, tokenHash *string, at time.Time) (*string, error)
+}
+
+type AttemptRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewAttemptRepository(postgresDB *sql.DB) AttemptRepository {
+	return &AttemptRepositoryStruct{postgresDB}
+}
+
+func (p *AttemptRepositoryStruct) InsertAttempt(ctx context.Context, attempt *Attempt) error {
+	ctx, end := postgresql.Observe(ctx, "attempt", "InsertAttempt")
+	defer end()
+	sqlStatement := `
+		INSERT INTO login_attempt (id, account_id, ip, succeeded, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, attempt.ID, attempt.AccountID, attempt.IP, attempt.Succeeded, attempt.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *AttemptRepositoryStruct) FindFailuresByAccountID(ctx context.Context, accountID *string, since time.Time) (*Failures, error) {
+	ctx, end := postgresql.Observe(ctx, "attempt", "FindFailuresByAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT COUNT(*), COALESCE(MAX(created_at), $2)
+		FROM login_attempt
+		WHERE account_id = $1
+		AND succeeded = false
+		AND created_at > GREATEST(
+			$2,
+			(SELECT MAX(created_at) FROM login_attempt WHERE account_id = $1 AND succeeded = true),
+			(SELECT GREATEST(created_at, unlocked_at) FROM account_lockout WHERE account_id = $1)
+		)`
+
+	return p.findFailures(ctx, sqlStatement, accountID, since)
+}
+
+// FindFailuresByIP counts every failure of the IP since, whatever succeeded in
+// between: a success from the IP only proves it knows the password of one
+// account, maybe its own.
+func (p *AttemptRepositoryStruct) FindFailuresByIP(ctx context.Context, ip *string, since time.Time) (*Failures, error) {
+	ctx, end := postgresql.Observe(ctx, "attempt", "FindFailuresByIP")
+	defer end()
+	sqlStatement := `
+		SELECT COUNT(*), COALESCE(MAX(created_at), $2)
+		FROM login_attempt
+		WHERE ip = $1
+		AND succeeded = false
+		AND created_at > $2`
+
+	return p.findFailures(ctx, sqlStatement, ip, since)
+}
+
+func (p *AttemptRepositoryStruct) findFailures(ctx context.Context, sqlStatement string, arg *string, since time.Time) (*Failures, error) {
+	var failures Failures
+	err := p.Db.QueryRowContext(ctx, sqlStatement, arg, since).Scan(&failures.Count, &failures.LastAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failures, nil
+}
+
+// FindLockoutByAccountID returns nil when the account was never locked.
+func (p *AttemptRepositoryStruct) FindLockoutByAccountID(ctx context.Context, accountID *string) (*Lockout, error) {
+	ctx, end := postgresql.Observe(ctx, "attempt", "FindLockoutByAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT account_id, locked_until, COALESCE(unlock_token_hash, ''), created_at
+		FROM account_lockout
+		WHERE account_id = $1`
+
+	var lockout Lockout
+	err := p.Db.QueryRowContext(ctx, sqlStatement, accountID).Scan(&lockout.AccountID, &lockout.LockedUntil, &lockout.UnlockTokenHash, &lockout.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &lockout, nil
+}
+
+// InsertLockout locks the account, replacing its previous lockout, and records
+// the message notifying the owner in the same transaction. The lockout holds
+// no unlock code until the notice is sent.
+func (p *AttemptRepositoryStruct) InsertLockout(ctx context.Context, lockout *Lockout, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "attempt", "InsertLockout")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+		INSERT INTO account_lockout (account_id, locked_until, unlock_token_hash, created_at, unlocked_at)
+		VALUES ($1, $2, NULL, $3, NULL)
+		ON CONFLICT (account_id) DO UPDATE
+		SET locked_until = $2, unlock_token_hash = NULL, created_at = $3, unlocked_at = NULL`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, lockout.AccountID, lockout.LockedUntil, lockout.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnlockByTokenHash ends the lockout holding the unlock token and returns its
+// account, or nil when no current lockout holds it. The token is cleared so it
+// can only be used once.
+func (p *AttemptRepositoryStruct) UnlockByTokenHash(ctx context.Context, tokenHash *string, at time.Time) (*string, error) {
+	ctx, end := postgresql.Observe(ctx, "attempt", "UnlockByTokenHash")
+	defer end()
+	sqlStatement := `
+		UPDATE account_lockout
+		SET locked_until = $2, unlocked_at = $2, unlock_token_hash = NULL
+		WHERE unlock_token_hash = $1
+		AND locked_until > $2
+		RETURNING account_id`
+
+	var accountID string
+	err := p.Db.QueryRowContext(ctx, sqlStatement, tokenHash, at).Scan(&accountID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &accountID, nil
+}
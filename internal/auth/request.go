@@ -3,4 +3,13 @@ package auth
 type AuthRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
-}
\ No newline at end of file
+}
+type UnlockRequest struct {
+	Token string `json:"token"`
+}
+
+type TwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Password       string `json:"password"`
+	Code           string `json:"code"`
+}
@@ -1,5 +1,17 @@
 package auth
 
+// AuthResponse holds the token, or the challenge token to send with a
+// two-factor code when the account has two-factor authentication enabled.
 type AuthResponse struct {
-	Token string `json:"token"`
-}
\ No newline at end of file
+	Token          string `json:"token,omitempty"`
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+type EnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"math/rand"
+	"regexp"
+	"social_network_project/internal/account"
+	"social_network_project/internal/auth"
+	"social_network_project/internal/platform/oidc"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"strings"
+	"time"
+)
+
+const oidcPurpose = "oidc"
+
+// OIDCLoginTTL is how long a sign in started with a provider may take.
+const OIDCLoginTTL = 10 * time.Minute
+
+var notUsername = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// StartOIDC starts a sign in with the provider. The user agent is sent to
+// authURL and must send loginToken back with the callback, which binds the
+// callback to the state, nonce and PKCE verifier of this sign in. A signed in
+// accountID links the provider to its account; it is empty otherwise.
+func (s *AuthService) StartOIDC(ctx context.Context, providerName, accountID string) (authURL string, loginToken string, err error) {
+
+	provider, found := s.providers[providerName]
+	if !found {
+		return "", "", &errors.NotFoundProviderError{}
+	}
+
+	state, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err = provider.AuthCodeURL(state, nonce, verifier)
+	if err != nil {
+		s.logger.WarnContext(ctx, "starting oidc login", "provider", providerName, "error", err)
+		return "", "", &errors.InvalidOIDCLoginError{}
+	}
+
+	loginClaims := jwt.MapClaims{
+		"purpose":  oidcPurpose,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+	}
+	if accountID != "" {
+		loginClaims["link"] = accountID
+	}
+	loginToken, err = s.keys.IssueToken(providerName, OIDCLoginTTL, loginClaims)
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, loginToken, nil
+}
+
+// FinishOIDC ends a sign in with the provider with the code it redirected
+// back with. The identity signs in to the account it is linked to, else to
+// the account linking it, else to an account created for it. Accounts with
+// two-factor authentication get a challenge token, as with CreateToken.
+func (s *AuthService) FinishOIDC(ctx context.Context, providerName, loginToken, state, code, ip, userAgent string) (*auth.AuthResponse, error) {
+
+	provider, found := s.providers[providerName]
+	if !found {
+		return nil, &errors.NotFoundProviderError{}
+	}
+
+	claims, err := s.keys.Parse(loginToken)
+	if err != nil || claims["purpose"] != oidcPurpose || claims["sub"] != providerName {
+		return nil, &errors.InvalidOIDCLoginError{}
+	}
+	loginState, _ := claims["state"].(string)
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	linkTo, _ := claims["link"].(string)
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(loginState)) != 1 {
+		return nil, &errors.InvalidOIDCLoginError{}
+	}
+
+	idToken, err := provider.Exchange(code, verifier)
+	if err != nil {
+		s.logger.WarnContext(ctx, "exchanging oidc code", "provider", providerName, "error", err)
+		return nil, &errors.InvalidOIDCLoginError{}
+	}
+	identity, err := provider.VerifyIDToken(idToken, nonce)
+	if err != nil {
+		s.logger.WarnContext(ctx, "verifying oidc id token", "provider", providerName, "error", err)
+		return nil, &errors.InvalidOIDCLoginError{}
+	}
+
+	now := s.now().UTC()
+	id, err := s.linkedAccount(ctx, providerName, identity, linkTo, now)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.checkAccount(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, &id)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		return s.createChallengeToken(id)
+	}
+
+	return s.signIn(ctx, id, ip, userAgent, now)
+}
+
+// linkedAccount returns the account the identity signs in to, linking it to
+// linkTo, when an account links it, or to a new account on its first sign in.
+// Local accounts do not prove they own their email, so the identity is never
+// linked to an account through its email: whoever registered it first would
+// get the identity.
+func (s *AuthService) linkedAccount(ctx context.Context, providerName string, claims *oidc.Claims, linkTo string, now time.Time) (string, error) {
+	id, err := s.identities.FindAccountIDByIdentity(ctx, &providerName, &claims.Subject)
+	if err != nil {
+		return "", err
+	}
+	if id != nil {
+		if linkTo != "" && *id != linkTo {
+			return "", &errors.ConflictIdentityError{}
+		}
+		return *id, nil
+	}
+
+	identity := &auth.Identity{
+		Provider:  providerName,
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		CreatedAt: now,
+	}
+	if linkTo != "" {
+		identity.AccountID = linkTo
+		return linkTo, s.identities.InsertIdentity(ctx, identity)
+	}
+
+	// An email the provider has not verified could belong to someone else,
+	// so it neither links nor creates an account.
+	if !claims.EmailVerified || claims.Email == "" {
+		return "", &errors.UnverifiedEmailError{}
+	}
+
+	existEmail, err := s.repository.ExistsAccountByEmail(ctx, &claims.Email)
+	if err != nil {
+		return "", err
+	}
+	if *existEmail {
+		return "", &errors.ConflictIdentityError{}
+	}
+
+	newAccount, err := s.newAccount(ctx, claims, now)
+	if err != nil {
+		return "", err
+	}
+	identity.AccountID = newAccount.ID
+	return newAccount.ID, s.identities.InsertAccountWithIdentity(ctx, newAccount, identity)
+}
+
+// newAccount is the account of an identity signing in for the first time. Its
+// username comes from the identity and its password is random, so it signs in
+// through the provider only.
+func (s *AuthService) newAccount(ctx context.Context, claims *oidc.Claims, now time.Time) (*account.Account, error) {
+	username, err := s.freeUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	name := []rune(strings.TrimSpace(claims.Name))
+	if len(name) > 24 {
+		name = name[:24]
+	}
+	if len(name) < 3 {
+		name = []rune(username)
+	}
+
+	password, err := crypto.NewToken(32)
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, err := crypto.EncryptPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account.Account{
+		ID:          uuid.New().String(),
+		Username:    username,
+		Name:        string(name),
+		Description: "",
+		Email:       claims.Email,
+		Password:    *passwordHash,
+		CreatedAt:   now.Format("2006-01-02"),
+		UpdatedAt:   now.Format("2006-01-02"),
+	}, nil
+}
+
+// freeUsername derives a username from the preferred username or the email of
+// the identity, adding digits while it is taken.
+func (s *AuthService) freeUsername(ctx context.Context, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.Split(claims.Email, "@")[0]
+	}
+	base = notUsername.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) > 12 {
+		base = base[:12]
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+
+	username := base
+	for i := 0; i < 10; i++ {
+		exist, err := s.repository.ExistsAccountByUsername(ctx, &username)
+		if err != nil {
+			return "", err
+		}
+		if !*exist {
+			return username, nil
+		}
+
+		suffix := fmt.Sprintf("%04d", rand.Intn(10000))
+		if len(base) > 12-len(suffix) {
+			base = base[:12-len(suffix)]
+		}
+		username = base + suffix
+	}
+
+	return "", fmt.Errorf("no free username for %q", claims.Email)
+}
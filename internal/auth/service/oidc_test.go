@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"social_network_project/internal/account"
+	"social_network_project/internal/auth"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/platform/oidc"
+	"social_network_project/internal/platform/oidc/oidctest"
+	"social_network_project/internal/utils/errors"
+	"testing"
+	"time"
+)
+
+type identityRepositoryFake struct {
+	identities map[string]*auth.Identity
+	accounts   []*account.Account
+}
+
+func (r *identityRepositoryFake) FindAccountIDByIdentity(ctx context.Context, provider, subject *string) (*string, error) {
+	identity, found := r.identities[*provider+"|"+*subject]
+	if !found {
+		return nil, nil
+	}
+	return &identity.AccountID, nil
+}
+
+func (r *identityRepositoryFake) InsertIdentity(ctx context.Context, identity *auth.Identity) error {
+	if r.identities == nil {
+		r.identities = map[string]*auth.Identity{}
+	}
+	r.identities[identity.Provider+"|"+identity.Subject] = identity
+	return nil
+}
+
+func (r *identityRepositoryFake) InsertAccountWithIdentity(ctx context.Context, account *account.Account, identity *auth.Identity) error {
+	r.accounts = append(r.accounts, account)
+	return r.InsertIdentity(ctx, identity)
+}
+
+func TestAuthService_OIDC(t *testing.T) {
+	server := oidctest.NewServer(t)
+	id := "6c08496b-b721-4e06-b0b7-1905524c9da2"
+
+	newService := func() (*AuthService, *identityRepositoryFake, *twoFactorRepositoryFake) {
+		identities := &identityRepositoryFake{}
+		twoFactors := &twoFactorRepositoryFake{}
+		authService := NewAuthService(&accountRepositoryFake{
+			id:       id,
+			username: "ana",
+			email:    "ana@mail.com",
+		}, &attemptRepositoryFake{}, twoFactors, identities, newSessions(), newKeys(t), map[string]*oidc.Provider{
+			"fake": oidc.NewProvider(oidc.Config{
+				Name:         "fake",
+				Issuer:       server.URL,
+				ClientID:     oidctest.ClientID,
+				ClientSecret: oidctest.ClientSecret,
+				RedirectURL:  "http://localhost:8080/auth/oidc/fake/callback",
+			}, http.DefaultClient),
+		}, LoginPolicy{}, "social_network", logging.Discard()).(*AuthService)
+		return authService, identities, twoFactors
+	}
+
+	// signInLinking signs in with the fake provider as user, from the start
+	// of the sign in to its callback, started by the signed in accountID to
+	// link the provider to its account unless it is empty.
+	signInLinking := func(t *testing.T, authService *AuthService, user oidctest.User, accountID string) (*auth.AuthResponse, error) {
+		server.User = user
+		authURL, loginToken, err := authService.StartOIDC(context.Background(), "fake", accountID)
+		assert.Nil(t, err)
+
+		code, state := server.Authorize(t, authURL)
+		return authService.FinishOIDC(context.Background(), "fake", loginToken, state, code, "10.0.0.1", "curl/7.84.0")
+	}
+
+	// signIn signs in with the fake provider as user.
+	signIn := func(t *testing.T, authService *AuthService, user oidctest.User) (*auth.AuthResponse, error) {
+		return signInLinking(t, authService, user, "")
+	}
+
+	accountOf := func(t *testing.T, authService *AuthService, response *auth.AuthResponse) string {
+		id, err := authService.keys.DecodeTokenAndReturnID(response.Token)
+		assert.Nil(t, err)
+		return id
+	}
+
+	t.Run("creates an account on the first sign in", func(t *testing.T) {
+		authService, identities, _ := newService()
+
+		response, err := signIn(t, authService, oidctest.User{Subject: "s1", Email: "bob@mail.com", EmailVerified: true, Name: "Bob", PreferredUsername: "ana"})
+		assert.Nil(t, err)
+		assert.Len(t, identities.accounts, 1)
+		created := identities.accounts[0]
+		assert.Equal(t, created.ID, accountOf(t, authService, response))
+		assert.Equal(t, "bob@mail.com", created.Email)
+		assert.Equal(t, "Bob", created.Name)
+		assert.NotEqual(t, "ana", created.Username)
+		assert.Regexp(t, `^ana\d{4}$`, created.Username)
+
+		response, err = signIn(t, authService, oidctest.User{Subject: "s1", Email: "bob@mail.com", EmailVerified: true})
+		assert.Nil(t, err)
+		assert.Equal(t, created.ID, accountOf(t, authService, response))
+		assert.Len(t, identities.accounts, 1)
+	})
+
+	t.Run("does not link the account of the same email", func(t *testing.T) {
+		authService, identities, _ := newService()
+
+		_, err := signIn(t, authService, oidctest.User{Subject: "s2", Email: "ana@mail.com", EmailVerified: true})
+		assert.IsType(t, &errors.ConflictIdentityError{}, err)
+		assert.Empty(t, identities.accounts)
+		assert.Empty(t, identities.identities)
+	})
+
+	t.Run("links the provider to the signed in account", func(t *testing.T) {
+		authService, identities, _ := newService()
+
+		response, err := signInLinking(t, authService, oidctest.User{Subject: "s2", Email: "other@mail.com"}, id)
+		assert.Nil(t, err)
+		assert.Equal(t, id, accountOf(t, authService, response))
+		assert.Empty(t, identities.accounts)
+		assert.Equal(t, id, identities.identities["fake|s2"].AccountID)
+
+		response, err = signIn(t, authService, oidctest.User{Subject: "s2", Email: "other@mail.com"})
+		assert.Nil(t, err)
+		assert.Equal(t, id, accountOf(t, authService, response))
+
+		_, err = signInLinking(t, authService, oidctest.User{Subject: "s2", Email: "other@mail.com"}, "another-account")
+		assert.IsType(t, &errors.ConflictIdentityError{}, err)
+		assert.Equal(t, id, identities.identities["fake|s2"].AccountID)
+	})
+
+	t.Run("refuses emails the provider has not verified", func(t *testing.T) {
+		authService, identities, _ := newService()
+
+		_, err := signIn(t, authService, oidctest.User{Subject: "s3", Email: "ana@mail.com"})
+		assert.IsType(t, &errors.UnverifiedEmailError{}, err)
+		_, err = signIn(t, authService, oidctest.User{Subject: "s3", Email: "carl@mail.com"})
+		assert.IsType(t, &errors.UnverifiedEmailError{}, err)
+		assert.Empty(t, identities.identities)
+		assert.Empty(t, identities.accounts)
+	})
+
+	t.Run("challenges accounts with two-factor authentication", func(t *testing.T) {
+		authService, _, twoFactors := newService()
+		twoFactors.twoFactor = &auth.TwoFactor{AccountID: id, Enabled: true}
+
+		response, err := signInLinking(t, authService, oidctest.User{Subject: "s4", Email: "ana@mail.com", EmailVerified: true}, id)
+		assert.Nil(t, err)
+		assert.Empty(t, response.Token)
+		assert.NotEmpty(t, response.ChallengeToken)
+	})
+
+	t.Run("rejects a callback of another sign in", func(t *testing.T) {
+		authService, _, _ := newService()
+		server.User = oidctest.User{Subject: "s5", Email: "ana@mail.com", EmailVerified: true}
+
+		authURL, _, err := authService.StartOIDC(context.Background(), "fake", "")
+		assert.Nil(t, err)
+		_, loginToken, err := authService.StartOIDC(context.Background(), "fake", "")
+		assert.Nil(t, err)
+		code, state := server.Authorize(t, authURL)
+
+		_, err = authService.FinishOIDC(context.Background(), "fake", loginToken, state, code, "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidOIDCLoginError{}, err)
+
+		session, err := authService.keys.IssueToken("fake", time.Minute, nil)
+		assert.Nil(t, err)
+		_, err = authService.FinishOIDC(context.Background(), "fake", session, state, code, "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidOIDCLoginError{}, err)
+	})
+
+	t.Run("rejects unknown providers", func(t *testing.T) {
+		authService, _, _ := newService()
+
+		_, _, err := authService.StartOIDC(context.Background(), "unknown", "")
+		assert.IsType(t, &errors.NotFoundProviderError{}, err)
+		_, err = authService.FinishOIDC(context.Background(), "unknown", "", "", "", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.NotFoundProviderError{}, err)
+	})
+}
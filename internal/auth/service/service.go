@@ -1,69 +1,285 @@
 package service
 
 import (
+	"context"
 	"github.com/golang-jwt/jwt/v4"
-	"os"
+	"github.com/google/uuid"
+	"log/slog"
 	"social_network_project/internal/account"
 	"social_network_project/internal/auth"
+	"social_network_project/internal/event"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/oidc"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/crypto"
 	"social_network_project/internal/utils/errors"
 	"time"
 )
 
+// dummyHash is compared against when the email is unknown or the account
+// cannot sign in yet, so that a sign in takes as long either way.
+const dummyHash = "$2a$10$iwEhVM9rXjL2C.Q8EbTJaOwI6ftAsPyd4XE/QyE8hEyyrGjsav0Hm"
+
 type AuthServiceClient interface {
-	CreateToken(email string, password string) (*auth.AuthResponse, error)
+	CreateToken(ctx context.Context, email string, password string, ip string, userAgent string) (*auth.AuthResponse, error)
+	Unlock(ctx context.Context, token string) error
+	VerifyTwoFactor(ctx context.Context, challengeToken, code, ip, userAgent string) (*auth.AuthResponse, error)
+	EnrollTwoFactor(ctx context.Context, accountID string) (*auth.EnrollResponse, error)
+	ConfirmTwoFactor(ctx context.Context, accountID, code string) (*auth.RecoveryCodesResponse, error)
+	DisableTwoFactor(ctx context.Context, accountID, password, code, ip string) error
+	RegenerateRecoveryCodes(ctx context.Context, accountID, password, code, ip string) (*auth.RecoveryCodesResponse, error)
+	StartOIDC(ctx context.Context, providerName, accountID string) (authURL string, loginToken string, err error)
+	FinishOIDC(ctx context.Context, providerName, loginToken, state, code, ip, userAgent string) (*auth.AuthResponse, error)
+}
+
+// LoginPolicy counts the failed sign ins of the last Window. After DelayAfter
+// failures of an account, or IPDelayAfter of an IP, each new attempt must wait
+// BaseDelay after the last failure, doubling with every failure up to
+// MaxDelay. LockoutAfter failures lock the account for LockoutDuration. A
+// zero threshold disables its delay or the lockout.
+type LoginPolicy struct {
+	Window          time.Duration
+	DelayAfter      int
+	IPDelayAfter    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// delay is how long after the last of failures the next attempt must wait.
+func (p LoginPolicy) delay(failures, after int) time.Duration {
+	if after <= 0 || failures < after {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := after; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
 }
 
 type AuthService struct {
 	repository account.AccountRepository
+	attempts   auth.AttemptRepository
+	twoFactors auth.TwoFactorRepository
+	identities auth.IdentityRepository
+	sessions   service2.SessionServiceClient
+	keys       *keyring.Keyring
+	providers  map[string]*oidc.Provider
+	policy     LoginPolicy
+	issuer     string
+	logger     *slog.Logger
+	now        func() time.Time
 }
 
-func NewAuthService(accountsRepository account.AccountRepository) AuthServiceClient {
+// NewAuthService signs tokens with keys for a session of sessions, signs in
+// with the OpenID Connect providers by name and names issuer in the
+// authenticator apps of the accounts enrolling two-factor authentication.
+func NewAuthService(accountsRepository account.AccountRepository, attemptRepository auth.AttemptRepository,
+	twoFactorRepository auth.TwoFactorRepository, identityRepository auth.IdentityRepository,
+	sessions service2.SessionServiceClient, keys *keyring.Keyring,
+	providers map[string]*oidc.Provider, policy LoginPolicy, issuer string, logger *slog.Logger) AuthServiceClient {
 	return &AuthService{
-		repository:    accountsRepository,
+		repository: accountsRepository,
+		attempts:   attemptRepository,
+		twoFactors: twoFactorRepository,
+		identities: identityRepository,
+		sessions:   sessions,
+		keys:       keys,
+		providers:  providers,
+		policy:     policy,
+		issuer:     issuer,
+		logger:     logger,
+		now:        time.Now,
 	}
 }
 
-func (s *AuthService) CreateToken(email string, password string) (*auth.AuthResponse, error) {
+// CreateToken signs in with email and password. Unknown emails, wrong
+// passwords and accounts locked or waiting after their last failure fail alike
+// with InvalidCredentialsError, so that the response does not tell whether an
+// account exists. Accounts with two-factor authentication get a challenge
+// token to send with a code to VerifyTwoFactor instead of a token.
+func (s *AuthService) CreateToken(ctx context.Context, email string, password string, ip string, userAgent string) (*auth.AuthResponse, error) {
 
-	existEmail, err := s.repository.ExistsAccountByEmail(&email)
+	now := s.now().UTC()
+	since := now.Add(-s.policy.Window)
+
+	ipFailures, err := s.attempts.FindFailuresByIP(ctx, &ip, since)
+	if err != nil {
+		return nil, err
+	}
+	if wait := s.wait(ipFailures, s.policy.IPDelayAfter, now); wait > 0 {
+		return nil, &errors.TooManyLoginAttemptsError{RetryAfter: wait}
+	}
+
+	existEmail, err := s.repository.ExistsAccountByEmail(ctx, &email)
 	if err != nil {
 		return nil, err
 	}
 	if !*existEmail {
-		return nil, &errors.NotFoundEmailError{}
+		return nil, s.refuse(ctx, ip, password, now)
 	}
 
-	passwordHash, err := s.repository.FindAccountPasswordByEmail(email)
+	id, err := s.repository.FindAccountIDbyEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	accountFailures, err := s.checkAccount(ctx, *id, now)
+	switch err.(type) {
+	case nil:
+	case *errors.AccountLockedError, *errors.TooManyLoginAttemptsError:
+		return nil, s.refuse(ctx, ip, password, now)
+	default:
+		return nil, err
+	}
+
+	passwordHash, err := s.repository.FindAccountPasswordByEmail(ctx, email)
 	if err != nil {
 		return nil, err
 	}
 
 	if !crypto.CompareHashAndPassword(*passwordHash, password) {
-		return nil, &errors.UnauthorizedPasswordError{}
+		err = s.recordFailure(ctx, *id, ip, accountFailures, now)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &errors.InvalidCredentialsError{}
 	}
 
-	id, err := s.repository.FindAccountIDbyEmail(email)
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
+	if twoFactor != nil && twoFactor.Enabled {
+		return s.createChallengeToken(*id)
+	}
 
-	token, err := s.createTokenByID(*id)
+	return s.signIn(ctx, *id, ip, userAgent, now)
+}
+
+// Unlock ends the lockout of the account the unlock code was sent to.
+func (s *AuthService) Unlock(ctx context.Context, token string) error {
+
+	tokenHash := crypto.HashToken(token)
+	accountID, err := s.attempts.UnlockByTokenHash(ctx, &tokenHash, s.now().UTC())
+	if err != nil {
+		return err
+	}
+	if accountID == nil {
+		return &errors.InvalidUnlockTokenError{}
+	}
+
+	return nil
+}
+
+// refuse fails a sign in without checking the password against an account,
+// taking as long as if it had been and counting it against the IP.
+func (s *AuthService) refuse(ctx context.Context, ip, password string, now time.Time) error {
+	crypto.CompareHashAndPassword(dummyHash, password)
+	err := s.recordAttempt(ctx, "", ip, false, now)
+	if err != nil {
+		return err
+	}
+	return &errors.InvalidCredentialsError{}
+}
+
+// checkAccount fails when the account is locked or must wait after its last
+// failure, and returns its failures otherwise.
+func (s *AuthService) checkAccount(ctx context.Context, accountID string, now time.Time) (*auth.Failures, error) {
+	lockout, err := s.attempts.FindLockoutByAccountID(ctx, &accountID)
+	if err != nil {
+		return nil, err
+	}
+	if lockout != nil && now.Before(lockout.LockedUntil) {
+		return nil, &errors.AccountLockedError{RetryAfter: lockout.LockedUntil.Sub(now)}
+	}
+
+	failures, err := s.attempts.FindFailuresByAccountID(ctx, &accountID, now.Add(-s.policy.Window))
+	if err != nil {
+		return nil, err
+	}
+	if wait := s.wait(failures, s.policy.DelayAfter, now); wait > 0 {
+		return nil, &errors.TooManyLoginAttemptsError{RetryAfter: wait}
+	}
+
+	return failures, nil
+}
+
+// recordFailure records a failed attempt on the account, locking it when the
+// failure is one too many.
+func (s *AuthService) recordFailure(ctx context.Context, accountID, ip string, failures *auth.Failures, now time.Time) error {
+	err := s.recordAttempt(ctx, accountID, ip, false, now)
+	if err != nil {
+		return err
+	}
+
+	if s.policy.LockoutAfter > 0 && failures.Count+1 >= s.policy.LockoutAfter {
+		return s.lock(ctx, accountID, now)
+	}
+	return nil
+}
+
+func (s *AuthService) signIn(ctx context.Context, accountID, ip, userAgent string, now time.Time) (*auth.AuthResponse, error) {
+	err := s.recordAttempt(ctx, accountID, ip, true, now)
 	if err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	return s.createTokenByID(ctx, accountID, ip, userAgent)
 }
 
-func (s *AuthService) createTokenByID(id string) (*auth.AuthResponse, error) {
+func (s *AuthService) wait(failures *auth.Failures, after int, now time.Time) time.Duration {
+	return failures.LastAt.Add(s.policy.delay(failures.Count, after)).Sub(now)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
+func (s *AuthService) recordAttempt(ctx context.Context, accountID, ip string, succeeded bool, now time.Time) error {
+	return s.attempts.InsertAttempt(ctx, &auth.Attempt{
+		ID:        uuid.New().String(),
+		AccountID: utils.NewNullString(accountID),
+		IP:        ip,
+		Succeeded: succeeded,
+		CreatedAt: now,
 	})
+}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_TOKEN_KEY")))
+// lock locks the account and notifies its owner, who is sent the code
+// unlocking it along with the notice.
+func (s *AuthService) lock(ctx context.Context, accountID string, now time.Time) error {
+	lockout := &auth.Lockout{
+		AccountID:   accountID,
+		LockedUntil: now.Add(s.policy.LockoutDuration),
+		CreatedAt:   now,
+	}
+
+	message, err := outbox.NewOutbox(ctx, event.New(accountID, &event.AccountLocked{
+		AccountID:   accountID,
+		LockedUntil: lockout.LockedUntil,
+	}))
+	if err != nil {
+		return err
+	}
+
+	return s.attempts.InsertLockout(ctx, lockout, message)
+}
+
+// createTokenByID starts a session on the device signing in and issues its
+// token.
+func (s *AuthService) createTokenByID(ctx context.Context, id, ip, userAgent string) (*auth.AuthResponse, error) {
+
+	created, err := s.sessions.CreateSession(ctx, id, ip, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenString, err := s.keys.IssueToken(id, s.keys.TokenTTL(), jwt.MapClaims{"sid": created.ID})
 	if err != nil {
 		return nil, err
 	}
@@ -71,4 +287,4 @@ func (s *AuthService) createTokenByID(id string) (*auth.AuthResponse, error) {
 	return &auth.AuthResponse{
 		Token: tokenString,
 	}, nil
-}
\ No newline at end of file
+}
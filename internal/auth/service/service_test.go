@@ -1,45 +1,447 @@
 package service
 
 import (
-	"github.com/golang-jwt/jwt/v4"
+	"context"
 	"github.com/stretchr/testify/assert"
-	"os"
+	"social_network_project/internal/account"
 	"social_network_project/internal/auth"
+	"social_network_project/internal/event"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/session"
+	service2 "social_network_project/internal/session/service"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"social_network_project/internal/utils/totp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
 
+func newKeys(t *testing.T) *keyring.Keyring {
+	keys, err := keyring.New(keyring.Config{
+		Dir:       t.TempDir(),
+		Algorithm: keyring.ALGORITHM_EDDSA,
+		Issuer:    "social_network",
+		Audience:  "social_network_api",
+		TokenTTL:  time.Hour,
+	})
+	assert.Nil(t, err)
+	return keys
+}
+
+func newSessions() service2.SessionServiceClient {
+	return service2.NewSessionService(session.NewSessionRepositoryMemory(time.Now), time.Hour)
+}
+
 func TestAccountsControllerStruct_CreateToken(t *testing.T) {
 
 	id := "6c08496b-b721-4e06-b0b7-1905524c9da2"
 
-	tokenExpected := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  id,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
-	})
+	authService := AuthService{keys: newKeys(t), sessions: newSessions()}
+
+	tokenStruct, err := authService.createTokenByID(context.Background(), id, "10.0.0.1", "curl/7.84.0")
+	assert.Nil(t, err)
+
+	claims, err := authService.keys.Parse(tokenStruct.Token)
+	assert.Nil(t, err)
 
-	authService := AuthService{}
+	assert.Equal(t, id, claims["sub"])
+	assert.Equal(t, "social_network_api", claims["aud"])
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), claims["exp"], 5)
 
-	tokenString, err := tokenExpected.SignedString([]byte(os.Getenv("JWT_TOKEN_KEY")))
+	valid, err := authService.sessions.ValidateSession(context.Background(), id, claims["sid"].(string))
 	assert.Nil(t, err)
+	assert.True(t, valid)
+}
+
+type accountRepositoryFake struct {
+	account.AccountRepository
+	id           string
+	username     string
+	email        string
+	passwordHash string
+}
+
+func (a *accountRepositoryFake) ExistsAccountByUsername(ctx context.Context, username *string) (*bool, error) {
+	exist := *username == a.username
+	return &exist, nil
+}
+
+func (a *accountRepositoryFake) ExistsAccountByEmail(ctx context.Context, email *string) (*bool, error) {
+	exist := *email == a.email
+	return &exist, nil
+}
 
-	tokenStructExpected := auth.AuthResponse{
-		Token: tokenString,
+func (a *accountRepositoryFake) FindAccountIDbyEmail(ctx context.Context, email string) (*string, error) {
+	return &a.id, nil
+}
+
+func (a *accountRepositoryFake) FindAccountPasswordByEmail(ctx context.Context, email string) (*string, error) {
+	return &a.passwordHash, nil
+}
+
+func (a *accountRepositoryFake) FindAccountByID(ctx context.Context, id *string) (*account.Account, error) {
+	return &account.Account{ID: a.id, Email: a.email, Password: a.passwordHash}, nil
+}
+
+type attemptRepositoryFake struct {
+	attempts []*auth.Attempt
+	lockout  *auth.Lockout
+	messages []*outbox.Outbox
+}
+
+func (a *attemptRepositoryFake) InsertAttempt(ctx context.Context, attempt *auth.Attempt) error {
+	a.attempts = append(a.attempts, attempt)
+	return nil
+}
+
+func (a *attemptRepositoryFake) FindFailuresByAccountID(ctx context.Context, accountID *string, since time.Time) (*auth.Failures, error) {
+	if a.lockout != nil && a.lockout.CreatedAt.After(since) {
+		since = a.lockout.CreatedAt
 	}
+	return a.failures(since, true, func(attempt *auth.Attempt) bool {
+		return attempt.AccountID.String == *accountID
+	}), nil
+}
 
-	tokenDecodeExpected := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenStructExpected.Token, tokenDecodeExpected, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
-	})
+func (a *attemptRepositoryFake) FindFailuresByIP(ctx context.Context, ip *string, since time.Time) (*auth.Failures, error) {
+	return a.failures(since, false, func(attempt *auth.Attempt) bool {
+		return attempt.IP == *ip
+	}), nil
+}
+
+func (a *attemptRepositoryFake) failures(since time.Time, resetOnSuccess bool, match func(attempt *auth.Attempt) bool) *auth.Failures {
+	failures := &auth.Failures{LastAt: since}
+	for _, attempt := range a.attempts {
+		if !match(attempt) || !attempt.CreatedAt.After(since) {
+			continue
+		}
+		if attempt.Succeeded {
+			if resetOnSuccess {
+				failures = &auth.Failures{LastAt: attempt.CreatedAt}
+			}
+			continue
+		}
+		failures.Count++
+		failures.LastAt = attempt.CreatedAt
+	}
+	return failures
+}
+
+func (a *attemptRepositoryFake) FindLockoutByAccountID(ctx context.Context, accountID *string) (*auth.Lockout, error) {
+	return a.lockout, nil
+}
+
+func (a *attemptRepositoryFake) InsertLockout(ctx context.Context, lockout *auth.Lockout, message *outbox.Outbox) error {
+	a.lockout = lockout
+	a.messages = append(a.messages, message)
+	return nil
+}
+
+func (a *attemptRepositoryFake) UnlockByTokenHash(ctx context.Context, tokenHash *string, at time.Time) (*string, error) {
+	if a.lockout == nil || a.lockout.UnlockTokenHash != *tokenHash || !at.Before(a.lockout.LockedUntil) {
+		return nil, nil
+	}
+	a.lockout.LockedUntil = at
+	a.lockout.CreatedAt = at
+	a.lockout.UnlockTokenHash = ""
+	return &a.lockout.AccountID, nil
+}
+
+type twoFactorRepositoryFake struct {
+	twoFactor     *auth.TwoFactor
+	recoveryCodes map[string]bool
+}
+
+func (r *twoFactorRepositoryFake) FindTwoFactorByAccountID(ctx context.Context, accountID *string) (*auth.TwoFactor, error) {
+	return r.twoFactor, nil
+}
+
+func (r *twoFactorRepositoryFake) InsertTwoFactor(ctx context.Context, twoFactor *auth.TwoFactor) error {
+	r.twoFactor = twoFactor
+	return nil
+}
+
+func (r *twoFactorRepositoryFake) EnableTwoFactor(ctx context.Context, accountID *string, step int64, codeHashes []string) error {
+	r.twoFactor.Enabled = true
+	r.twoFactor.LastUsedStep = step
+	return r.ReplaceRecoveryCodes(context.Background(), accountID, codeHashes)
+}
+
+func (r *twoFactorRepositoryFake) UseTwoFactorStep(ctx context.Context, accountID *string, step int64) (bool, error) {
+	if step <= r.twoFactor.LastUsedStep {
+		return false, nil
+	}
+	r.twoFactor.LastUsedStep = step
+	return true, nil
+}
+
+func (r *twoFactorRepositoryFake) UseRecoveryCode(ctx context.Context, accountID, codeHash *string, at time.Time) (bool, error) {
+	if !r.recoveryCodes[*codeHash] {
+		return false, nil
+	}
+	r.recoveryCodes[*codeHash] = false
+	return true, nil
+}
+
+func (r *twoFactorRepositoryFake) ReplaceRecoveryCodes(ctx context.Context, accountID *string, codeHashes []string) error {
+	r.recoveryCodes = map[string]bool{}
+	for _, codeHash := range codeHashes {
+		r.recoveryCodes[codeHash] = true
+	}
+	return nil
+}
+
+func (r *twoFactorRepositoryFake) DeleteTwoFactor(ctx context.Context, accountID *string) error {
+	r.twoFactor = nil
+	r.recoveryCodes = nil
+	return nil
+}
+
+func TestAuthService_CreateToken(t *testing.T) {
+	passwordHash, err := crypto.EncryptPassword("2233445566")
 	assert.Nil(t, err)
+	policy := LoginPolicy{
+		Window:          15 * time.Minute,
+		DelayAfter:      2,
+		IPDelayAfter:    5,
+		BaseDelay:       time.Second,
+		MaxDelay:        4 * time.Second,
+		LockoutAfter:    4,
+		LockoutDuration: 15 * time.Minute,
+	}
+
+	newService := func() (*AuthService, *attemptRepositoryFake, *time.Time) {
+		clock := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		attempts := &attemptRepositoryFake{}
+		authService := NewAuthService(&accountRepositoryFake{
+			id:           "6c08496b-b721-4e06-b0b7-1905524c9da2",
+			email:        "ana@mail.com",
+			passwordHash: *passwordHash,
+		}, attempts, &twoFactorRepositoryFake{}, &identityRepositoryFake{}, newSessions(), newKeys(t), nil, policy, "social_network", logging.Discard()).(*AuthService)
+		authService.now = func() time.Time { return clock }
+		return authService, attempts, &clock
+	}
+
+	t.Run("same error for unknown email and wrong password", func(t *testing.T) {
+		authService, _, _ := newService()
+
+		_, err := authService.CreateToken(context.Background(), "bob@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "wrong", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+
+		token, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		assert.NotEmpty(t, token.Token)
+	})
+
+	t.Run("delays progressively then locks, failing as a wrong password", func(t *testing.T) {
+		authService, attempts, clock := newService()
+
+		for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+			_, err := authService.CreateToken(context.Background(), "ana@mail.com", "wrong", ip, "curl/7.84.0")
+			assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+		}
+
+		_, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.3", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
 
-	tokenStruct, err := authService.createTokenByID(id)
+		*clock = clock.Add(time.Second)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "wrong", "10.0.0.3", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.3", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+
+		*clock = clock.Add(2 * time.Second)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "wrong", "10.0.0.4", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+		assert.Equal(t, 1, len(attempts.messages))
+		assert.Equal(t, string(event.TypeAccountLocked), attempts.messages[0].Type)
+
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.5", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+
+		*clock = clock.Add(15 * time.Minute)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.5", "curl/7.84.0")
+		assert.Nil(t, err)
+	})
+
+	t.Run("delays an ip failing on many accounts", func(t *testing.T) {
+		authService, _, _ := newService()
+
+		for i := 0; i < 5; i++ {
+			authService.CreateToken(context.Background(), "user"+strconv.Itoa(i)+"@mail.com", "wrong", "10.0.0.1", "curl/7.84.0")
+		}
+
+		_, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.TooManyLoginAttemptsError{}, err)
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.2", "curl/7.84.0")
+		assert.Nil(t, err)
+	})
+
+	t.Run("signing in to its own account does not reset the delay of an ip", func(t *testing.T) {
+		authService, _, _ := newService()
+
+		for i := 0; i < 4; i++ {
+			authService.CreateToken(context.Background(), "user"+strconv.Itoa(i)+"@mail.com", "wrong", "10.0.0.1", "curl/7.84.0")
+		}
+		_, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		authService.CreateToken(context.Background(), "user4@mail.com", "wrong", "10.0.0.1", "curl/7.84.0")
+
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.TooManyLoginAttemptsError{}, err)
+	})
+
+	t.Run("unlocks with the code sent", func(t *testing.T) {
+		authService, attempts, clock := newService()
+
+		for i := 0; i < 4; i++ {
+			authService.CreateToken(context.Background(), "ana@mail.com", "wrong", "10.0.0."+strconv.Itoa(i), "curl/7.84.0")
+			*clock = clock.Add(4 * time.Second)
+		}
+		envelope, err := event.Decode([]byte(attempts.messages[0].Payload))
+		assert.Nil(t, err)
+		assert.NotContains(t, attempts.messages[0].Payload, "unlock")
+		assert.Empty(t, attempts.lockout.UnlockTokenHash)
+
+		// The notice sent to the owner stores the hash of the code it carries.
+		unlockToken := "5f2b1c"
+		attempts.lockout.UnlockTokenHash = crypto.HashToken(unlockToken)
+		assert.Equal(t, "6c08496b-b721-4e06-b0b7-1905524c9da2", envelope.Payload.(*event.AccountLocked).AccountID)
+
+		assert.IsType(t, &errors.InvalidUnlockTokenError{}, authService.Unlock(context.Background(), "wrong"))
+		assert.Nil(t, authService.Unlock(context.Background(), unlockToken))
+		assert.IsType(t, &errors.InvalidUnlockTokenError{}, authService.Unlock(context.Background(), unlockToken))
+
+		_, err = authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.9", "curl/7.84.0")
+		assert.Nil(t, err)
+	})
+}
+
+func TestAuthService_TwoFactor(t *testing.T) {
+	passwordHash, err := crypto.EncryptPassword("2233445566")
 	assert.Nil(t, err)
+	id := "6c08496b-b721-4e06-b0b7-1905524c9da2"
 
-	tokenDecode := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenStruct.Token, tokenDecode, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
+	newService := func() (*AuthService, *time.Time) {
+		clock := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		authService := NewAuthService(&accountRepositoryFake{
+			id:           id,
+			email:        "ana@mail.com",
+			passwordHash: *passwordHash,
+		}, &attemptRepositoryFake{}, &twoFactorRepositoryFake{}, &identityRepositoryFake{}, newSessions(), newKeys(t), nil, LoginPolicy{
+			Window:          15 * time.Minute,
+			LockoutAfter:    3,
+			LockoutDuration: 15 * time.Minute,
+		}, "social_network", logging.Discard()).(*AuthService)
+		authService.now = func() time.Time { return clock }
+		return authService, &clock
+	}
+	code := func(secret string, clock *time.Time) string {
+		code, err := totp.Code(secret, totp.Step(*clock))
+		assert.Nil(t, err)
+		return code
+	}
+	enable := func(authService *AuthService, clock *time.Time) (string, []string) {
+		enrollment, err := authService.EnrollTwoFactor(context.Background(), id)
+		assert.Nil(t, err)
+		recoveryCodes, err := authService.ConfirmTwoFactor(context.Background(), id, code(enrollment.Secret, clock))
+		assert.Nil(t, err)
+		*clock = clock.Add(totp.Period)
+		return enrollment.Secret, recoveryCodes.RecoveryCodes
+	}
+
+	t.Run("enrolls with a confirmation code", func(t *testing.T) {
+		authService, clock := newService()
+
+		enrollment, err := authService.EnrollTwoFactor(context.Background(), id)
+		assert.Nil(t, err)
+		assert.Contains(t, enrollment.URI, "otpauth://totp/social_network:ana@mail.com?")
+
+		_, err = authService.ConfirmTwoFactor(context.Background(), id, "000000")
+		assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, err)
+
+		recoveryCodes, err := authService.ConfirmTwoFactor(context.Background(), id, code(enrollment.Secret, clock))
+		assert.Nil(t, err)
+		assert.Equal(t, 10, len(recoveryCodes.RecoveryCodes))
+
+		_, err = authService.EnrollTwoFactor(context.Background(), id)
+		assert.IsType(t, &errors.ConflictTwoFactorError{}, err)
+	})
+
+	t.Run("signs in with a challenge and a code used once", func(t *testing.T) {
+		authService, clock := newService()
+		secret, _ := enable(authService, clock)
+
+		challenge, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		assert.Empty(t, challenge.Token)
+		assert.NotEmpty(t, challenge.ChallengeToken)
+
+		_, err = authService.keys.DecodeTokenAndReturnID(challenge.ChallengeToken)
+		assert.NotNil(t, err)
+
+		token, err := authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, code(secret, clock), "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		tokenID, err := authService.keys.DecodeTokenAndReturnID(token.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, id, tokenID)
+
+		_, err = authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, code(secret, clock), "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, err)
+		_, err = authService.VerifyTwoFactor(context.Background(), token.Token, code(secret, clock), "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidChallengeTokenError{}, err)
 	})
 
-	assert.Equal(t, tokenDecodeExpected["id"], tokenDecode["id"])
-}
\ No newline at end of file
+	t.Run("recovery codes are single use", func(t *testing.T) {
+		authService, clock := newService()
+		_, recoveryCodes := enable(authService, clock)
+
+		challenge, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		_, err = authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, strings.ToUpper(recoveryCodes[0]), "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		_, err = authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, recoveryCodes[0], "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, err)
+	})
+
+	t.Run("wrong codes lock the account", func(t *testing.T) {
+		authService, clock := newService()
+		enable(authService, clock)
+
+		challenge, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			_, err = authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, "000000", "10.0.0.1", "curl/7.84.0")
+			assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, err)
+		}
+		_, err = authService.VerifyTwoFactor(context.Background(), challenge.ChallengeToken, "000000", "10.0.0.1", "curl/7.84.0")
+		assert.IsType(t, &errors.AccountLockedError{}, err)
+	})
+
+	t.Run("disables and regenerates with password and code", func(t *testing.T) {
+		authService, clock := newService()
+		secret, recoveryCodes := enable(authService, clock)
+
+		_, err := authService.RegenerateRecoveryCodes(context.Background(), id, "wrong", code(secret, clock), "10.0.0.1")
+		assert.IsType(t, &errors.InvalidCredentialsError{}, err)
+
+		regenerated, err := authService.RegenerateRecoveryCodes(context.Background(), id, "2233445566", code(secret, clock), "10.0.0.1")
+		assert.Nil(t, err)
+		assert.NotEqual(t, recoveryCodes, regenerated.RecoveryCodes)
+
+		err = authService.DisableTwoFactor(context.Background(), id, "2233445566", recoveryCodes[0], "10.0.0.1")
+		assert.IsType(t, &errors.InvalidTwoFactorCodeError{}, err)
+		err = authService.DisableTwoFactor(context.Background(), id, "2233445566", regenerated.RecoveryCodes[0], "10.0.0.1")
+		assert.Nil(t, err)
+
+		token, err := authService.CreateToken(context.Background(), "ana@mail.com", "2233445566", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		assert.NotEmpty(t, token.Token)
+	})
+}
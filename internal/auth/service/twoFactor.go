@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v4"
+	"social_network_project/internal/auth"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"social_network_project/internal/utils/totp"
+	"strings"
+	"time"
+)
+
+const (
+	challengePurpose   = "2fa"
+	challengeTTL       = 5 * time.Minute
+	recoveryCodesCount = 10
+	// totpSkew is how many steps a code may be early or late.
+	totpSkew = 1
+)
+
+// VerifyTwoFactor ends a sign in started by CreateToken with a TOTP or
+// recovery code. Wrong codes count as failed attempts of the account.
+func (s *AuthService) VerifyTwoFactor(ctx context.Context, challengeToken, code, ip, userAgent string) (*auth.AuthResponse, error) {
+
+	now := s.now().UTC()
+	id, err := s.parseChallengeToken(challengeToken)
+	if err != nil {
+		return nil, &errors.InvalidChallengeTokenError{}
+	}
+
+	failures, err := s.checkAccount(ctx, id, now)
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, &id)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		return nil, &errors.InvalidChallengeTokenError{}
+	}
+
+	valid, err := s.verifyCode(ctx, twoFactor, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		err = s.recordFailure(ctx, id, ip, failures, now)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &errors.InvalidTwoFactorCodeError{}
+	}
+
+	return s.signIn(ctx, id, ip, userAgent, now)
+}
+
+// EnrollTwoFactor generates the secret to add to an authenticator app. It is
+// only enabled by ConfirmTwoFactor, and enrolling again before replaces it.
+func (s *AuthService) EnrollTwoFactor(ctx context.Context, accountID string) (*auth.EnrollResponse, error) {
+
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, &accountID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		return nil, &errors.ConflictTwoFactorError{}
+	}
+
+	account, err := s.repository.FindAccountByID(ctx, &accountID)
+	if err != nil {
+		return nil, &errors.NotFoundAccountIDError{}
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	err = s.twoFactors.InsertTwoFactor(ctx, &auth.TwoFactor{
+		AccountID: accountID,
+		Secret:    secret,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.EnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, account.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables the enrolled secret with a code of the
+// authenticator app and returns the recovery codes, shown only this once.
+func (s *AuthService) ConfirmTwoFactor(ctx context.Context, accountID, code string) (*auth.RecoveryCodesResponse, error) {
+
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, &accountID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil {
+		return nil, &errors.NotFoundTwoFactorError{}
+	}
+	if twoFactor.Enabled {
+		return nil, &errors.ConflictTwoFactorError{}
+	}
+
+	step, valid := totp.Validate(twoFactor.Secret, normalizeCode(code), s.now(), totpSkew)
+	if !valid {
+		return nil, &errors.InvalidTwoFactorCodeError{}
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.twoFactors.EnableTwoFactor(ctx, &accountID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *AuthService) DisableTwoFactor(ctx context.Context, accountID, password, code, ip string) error {
+
+	err := s.reauthenticate(ctx, accountID, password, code, ip)
+	if err != nil {
+		return err
+	}
+
+	return s.twoFactors.DeleteTwoFactor(ctx, &accountID)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of the account,
+// used or not, with new ones.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, accountID, password, code, ip string) (*auth.RecoveryCodesResponse, error) {
+
+	err := s.reauthenticate(ctx, accountID, password, code, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.twoFactors.ReplaceRecoveryCodes(ctx, &accountID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// reauthenticate asks a signed in account for its password and a code again
+// before changing its two-factor authentication. Failures count as failed
+// attempts, so it cannot be used to guess the password.
+func (s *AuthService) reauthenticate(ctx context.Context, accountID, password, code, ip string) error {
+
+	now := s.now().UTC()
+	twoFactor, err := s.twoFactors.FindTwoFactorByAccountID(ctx, &accountID)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		return &errors.NotFoundTwoFactorError{}
+	}
+
+	failures, err := s.checkAccount(ctx, accountID, now)
+	if err != nil {
+		return err
+	}
+
+	account, err := s.repository.FindAccountByID(ctx, &accountID)
+	if err != nil {
+		return &errors.NotFoundAccountIDError{}
+	}
+	if !crypto.CompareHashAndPassword(account.Password, password) {
+		err = s.recordFailure(ctx, accountID, ip, failures, now)
+		if err != nil {
+			return err
+		}
+		return &errors.InvalidCredentialsError{}
+	}
+
+	valid, err := s.verifyCode(ctx, twoFactor, code, now)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		err = s.recordFailure(ctx, accountID, ip, failures, now)
+		if err != nil {
+			return err
+		}
+		return &errors.InvalidTwoFactorCodeError{}
+	}
+
+	return nil
+}
+
+// verifyCode accepts a TOTP code not used before or an unused recovery code,
+// and spends it.
+func (s *AuthService) verifyCode(ctx context.Context, twoFactor *auth.TwoFactor, code string, now time.Time) (bool, error) {
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		step, valid := totp.Validate(twoFactor.Secret, code, now, totpSkew)
+		if !valid {
+			return false, nil
+		}
+		return s.twoFactors.UseTwoFactorStep(ctx, &twoFactor.AccountID, step)
+	}
+
+	codeHash := crypto.HashToken(code)
+	return s.twoFactors.UseRecoveryCode(ctx, &twoFactor.AccountID, &codeHash, now)
+}
+
+func (s *AuthService) createChallengeToken(id string) (*auth.AuthResponse, error) {
+
+	tokenString, err := s.keys.IssueToken(id, challengeTTL, jwt.MapClaims{
+		"purpose": challengePurpose,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.AuthResponse{
+		ChallengeToken: tokenString,
+	}, nil
+}
+
+func (s *AuthService) parseChallengeToken(challengeToken string) (string, error) {
+	claims, err := s.keys.Parse(challengeToken)
+	if err != nil {
+		return "", err
+	}
+
+	id, ok := claims["sub"].(string)
+	if !ok || claims["purpose"] != challengePurpose {
+		return "", &errors.InvalidChallengeTokenError{}
+	}
+	return id, nil
+}
+
+// newRecoveryCodes returns codes formatted as xxxxx-xxxxx and the hashes
+// stored in their place.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := crypto.NewToken(5)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, crypto.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeCode drops the spaces and dashes users type in codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
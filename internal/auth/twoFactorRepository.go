@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	"social_network_project/internal/platform/database/postgresql"
+	"time"
+)
+
+type TwoFactorRepository interface {
+	FindTwoFactorByAccountID(ctx context.Context, accountID *string) (*TwoFactor, error)
+	InsertTwoFactor(ctx context.Context, twoFactor *TwoFactor) error
+	EnableTwoFactor(ctx context.Context, accountID *string, step int64, codeHashes []string) error
+	UseTwoFactorStep(ctx context.Context, accountID *string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, accountID, codeHash *string, at time.Time) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, accountID *string, codeHashes []string) error
+	DeleteTwoFactor(ctx context.Context, accountID *string) error
+}
+
+type TwoFactorRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewTwoFactorRepository(postgresDB *sql.DB) TwoFactorRepository {
+	return &TwoFactorRepositoryStruct{postgresDB}
+}
+
+// FindTwoFactorByAccountID returns nil when the account never enrolled.
+func (p *TwoFactorRepositoryStruct) FindTwoFactorByAccountID(ctx context.Context, accountID *string) (*TwoFactor, error) {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "FindTwoFactorByAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT account_id, secret, enabled, last_used_step, created_at, updated_at
+		FROM account_two_factor
+		WHERE account_id = $1`
+
+	var twoFactor TwoFactor
+	err := p.Db.QueryRowContext(ctx, sqlStatement, accountID).Scan(&twoFactor.AccountID, &twoFactor.Secret, &twoFactor.Enabled,
+		&twoFactor.LastUsedStep, &twoFactor.CreatedAt, &twoFactor.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoFactor, nil
+}
+
+// InsertTwoFactor enrolls a secret, replacing an enrollment not confirmed yet.
+func (p *TwoFactorRepositoryStruct) InsertTwoFactor(ctx context.Context, twoFactor *TwoFactor) error {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "InsertTwoFactor")
+	defer end()
+	sqlStatement := `
+		INSERT INTO account_two_factor (account_id, secret, enabled, last_used_step, created_at, updated_at)
+		VALUES ($1, $2, false, 0, $3, $4)
+		ON CONFLICT (account_id) DO UPDATE
+		SET secret = $2, last_used_step = 0, created_at = $3, updated_at = $4
+		WHERE account_two_factor.enabled = false`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, twoFactor.AccountID, twoFactor.Secret, twoFactor.CreatedAt, twoFactor.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EnableTwoFactor enables the enrolled secret, confirmed with the code of
+// step, together with its recovery codes.
+func (p *TwoFactorRepositoryStruct) EnableTwoFactor(ctx context.Context, accountID *string, step int64, codeHashes []string) error {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "EnableTwoFactor")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+		UPDATE account_two_factor
+		SET enabled = true, last_used_step = $2, updated_at = $3
+		WHERE account_id = $1`
+
+	_, err = tx.ExecContext(ctx, sqlStatement, accountID, step, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	err = insertRecoveryCodes(ctx, tx, accountID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTwoFactorStep records that the code of step was used and reports false
+// when it or a later one already was, so a code cannot be replayed.
+func (p *TwoFactorRepositoryStruct) UseTwoFactorStep(ctx context.Context, accountID *string, step int64) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "UseTwoFactorStep")
+	defer end()
+	sqlStatement := `
+		UPDATE account_two_factor
+		SET last_used_step = $2
+		WHERE account_id = $1
+		AND last_used_step < $2`
+
+	result, err := p.Db.ExecContext(ctx, sqlStatement, accountID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// UseRecoveryCode spends the recovery code and reports false when the account
+// holds no unused code with that hash.
+func (p *TwoFactorRepositoryStruct) UseRecoveryCode(ctx context.Context, accountID, codeHash *string, at time.Time) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "UseRecoveryCode")
+	defer end()
+	sqlStatement := `
+		UPDATE account_recovery_code
+		SET used_at = $3
+		WHERE account_id = $1
+		AND code_hash = $2
+		AND used_at IS NULL`
+
+	result, err := p.Db.ExecContext(ctx, sqlStatement, accountID, codeHash, at)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (p *TwoFactorRepositoryStruct) ReplaceRecoveryCodes(ctx context.Context, accountID *string, codeHashes []string) error {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "ReplaceRecoveryCodes")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM account_recovery_code WHERE account_id = $1`, accountID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRecoveryCodes(ctx, tx, accountID, codeHashes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *TwoFactorRepositoryStruct) DeleteTwoFactor(ctx context.Context, accountID *string) error {
+	ctx, end := postgresql.Observe(ctx, "two_factor", "DeleteTwoFactor")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM account_recovery_code WHERE account_id = $1`, accountID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM account_two_factor WHERE account_id = $1`, accountID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertRecoveryCodes(ctx context.Context, tx *sql.Tx, accountID *string, codeHashes []string) error {
+	sqlStatement := `
+		INSERT INTO account_recovery_code (id, account_id, code_hash, used_at, created_at)
+		VALUES ($1, $2, $3, NULL, $4)`
+
+	now := time.Now().UTC()
+	for _, codeHash := range codeHashes {
+		_, err := tx.ExecContext(ctx, sqlStatement, uuid.New().String(), accountID, codeHash, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -15,6 +15,7 @@ type Comment struct {
 	Removed   bool
 	Like      int
 	Dislike   int
+	Version   int
 }
 
 func (a *Comment) ToResponse() *CommentResponse {
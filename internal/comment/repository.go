@@ -1,22 +1,26 @@
 package comment
 
 import (
+	"context"
 	"database/sql"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/database/postgresql"
 	"strings"
 	"time"
 )
 
 type CommentRepository interface {
-	InsertComment(comment *Comment) error
-	ExistsCommentByID(id *string) (*bool, error)
-	FindCommentsByAccountID(accountID, page *string) ([]interface{}, error)
-	FindCommentsByPostOrCommentID(postID, commentID, page *string) ([]interface{}, error)
-	UpdateCommentDataByID(commentID, accountID, content *string) error
-	FindCommentByID(id *string) (*Comment, error)
-	RemoveCommentByID(commentID, accountID *string) error
-	ExistsCommentByCommentIDAndAccountID(commentID, accountID *string) (*bool, error)
-	FindAccountEmailOfPostByCommentID(commentID *string) ([]interface{}, error)
-	FindAccountEmailOfPostAndCommentByCommentID(commentID *string) ([]interface{}, error)
+	InsertComment(ctx context.Context, comment *Comment, message *outbox.Outbox) error
+	ExistsCommentByID(ctx context.Context, id *string) (*bool, error)
+	FindCommentsByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	FindCommentsByPostOrCommentID(ctx context.Context, postID, commentID, page *string) ([]interface{}, error)
+	UpdateCommentDataByID(ctx context.Context, commentID, accountID, content *string, version *int) (bool, error)
+	FindCommentByID(ctx context.Context, id *string) (*Comment, error)
+	RemoveCommentByID(ctx context.Context, commentID, accountID *string, entry *audit.Audit) error
+	ExistsCommentByCommentIDAndAccountID(ctx context.Context, commentID, accountID *string) (*bool, error)
+	FindAccountEmailOfPostByCommentID(ctx context.Context, commentID *string) ([]interface{}, error)
+	FindAccountEmailOfPostAndCommentByCommentID(ctx context.Context, commentID *string) ([]interface{}, error)
 }
 
 type CommentRepositoryStruct struct {
@@ -27,27 +31,42 @@ func NewComentRepository(postgresDB *sql.DB) CommentRepository {
 	return &CommentRepositoryStruct{postgresDB}
 }
 
-func (p *CommentRepositoryStruct) InsertComment(comment *Comment) error {
+func (p *CommentRepositoryStruct) InsertComment(ctx context.Context, comment *Comment, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "comment", "InsertComment")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		INSERT INTO comment (id, account_id, post_id, comment_id, content, created_at, updated_at, removed)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	row := p.Db.QueryRow(sqlStatement, comment.ID, comment.AccountID, comment.PostID, comment.CommentID,
+	_, err = tx.ExecContext(ctx, sqlStatement, comment.ID, comment.AccountID, comment.PostID, comment.CommentID,
 		comment.Content, comment.CreatedAt, comment.UpdatedAt, comment.Removed)
-	if row.Err() != nil {
-		return row.Err()
+	if err != nil {
+		return err
+	}
+
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (p *CommentRepositoryStruct) ExistsCommentByID(id *string) (*bool, error) {
+func (p *CommentRepositoryStruct) ExistsCommentByID(ctx context.Context, id *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "ExistsCommentByID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM comment
 		WHERE id = $1
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +75,9 @@ func (p *CommentRepositoryStruct) ExistsCommentByID(id *string) (*bool, error) {
 	return &next, nil
 }
 
-func (p *CommentRepositoryStruct) FindCommentsByAccountID(accountID, page *string) ([]interface{}, error) {
+func (p *CommentRepositoryStruct) FindCommentsByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "FindCommentsByAccountID")
+	defer end()
 
 	stringQuery := `
 		SELECT comment.id, comment.account_id, comment.post_id, comment.comment_id, comment.content, comment.created_at, comment.updated_at, 
@@ -73,7 +94,7 @@ func (p *CommentRepositoryStruct) FindCommentsByAccountID(accountID, page *strin
 	OFFSET ($2 - 1) * 10
 	FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(stringQuery, accountID, page)
+	rows, err := p.Db.QueryContext(ctx, stringQuery, accountID, page)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +124,9 @@ func (p *CommentRepositoryStruct) FindCommentsByAccountID(accountID, page *strin
 	return list, nil
 }
 
-func (p *CommentRepositoryStruct) FindCommentsByPostOrCommentID(postID, commentID, page *string) ([]interface{}, error) {
+func (p *CommentRepositoryStruct) FindCommentsByPostOrCommentID(ctx context.Context, postID, commentID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "FindCommentsByPostOrCommentID")
+	defer end()
 
 	var str string
 	var value *string
@@ -130,7 +153,7 @@ func (p *CommentRepositoryStruct) FindCommentsByPostOrCommentID(postID, commentI
 		OFFSET ($2 - 1) * 10
 		FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(stringQuery, value, page)
+	rows, err := p.Db.QueryContext(ctx, stringQuery, value, page)
 	if err != nil {
 		return nil, err
 	}
@@ -160,40 +183,45 @@ func (p *CommentRepositoryStruct) FindCommentsByPostOrCommentID(postID, commentI
 	return list, nil
 }
 
-func (p *CommentRepositoryStruct) UpdateCommentDataByID(commentID, accountID, content *string) error {
+// UpdateCommentDataByID reports whether the comment was updated. With a
+// version, only a comment still at that version is.
+func (p *CommentRepositoryStruct) UpdateCommentDataByID(ctx context.Context, commentID, accountID, content *string, version *int) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "UpdateCommentDataByID")
+	defer end()
 	sqlStatement := `
 		UPDATE comment
-		SET content = $1, updated_at = $2
+		SET content = $1, updated_at = $2, version = version + 1
 		WHERE id = $3
 		AND account_id = $4
-		AND removed = false`
+		AND removed = false
+		AND ($5::INT IS NULL OR version = $5)`
 
 	updateTime := time.Now().UTC().Format("2006-01-02")
 
-	row := p.Db.QueryRow(sqlStatement, content, updateTime, commentID, accountID)
-	if row.Err() != nil {
-		return row.Err()
+	result, err := p.Db.ExecContext(ctx, sqlStatement, content, updateTime, commentID, accountID, version)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
 }
 
-func (p *CommentRepositoryStruct) FindCommentByID(id *string) (*Comment, error) {
+func (p *CommentRepositoryStruct) FindCommentByID(ctx context.Context, id *string) (*Comment, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "FindCommentByID")
+	defer end()
 	sqlStatement := `
-		SELECT id, account_id, post_id, comment_id, content, created_at, updated_at
+		SELECT id, account_id, post_id, comment_id, content, created_at, updated_at, version
 		FROM comment
 		WHERE id = $1
 		AND removed = false`
 
-	rows, err := p.Db.Query(sqlStatement, id)
-	if err != nil {
-		return nil, err
-	}
-
-	rows.Next()
-
 	var comment Comment
-	err = rows.Scan(
+	err := p.Db.QueryRowContext(ctx, sqlStatement, id).Scan(
 		&comment.ID,
 		&comment.AccountID,
 		&comment.PostID,
@@ -201,6 +229,7 @@ func (p *CommentRepositoryStruct) FindCommentByID(id *string) (*Comment, error)
 		&comment.Content,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
+		&comment.Version,
 	)
 	if err != nil {
 		return nil, err
@@ -209,29 +238,48 @@ func (p *CommentRepositoryStruct) FindCommentByID(id *string) (*Comment, error)
 	return &comment, nil
 }
 
-func (p *CommentRepositoryStruct) RemoveCommentByID(commentID, accountID *string) error {
+// RemoveCommentByID removes the comment of accountID. A comment removed by a moderator
+// comes with the entry of the audit log, stored in the same transaction.
+func (p *CommentRepositoryStruct) RemoveCommentByID(ctx context.Context, commentID, accountID *string, entry *audit.Audit) error {
+	ctx, end := postgresql.Observe(ctx, "comment", "RemoveCommentByID")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		UPDATE comment 
 		SET removed = true
 		WHERE id = $1
 		AND account_id = $2`
 
-	row := p.Db.QueryRow(sqlStatement, commentID, accountID)
-	if row.Err() != nil {
-		return row.Err()
+	_, err = tx.ExecContext(ctx, sqlStatement, commentID, accountID)
+	if err != nil {
+		return err
+	}
+
+	if entry != nil {
+		err = audit.InsertAudit(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (p *CommentRepositoryStruct) ExistsCommentByCommentIDAndAccountID(commentID, accountID *string) (*bool, error) {
+func (p *CommentRepositoryStruct) ExistsCommentByCommentIDAndAccountID(ctx context.Context, commentID, accountID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "ExistsCommentByCommentIDAndAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM comment
 		WHERE id = $1
 		AND account_id = $2
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, commentID, accountID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, commentID, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -240,7 +288,9 @@ func (p *CommentRepositoryStruct) ExistsCommentByCommentIDAndAccountID(commentID
 	return &next, nil
 }
 
-func (p *CommentRepositoryStruct) FindAccountEmailOfPostByCommentID(commentID *string) ([]interface{}, error) {
+func (p *CommentRepositoryStruct) FindAccountEmailOfPostByCommentID(ctx context.Context, commentID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "FindAccountEmailOfPostByCommentID")
+	defer end()
 	sqlStatement := `
 		SELECT account.email
 		FROM comment
@@ -248,7 +298,7 @@ func (p *CommentRepositoryStruct) FindAccountEmailOfPostByCommentID(commentID *s
 		INNER JOIN account ON account.id = post.account_id
 		WHERE comment.id = $1`
 
-	rows, err := p.Db.Query(sqlStatement, commentID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, commentID)
 	if err != nil {
 		return nil, err
 	}
@@ -268,7 +318,9 @@ func (p *CommentRepositoryStruct) FindAccountEmailOfPostByCommentID(commentID *s
 	return list, nil
 }
 
-func (p *CommentRepositoryStruct) FindAccountEmailOfPostAndCommentByCommentID(commentID *string) ([]interface{}, error) {
+func (p *CommentRepositoryStruct) FindAccountEmailOfPostAndCommentByCommentID(ctx context.Context, commentID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "comment", "FindAccountEmailOfPostAndCommentByCommentID")
+	defer end()
 	sqlStatement := `
 	SELECT account.email,	
 	(
@@ -282,7 +334,7 @@ func (p *CommentRepositoryStruct) FindAccountEmailOfPostAndCommentByCommentID(co
 	INNER JOIN account ON account.id = post.account_id
 	WHERE comment.id = $1`
 
-	rows, err := p.Db.Query(sqlStatement, commentID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, commentID)
 	if err != nil {
 		return nil, err
 	}
@@ -1,40 +1,51 @@
 package service
 
 import (
+	"context"
+	"log/slog"
 	"social_network_project/internal/account"
+	"social_network_project/internal/audit"
 	"social_network_project/internal/comment"
-	"social_network_project/internal/notification"
-	"social_network_project/internal/notification/service"
+	"social_network_project/internal/event"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/platform/metrics"
 	"social_network_project/internal/post"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
 )
 
 type CommentsServiceClient interface {
-	InsertComment(comment *comment.Comment) error
-	FindCommentsByAccountID(accountID, idToGet, postID, commentID, page *string) ([]interface{}, error)
-	UpdateCommentDataByID(comment *comment.Comment) (*comment.CommentResponse, error)
-	RemoveCommentByID(comment *comment.Comment) (*comment.CommentResponse, error)
+	InsertComment(ctx context.Context, comment *comment.Comment) error
+	FindCommentsByAccountID(ctx context.Context, accountID, idToGet, postID, commentID, page *string) ([]interface{}, error)
+	FindCommentByID(ctx context.Context, id *string) (*comment.CommentResponse, error)
+	UpdateCommentDataByID(ctx context.Context, comment *comment.Comment, ifMatch string) (*comment.CommentResponse, error)
+	RemoveCommentByID(ctx context.Context, comment *comment.Comment) (*comment.CommentResponse, error)
 }
 
 type CommentsService struct {
 	repositoryComment comment.CommentRepository
 	repositoryAccount account.AccountRepository
-	repositoryPost post.PostRepository
-	rabbitControl  service.NotificationServiceClient
+	repositoryPost    post.PostRepository
+	cache             cache.Invalidator
+	logger            *slog.Logger
 }
 
-func NewCommentsService(_repositoryComment comment.CommentRepository, _repositoryAccount account.AccountRepository, _repositoryPost post.PostRepository,rabbitmq service.NotificationServiceClient) CommentsServiceClient {
+func NewCommentsService(_repositoryComment comment.CommentRepository, _repositoryAccount account.AccountRepository, _repositoryPost post.PostRepository,
+	_cache cache.Invalidator, logger *slog.Logger) CommentsServiceClient {
 	return &CommentsService{
 		repositoryComment: _repositoryComment,
 		repositoryAccount: _repositoryAccount,
 		repositoryPost:    _repositoryPost,
-		rabbitControl:     rabbitmq,
+		cache:             _cache,
+		logger:            logger,
 	}
 }
 
-func (c *CommentsService) InsertComment(comment *comment.Comment) error {
+func (c *CommentsService) InsertComment(ctx context.Context, comment *comment.Comment) error {
 
-	existID, err := c.repositoryAccount.ExistsAccountByID(&comment.AccountID)
+	existID, err := c.repositoryAccount.ExistsAccountByID(ctx, &comment.AccountID)
 	if err != nil {
 		return err
 	}
@@ -43,7 +54,7 @@ func (c *CommentsService) InsertComment(comment *comment.Comment) error {
 	}
 
 	if comment.CommentID.String != "" {
-		existID, err = c.repositoryComment.ExistsCommentByID(&comment.CommentID.String)
+		existID, err = c.repositoryComment.ExistsCommentByID(ctx, &comment.CommentID.String)
 		if err != nil {
 			return err
 		}
@@ -52,18 +63,37 @@ func (c *CommentsService) InsertComment(comment *comment.Comment) error {
 		}
 	}
 
-	err = c.repositoryComment.InsertComment(comment)
+	existID, err = c.repositoryPost.ExistsPostByID(ctx, &comment.PostID)
 	if err != nil {
+		return err
+	}
+	if !*existID {
 		return &errors.NotFoundPostIDError{}
 	}
 
-	c.rabbitControl.SendMessage(notification.CreateNotificationJson("Comment", comment.ID))
+	message, err := outbox.NewOutbox(ctx, event.New(comment.AccountID, &event.CommentCreated{
+		CommentID:       comment.ID,
+		PostID:          comment.PostID,
+		ParentCommentID: comment.CommentID.String,
+		AccountID:       comment.AccountID,
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = c.repositoryComment.InsertComment(ctx, comment, message)
+	if err != nil {
+		return err
+	}
+	metrics.CommentsCreated.Inc()
+
+	c.invalidate(ctx, commentsTags(comment)...)
 	return nil
 }
 
-func (c *CommentsService) FindCommentsByAccountID(accountID, idToGet, postID, commentID, page *string) ([]interface{}, error) {
+func (c *CommentsService) FindCommentsByAccountID(ctx context.Context, accountID, idToGet, postID, commentID, page *string) ([]interface{}, error) {
 
-	existID, err := c.repositoryAccount.ExistsAccountByID(accountID)
+	existID, err := c.repositoryAccount.ExistsAccountByID(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +102,7 @@ func (c *CommentsService) FindCommentsByAccountID(accountID, idToGet, postID, co
 	}
 
 	if *idToGet != "" {
-		existID, err := c.repositoryAccount.ExistsAccountByID(idToGet)
+		existID, err := c.repositoryAccount.ExistsAccountByID(ctx, idToGet)
 		if err != nil {
 			return nil, err
 		}
@@ -83,34 +113,43 @@ func (c *CommentsService) FindCommentsByAccountID(accountID, idToGet, postID, co
 	}
 
 	if *postID != "" {
-		existID, err = c.repositoryPost.ExistsPostByID(postID)
+		existID, err = c.repositoryPost.ExistsPostByID(ctx, postID)
 		if err != nil {
 			return nil, err
 		}
 		if !*existID {
 			return nil, &errors.NotFoundPostIDError{}
 		}
-		return c.repositoryComment.FindCommentsByPostOrCommentID(postID, commentID, page)
+		return c.repositoryComment.FindCommentsByPostOrCommentID(ctx, postID, commentID, page)
 
 	}
 
 	if *commentID != "" {
-		existID, err = c.repositoryComment.ExistsCommentByID(commentID)
+		existID, err = c.repositoryComment.ExistsCommentByID(ctx, commentID)
 		if err != nil {
 			return nil, err
 		}
 		if !*existID {
 			return nil, &errors.NotFoundCommentIDError{}
 		}
-		return c.repositoryComment.FindCommentsByPostOrCommentID(postID, commentID, page)
+		return c.repositoryComment.FindCommentsByPostOrCommentID(ctx, postID, commentID, page)
 	}
 
-	return c.repositoryComment.FindCommentsByAccountID(accountID, page)
+	return c.repositoryComment.FindCommentsByAccountID(ctx, accountID, page)
 }
 
-func (c *CommentsService) UpdateCommentDataByID(comment *comment.Comment) (*comment.CommentResponse, error) {
+func (c *CommentsService) FindCommentByID(ctx context.Context, id *string) (*comment.CommentResponse, error) {
+	found, err := c.repositoryComment.FindCommentByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.NotFoundCommentIDError{})
+	}
+
+	return found.ToResponse(), nil
+}
 
-	exist, err := c.repositoryComment.ExistsCommentByID(&comment.ID)
+func (c *CommentsService) UpdateCommentDataByID(ctx context.Context, comment *comment.Comment, ifMatch string) (*comment.CommentResponse, error) {
+
+	exist, err := c.repositoryComment.ExistsCommentByID(ctx, &comment.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +157,7 @@ func (c *CommentsService) UpdateCommentDataByID(comment *comment.Comment) (*comm
 		return nil, &errors.NotFoundCommentIDError{}
 	}
 
-	exist, err = c.repositoryComment.ExistsCommentByCommentIDAndAccountID(&comment.ID, &comment.AccountID)
+	exist, err = c.repositoryComment.ExistsCommentByCommentIDAndAccountID(ctx, &comment.ID, &comment.AccountID)
 	if err != nil {
 		return nil, err
 	}
@@ -126,38 +165,96 @@ func (c *CommentsService) UpdateCommentDataByID(comment *comment.Comment) (*comm
 		return nil, &errors.UnauthorizedAccountIDError{}
 	}
 
-	err = c.repositoryComment.UpdateCommentDataByID(&comment.ID, &comment.AccountID, &comment.Content)
+	var version *int
+	if ifMatch != "" {
+		current, err := c.repositoryComment.FindCommentByID(ctx, &comment.ID)
+		if err != nil {
+			return nil, errors.NotFound(err, &errors.NotFoundCommentIDError{})
+		}
+		err = utils.CheckIfMatch(ifMatch, current.ToResponse())
+		if err != nil {
+			return nil, err
+		}
+		version = &current.Version
+	}
+
+	updated, err := c.repositoryComment.UpdateCommentDataByID(ctx, &comment.ID, &comment.AccountID, &comment.Content, version)
 	if err != nil {
 		return nil, err
 	}
+	if !updated && version != nil {
+		return nil, &errors.PreconditionFailedError{}
+	}
+	c.invalidate(ctx, cache.CommentTag(comment.ID))
 
-	postUpdated, err := c.repositoryComment.FindCommentByID(&comment.ID)
+	postUpdated, err := c.repositoryComment.FindCommentByID(ctx, &comment.ID)
 	if err != nil {
-		return nil, &errors.NotFoundCommentIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundCommentIDError{})
 	}
 
 	return postUpdated.ToResponse(), nil
 }
 
-func (p CommentsService) RemoveCommentByID(comment *comment.Comment) (*comment.CommentResponse, error) {
+func (p CommentsService) RemoveCommentByID(ctx context.Context, comment *comment.Comment) (*comment.CommentResponse, error) {
 
-	commentToRemoved, err := p.repositoryComment.FindCommentByID(&comment.ID)
+	commentToRemoved, err := p.repositoryComment.FindCommentByID(ctx, &comment.ID)
 	if err != nil {
-		return nil, &errors.NotFoundCommentIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundCommentIDError{})
 	}
 
-	existID, err := p.repositoryComment.ExistsCommentByCommentIDAndAccountID(&comment.ID, &comment.AccountID)
+	existID, err := p.repositoryComment.ExistsCommentByCommentIDAndAccountID(ctx, &comment.ID, &comment.AccountID)
 	if err != nil {
 		return nil, err
 	}
+	var entry *audit.Audit
 	if !*existID {
-		return nil, &errors.UnauthorizedAccountIDError{}
+		entry, err = p.moderation(ctx, comment.AccountID, commentToRemoved)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = p.repositoryComment.RemoveCommentByID(&comment.ID, &comment.AccountID)
+	err = p.repositoryComment.RemoveCommentByID(ctx, &comment.ID, &commentToRemoved.AccountID, entry)
 	if err != nil {
 		return nil, err
 	}
+	p.invalidate(ctx, append(commentsTags(commentToRemoved), cache.CommentTag(comment.ID))...)
 
 	return commentToRemoved.ToResponse(), nil
 }
+
+// moderation returns the audit entry of accountID removing a comment of
+// someone else, or UnauthorizedAccountIDError when its role does not allow it.
+func (c *CommentsService) moderation(ctx context.Context, accountID string, commentToRemoved *comment.Comment) (*audit.Audit, error) {
+	actor, err := c.repositoryAccount.FindAccountByID(ctx, &accountID)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.UnauthorizedAccountIDError{})
+	}
+	if !rbac.Can(actor.Role, rbac.PERMISSION_REMOVE_ANY_COMMENT) {
+		return nil, &errors.UnauthorizedAccountIDError{}
+	}
+
+	return audit.NewAudit(accountID, audit.ACTION_REMOVE_COMMENT, audit.TARGET_COMMENT, commentToRemoved.ID, map[string]string{
+		"account_id": commentToRemoved.AccountID,
+		"post_id":    commentToRemoved.PostID,
+	}), nil
+}
+
+func (c *CommentsService) invalidate(ctx context.Context, tags ...string) {
+	err := c.cache.Invalidate(ctx, tags...)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "invalidating cache", "tags", tags, "error", err)
+	}
+}
+
+// commentsTags lists the tags of the comment lists a comment appears in.
+func commentsTags(comment *comment.Comment) []string {
+	tags := []string{
+		cache.CommentsOfAccountTag(comment.AccountID),
+		cache.CommentsOfPostTag(comment.PostID),
+	}
+	if comment.CommentID.String != "" {
+		tags = append(tags, cache.CommentsOfCommentTag(comment.CommentID.String))
+	}
+	return tags
+}
@@ -0,0 +1,121 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type Type string
+
+// Event is the payload of an Envelope. Every implementation is registered
+// with its current schema version in registry.go.
+type Event interface {
+	EventType() Type
+}
+
+type Envelope struct {
+	ID         string
+	Type       Type
+	Version    int
+	OccurredAt time.Time
+	ActorID    string
+	Payload    Event
+}
+
+type envelopeJSON struct {
+	ID         string          `json:"event_id"`
+	Type       Type            `json:"type"`
+	Version    int             `json:"schema_version"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    string          `json:"actor_id"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+func New(actorID string, payload Event) *Envelope {
+	return &Envelope{
+		ID:         uuid.New().String(),
+		Type:       payload.EventType(),
+		Version:    CurrentVersion(payload.EventType()),
+		OccurredAt: time.Now().UTC(),
+		ActorID:    actorID,
+		Payload:    payload,
+	}
+}
+
+func Encode(envelope *Envelope) ([]byte, error) {
+	payload, err := json.Marshal(envelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelopeJSON{
+		ID:         envelope.ID,
+		Type:       envelope.Type,
+		Version:    envelope.Version,
+		OccurredAt: envelope.OccurredAt,
+		ActorID:    envelope.ActorID,
+		Payload:    payload,
+	})
+}
+
+// Decode parses an encoded envelope, rejecting unknown fields, unknown event
+// types and versions newer than this build knows. Payloads written with an
+// older schema version are upgraded to the current one before decoding.
+func Decode(data []byte) (*Envelope, error) {
+	var raw envelopeJSON
+	err := decodeStrict(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if raw.ID == "" {
+		return nil, fmt.Errorf("event: missing event_id")
+	}
+	if len(raw.Payload) == 0 || string(raw.Payload) == "null" {
+		return nil, fmt.Errorf("event %s: missing payload", raw.ID)
+	}
+
+	s, ok := schemas[raw.Type]
+	if !ok {
+		return nil, fmt.Errorf("event %s: unknown type %q", raw.ID, raw.Type)
+	}
+	if raw.Version < 1 || raw.Version > s.version {
+		return nil, fmt.Errorf("event %s: unsupported version %d of %q", raw.ID, raw.Version, raw.Type)
+	}
+
+	payload := raw.Payload
+	for version := raw.Version; version < s.version; version++ {
+		upgrade, ok := s.upgrades[version]
+		if !ok {
+			return nil, fmt.Errorf("event %s: no upgrade of %q from version %d", raw.ID, raw.Type, version)
+		}
+		payload, err = upgrade(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	e := s.newEvent()
+	err = decodeStrict(payload, e)
+	if err != nil {
+		return nil, fmt.Errorf("event %s: %w", raw.ID, err)
+	}
+
+	return &Envelope{
+		ID:         raw.ID,
+		Type:       raw.Type,
+		Version:    s.version,
+		OccurredAt: raw.OccurredAt,
+		ActorID:    raw.ActorID,
+		Payload:    e,
+	}, nil
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,100 @@
+package event
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type renamedField struct {
+	Name string `json:"name"`
+}
+
+func (e *renamedField) EventType() Type {
+	return "test.renamed"
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		envelope := New("6c08496b-b721-4e06-b0b7-1905524c9da2", &AccountFollowed{
+			AccountID:         "6c08496b-b721-4e06-b0b7-1905524c9da2",
+			FollowedAccountID: "8216385e-730b-40a7-8fbd-a37889feac7d",
+		})
+
+		data, err := Encode(envelope)
+		assert.Nil(t, err)
+
+		decoded, err := Decode(data)
+		assert.Nil(t, err)
+		assert.Equal(t, envelope.ID, decoded.ID)
+		assert.Equal(t, TypeAccountFollowed, decoded.Type)
+		assert.Equal(t, 1, decoded.Version)
+		assert.Equal(t, envelope.ActorID, decoded.ActorID)
+		assert.True(t, envelope.OccurredAt.Equal(decoded.OccurredAt))
+		assert.Equal(t, envelope.Payload, decoded.Payload)
+	})
+	t.Run("unknown envelope field", func(t *testing.T) {
+		_, err := Decode([]byte(`{"event_id":"1","type":"post.created","schema_version":1,"payload":{},"extra":true}`))
+		assert.NotNil(t, err)
+	})
+	t.Run("unknown payload field", func(t *testing.T) {
+		_, err := Decode([]byte(`{"event_id":"1","type":"post.created","schema_version":1,"payload":{"post_id":"1","title":"x"}}`))
+		assert.NotNil(t, err)
+	})
+	t.Run("unknown type", func(t *testing.T) {
+		_, err := Decode([]byte(`{"event_id":"1","type":"post.deleted","schema_version":1,"payload":{}}`))
+		assert.NotNil(t, err)
+	})
+	t.Run("newer version", func(t *testing.T) {
+		_, err := Decode([]byte(`{"event_id":"1","type":"post.created","schema_version":2,"payload":{}}`))
+		assert.NotNil(t, err)
+	})
+	t.Run("missing payload", func(t *testing.T) {
+		_, err := Decode([]byte(`{"event_id":"1","type":"post.created","schema_version":1}`))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestRegisterUpgrade(t *testing.T) {
+	Register("test.renamed", 2, func() Event { return &renamedField{} })
+	RegisterUpgrade("test.renamed", 1, func(payload json.RawMessage) (json.RawMessage, error) {
+		var v1 struct {
+			Title string `json:"title"`
+		}
+		err := json.Unmarshal(payload, &v1)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(renamedField{Name: v1.Title})
+	})
+	defer delete(schemas, "test.renamed")
+
+	decoded, err := Decode([]byte(`{"event_id":"1","type":"test.renamed","schema_version":1,"payload":{"title":"old"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, decoded.Version)
+	assert.Equal(t, &renamedField{Name: "old"}, decoded.Payload)
+}
+
+func TestRegisterUpgradePanics(t *testing.T) {
+	upgrade := func(payload json.RawMessage) (json.RawMessage, error) { return payload, nil }
+
+	t.Run("unregistered type", func(t *testing.T) {
+		assert.PanicsWithValue(t, `event: upgrade of unregistered type "test.unknown"`, func() {
+			RegisterUpgrade("test.unknown", 1, upgrade)
+		})
+	})
+	t.Run("version not older than the current one", func(t *testing.T) {
+		assert.PanicsWithValue(t, `event: upgrade of "post.created" from version 1, which is not older than version 1`, func() {
+			RegisterUpgrade(TypePostCreated, 1, upgrade)
+		})
+	})
+}
+
+func TestDecodeAccountLockedVersion1(t *testing.T) {
+	decoded, err := Decode([]byte(`{"event_id":"1","type":"account.locked","schema_version":1,"occurred_at":"2022-08-01T12:00:00Z",` +
+		`"actor_id":"6c08496b-b721-4e06-b0b7-1905524c9da2","payload":{"account_id":"6c08496b-b721-4e06-b0b7-1905524c9da2",` +
+		`"locked_until":"2022-08-01T12:15:00Z","unlock_token":"5f2b1c"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, decoded.Version)
+	assert.Equal(t, "6c08496b-b721-4e06-b0b7-1905524c9da2", decoded.Payload.(*AccountLocked).AccountID)
+}
@@ -0,0 +1,76 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// UpgradeFunc rewrites a payload of one schema version into the next one.
+type UpgradeFunc func(payload json.RawMessage) (json.RawMessage, error)
+
+type schema struct {
+	version  int
+	newEvent func() Event
+	upgrades map[int]UpgradeFunc
+}
+
+var schemas = map[Type]*schema{}
+
+func init() {
+	Register(TypePostCreated, 1, func() Event { return &PostCreated{} })
+	Register(TypeCommentCreated, 1, func() Event { return &CommentCreated{} })
+	Register(TypeInteractionCreated, 1, func() Event { return &InteractionCreated{} })
+	Register(TypeAccountFollowed, 1, func() Event { return &AccountFollowed{} })
+	Register(TypeAccountUnfollowed, 1, func() Event { return &AccountUnfollowed{} })
+	Register(TypeWebhookPing, 1, func() Event { return &WebhookPing{} })
+	Register(TypeAccountLocked, 2, func() Event { return &AccountLocked{} })
+	// Version 1 carried the unlock code in plain text.
+	RegisterUpgrade(TypeAccountLocked, 1, dropField("unlock_token"))
+}
+
+// Register declares the current schema version of an event type. Bumping the
+// version of an existing type requires a RegisterUpgrade from the previous
+// version, so events still waiting in the outbox or the queue can be read.
+func Register(eventType Type, version int, newEvent func() Event) {
+	schemas[eventType] = &schema{
+		version:  version,
+		newEvent: newEvent,
+		upgrades: map[int]UpgradeFunc{},
+	}
+}
+
+// RegisterUpgrade declares how to upgrade a payload of eventType from
+// fromVersion to the next one. Like Register it is called at init, so it
+// panics when eventType is not registered or fromVersion is not older than its
+// current version.
+func RegisterUpgrade(eventType Type, fromVersion int, upgrade UpgradeFunc) {
+	s, ok := schemas[eventType]
+	if !ok {
+		panic(fmt.Sprintf("event: upgrade of unregistered type %q", eventType))
+	}
+	if fromVersion < 1 || fromVersion >= s.version {
+		panic(fmt.Sprintf("event: upgrade of %q from version %d, which is not older than version %d", eventType, fromVersion, s.version))
+	}
+	s.upgrades[fromVersion] = upgrade
+}
+
+// dropField upgrades a payload by removing one of its fields.
+func dropField(name string) UpgradeFunc {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]json.RawMessage
+		err := json.Unmarshal(payload, &fields)
+		if err != nil {
+			return nil, err
+		}
+		delete(fields, name)
+		return json.Marshal(fields)
+	}
+}
+
+func CurrentVersion(eventType Type) int {
+	s, ok := schemas[eventType]
+	if !ok {
+		return 0
+	}
+	return s.version
+}
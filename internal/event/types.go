@@ -0,0 +1,83 @@
+package event
+
+import "time"
+
+const (
+	TypePostCreated        Type = "post.created"
+	TypeCommentCreated     Type = "comment.created"
+	TypeInteractionCreated Type = "interaction.created"
+	TypeAccountFollowed    Type = "account.followed"
+	TypeAccountUnfollowed  Type = "account.unfollowed"
+	TypeWebhookPing        Type = "webhook.ping"
+	TypeAccountLocked      Type = "account.locked"
+)
+
+type PostCreated struct {
+	PostID    string `json:"post_id"`
+	AccountID string `json:"account_id"`
+}
+
+func (e *PostCreated) EventType() Type {
+	return TypePostCreated
+}
+
+type CommentCreated struct {
+	CommentID       string `json:"comment_id"`
+	PostID          string `json:"post_id"`
+	ParentCommentID string `json:"parent_comment_id,omitempty"`
+	AccountID       string `json:"account_id"`
+}
+
+func (e *CommentCreated) EventType() Type {
+	return TypeCommentCreated
+}
+
+type InteractionCreated struct {
+	InteractionID string `json:"interaction_id"`
+	PostID        string `json:"post_id,omitempty"`
+	CommentID     string `json:"comment_id,omitempty"`
+	AccountID     string `json:"account_id"`
+	Type          string `json:"type"`
+}
+
+func (e *InteractionCreated) EventType() Type {
+	return TypeInteractionCreated
+}
+
+type AccountFollowed struct {
+	AccountID         string `json:"account_id"`
+	FollowedAccountID string `json:"followed_account_id"`
+}
+
+func (e *AccountFollowed) EventType() Type {
+	return TypeAccountFollowed
+}
+
+type AccountUnfollowed struct {
+	AccountID           string `json:"account_id"`
+	UnfollowedAccountID string `json:"unfollowed_account_id"`
+}
+
+func (e *AccountUnfollowed) EventType() Type {
+	return TypeAccountUnfollowed
+}
+
+type WebhookPing struct {
+	WebhookID string `json:"webhook_id"`
+}
+
+func (e *WebhookPing) EventType() Type {
+	return TypeWebhookPing
+}
+
+// AccountLocked is only delivered to the account owner, with the one-time code
+// that unlocks the account. The code is minted when the notice is sent, so it
+// is never written to the outbox or the queue.
+type AccountLocked struct {
+	AccountID   string    `json:"account_id"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+func (e *AccountLocked) EventType() Type {
+	return TypeAccountLocked
+}
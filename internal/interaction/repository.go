@@ -1,21 +1,24 @@
 package interaction
 
 import (
+	"context"
 	"database/sql"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/database/postgresql"
 	"time"
 )
 
 type InteractionRepository interface {
-	InsertInteraction(interaction *Interaction) error
-	ExistsInteractionByID(id *string) (*bool, error)
-	UpdateInteractonDataByID(interactionID, accountID *string, typeValue *InteractionType) error
-	ExistsInteractionByInteractionIDAndAccountID(interactionID, accountID *string) (*bool, error)
-	FindInteractionByID(id *string) (*Interaction, error)
-	RemoveInteractionByID(interactionID, accountID *string) error
-	ExistsInteractionByPostIDAndAccountID(postID, accountID *string) (*bool, error)
-	ExistsInteractionByCommentIDAndAccountID(commentID, accountID *string) (*bool, error)
-	FindAccountEmailOfPostByInteractionID(interactionID *string) ([]interface{}, error)
-	FindAccountEmailOfCommentByInteractionID(interactionID *string) ([]interface{}, error)
+	InsertInteraction(ctx context.Context, interaction *Interaction, message *outbox.Outbox) error
+	ExistsInteractionByID(ctx context.Context, id *string) (*bool, error)
+	UpdateInteractonDataByID(ctx context.Context, interactionID, accountID *string, typeValue *InteractionType) error
+	ExistsInteractionByInteractionIDAndAccountID(ctx context.Context, interactionID, accountID *string) (*bool, error)
+	FindInteractionByID(ctx context.Context, id *string) (*Interaction, error)
+	RemoveInteractionByID(ctx context.Context, interactionID, accountID *string) error
+	ExistsInteractionByPostIDAndAccountID(ctx context.Context, postID, accountID *string) (*bool, error)
+	ExistsInteractionByCommentIDAndAccountID(ctx context.Context, commentID, accountID *string) (*bool, error)
+	FindAccountEmailOfPostByInteractionID(ctx context.Context, interactionID *string) ([]interface{}, error)
+	FindAccountEmailOfCommentByInteractionID(ctx context.Context, interactionID *string) ([]interface{}, error)
 }
 
 type InteractionRepositoryStruct struct {
@@ -26,27 +29,42 @@ func NewInteractionRepository(postgresDB *sql.DB) InteractionRepository {
 	return &InteractionRepositoryStruct{postgresDB}
 }
 
-func (p *InteractionRepositoryStruct) InsertInteraction(interaction *Interaction) error {
+func (p *InteractionRepositoryStruct) InsertInteraction(ctx context.Context, interaction *Interaction, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "interaction", "InsertInteraction")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		INSERT INTO interaction (id, account_id, post_id, comment_id, type, created_at, updated_at, removed)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 
-	row := p.Db.QueryRow(sqlStatement, interaction.ID, interaction.AccountID, interaction.PostID, interaction.CommentID,
+	_, err = tx.ExecContext(ctx, sqlStatement, interaction.ID, interaction.AccountID, interaction.PostID, interaction.CommentID,
 		interaction.Type.ToString(), interaction.CreatedAt, interaction.UpdatedAt, interaction.Removed)
-	if row.Err() != nil {
-		return row.Err()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (p *InteractionRepositoryStruct) ExistsInteractionByID(id *string) (*bool, error) {
+func (p *InteractionRepositoryStruct) ExistsInteractionByID(ctx context.Context, id *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "ExistsInteractionByID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM interaction
 		WHERE id = $1
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +73,9 @@ func (p *InteractionRepositoryStruct) ExistsInteractionByID(id *string) (*bool,
 	return &next, nil
 }
 
-func (p *InteractionRepositoryStruct) UpdateInteractonDataByID(interactionID, accountID *string, typeValue *InteractionType) error {
+func (p *InteractionRepositoryStruct) UpdateInteractonDataByID(ctx context.Context, interactionID, accountID *string, typeValue *InteractionType) error {
+	ctx, end := postgresql.Observe(ctx, "interaction", "UpdateInteractonDataByID")
+	defer end()
 	sqlStatement := `
 		UPDATE interaction
 		SET type = $1, updated_at = $2
@@ -65,7 +85,7 @@ func (p *InteractionRepositoryStruct) UpdateInteractonDataByID(interactionID, ac
 
 	updateTime := time.Now().UTC().Format("2006-01-02")
 
-	row := p.Db.QueryRow(sqlStatement, typeValue.ToString(), updateTime, interactionID, accountID)
+	row := p.Db.QueryRowContext(ctx, sqlStatement, typeValue.ToString(), updateTime, interactionID, accountID)
 	if row.Err() != nil {
 		return row.Err()
 	}
@@ -73,14 +93,16 @@ func (p *InteractionRepositoryStruct) UpdateInteractonDataByID(interactionID, ac
 	return nil
 }
 
-func (p *InteractionRepositoryStruct) ExistsInteractionByInteractionIDAndAccountID(interactionID, accountID *string) (*bool, error) {
+func (p *InteractionRepositoryStruct) ExistsInteractionByInteractionIDAndAccountID(ctx context.Context, interactionID, accountID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "ExistsInteractionByInteractionIDAndAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM interaction
 		WHERE id = $1
 		AND account_id = $2
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, interactionID, accountID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, interactionID, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,14 +111,16 @@ func (p *InteractionRepositoryStruct) ExistsInteractionByInteractionIDAndAccount
 	return &next, nil
 }
 
-func (p *InteractionRepositoryStruct) FindInteractionByID(id *string) (*Interaction, error) {
+func (p *InteractionRepositoryStruct) FindInteractionByID(ctx context.Context, id *string) (*Interaction, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "FindInteractionByID")
+	defer end()
 	sqlStatement := `
 		SELECT id, account_id, post_id, comment_id, type, created_at, updated_at
 		FROM interaction
 		WHERE id = $1
 		AND removed = false`
 
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -123,14 +147,16 @@ func (p *InteractionRepositoryStruct) FindInteractionByID(id *string) (*Interact
 	return &interaction, nil
 }
 
-func (p *InteractionRepositoryStruct) RemoveInteractionByID(interactionID, accountID *string) error {
+func (p *InteractionRepositoryStruct) RemoveInteractionByID(ctx context.Context, interactionID, accountID *string) error {
+	ctx, end := postgresql.Observe(ctx, "interaction", "RemoveInteractionByID")
+	defer end()
 	sqlStatement := `
 		UPDATE interaction 
 		SET removed = true
 		WHERE id = $1
 		AND account_id = $2`
 
-	row := p.Db.QueryRow(sqlStatement, interactionID, accountID)
+	row := p.Db.QueryRowContext(ctx, sqlStatement, interactionID, accountID)
 	if row.Err() != nil {
 		return row.Err()
 	}
@@ -138,14 +164,16 @@ func (p *InteractionRepositoryStruct) RemoveInteractionByID(interactionID, accou
 	return nil
 }
 
-func (p *InteractionRepositoryStruct) ExistsInteractionByPostIDAndAccountID(postID, accountID *string) (*bool, error) {
+func (p *InteractionRepositoryStruct) ExistsInteractionByPostIDAndAccountID(ctx context.Context, postID, accountID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "ExistsInteractionByPostIDAndAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM interaction
 		WHERE post_id = $1
 		AND account_id = $2
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, postID, accountID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, postID, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -154,14 +182,16 @@ func (p *InteractionRepositoryStruct) ExistsInteractionByPostIDAndAccountID(post
 	return &next, nil
 }
 
-func (p *InteractionRepositoryStruct) ExistsInteractionByCommentIDAndAccountID(commentID, accountID *string) (*bool, error) {
+func (p *InteractionRepositoryStruct) ExistsInteractionByCommentIDAndAccountID(ctx context.Context, commentID, accountID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "ExistsInteractionByCommentIDAndAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM interaction
 		WHERE comment_id = $1
 		AND account_id = $2
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, commentID, accountID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, commentID, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -170,7 +200,9 @@ func (p *InteractionRepositoryStruct) ExistsInteractionByCommentIDAndAccountID(c
 	return &next, nil
 }
 
-func (p *InteractionRepositoryStruct) FindAccountEmailOfPostByInteractionID(interactionID *string) ([]interface{}, error) {
+func (p *InteractionRepositoryStruct) FindAccountEmailOfPostByInteractionID(ctx context.Context, interactionID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "FindAccountEmailOfPostByInteractionID")
+	defer end()
 	sqlStatement := `
 	SELECT account.email
 	FROM interaction
@@ -178,7 +210,7 @@ func (p *InteractionRepositoryStruct) FindAccountEmailOfPostByInteractionID(inte
 	INNER JOIN account ON account.id = post.account_id
 	WHERE interaction.id = $1`
 
-	rows, err := p.Db.Query(sqlStatement, interactionID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, interactionID)
 	if err != nil {
 		return nil, err
 	}
@@ -198,7 +230,9 @@ func (p *InteractionRepositoryStruct) FindAccountEmailOfPostByInteractionID(inte
 	return list, nil
 }
 
-func (p *InteractionRepositoryStruct) FindAccountEmailOfCommentByInteractionID(interactionID *string) ([]interface{}, error) {
+func (p *InteractionRepositoryStruct) FindAccountEmailOfCommentByInteractionID(ctx context.Context, interactionID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "interaction", "FindAccountEmailOfCommentByInteractionID")
+	defer end()
 	sqlStatement := `
 	SELECT account.email
 	FROM interaction
@@ -206,7 +240,7 @@ func (p *InteractionRepositoryStruct) FindAccountEmailOfCommentByInteractionID(i
 	INNER JOIN account ON account.id = comment.account_id
 	WHERE interaction.id = $1`
 
-	rows, err := p.Db.Query(sqlStatement, interactionID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, interactionID)
 	if err != nil {
 		return nil, err
 	}
@@ -1,39 +1,45 @@
 package service
 
 import (
+	"context"
+	"log/slog"
 	"social_network_project/internal/account"
 	"social_network_project/internal/comment"
+	"social_network_project/internal/event"
 	"social_network_project/internal/interaction"
-	"social_network_project/internal/notification"
-	"social_network_project/internal/notification/service"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/cache"
 	"social_network_project/internal/utils/errors"
 )
 
 type InteractionsServiceClient interface {
-	InsertInteraction(interaction *interaction.Interaction) error
-	UpdateInteractonDataByID(interaction *interaction.Interaction) (*interaction.InteractionResponse, error)
-	RemoveInteractionByID(interaction *interaction.Interaction) (*interaction.InteractionResponse, error)
+	InsertInteraction(ctx context.Context, interaction *interaction.Interaction) error
+	UpdateInteractonDataByID(ctx context.Context, interaction *interaction.Interaction) (*interaction.InteractionResponse, error)
+	RemoveInteractionByID(ctx context.Context, interaction *interaction.Interaction) (*interaction.InteractionResponse, error)
 }
 
 type InteractionsService struct {
 	repositoryAccount     account.AccountRepository
 	repositoryComment     comment.CommentRepository
 	repositoryInteraction interaction.InteractionRepository
-	rabbitControl         service.NotificationServiceClient
+	cache                 cache.Invalidator
+	logger                *slog.Logger
 }
 
-func NewInteractionsService(_repositoryAccount account.AccountRepository, _repositoryComment comment.CommentRepository, _repositoryInteraction interaction.InteractionRepository, rabbitmq service.NotificationServiceClient) InteractionsServiceClient {
+func NewInteractionsService(_repositoryAccount account.AccountRepository, _repositoryComment comment.CommentRepository, _repositoryInteraction interaction.InteractionRepository,
+	_cache cache.Invalidator, logger *slog.Logger) InteractionsServiceClient {
 	return &InteractionsService{
 		repositoryAccount:     _repositoryAccount,
 		repositoryComment:     _repositoryComment,
 		repositoryInteraction: _repositoryInteraction,
-		rabbitControl:         rabbitmq,
+		cache:                 _cache,
+		logger:                logger,
 	}
 }
 
-func (i InteractionsService) InsertInteraction(interaction *interaction.Interaction) error {
+func (i InteractionsService) InsertInteraction(ctx context.Context, interaction *interaction.Interaction) error {
 
-	existID, err := i.repositoryAccount.ExistsAccountByID(&interaction.AccountID)
+	existID, err := i.repositoryAccount.ExistsAccountByID(ctx, &interaction.AccountID)
 	if err != nil {
 		return err
 	}
@@ -42,14 +48,14 @@ func (i InteractionsService) InsertInteraction(interaction *interaction.Interact
 	}
 
 	if interaction.CommentID.String != "" {
-		existID, err = i.repositoryComment.ExistsCommentByID(&interaction.CommentID.String)
+		existID, err = i.repositoryComment.ExistsCommentByID(ctx, &interaction.CommentID.String)
 		if err != nil {
 			return err
 		}
 		if !*existID {
 			return &errors.NotFoundCommentIDError{}
 		}
-		existID, err = i.repositoryInteraction.ExistsInteractionByCommentIDAndAccountID(&interaction.CommentID.String, &interaction.AccountID)
+		existID, err = i.repositoryInteraction.ExistsInteractionByCommentIDAndAccountID(ctx, &interaction.CommentID.String, &interaction.AccountID)
 		if err != nil {
 			return err
 		}
@@ -59,7 +65,7 @@ func (i InteractionsService) InsertInteraction(interaction *interaction.Interact
 	}
 
 	if interaction.PostID.String != "" {
-		existID, err = i.repositoryInteraction.ExistsInteractionByPostIDAndAccountID(&interaction.PostID.String, &interaction.AccountID)
+		existID, err = i.repositoryInteraction.ExistsInteractionByPostIDAndAccountID(ctx, &interaction.PostID.String, &interaction.AccountID)
 		if err != nil {
 			return err
 		}
@@ -68,18 +74,29 @@ func (i InteractionsService) InsertInteraction(interaction *interaction.Interact
 		}
 	}
 
-	err = i.repositoryInteraction.InsertInteraction(interaction)
+	message, err := outbox.NewOutbox(ctx, event.New(interaction.AccountID, &event.InteractionCreated{
+		InteractionID: interaction.ID,
+		PostID:        interaction.PostID.String,
+		CommentID:     interaction.CommentID.String,
+		AccountID:     interaction.AccountID,
+		Type:          interaction.Type.ToString(),
+	}))
+	if err != nil {
+		return err
+	}
+
+	err = i.repositoryInteraction.InsertInteraction(ctx, interaction, message)
 	if err != nil {
 		return &errors.NotFoundPostIDError{}
 	}
 
-	i.rabbitControl.SendMessage(notification.CreateNotificationJson("Interaction", interaction.ID))
+	i.invalidate(ctx, interaction)
 	return nil
 }
 
-func (i InteractionsService) UpdateInteractonDataByID(interaction *interaction.Interaction) (*interaction.InteractionResponse, error) {
+func (i InteractionsService) UpdateInteractonDataByID(ctx context.Context, interaction *interaction.Interaction) (*interaction.InteractionResponse, error) {
 
-	exist, err := i.repositoryInteraction.ExistsInteractionByID(&interaction.ID)
+	exist, err := i.repositoryInteraction.ExistsInteractionByID(ctx, &interaction.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +104,7 @@ func (i InteractionsService) UpdateInteractonDataByID(interaction *interaction.I
 		return nil, &errors.NotFoundInteractionIDError{}
 	}
 
-	exist, err = i.repositoryInteraction.ExistsInteractionByInteractionIDAndAccountID(&interaction.ID, &interaction.AccountID)
+	exist, err = i.repositoryInteraction.ExistsInteractionByInteractionIDAndAccountID(ctx, &interaction.ID, &interaction.AccountID)
 	if err != nil {
 		return nil, err
 	}
@@ -95,27 +112,28 @@ func (i InteractionsService) UpdateInteractonDataByID(interaction *interaction.I
 		return nil, &errors.UnauthorizedAccountIDError{}
 	}
 
-	err = i.repositoryInteraction.UpdateInteractonDataByID(&interaction.ID, &interaction.AccountID, &interaction.Type)
+	err = i.repositoryInteraction.UpdateInteractonDataByID(ctx, &interaction.ID, &interaction.AccountID, &interaction.Type)
 	if err != nil {
 		return nil, err
 	}
 
-	interactionUpdated, err := i.repositoryInteraction.FindInteractionByID(&interaction.ID)
+	interactionUpdated, err := i.repositoryInteraction.FindInteractionByID(ctx, &interaction.ID)
 	if err != nil {
 		return nil, &errors.NotFoundInteractionIDError{}
 	}
+	i.invalidate(ctx, interactionUpdated)
 
 	return interactionUpdated.ToResponse(), nil
 }
 
-func (i InteractionsService) RemoveInteractionByID(interaction *interaction.Interaction) (*interaction.InteractionResponse, error) {
+func (i InteractionsService) RemoveInteractionByID(ctx context.Context, interaction *interaction.Interaction) (*interaction.InteractionResponse, error) {
 
-	interactionToRemove, err := i.repositoryInteraction.FindInteractionByID(&interaction.ID)
+	interactionToRemove, err := i.repositoryInteraction.FindInteractionByID(ctx, &interaction.ID)
 	if err != nil {
 		return nil, &errors.NotFoundInteractionIDError{}
 	}
 
-	existID, err := i.repositoryInteraction.ExistsInteractionByInteractionIDAndAccountID(&interaction.ID, &interaction.AccountID)
+	existID, err := i.repositoryInteraction.ExistsInteractionByInteractionIDAndAccountID(ctx, &interaction.ID, &interaction.AccountID)
 	if err != nil {
 		return nil, err
 	}
@@ -123,10 +141,28 @@ func (i InteractionsService) RemoveInteractionByID(interaction *interaction.Inte
 		return nil, &errors.UnauthorizedAccountIDError{}
 	}
 
-	err = i.repositoryInteraction.RemoveInteractionByID(&interaction.ID, &interaction.AccountID)
+	err = i.repositoryInteraction.RemoveInteractionByID(ctx, &interaction.ID, &interaction.AccountID)
 	if err != nil {
 		return nil, err
 	}
+	i.invalidate(ctx, interactionToRemove)
 
 	return interactionToRemove.ToResponse(), nil
 }
+
+// invalidate drops the cached post or comment whose like and dislike counts
+// the interaction changed.
+func (i InteractionsService) invalidate(ctx context.Context, interaction *interaction.Interaction) {
+	var tags []string
+	if interaction.PostID.String != "" {
+		tags = append(tags, cache.PostTag(interaction.PostID.String))
+	}
+	if interaction.CommentID.String != "" {
+		tags = append(tags, cache.CommentTag(interaction.CommentID.String))
+	}
+
+	err := i.cache.Invalidate(ctx, tags...)
+	if err != nil {
+		i.logger.ErrorContext(ctx, "invalidating cache", "tags", tags, "error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package notification
+
+import (
+	"sort"
+)
+
+// Aggregate collapses notifications of the same kind on the same target for
+// the same recipient into one digest, oldest first. An actor repeated in a
+// digest is named once, while the events of every notification collapsed in
+// it are kept, so that they are deleted once the digest is sent.
+//
+// The pending notifications are saved in Postgres, and the aggregation window
+// and follow dedupe are applied there, so that every instance sends from the
+// same state. See NotificationRepository.ClaimPendingNotifications.
+func Aggregate(notifications []*Notification) []*Digest {
+	sorted := append([]*Notification(nil), notifications...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	digests := map[string]*Digest{}
+	for _, notification := range sorted {
+		key := digestKey(notification.RecipientID, notification.Kind, notification.TargetType, notification.TargetID)
+		digest, found := digests[key]
+		if !found {
+			digest = &Digest{
+				Kind:           notification.Kind,
+				TargetType:     notification.TargetType,
+				TargetID:       notification.TargetID,
+				RecipientID:    notification.RecipientID,
+				RecipientEmail: notification.RecipientEmail,
+				FirstAt:        notification.CreatedAt,
+			}
+			digests[key] = digest
+		}
+		digest.EventIDs = append(digest.EventIDs, notification.EventID)
+
+		if !digest.hasActor(notification.ActorID) {
+			digest.ActorIDs = append(digest.ActorIDs, notification.ActorID)
+			digest.ActorNames = append(digest.ActorNames, notification.ActorName)
+		}
+	}
+
+	list := make([]*Digest, 0, len(digests))
+	for _, digest := range digests {
+		list = append(list, digest)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].FirstAt.Before(list[j].FirstAt)
+	})
+	return list
+}
+
+func (d *Digest) hasActor(actorID string) bool {
+	for _, id := range d.ActorIDs {
+		if id == actorID {
+			return true
+		}
+	}
+	return false
+}
+
+func digestKey(recipientID, kind, targetType, targetID string) string {
+	return recipientID + "|" + kind + "|" + targetType + "|" + targetID
+}
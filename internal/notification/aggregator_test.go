@@ -0,0 +1,78 @@
+package notification
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var handledAt = time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+func like(eventID, actorID, actorName string, after time.Duration) *Notification {
+	return &Notification{
+		EventID:     eventID,
+		Kind:        KIND_LIKE,
+		TargetType:  TARGET_POST,
+		TargetID:    "post-1",
+		RecipientID: "owner",
+		ActorID:     actorID,
+		ActorName:   actorName,
+		CreatedAt:   handledAt.Add(after),
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	t.Run("collapses a burst on the same target", func(t *testing.T) {
+		notifications := []*Notification{like("e", "ana", "Ana", 0)}
+		for i := 0; i < 12; i++ {
+			notifications = append(notifications, like("e"+strconv.Itoa(i), "actor-"+strconv.Itoa(i), "Actor "+strconv.Itoa(i), time.Duration(i+1)*time.Second))
+		}
+
+		digests := Aggregate(notifications)
+		assert.Equal(t, 1, len(digests))
+		assert.Equal(t, "Ana and 12 others liked your post", digests[0].Message())
+		assert.Equal(t, 13, len(digests[0].EventIDs))
+	})
+
+	t.Run("keeps different kinds and targets apart", func(t *testing.T) {
+		other := like("e2", "bob", "Bob", 0)
+		other.TargetID = "post-2"
+		dislike := like("e3", "carl", "Carl", 0)
+		dislike.Kind = KIND_DISLIKE
+
+		assert.Equal(t, 3, len(Aggregate([]*Notification{like("e1", "ana", "Ana", 0), other, dislike})))
+	})
+
+	t.Run("names the same actor once and keeps every event", func(t *testing.T) {
+		digests := Aggregate([]*Notification{
+			like("e1", "ana", "Ana", 0),
+			like("e2", "ana", "Ana", time.Second),
+			like("e3", "bob", "Bob", 2*time.Second),
+		})
+
+		assert.Equal(t, 1, len(digests))
+		assert.Equal(t, "Ana and Bob liked your post", digests[0].Message())
+		assert.Equal(t, []string{"e1", "e2", "e3"}, digests[0].EventIDs)
+	})
+
+	t.Run("names the actors in the order they acted", func(t *testing.T) {
+		digests := Aggregate([]*Notification{
+			like("e2", "bob", "Bob", time.Second),
+			like("e1", "ana", "Ana", 0),
+		})
+
+		assert.Equal(t, "Ana and Bob liked your post", digests[0].Message())
+		assert.Equal(t, handledAt, digests[0].FirstAt)
+	})
+
+	t.Run("returns digests oldest first", func(t *testing.T) {
+		other := like("e1", "bob", "Bob", 0)
+		other.TargetID = "post-2"
+
+		digests := Aggregate([]*Notification{like("e2", "ana", "Ana", 10*time.Second), other})
+		assert.Equal(t, 2, len(digests))
+		assert.Equal(t, "post-2", digests[0].TargetID)
+		assert.Equal(t, "post-1", digests[1].TargetID)
+	})
+}
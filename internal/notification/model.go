@@ -0,0 +1,77 @@
+package notification
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	KIND_POST     = "POST"
+	KIND_COMMENT  = "COMMENT"
+	KIND_REPLY    = "REPLY"
+	KIND_LIKE     = "LIKE"
+	KIND_DISLIKE  = "DISLIKE"
+	KIND_FOLLOW   = "FOLLOW"
+	KIND_UNFOLLOW = "UNFOLLOW"
+)
+
+const (
+	TARGET_POST    = "post"
+	TARGET_COMMENT = "comment"
+	TARGET_ACCOUNT = "account"
+)
+
+type Notification struct {
+	EventID        string
+	Kind           string
+	TargetType     string
+	TargetID       string
+	RecipientID    string
+	RecipientEmail string
+	ActorID        string
+	ActorName      string
+	// CreatedAt is when a saved notification was first handled. It is zero
+	// for the ones being handled now.
+	CreatedAt time.Time
+}
+
+type Digest struct {
+	Kind           string
+	TargetType     string
+	TargetID       string
+	RecipientID    string
+	RecipientEmail string
+	ActorIDs       []string
+	ActorNames     []string
+	// EventIDs are the events of the notifications collapsed in the digest.
+	EventIDs []string
+	FirstAt  time.Time
+}
+
+func (d *Digest) Message() string {
+	var actors string
+	switch len(d.ActorNames) {
+	case 1:
+		actors = d.ActorNames[0]
+	case 2:
+		actors = d.ActorNames[0] + " and " + d.ActorNames[1]
+	default:
+		actors = fmt.Sprintf("%s and %d others", d.ActorNames[0], len(d.ActorNames)-1)
+	}
+
+	switch d.Kind {
+	case KIND_POST:
+		return actors + " published a new post"
+	case KIND_COMMENT:
+		return actors + " commented on your post"
+	case KIND_REPLY:
+		return actors + " replied to your comment"
+	case KIND_LIKE:
+		return actors + " liked your " + d.TargetType
+	case KIND_DISLIKE:
+		return actors + " disliked your " + d.TargetType
+	case KIND_FOLLOW:
+		return actors + " started following you"
+	}
+	return actors
+}
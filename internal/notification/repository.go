@@ -1,134 +1,403 @@
 package notification
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
-	"log"
-	"social_network_project/internal/account"
-	"social_network_project/internal/comment"
-	"social_network_project/internal/interaction"
-	"social_network_project/internal/post"
+	"github.com/lib/pq"
+	"log/slog"
+	"social_network_project/internal/event"
+	"social_network_project/internal/platform/database/postgresql"
+	"social_network_project/internal/platform/mail"
+	"social_network_project/internal/utils/crypto"
+	"time"
 )
 
-
 type NotificationRepositoryClient interface {
-	HandlerNotification(notification *Notification)
-	NotificationPost(id *string)
-	NotificationComment(id *string)
-	NotificationInteraction(id *string)
-	NotificationFollowAccount(id *string)
-}
-
-type Notification struct {
-	Type string
-	ID   string
+	FindNotifications(ctx context.Context, envelope *event.Envelope) ([]*Notification, error)
+	NotificationPost(ctx context.Context, actorID string, e *event.PostCreated) ([]*Notification, error)
+	NotificationComment(ctx context.Context, actorID string, e *event.CommentCreated) ([]*Notification, error)
+	NotificationInteraction(ctx context.Context, actorID string, e *event.InteractionCreated) ([]*Notification, error)
+	NotificationFollowAccount(ctx context.Context, actorID string, e *event.AccountFollowed) ([]*Notification, error)
+	NotificationUnfollowAccount(ctx context.Context, actorID string, e *event.AccountUnfollowed) ([]*Notification, error)
+	SendNotification(ctx context.Context, digest *Digest) error
+	SendAccountLockedNotification(ctx context.Context, e *event.AccountLocked, unlockToken string) error
+	ExistsProcessedNotification(ctx context.Context, messageID *string) (*bool, error)
+	InsertProcessedNotification(ctx context.Context, messageID *string) error
+	InsertPendingNotifications(ctx context.Context, messageID *string, notifications []*Notification, followedSince time.Time) error
+	ClaimPendingNotifications(ctx context.Context, dueBefore, claimedUntil time.Time) ([]*Notification, error)
+	DeletePendingNotifications(ctx context.Context, digest *Digest, sentAt time.Time) error
 }
 
 type NotificationRepository struct {
-	repositoryComment comment.CommentRepository
-	repositoryAccount     account.AccountRepository
-	repositoryPost        post.PostRepository
-	repositoryInteraction interaction.InteractionRepository
+	Db     *sql.DB
+	mailer mail.Mailer
+	logger *slog.Logger
 }
 
-func NewNotificationRepository(postgresDB *sql.DB) NotificationRepositoryClient {
+// NewNotificationRepository sends security notices by email with mailer.
+func NewNotificationRepository(postgresDB *sql.DB, mailer mail.Mailer, logger *slog.Logger) NotificationRepositoryClient {
 	return &NotificationRepository{
-		repositoryAccount:     account.NewAccountRepository(postgresDB),
-		repositoryPost:        post.NewPostRepository(postgresDB),
-		repositoryComment:     comment.NewComentRepository(postgresDB),
-		repositoryInteraction: interaction.NewInteractionRepository(postgresDB),
+		Db:     postgresDB,
+		mailer: mailer,
+		logger: logger,
 	}
 }
 
-func (n *NotificationRepository) HandlerNotification(notification *Notification) {
+func (n *NotificationRepository) FindNotifications(ctx context.Context, envelope *event.Envelope) ([]*Notification, error) {
 
-	switch notification.Type {
-	case "Post":
-		n.NotificationPost(&notification.ID)
-		return
-	case "Comment":
-		n.NotificationComment(&notification.ID)
-	case "Interaction":
-		n.NotificationInteraction(&notification.ID)
-	case "FollowAccount":
-		n.NotificationFollowAccount(&notification.ID)
+	switch e := envelope.Payload.(type) {
+	case *event.PostCreated:
+		return n.NotificationPost(ctx, envelope.ActorID, e)
+	case *event.CommentCreated:
+		return n.NotificationComment(ctx, envelope.ActorID, e)
+	case *event.InteractionCreated:
+		return n.NotificationInteraction(ctx, envelope.ActorID, e)
+	case *event.AccountFollowed:
+		return n.NotificationFollowAccount(ctx, envelope.ActorID, e)
+	case *event.AccountUnfollowed:
+		return n.NotificationUnfollowAccount(ctx, envelope.ActorID, e)
 	}
+	return nil, nil
+}
+
+func (n *NotificationRepository) NotificationPost(ctx context.Context, actorID string, e *event.PostCreated) ([]*Notification, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "NotificationPost")
+	defer end()
+	sqlStatement := `
+	SELECT account.id, account.email
+	FROM account_follow
+	INNER JOIN account ON account_follow.account_id = account.id
+	WHERE account_follow.account_id_followed = $1
+	AND account_follow.unfollowed = false
+	AND account.deleted = false`
+
+	return n.findNotifications(ctx, sqlStatement, e.AccountID, actorID, KIND_POST, TARGET_POST, e.PostID)
 }
 
-func (n *NotificationRepository) NotificationPost(id *string) {
+func (n *NotificationRepository) NotificationComment(ctx context.Context, actorID string, e *event.CommentCreated) ([]*Notification, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "NotificationComment")
+	defer end()
+	sqlStatement := `
+	SELECT account.id, account.email
+	FROM post
+	INNER JOIN account ON account.id = post.account_id
+	WHERE post.id = $1
+	AND account.deleted = false`
+
+	notifications, err := n.findNotifications(ctx, sqlStatement, e.PostID, actorID, KIND_COMMENT, TARGET_POST, e.PostID)
+	if err != nil {
+		return nil, err
+	}
+	if e.ParentCommentID == "" {
+		return notifications, nil
+	}
+
+	sqlStatement = `
+	SELECT account.id, account.email
+	FROM comment
+	INNER JOIN account ON account.id = comment.account_id
+	WHERE comment.id = $1
+	AND account.deleted = false`
 
-	emailToNotificate, err := n.repositoryAccount.FindAccountEmailFollowersByAccountID(id)
+	replies, err := n.findNotifications(ctx, sqlStatement, e.ParentCommentID, actorID, KIND_REPLY, TARGET_COMMENT, e.ParentCommentID)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	return append(notifications, replies...), nil
+}
+
+func (n *NotificationRepository) NotificationInteraction(ctx context.Context, actorID string, e *event.InteractionCreated) ([]*Notification, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "NotificationInteraction")
+	defer end()
+
+	kind := KIND_LIKE
+	if e.Type == "DISLIKE" {
+		kind = KIND_DISLIKE
 	}
-	log.Println(emailToNotificate)
+
+	if e.PostID != "" {
+		sqlStatement := `
+		SELECT account.id, account.email
+		FROM post
+		INNER JOIN account ON account.id = post.account_id
+		WHERE post.id = $1
+		AND account.deleted = false`
+
+		return n.findNotifications(ctx, sqlStatement, e.PostID, actorID, kind, TARGET_POST, e.PostID)
+	}
+
+	if e.CommentID != "" {
+		sqlStatement := `
+		SELECT account.id, account.email
+		FROM comment
+		INNER JOIN account ON account.id = comment.account_id
+		WHERE comment.id = $1
+		AND account.deleted = false`
+
+		return n.findNotifications(ctx, sqlStatement, e.CommentID, actorID, kind, TARGET_COMMENT, e.CommentID)
+	}
+	return nil, nil
+}
+
+func (n *NotificationRepository) NotificationFollowAccount(ctx context.Context, actorID string, e *event.AccountFollowed) ([]*Notification, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "NotificationFollowAccount")
+	defer end()
+	sqlStatement := `
+	SELECT account.id, account.email
+	FROM account
+	WHERE account.id = $1
+	AND account.deleted = false`
+
+	return n.findNotifications(ctx, sqlStatement, e.FollowedAccountID, actorID, KIND_FOLLOW, TARGET_ACCOUNT, e.FollowedAccountID)
+}
+
+func (n *NotificationRepository) NotificationUnfollowAccount(ctx context.Context, actorID string, e *event.AccountUnfollowed) ([]*Notification, error) {
+
+	return []*Notification{{
+		Kind:        KIND_UNFOLLOW,
+		TargetType:  TARGET_ACCOUNT,
+		TargetID:    e.UnfollowedAccountID,
+		RecipientID: e.UnfollowedAccountID,
+		ActorID:     actorID,
+	}}, nil
 }
 
-func (n *NotificationRepository) NotificationComment(id *string) {
+func (n *NotificationRepository) SendNotification(ctx context.Context, digest *Digest) error {
+	n.logger.InfoContext(ctx, "notification sent", "recipient", digest.RecipientEmail, "message", digest.Message())
+	return nil
+}
+
+// SendAccountLockedNotification emails unlockToken to the owner of the locked
+// account, replacing the hash of any code sent before, so that only the last
+// one works. Nothing is sent once the lockout is over.
+func (n *NotificationRepository) SendAccountLockedNotification(ctx context.Context, e *event.AccountLocked, unlockToken string) error {
+	ctx, end := postgresql.Observe(ctx, "notification", "SendAccountLockedNotification")
+	defer end()
+	sqlStatement := `
+		UPDATE account_lockout
+		SET unlock_token_hash = $1
+		WHERE account_id = $2
+		AND locked_until > $3
+		RETURNING (SELECT email FROM account WHERE id = $2)`
 
-	comment, err := n.repositoryComment.FindCommentByID(id)
+	var email string
+	err := n.Db.QueryRowContext(ctx, sqlStatement, crypto.HashToken(unlockToken), e.AccountID, time.Now().UTC()).Scan(&email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
-		return
+		return err
 	}
 
-	if !comment.CommentID.Valid {
-		emailToNotificate, err := n.repositoryComment.FindAccountEmailOfPostByCommentID(id)
+	return n.mailer.Send(ctx, email, "Your account was locked", "Your account was locked after too many failed sign in attempts until "+
+		e.LockedUntil.Format(time.RFC1123)+". If it was you, unlock it now with the code "+unlockToken)
+}
+
+// findNotifications builds one notification per recipient returned by
+// sqlStatement, which must select the account id and email.
+func (n *NotificationRepository) findNotifications(ctx context.Context, sqlStatement, arg, actorID, kind, targetType, targetID string) ([]*Notification, error) {
+	var actorName string
+	err := n.Db.QueryRowContext(ctx, `SELECT name FROM account WHERE id = $1`, actorID).Scan(&actorName)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := n.Db.QueryContext(ctx, sqlStatement, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Notification
+	for rows.Next() {
+		notification := &Notification{
+			Kind:       kind,
+			TargetType: targetType,
+			TargetID:   targetID,
+			ActorID:    actorID,
+			ActorName:  actorName,
+		}
+		err = rows.Scan(
+			&notification.RecipientID,
+			&notification.RecipientEmail,
+		)
 		if err != nil {
-			return
+			return nil, err
 		}
-		log.Println(emailToNotificate)
-		return
+		list = append(list, notification)
 	}
 
-	emailToNotificate, err := n.repositoryComment.FindAccountEmailOfPostAndCommentByCommentID(id)
+	return list, rows.Err()
+}
+
+func (n *NotificationRepository) ExistsProcessedNotification(ctx context.Context, messageID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "ExistsProcessedNotification")
+	defer end()
+	sqlStatement := `
+		SELECT message_id
+		FROM notification_processed
+		WHERE message_id = $1`
+	rows, err := n.Db.QueryContext(ctx, sqlStatement, messageID)
 	if err != nil {
-		return
+		return nil, err
 	}
-	log.Println(emailToNotificate)
+	defer rows.Close()
+
+	next := rows.Next()
+	return &next, nil
 }
 
-func (n *NotificationRepository) NotificationInteraction(id *string) {
+func (n *NotificationRepository) InsertProcessedNotification(ctx context.Context, messageID *string) error {
+	ctx, end := postgresql.Observe(ctx, "notification", "InsertProcessedNotification")
+	defer end()
+	sqlStatement := `
+		INSERT INTO notification_processed (message_id, processed_at)
+		VALUES ($1, $2)
+		ON CONFLICT (message_id) DO NOTHING`
 
-	interaction, err := n.repositoryInteraction.FindInteractionByID(id)
+	_, err := n.Db.ExecContext(ctx, sqlStatement, messageID, time.Now().UTC())
 	if err != nil {
-		return
+		return err
 	}
 
-	if interaction.PostID.Valid {
-		emailToNotificate, err := n.repositoryInteraction.FindAccountEmailOfPostByInteractionID(id)
-		if err != nil {
-			return
-		}
-		log.Println(emailToNotificate)
-		return
+	return nil
+}
+
+// InsertPendingNotifications saves the notifications of a message until their
+// digest is sent, and marks the message processed, in one transaction. An
+// unfollow is not saved: it removes the pending follow of the same actor. A
+// follow already notified after followedSince is not saved either.
+func (n *NotificationRepository) InsertPendingNotifications(ctx context.Context, messageID *string, notifications []*Notification, followedSince time.Time) error {
+	ctx, end := postgresql.Observe(ctx, "notification", "InsertPendingNotifications")
+	defer end()
+	tx, err := n.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	if interaction.CommentID.Valid {
-		emailToNotificate, err := n.repositoryInteraction.FindAccountEmailOfCommentByInteractionID(id)
+	now := time.Now().UTC()
+	for _, notification := range notifications {
+		if notification.Kind == KIND_UNFOLLOW {
+			_, err = tx.ExecContext(ctx, `
+				DELETE FROM notification_pending
+				WHERE recipient_id = $1 AND kind = $2 AND actor_id = $3`,
+				notification.RecipientID, KIND_FOLLOW, notification.ActorID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		sqlStatement := `
+			INSERT INTO notification_pending (event_id, recipient_id, kind, recipient_email, target_type, target_id, actor_id, actor_name, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (event_id, recipient_id, kind) DO NOTHING`
+		args := []interface{}{notification.EventID, notification.RecipientID, notification.Kind, notification.RecipientEmail,
+			notification.TargetType, notification.TargetID, notification.ActorID, notification.ActorName, now}
+		if notification.Kind == KIND_FOLLOW {
+			sqlStatement = `
+			INSERT INTO notification_pending (event_id, recipient_id, kind, recipient_email, target_type, target_id, actor_id, actor_name, created_at)
+			SELECT $1::UUID, $2::UUID, $3, $4, $5, $6, $7::UUID, $8, $9::TIMESTAMP
+			WHERE NOT EXISTS (
+				SELECT 1 FROM notification_follow_sent
+				WHERE recipient_id = $2 AND actor_id = $7 AND sent_at > $10)
+			ON CONFLICT (event_id, recipient_id, kind) DO NOTHING`
+			args = append(args, followedSince)
+		}
+
+		_, err = tx.ExecContext(ctx, sqlStatement, args...)
 		if err != nil {
-			return
+			return err
 		}
-		log.Println(emailToNotificate)
-		return
 	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO notification_processed (message_id, processed_at)
+		VALUES ($1, $2)
+		ON CONFLICT (message_id) DO NOTHING`, messageID, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (n *NotificationRepository) NotificationFollowAccount(id *string) {
+// ClaimPendingNotifications claims until claimedUntil the saved notifications
+// of every digest whose first notification was handled before dueBefore, and
+// returns them. Rows claimed by another instance, or locked by one claiming
+// them, are skipped, so that each digest is sent by one instance only. A claim
+// that lapses before the digest is deleted is taken again.
+func (n *NotificationRepository) ClaimPendingNotifications(ctx context.Context, dueBefore, claimedUntil time.Time) ([]*Notification, error) {
+	ctx, end := postgresql.Observe(ctx, "notification", "ClaimPendingNotifications")
+	defer end()
+	sqlStatement := `
+		UPDATE notification_pending
+		SET claimed_until = $1
+		WHERE (event_id, recipient_id, kind) IN (
+			SELECT event_id, recipient_id, kind
+			FROM notification_pending
+			WHERE (claimed_until IS NULL OR claimed_until < $2)
+			AND (recipient_id, kind, target_type, target_id) IN (
+				SELECT recipient_id, kind, target_type, target_id
+				FROM notification_pending
+				WHERE claimed_until IS NULL OR claimed_until < $2
+				GROUP BY recipient_id, kind, target_type, target_id
+				HAVING MIN(created_at) <= $3)
+			FOR UPDATE SKIP LOCKED)
+		RETURNING event_id, recipient_id, kind, recipient_email, target_type, target_id, actor_id, actor_name, created_at`
 
-	emailToNotificate, err := n.repositoryAccount.FindAccountEmailByID(id)
+	rows, err := n.Db.QueryContext(ctx, sqlStatement, claimedUntil, time.Now().UTC(), dueBefore)
 	if err != nil {
-		return
+		return nil, err
 	}
-	log.Println(emailToNotificate)
-	return
+	defer rows.Close()
+
+	var list []*Notification
+	for rows.Next() {
+		var notification Notification
+		err = rows.Scan(
+			&notification.EventID,
+			&notification.RecipientID,
+			&notification.Kind,
+			&notification.RecipientEmail,
+			&notification.TargetType,
+			&notification.TargetID,
+			&notification.ActorID,
+			&notification.ActorName,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &notification)
+	}
+
+	return list, rows.Err()
 }
 
-func CreateNotificationJson(typeN, id string) string {
-	not := &Notification{
-		Type: typeN,
-		ID:   id,
+// DeletePendingNotifications deletes the saved notifications sent in digest.
+// The actors of a follow digest are recorded as notified at sentAt, in the
+// same statement, for the follow dedupe.
+func (n *NotificationRepository) DeletePendingNotifications(ctx context.Context, digest *Digest, sentAt time.Time) error {
+	ctx, end := postgresql.Observe(ctx, "notification", "DeletePendingNotifications")
+	defer end()
+	sqlStatement := `
+		WITH sent AS (
+			DELETE FROM notification_pending
+			WHERE recipient_id = $1 AND kind = $2 AND event_id = ANY($3)
+			RETURNING recipient_id, actor_id, kind)
+		INSERT INTO notification_follow_sent (recipient_id, actor_id, sent_at)
+		SELECT DISTINCT recipient_id, actor_id, $4::TIMESTAMP
+		FROM sent
+		WHERE kind = $5
+		ON CONFLICT (recipient_id, actor_id) DO UPDATE SET sent_at = EXCLUDED.sent_at`
+
+	_, err := n.Db.ExecContext(ctx, sqlStatement, digest.RecipientID, digest.Kind, pq.Array(digest.EventIDs), sentAt, KIND_FOLLOW)
+	if err != nil {
+		return err
 	}
-	jsonStr, _ := json.Marshal(not)
 
-	return string(jsonStr)
+	return nil
 }
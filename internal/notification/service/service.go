@@ -1,102 +1,205 @@
 package service
 
 import (
-	"encoding/json"
-	"fmt"
-	"github.com/streadway/amqp"
+	"context"
+	"log/slog"
+	"social_network_project/internal/event"
 	"social_network_project/internal/notification"
+	"social_network_project/internal/platform/logging"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"social_network_project/internal/platform/metrics"
+	"social_network_project/internal/platform/tracing"
+	"social_network_project/internal/utils/crypto"
+	"time"
 )
 
+const NotificationQueue = "NotificationQueue"
+
 type NotificationServiceClient interface {
-	SendMessage(message string)
-	ConsumerMessage()
+	SendMessage(ctx context.Context, message string) error
+	ConsumerMessage(ctx context.Context)
+	DeliverNotifications(ctx context.Context)
+	SendPendingNotifications(ctx context.Context) int
 }
 
+// claimTimeout is how long a digest being sent is kept from other instances.
+// It must be longer than sending one batch of digests takes.
+const claimTimeout = time.Minute
+
 type NotificationService struct {
-	Conn       *amqp.Connection
-	Repository notification.NotificationRepositoryClient
+	Broker       messagebroker.Broker
+	Repository   notification.NotificationRepositoryClient
+	window       time.Duration
+	dedupeWindow time.Duration
+	interval     time.Duration
+	now          func() time.Time
+	logger       *slog.Logger
 }
 
-func NewNotificationService(_conn *amqp.Connection, _repository notification.NotificationRepositoryClient) NotificationServiceClient {
+// NewNotificationService collapses the notifications of the same kind on the
+// same target for the same recipient handled during window into one digest,
+// sent at the first interval after the window has passed. Follows already
+// notified are not notified again for dedupeWindow, so that unfollowing and
+// following again does not notify twice.
+func NewNotificationService(_broker messagebroker.Broker, _repository notification.NotificationRepositoryClient,
+	window, dedupeWindow, interval time.Duration, now func() time.Time, logger *slog.Logger) NotificationServiceClient {
 	return &NotificationService{
-		Conn: _conn,
-		Repository: _repository,
+		Broker:       _broker,
+		Repository:   _repository,
+		window:       window,
+		dedupeWindow: dedupeWindow,
+		interval:     interval,
+		now:          now,
+		logger:       logger,
 	}
 }
 
-func (r *NotificationService) SendMessage(message string) {
+// SendMessage publishes message with the request id and the trace context of
+// ctx in its headers, so that its handling is logged and traced as part of
+// the same request.
+func (r *NotificationService) SendMessage(ctx context.Context, message string) error {
+	ctx, span := tracing.StartProducer(ctx, NotificationQueue)
+	headers := map[string]string{}
+	if id := logging.RequestID(ctx); id != "" {
+		headers[logging.RequestIDHeader] = id
+	}
+	tracing.Inject(ctx, headers)
 
-	ch, err := r.Conn.Channel()
+	err := r.Broker.Publish(NotificationQueue, &messagebroker.Message{
+		Body:    []byte(message),
+		Headers: headers,
+	})
+	tracing.End(span, err)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
-	defer ch.Close()
+	metrics.MessagesPublished.WithLabelValues(NotificationQueue).Inc()
 
-	q, err := ch.QueueDeclare(
-		"NotificationQueue",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+	r.logger.DebugContext(ctx, "message published", "queue", NotificationQueue)
+	return nil
+}
 
-	fmt.Println(q)
+// ConsumerMessage handles notification events until ctx is done. Each message
+// is handled in a span continuing the trace found in its headers, with the
+// request id found there, apart from ctx so that stopping does not cancel the
+// message being handled.
+func (r *NotificationService) ConsumerMessage(ctx context.Context) {
+	r.logger.Info("consuming messages", "queue", NotificationQueue)
+
+	err := r.Broker.Subscribe(ctx, NotificationQueue, func(message *messagebroker.Message) error {
+		messageCtx, span := tracing.StartConsumer(NotificationQueue, message.Headers)
+		messageCtx = logging.WithRequestID(messageCtx, message.Headers[logging.RequestIDHeader])
+		r.logger.DebugContext(messageCtx, "message received", "queue", NotificationQueue, "redelivered", message.Redelivered)
+
+		err := r.handleMessage(messageCtx, message.Body)
+		tracing.End(span, err)
+		if err != nil {
+			metrics.MessagesFailed.WithLabelValues(NotificationQueue).Inc()
+			r.logger.ErrorContext(messageCtx, "handling message", "queue", NotificationQueue, "redelivered", message.Redelivered, "error", err)
+			return err
+		}
+		metrics.MessagesConsumed.WithLabelValues(NotificationQueue).Inc()
+		return nil
+	})
+	if err != nil {
+		r.logger.Error("consuming messages", "queue", NotificationQueue, "error", err)
+	}
+}
 
+// handleMessage processes a delivery at most once per event ID. The outbox
+// relay publishes at least once, so the same message may arrive again after a
+// relay or broker failure.
+func (r *NotificationService) handleMessage(ctx context.Context, body []byte) error {
+	envelope, err := event.Decode(body)
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
-	err = ch.Publish(
-		"",
-		"NotificationQueue",
-		false,
-		false,
-		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(message),
-		},
-	)
+	processed, err := r.Repository.ExistsProcessedNotification(ctx, &envelope.ID)
+	if err != nil {
+		return err
+	}
+	if *processed {
+		r.logger.DebugContext(ctx, "message already processed", "event_id", envelope.ID)
+		return nil
+	}
 
+	// Security notices are sent at once rather than aggregated. The unlock code
+	// is minted here, so that it only ever exists in the email.
+	if locked, ok := envelope.Payload.(*event.AccountLocked); ok {
+		unlockToken, err := crypto.NewToken(16)
+		if err != nil {
+			return err
+		}
+		err = r.Repository.SendAccountLockedNotification(ctx, locked, unlockToken)
+		if err != nil {
+			return err
+		}
+		return r.Repository.InsertProcessedNotification(ctx, &envelope.ID)
+	}
+
+	notifications, err := r.Repository.FindNotifications(ctx, envelope)
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+
+	// Actions on one's own content do not notify.
+	var kept []*notification.Notification
+	for _, n := range notifications {
+		if n.ActorID == n.RecipientID {
+			continue
+		}
+		n.EventID = envelope.ID
+		kept = append(kept, n)
 	}
-	fmt.Println("Successfully Published Message to Queue")
 
+	// The notifications are saved with the message marked processed, as the
+	// message is gone once handled but their digest is sent later, by any
+	// instance.
+	return r.Repository.InsertPendingNotifications(ctx, &envelope.ID, kept, r.now().UTC().Add(-r.dedupeWindow))
 }
 
-func (r *NotificationService) ConsumerMessage() {
-	ch, err := r.Conn.Channel()
-	if err != nil {
-		fmt.Println(err)
+// DeliverNotifications sends the pending digests every interval until ctx is
+// done. Digests still pending then are left saved, to be sent by another
+// instance or after a restart once their window has passed.
+func (r *NotificationService) DeliverNotifications(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.SendPendingNotifications(ctx)
+		}
 	}
-	defer ch.Close()
+}
 
+// SendPendingNotifications claims the saved notifications whose aggregation
+// window has elapsed, sends their digests and returns how many were sent. A
+// digest that fails to be sent is claimed again once its claim lapses.
+func (r *NotificationService) SendPendingNotifications(ctx context.Context) int {
+	now := r.now().UTC()
+	notifications, err := r.Repository.ClaimPendingNotifications(ctx, now.Add(-r.window), now.Add(claimTimeout))
 	if err != nil {
-		fmt.Println(err)
+		r.logger.ErrorContext(ctx, "claiming pending notifications", "error", err)
+		return 0
 	}
 
-	msgs, err := ch.Consume(
-		"NotificationQueue",
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-
-	forever := make(chan bool)
-	go func() {
-		for d := range msgs {
-			n := &notification.Notification{}
-			json.Unmarshal(d.Body, n)
-			r.Repository.HandlerNotification(n)
-			fmt.Printf("Recieved Message: %s\n", d.Body)
+	sent := 0
+	for _, digest := range notification.Aggregate(notifications) {
+		err = r.Repository.SendNotification(ctx, digest)
+		if err != nil {
+			r.logger.ErrorContext(ctx, "sending notification", "recipient", digest.RecipientEmail, "error", err)
+			continue
 		}
-	}()
+		sent++
 
-	fmt.Println("Successfully Connected to our RabbitMQ Instance")
-	fmt.Println(" [*] - Waiting for messages")
-	<-forever
+		err = r.Repository.DeletePendingNotifications(ctx, digest, r.now().UTC())
+		if err != nil {
+			r.logger.ErrorContext(ctx, "deleting sent notifications", "recipient", digest.RecipientEmail, "error", err)
+		}
+	}
+	return sent
 }
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/event"
+	"social_network_project/internal/notification"
+	"social_network_project/internal/platform/logging"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"social_network_project/internal/platform/message-broker/memory"
+	"testing"
+	"time"
+)
+
+type notificationRepositoryFake struct {
+	broker    messagebroker.Broker
+	handled   []*event.Envelope
+	processed map[string]bool
+	sent      []*notification.Digest
+	locked    []*event.AccountLocked
+	codes     []string
+	pending   []*notification.Notification
+	claimed   map[*notification.Notification]time.Time
+	followed  map[string]time.Time
+}
+
+func newNotificationRepositoryFake(broker messagebroker.Broker) *notificationRepositoryFake {
+	return &notificationRepositoryFake{
+		broker:    broker,
+		processed: map[string]bool{},
+		claimed:   map[*notification.Notification]time.Time{},
+		followed:  map[string]time.Time{},
+	}
+}
+
+func (n *notificationRepositoryFake) FindNotifications(ctx context.Context, envelope *event.Envelope) ([]*notification.Notification, error) {
+	if envelope.ActorID == "close" {
+		return nil, n.broker.Close()
+	}
+	n.handled = append(n.handled, envelope)
+
+	switch e := envelope.Payload.(type) {
+	case *event.InteractionCreated:
+		return n.NotificationInteraction(context.Background(), envelope.ActorID, e)
+	case *event.AccountFollowed:
+		return n.NotificationFollowAccount(context.Background(), envelope.ActorID, e)
+	case *event.AccountUnfollowed:
+		return n.NotificationUnfollowAccount(context.Background(), envelope.ActorID, e)
+	}
+	return nil, nil
+}
+
+func (n *notificationRepositoryFake) NotificationPost(ctx context.Context, actorID string, e *event.PostCreated) ([]*notification.Notification, error) {
+	return nil, nil
+}
+
+func (n *notificationRepositoryFake) NotificationComment(ctx context.Context, actorID string, e *event.CommentCreated) ([]*notification.Notification, error) {
+	return nil, nil
+}
+
+func (n *notificationRepositoryFake) NotificationInteraction(ctx context.Context, actorID string, e *event.InteractionCreated) ([]*notification.Notification, error) {
+	return []*notification.Notification{{
+		Kind:        notification.KIND_LIKE,
+		TargetType:  notification.TARGET_POST,
+		TargetID:    e.PostID,
+		RecipientID: "6c08496b-b721-4e06-b0b7-1905524c9da2",
+		ActorID:     actorID,
+		ActorName:   actorID,
+	}}, nil
+}
+
+func (n *notificationRepositoryFake) NotificationFollowAccount(ctx context.Context, actorID string, e *event.AccountFollowed) ([]*notification.Notification, error) {
+	return []*notification.Notification{{
+		Kind:        notification.KIND_FOLLOW,
+		TargetType:  notification.TARGET_ACCOUNT,
+		TargetID:    e.FollowedAccountID,
+		RecipientID: e.FollowedAccountID,
+		ActorID:     actorID,
+		ActorName:   actorID,
+	}}, nil
+}
+
+func (n *notificationRepositoryFake) NotificationUnfollowAccount(ctx context.Context, actorID string, e *event.AccountUnfollowed) ([]*notification.Notification, error) {
+	return []*notification.Notification{{
+		Kind:        notification.KIND_UNFOLLOW,
+		TargetType:  notification.TARGET_ACCOUNT,
+		TargetID:    e.UnfollowedAccountID,
+		RecipientID: e.UnfollowedAccountID,
+		ActorID:     actorID,
+	}}, nil
+}
+
+func (n *notificationRepositoryFake) SendNotification(ctx context.Context, digest *notification.Digest) error {
+	n.sent = append(n.sent, digest)
+	return nil
+}
+
+func (n *notificationRepositoryFake) SendAccountLockedNotification(ctx context.Context, e *event.AccountLocked, unlockToken string) error {
+	n.locked = append(n.locked, e)
+	n.codes = append(n.codes, unlockToken)
+	return nil
+}
+
+func (n *notificationRepositoryFake) ExistsProcessedNotification(ctx context.Context, messageID *string) (*bool, error) {
+	exist := n.processed[*messageID]
+	return &exist, nil
+}
+
+func (n *notificationRepositoryFake) InsertProcessedNotification(ctx context.Context, messageID *string) error {
+	n.processed[*messageID] = true
+	return nil
+}
+
+func (n *notificationRepositoryFake) InsertPendingNotifications(ctx context.Context, messageID *string, notifications []*notification.Notification, followedSince time.Time) error {
+	for _, saved := range notifications {
+		if saved.Kind == notification.KIND_UNFOLLOW {
+			var kept []*notification.Notification
+			for _, pending := range n.pending {
+				if pending.Kind != notification.KIND_FOLLOW || pending.RecipientID != saved.RecipientID || pending.ActorID != saved.ActorID {
+					kept = append(kept, pending)
+				}
+			}
+			n.pending = kept
+			continue
+		}
+		sentAt, found := n.followed[saved.RecipientID+"|"+saved.ActorID]
+		if saved.Kind == notification.KIND_FOLLOW && found && sentAt.After(followedSince) {
+			continue
+		}
+
+		copied := *saved
+		copied.CreatedAt = time.Now().UTC()
+		n.pending = append(n.pending, &copied)
+	}
+	return n.InsertProcessedNotification(ctx, messageID)
+}
+
+func (n *notificationRepositoryFake) ClaimPendingNotifications(ctx context.Context, dueBefore, claimedUntil time.Time) ([]*notification.Notification, error) {
+	now := time.Now().UTC()
+	var list []*notification.Notification
+	for _, saved := range n.pending {
+		if n.claimed[saved].After(now) || saved.CreatedAt.After(dueBefore) {
+			continue
+		}
+		n.claimed[saved] = claimedUntil
+		list = append(list, saved)
+	}
+	return list, nil
+}
+
+func (n *notificationRepositoryFake) DeletePendingNotifications(ctx context.Context, digest *notification.Digest, sentAt time.Time) error {
+	var kept []*notification.Notification
+	for _, saved := range n.pending {
+		deleted := false
+		for _, eventID := range digest.EventIDs {
+			deleted = deleted || saved.RecipientID == digest.RecipientID && saved.Kind == digest.Kind && saved.EventID == eventID
+		}
+		if !deleted {
+			kept = append(kept, saved)
+		} else if saved.Kind == notification.KIND_FOLLOW {
+			n.followed[saved.RecipientID+"|"+saved.ActorID] = sentAt
+		}
+	}
+	n.pending = kept
+	return nil
+}
+
+func TestNotificationService_ConsumerMessage(t *testing.T) {
+	broker := memory.NewMemory()
+	repository := newNotificationRepositoryFake(broker)
+	notificationService := NewNotificationService(broker, repository, time.Minute, time.Hour, time.Second, time.Now, logging.Discard())
+
+	message, err := event.Encode(event.New("6c08496b-b721-4e06-b0b7-1905524c9da2", &event.PostCreated{
+		PostID:    "52ba9bd3-e7e2-47fc-8ef4-99a24b32f888",
+		AccountID: "6c08496b-b721-4e06-b0b7-1905524c9da2",
+	}))
+	assert.Nil(t, err)
+	closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+	assert.Nil(t, err)
+
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+
+	notificationService.ConsumerMessage(context.Background())
+
+	assert.Equal(t, 1, len(repository.handled))
+	assert.Equal(t, event.TypePostCreated, repository.handled[0].Type)
+	assert.Equal(t, "6c08496b-b721-4e06-b0b7-1905524c9da2", repository.handled[0].ActorID)
+	assert.Equal(t, "52ba9bd3-e7e2-47fc-8ef4-99a24b32f888", repository.handled[0].Payload.(*event.PostCreated).PostID)
+}
+
+func TestNotificationService_SendPendingNotifications(t *testing.T) {
+	broker := memory.NewMemory()
+	repository := newNotificationRepositoryFake(broker)
+	notificationService := NewNotificationService(broker, repository, 0, time.Hour, time.Second, time.Now, logging.Discard())
+
+	for _, actorID := range []string{"ana", "bob", "carl", "6c08496b-b721-4e06-b0b7-1905524c9da2"} {
+		message, err := event.Encode(event.New(actorID, &event.InteractionCreated{
+			InteractionID: actorID,
+			PostID:        "52ba9bd3-e7e2-47fc-8ef4-99a24b32f888",
+			AccountID:     actorID,
+			Type:          "LIKE",
+		}))
+		assert.Nil(t, err)
+		assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	}
+	closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+	assert.Nil(t, err)
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+
+	notificationService.ConsumerMessage(context.Background())
+
+	assert.Equal(t, 1, notificationService.SendPendingNotifications(context.Background()))
+	assert.Equal(t, "ana and 2 others liked your post", repository.sent[0].Message())
+	assert.Equal(t, 0, notificationService.SendPendingNotifications(context.Background()))
+}
+
+func TestNotificationService_AccountLocked(t *testing.T) {
+	broker := memory.NewMemory()
+	repository := newNotificationRepositoryFake(broker)
+	notificationService := NewNotificationService(broker, repository, time.Minute, time.Hour, time.Second, time.Now, logging.Discard())
+
+	message, err := event.Encode(event.New("6c08496b-b721-4e06-b0b7-1905524c9da2", &event.AccountLocked{
+		AccountID:   "6c08496b-b721-4e06-b0b7-1905524c9da2",
+		LockedUntil: time.Date(2022, 8, 1, 12, 15, 0, 0, time.UTC),
+	}))
+	assert.Nil(t, err)
+	closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+	assert.Nil(t, err)
+
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+
+	notificationService.ConsumerMessage(context.Background())
+
+	assert.Equal(t, 1, len(repository.locked))
+	assert.Len(t, repository.codes[0], 32)
+	assert.Equal(t, 0, notificationService.SendPendingNotifications(context.Background()))
+}
+
+func TestNotificationService_DeliverNotifications(t *testing.T) {
+	broker := memory.NewMemory()
+	repository := newNotificationRepositoryFake(broker)
+	notificationService := NewNotificationService(broker, repository, time.Hour, time.Hour, time.Hour, time.Now, logging.Discard())
+
+	message, err := event.Encode(event.New("ana", &event.InteractionCreated{
+		InteractionID: "ana",
+		PostID:        "52ba9bd3-e7e2-47fc-8ef4-99a24b32f888",
+		AccountID:     "ana",
+		Type:          "LIKE",
+	}))
+	assert.Nil(t, err)
+	closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+	assert.Nil(t, err)
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+	notificationService.ConsumerMessage(context.Background())
+
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	notificationService.DeliverNotifications(ctx)
+
+	// Stopping leaves the digest to be sent once its window has passed.
+	assert.Equal(t, 0, len(repository.sent))
+	assert.Equal(t, 1, len(repository.pending))
+}
+
+func TestNotificationService_SharedPendingNotifications(t *testing.T) {
+	broker := memory.NewMemory()
+	repository := newNotificationRepositoryFake(broker)
+	notificationService := NewNotificationService(broker, repository, 0, time.Hour, time.Hour, time.Now, logging.Discard())
+
+	for _, actorID := range []string{"ana", "bob", "bob", "6c08496b-b721-4e06-b0b7-1905524c9da2"} {
+		message, err := event.Encode(event.New(actorID, &event.InteractionCreated{
+			InteractionID: actorID,
+			PostID:        "52ba9bd3-e7e2-47fc-8ef4-99a24b32f888",
+			AccountID:     actorID,
+			Type:          "LIKE",
+		}))
+		assert.Nil(t, err)
+		assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+	}
+	closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+	assert.Nil(t, err)
+	assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+	notificationService.ConsumerMessage(context.Background())
+
+	// The self notification is not saved, the others wait for their digest.
+	assert.Equal(t, 3, len(repository.pending))
+
+	// Another instance, or the same one after a restart, finds what was saved
+	// and the digest is sent once.
+	other := NewNotificationService(broker, repository, 0, time.Hour, time.Hour, time.Now, logging.Discard())
+	assert.Equal(t, 1, other.SendPendingNotifications(context.Background()))
+	assert.Equal(t, 0, notificationService.SendPendingNotifications(context.Background()))
+
+	assert.Equal(t, 1, len(repository.sent))
+	assert.Equal(t, "ana and bob liked your post", repository.sent[0].Message())
+	assert.Equal(t, 0, len(repository.pending))
+}
+
+func TestNotificationService_FollowDedupe(t *testing.T) {
+	// handle runs an instance handling payloads from ana, each in its own
+	// message, over the saved state of repository.
+	handle := func(t *testing.T, repository *notificationRepositoryFake, now func() time.Time, payloads ...event.Event) NotificationServiceClient {
+		broker := memory.NewMemory()
+		repository.broker = broker
+		notificationService := NewNotificationService(broker, repository, 0, time.Hour, time.Hour, now, logging.Discard())
+		for _, payload := range payloads {
+			message, err := event.Encode(event.New("ana", payload))
+			assert.Nil(t, err)
+			assert.Nil(t, notificationService.SendMessage(context.Background(), string(message)))
+		}
+		closeMessage, err := event.Encode(event.New("close", &event.PostCreated{}))
+		assert.Nil(t, err)
+		assert.Nil(t, notificationService.SendMessage(context.Background(), string(closeMessage)))
+		notificationService.ConsumerMessage(context.Background())
+		return notificationService
+	}
+	followed := &event.AccountFollowed{FollowedAccountID: "owner"}
+	unfollowed := &event.AccountUnfollowed{UnfollowedAccountID: "owner"}
+
+	t.Run("follow, unfollow and refollow notifies once", func(t *testing.T) {
+		repository := newNotificationRepositoryFake(nil)
+
+		notificationService := handle(t, repository, time.Now, followed, unfollowed, followed)
+		assert.Equal(t, 1, notificationService.SendPendingNotifications(context.Background()))
+		assert.Equal(t, "ana started following you", repository.sent[0].Message())
+
+		// The dedupe is saved, so that another instance does not notify again.
+		other := handle(t, repository, time.Now, unfollowed, followed)
+		assert.Equal(t, 0, len(repository.pending))
+		assert.Equal(t, 0, other.SendPendingNotifications(context.Background()))
+	})
+
+	t.Run("refollow notifies again after the dedupe window", func(t *testing.T) {
+		repository := newNotificationRepositoryFake(nil)
+
+		notificationService := handle(t, repository, time.Now, followed)
+		assert.Equal(t, 1, notificationService.SendPendingNotifications(context.Background()))
+
+		later := handle(t, repository, func() time.Time {
+			return time.Now().Add(2 * time.Hour)
+		}, unfollowed, followed)
+		assert.Equal(t, 1, later.SendPendingNotifications(context.Background()))
+		assert.Equal(t, 2, len(repository.sent))
+	})
+}
@@ -0,0 +1,34 @@
+package outbox
+
+import (
+	"context"
+	"social_network_project/internal/event"
+	"social_network_project/internal/platform/logging"
+	"time"
+)
+
+type Outbox struct {
+	ID        string
+	Type      string
+	Payload   string
+	CreatedAt time.Time
+	Attempts  int
+	RequestID string
+}
+
+// NewOutbox returns the message of envelope, carrying the id of the request
+// of ctx to the consumers of the event.
+func NewOutbox(ctx context.Context, envelope *event.Envelope) (*Outbox, error) {
+	payload, err := event.Encode(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Outbox{
+		ID:        envelope.ID,
+		Type:      string(envelope.Type),
+		Payload:   string(payload),
+		CreatedAt: envelope.OccurredAt,
+		RequestID: logging.RequestID(ctx),
+	}, nil
+}
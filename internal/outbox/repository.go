@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"social_network_project/internal/platform/database/postgresql"
+	"time"
+)
+
+type OutboxRepository interface {
+	PublishPendingOutbox(ctx context.Context, limit int, publish func(message *Outbox) error) (int, error)
+	DeletePublishedOutbox(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+type OutboxRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewOutboxRepository(postgresDB *sql.DB) OutboxRepository {
+	return &OutboxRepositoryStruct{postgresDB}
+}
+
+// InsertOutbox writes the message inside the caller's transaction, so the
+// event is only stored when the domain row that produced it is committed.
+func InsertOutbox(ctx context.Context, tx *sql.Tx, message *Outbox) error {
+	sqlStatement := `
+		INSERT INTO outbox (id, type, payload, created_at, attempts, request_id)
+		VALUES ($1, $2, $3, $4, 0, $5)`
+
+	_, err := tx.ExecContext(ctx, sqlStatement, message.ID, message.Type, message.Payload, message.CreatedAt, message.RequestID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PublishPendingOutbox locks up to limit unpublished messages, hands them to
+// publish in creation order and marks the ones that succeed as published. The
+// first failure stops the batch so ordering is kept for the next run. Rows are
+// locked with SKIP LOCKED, so several relays can run against the same table.
+func (p *OutboxRepositoryStruct) PublishPendingOutbox(ctx context.Context, limit int, publish func(message *Outbox) error) (int, error) {
+	ctx, end := postgresql.Observe(ctx, "outbox", "PublishPendingOutbox")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	sqlStatement := `
+		SELECT id, type, payload, created_at, attempts, request_id
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.QueryContext(ctx, sqlStatement, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var messages []*Outbox
+	for rows.Next() {
+		var message Outbox
+		err = rows.Scan(
+			&message.ID,
+			&message.Type,
+			&message.Payload,
+			&message.CreatedAt,
+			&message.Attempts,
+			&message.RequestID,
+		)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		messages = append(messages, &message)
+	}
+	rows.Close()
+
+	published := 0
+	var errPublish error
+	for _, message := range messages {
+		if errPublish = publish(message); errPublish != nil {
+			_, err = tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1 WHERE id = $1`, message.ID)
+			if err != nil {
+				return 0, err
+			}
+			break
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE outbox SET published_at = $1 WHERE id = $2`, time.Now().UTC(), message.ID)
+		if err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return published, errPublish
+}
+
+// DeletePublishedOutbox deletes up to limit messages published before before,
+// oldest first, and returns how many it deleted. Pending messages are kept
+// however old they are.
+func (p *OutboxRepositoryStruct) DeletePublishedOutbox(ctx context.Context, before time.Time, limit int) (int, error) {
+	ctx, end := postgresql.Observe(ctx, "outbox", "DeletePublishedOutbox")
+	defer end()
+	sqlStatement := `
+		DELETE FROM outbox
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE published_at < $1
+			ORDER BY published_at
+			LIMIT $2)`
+
+	result, err := p.Db.ExecContext(ctx, sqlStatement, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(deleted), nil
+}
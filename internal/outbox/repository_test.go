@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPublishPendingOutbox(t *testing.T) {
+	columns := []string{"id", "type", "payload", "created_at", "attempts", "request_id"}
+
+	t.Run("marks published messages", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, type, payload, created_at, attempts").
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "post.created", "{}", time.Now(), 0, "req-1").
+				AddRow("2", "comment.created", "{}", time.Now(), 0, ""))
+		mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(sqlmock.AnyArg(), "1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE outbox SET published_at").WithArgs(sqlmock.AnyArg(), "2").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		var sent, requestIDs []string
+		repository := NewOutboxRepository(db)
+		published, err := repository.PublishPendingOutbox(context.Background(), 10, func(message *Outbox) error {
+			sent = append(sent, message.ID)
+			requestIDs = append(requestIDs, message.RequestID)
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, published)
+		assert.Equal(t, []string{"1", "2"}, sent)
+		assert.Equal(t, []string{"req-1", ""}, requestIDs)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+	t.Run("stops on broker failure and keeps message pending", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		assert.Nil(t, err)
+		defer db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, type, payload, created_at, attempts").
+			WithArgs(10).
+			WillReturnRows(sqlmock.NewRows(columns).
+				AddRow("1", "post.created", "{}", time.Now(), 0, "req-1").
+				AddRow("2", "comment.created", "{}", time.Now(), 0, ""))
+		mock.ExpectExec("UPDATE outbox SET attempts").WithArgs("1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		errBroker := errors.New("broker unavailable")
+		repository := NewOutboxRepository(db)
+		published, err := repository.PublishPendingOutbox(context.Background(), 10, func(message *Outbox) error {
+			return errBroker
+		})
+
+		assert.Equal(t, errBroker, err)
+		assert.Equal(t, 0, published)
+		assert.Nil(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDeletePublishedOutbox(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	before := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM outbox").WithArgs(before, 10).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	repository := NewOutboxRepository(db)
+	deleted, err := repository.DeletePublishedOutbox(context.Background(), before, 10)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
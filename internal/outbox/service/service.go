@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/logging"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"social_network_project/internal/platform/metrics"
+	"social_network_project/internal/platform/tracing"
+	"time"
+)
+
+type OutboxServiceClient interface {
+	RelayMessages(ctx context.Context)
+	RelayPendingMessages(ctx context.Context) (int, error)
+	DeletePublishedMessages(ctx context.Context) (int, error)
+}
+
+type OutboxService struct {
+	repository outbox.OutboxRepository
+	broker     messagebroker.Publisher
+	queues     []string
+	interval   time.Duration
+	batchSize  int
+	retention  time.Duration
+	now        func() time.Time
+	logger     *slog.Logger
+}
+
+// NewOutboxService keeps published messages for retention before deleting
+// them.
+func NewOutboxService(_repository outbox.OutboxRepository, _broker messagebroker.Publisher, queues []string, interval time.Duration, batchSize int,
+	retention time.Duration, logger *slog.Logger) OutboxServiceClient {
+	return &OutboxService{
+		repository: _repository,
+		broker:     _broker,
+		queues:     queues,
+		interval:   interval,
+		batchSize:  batchSize,
+		retention:  retention,
+		now:        time.Now,
+		logger:     logger,
+	}
+}
+
+// RelayMessages polls the outbox table until ctx is done, publishing pending
+// messages to the broker. A message is only marked as published after the broker accepted
+// it, which gives at-least-once delivery. Messages published longer than the
+// retention ago are deleted on the way.
+func (o *OutboxService) RelayMessages(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			published, err := o.RelayPendingMessages(ctx)
+			if err != nil {
+				o.logger.Error("relaying messages", "error", err)
+				break
+			}
+			if published < o.batchSize {
+				break
+			}
+		}
+
+		for ctx.Err() == nil {
+			deleted, err := o.DeletePublishedMessages(ctx)
+			if err != nil {
+				o.logger.Error("deleting published messages", "error", err)
+				break
+			}
+			if deleted < o.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// DeletePublishedMessages deletes one batch of the messages published longer
+// than the retention ago.
+func (o *OutboxService) DeletePublishedMessages(ctx context.Context) (int, error) {
+	return o.repository.DeletePublishedOutbox(ctx, o.now().UTC().Add(-o.retention), o.batchSize)
+}
+
+// RelayPendingMessages publishes one batch to every consumer queue. A failure
+// on any queue leaves the message pending, so queues that already received it
+// get it again; consumers are idempotent on the event ID.
+func (o *OutboxService) RelayPendingMessages(ctx context.Context) (int, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "outbox.relay")
+	published, err := o.repository.PublishPendingOutbox(ctx, o.batchSize, func(message *outbox.Outbox) error {
+		for _, queue := range o.queues {
+			err := o.publish(ctx, queue, message)
+			if err != nil {
+				return err
+			}
+			metrics.MessagesPublished.WithLabelValues(queue).Inc()
+		}
+		return nil
+	})
+	tracing.End(span, err)
+	return published, err
+}
+
+// publish sends message to queue with the id of the request that produced it
+// and the trace context of ctx in its headers.
+func (o *OutboxService) publish(ctx context.Context, queue string, message *outbox.Outbox) error {
+	ctx, span := tracing.StartProducer(ctx, queue)
+	headers := map[string]string{}
+	if message.RequestID != "" {
+		headers[logging.RequestIDHeader] = message.RequestID
+	}
+	tracing.Inject(ctx, headers)
+
+	err := o.broker.Publish(queue, &messagebroker.Message{
+		Body:    []byte(message.Payload),
+		Headers: headers,
+	})
+	tracing.End(span, err)
+	return err
+}
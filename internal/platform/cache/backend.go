@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Backend stores cached responses. Keys can be grouped under tags so that a
+// write invalidates every response built from the data it changed.
+type Backend interface {
+	InsertInDatabase(ctx context.Context, key string, value string, ttl time.Duration) error
+	FindInDatabase(ctx context.Context, key string) (string, error)
+	DeleteInDatabase(ctx context.Context, keys ...string) error
+	AddKeyToTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error
+	FindKeysByTags(ctx context.Context, tags ...string) ([]string, error)
+	DeleteTags(ctx context.Context, tags ...string) error
+}
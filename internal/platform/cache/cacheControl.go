@@ -1,62 +1,259 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"math"
+	"math/rand"
 	"net/http"
-	"os"
-	"social_network_project/internal/platform/cache/redisDB"
 	"social_network_project/internal/utils"
-	"social_network_project/internal/utils/errors"
+	"sync/atomic"
+	"time"
 )
 
+type Invalidator interface {
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+// Loader builds a response and the tags it depends on. It returns the tags
+// with a not found error too, so that creating what was missing drops the
+// cached error. ctx is not the request's: a load shared by concurrent
+// requests goes on when the first of them goes away.
+type Loader func(ctx context.Context) (obj any, tags []string, err error)
+
 type RedisServiceClient interface {
-	InsertCache(req *http.Request, obj any) error
-	FindInCache(req *http.Request) (*interface{}, error)
+	Invalidator
+	GetOrLoad(req *http.Request, accountID string, load Loader) (*Response, error)
+	Stats() Stats
+}
+
+type Stats struct {
+	Hits           uint64 `json:"hits"`
+	Misses         uint64 `json:"misses"`
+	Coalesced      uint64 `json:"coalesced"`
+	NegativeHits   uint64 `json:"negative_hits"`
+	EarlyRefreshes uint64 `json:"early_refreshes"`
+}
+
+// Response is a JSON response body as it was cached, with its strong entity
+// tag and the time it was built.
+type Response struct {
+	Body         []byte
+	ETag         string
+	LastModified time.Time
 }
 
-func NewRedisService(_client redisDB.RedisClient) RedisServiceClient {
+// entry is what is stored in the backend. Delta is how long the response took
+// to build and ExpiresAt when the backend drops it; both drive early refresh.
+type entry struct {
+	Value     json.RawMessage `json:"value,omitempty"`
+	ETag      string          `json:"etag,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	Delta     time.Duration   `json:"delta"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+func (e *entry) toResponse() *Response {
+	return &Response{
+		Body:         e.Value,
+		ETag:         e.ETag,
+		LastModified: e.CreatedAt,
+	}
+}
+
+// NewRedisService caches responses in the backend for ttl, or for the TTL in
+// routeTTLs of the request path when there is one. Not found errors are
+// cached for negativeTTL. Loads are given up after loadTimeout.
+func NewRedisService(_client Backend, ttl time.Duration, routeTTLs map[string]time.Duration, negativeTTL, loadTimeout time.Duration, logger *slog.Logger) RedisServiceClient {
+	tagTTL := ttl
+	for _, routeTTL := range routeTTLs {
+		if routeTTL > tagTTL {
+			tagTTL = routeTTL
+		}
+	}
+
 	return &RedisService{
-		client: _client,
+		client:      _client,
+		ttl:         ttl,
+		routeTTLs:   routeTTLs,
+		tagTTL:      tagTTL,
+		negativeTTL: negativeTTL,
+		loadTimeout: loadTimeout,
+		beta:        1,
+		now:         time.Now,
+		random:      rand.Float64,
+		logger:      logger,
 	}
 }
 
 type RedisService struct {
-	client redisDB.RedisClient
+	client      Backend
+	ttl         time.Duration
+	routeTTLs   map[string]time.Duration
+	tagTTL      time.Duration
+	negativeTTL time.Duration
+	loadTimeout time.Duration
+	group       singleflight.Group
+	stats       Stats
+
+	beta   float64
+	now    func() time.Time
+	random func() float64
+	logger *slog.Logger
+}
+
+// GetOrLoad returns the cached response of the request or builds it with load.
+// Concurrent misses on the same key share a single load, and an entry close to
+// expiring is refreshed early by one of its readers so that the backend is
+// not hit by every request at once when it expires. A request going away
+// stops waiting for the load but does not cancel it for the others.
+func (r *RedisService) GetOrLoad(req *http.Request, accountID string, load Loader) (*Response, error) {
+	ctx := req.Context()
+	reqID := requestKey(req, accountID)
+
+	cached, found := r.find(ctx, reqID)
+	if found {
+		if cached.Error != "" {
+			atomic.AddUint64(&r.stats.NegativeHits, 1)
+			return nil, newNegativeError(cached.Error)
+		}
+		if !r.refreshEarly(cached) {
+			atomic.AddUint64(&r.stats.Hits, 1)
+			return cached.toResponse(), nil
+		}
+		atomic.AddUint64(&r.stats.EarlyRefreshes, 1)
+	}
+	atomic.AddUint64(&r.stats.Misses, 1)
+
+	var loaded int32
+	results := r.group.DoChan(reqID, func() (interface{}, error) {
+		atomic.StoreInt32(&loaded, 1)
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.loadTimeout)
+		defer cancel()
+		return r.load(loadCtx, reqID, req.URL.Path, load)
+	})
+
+	var result singleflight.Result
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result = <-results:
+	}
+	if atomic.LoadInt32(&loaded) == 0 {
+		atomic.AddUint64(&r.stats.Coalesced, 1)
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	return result.Val.(*Response), nil
+}
+
+func (r *RedisService) Invalidate(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	return r.client.DeleteTags(ctx, tags...)
+}
+
+func (r *RedisService) Stats() Stats {
+	return Stats{
+		Hits:           atomic.LoadUint64(&r.stats.Hits),
+		Misses:         atomic.LoadUint64(&r.stats.Misses),
+		Coalesced:      atomic.LoadUint64(&r.stats.Coalesced),
+		NegativeHits:   atomic.LoadUint64(&r.stats.NegativeHits),
+		EarlyRefreshes: atomic.LoadUint64(&r.stats.EarlyRefreshes),
+	}
 }
 
-func (r *RedisService) InsertCache(req *http.Request, obj any) error {
-	reqID := req.Method + "-" + req.URL.Path + utils.TransformMapInQueryParams(req.URL.Query()) + "-" + req.Header.Get(os.Getenv("JWT_TOKEN_HEADER"))
+func (r *RedisService) load(ctx context.Context, reqID, path string, load Loader) (*Response, error) {
+	start := r.now()
+	obj, tags, err := load(ctx)
+	delta := r.now().Sub(start)
+
+	if err != nil {
+		if name, negative := negativeErrorName(err); negative {
+			storeErr := r.storeEntry(ctx, reqID, &entry{Error: name}, r.negativeTTL, tags)
+			if storeErr != nil {
+				r.logger.ErrorContext(ctx, "storing cached error", "key", reqID, "error", storeErr)
+			}
+		}
+		return nil, err
+	}
 
 	respJson, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	cached := &entry{Value: respJson, ETag: utils.NewETag(respJson), Delta: delta}
+	err = r.storeEntry(ctx, reqID, cached, r.routeTTL(path), tags)
+	if err != nil {
+		r.logger.ErrorContext(ctx, "storing cached response", "key", reqID, "error", err)
+	}
+	return cached.toResponse(), nil
+}
+
+func (r *RedisService) storeEntry(ctx context.Context, reqID string, cached *entry, ttl time.Duration, tags []string) error {
+	cached.CreatedAt = r.now().UTC()
+	cached.ExpiresAt = cached.CreatedAt.Add(ttl)
+
+	entryJson, err := json.Marshal(cached)
 	if err != nil {
 		return err
 	}
 
-	err = r.client.InsertInDatabase(reqID, string(respJson))
+	err = r.client.InsertInDatabase(ctx, reqID, string(entryJson), ttl)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if len(tags) == 0 {
+		return nil
+	}
+	return r.client.AddKeyToTags(ctx, reqID, r.tagTTL, tags...)
 }
 
-func (r *RedisService) FindInCache(req *http.Request) (*interface{}, error) {
-	reqID := req.Method + "-" + req.URL.Path + utils.TransformMapInQueryParams(req.URL.Query()) + "-" + req.Header.Get(os.Getenv("JWT_TOKEN_HEADER"))
+func (r *RedisService) find(ctx context.Context, reqID string) (*entry, bool) {
+	val, err := r.client.FindInDatabase(ctx, reqID)
+	if err != nil {
+		return nil, false
+	}
 
-	val, err := r.client.FindInDatabase(reqID)
+	var cached entry
+	err = json.Unmarshal([]byte(val), &cached)
 	if err != nil {
-		switch e := err.(type) {
-		case *errors.CacheNotFoundError:
-			return nil, e
-		}
+		r.logger.ErrorContext(ctx, "decoding cached response", "key", reqID, "error", err)
+		return nil, false
 	}
 
-	var responseCache interface{}
+	return &cached, true
+}
 
-	err = json.Unmarshal([]byte(val), &responseCache)
-	if err != nil {
-		return nil, err
+// refreshEarly decides whether this read rebuilds the entry before it
+// expires, with a probability growing as expiry gets closer and the longer the
+// entry took to build (XFetch).
+func (r *RedisService) refreshEarly(cached *entry) bool {
+	if cached.Delta <= 0 {
+		return false
 	}
 
-	return &responseCache, nil
+	gap := time.Duration(float64(cached.Delta) * r.beta * -math.Log(1-r.random()))
+	return !r.now().Add(gap).Before(cached.ExpiresAt)
+}
+
+func (r *RedisService) routeTTL(path string) time.Duration {
+	if ttl, found := r.routeTTLs[path]; found {
+		return ttl
+	}
+	return r.ttl
+}
+
+// requestKey identifies a cached response by the account that asked for it
+// rather than by its token, so every token of an account shares the entry.
+func requestKey(req *http.Request, accountID string) string {
+	return req.Method + "-" + req.URL.Path + utils.TransformMapInQueryParams(req.URL.Query()) + "-" + accountID
 }
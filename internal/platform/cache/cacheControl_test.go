@@ -0,0 +1,312 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/utils"
+	"social_network_project/internal/utils/errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type backendFake struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+	tags   map[string][]string
+}
+
+func newBackendFake() *backendFake {
+	return &backendFake{
+		values: map[string]string{},
+		ttls:   map[string]time.Duration{},
+		tags:   map[string][]string{},
+	}
+}
+
+func (r *backendFake) InsertInDatabase(ctx context.Context, key string, value string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.values[key] = value
+	r.ttls[key] = ttl
+	return nil
+}
+
+func (r *backendFake) FindInDatabase(ctx context.Context, key string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, found := r.values[key]
+	if !found {
+		return "", &errors.CacheNotFoundError{}
+	}
+	return value, nil
+}
+
+func (r *backendFake) DeleteInDatabase(ctx context.Context, keys ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		delete(r.values, key)
+	}
+	return nil
+}
+
+func (r *backendFake) FindKeysByTags(ctx context.Context, tags ...string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []string
+	for _, tag := range tags {
+		keys = append(keys, r.tags[tag]...)
+	}
+	return keys, nil
+}
+
+func (r *backendFake) AddKeyToTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tag := range tags {
+		r.tags[tag] = append(r.tags[tag], key)
+	}
+	return nil
+}
+
+func (r *backendFake) DeleteTags(ctx context.Context, tags ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, tag := range tags {
+		for _, key := range r.tags[tag] {
+			delete(r.values, key)
+		}
+		delete(r.tags, tag)
+	}
+	return nil
+}
+
+func TestRedisService(t *testing.T) {
+	t.Run("keyed by account and sorted query", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard())
+		loads := 0
+		load := func(ctx context.Context) (any, []string, error) {
+			loads++
+			return []string{"post"}, nil, nil
+		}
+
+		req := httptest.NewRequest("GET", "/accounts/posts?page=1&account_id=a1", nil)
+		req.Header.Set("Authorization", "Bearer token-1")
+		_, err := redisService.GetOrLoad(req, "a1", load)
+		assert.Nil(t, err)
+
+		other := httptest.NewRequest("GET", "/accounts/posts?account_id=a1&page=1", nil)
+		other.Header.Set("Authorization", "Bearer token-2")
+		res, err := redisService.GetOrLoad(other, "a1", load)
+		assert.Nil(t, err)
+		assert.Equal(t, `["post"]`, string(res.Body))
+		assert.Equal(t, 1, loads)
+
+		_, err = redisService.GetOrLoad(other, "a2", load)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, loads)
+		assert.Equal(t, Stats{Hits: 1, Misses: 2}, redisService.Stats())
+	})
+
+	t.Run("invalidated by tag", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard())
+		loads := 0
+		loader := func(tags ...string) Loader {
+			return func(ctx context.Context) (any, []string, error) {
+				loads++
+				return "value", tags, nil
+			}
+		}
+
+		posts := httptest.NewRequest("GET", "/accounts/posts", nil)
+		account := httptest.NewRequest("GET", "/accounts", nil)
+		redisService.GetOrLoad(posts, "a1", loader(PostsTag("a1"), PostTag("p1")))
+		redisService.GetOrLoad(account, "a1", loader(AccountTag("a1")))
+
+		assert.Nil(t, redisService.Invalidate(context.Background(), PostTag("p1")))
+
+		redisService.GetOrLoad(posts, "a1", loader(PostsTag("a1"), PostTag("p1")))
+		redisService.GetOrLoad(account, "a1", loader(AccountTag("a1")))
+		assert.Equal(t, 3, loads)
+	})
+
+	t.Run("ttl by route", func(t *testing.T) {
+		backend := newBackendFake()
+		redisService := NewRedisService(backend, time.Minute, map[string]time.Duration{
+			"/accounts/follows/posts": 10 * time.Second,
+		}, time.Second, time.Second, logging.Discard())
+		load := func(ctx context.Context) (any, []string, error) {
+			return "value", nil, nil
+		}
+
+		redisService.GetOrLoad(httptest.NewRequest("GET", "/accounts/follows/posts", nil), "a1", load)
+		redisService.GetOrLoad(httptest.NewRequest("GET", "/accounts", nil), "a1", load)
+
+		assert.Equal(t, 10*time.Second, backend.ttls["GET-/accounts/follows/posts-a1"])
+		assert.Equal(t, time.Minute, backend.ttls["GET-/accounts-a1"])
+	})
+
+	t.Run("coalesces concurrent misses", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard())
+		release := make(chan struct{})
+		var loads int32
+		load := func(ctx context.Context) (any, []string, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return "value", nil, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := redisService.GetOrLoad(httptest.NewRequest("GET", "/accounts", nil), "a1", load)
+				assert.Nil(t, err)
+				assert.Equal(t, `"value"`, string(res.Body))
+			}()
+		}
+		for redisService.Stats().Misses < 10 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+		assert.Equal(t, uint64(9), redisService.Stats().Coalesced)
+	})
+
+	t.Run("caches not found errors", func(t *testing.T) {
+		backend := newBackendFake()
+		redisService := NewRedisService(backend, time.Minute, nil, 5*time.Second, time.Second, logging.Discard())
+		loads := 0
+		load := func(ctx context.Context) (any, []string, error) {
+			loads++
+			return nil, nil, &errors.NotFoundPostIDError{}
+		}
+
+		req := httptest.NewRequest("GET", "/accounts/comments?post_id=p1", nil)
+		_, err := redisService.GetOrLoad(req, "a1", load)
+		assert.IsType(t, &errors.NotFoundPostIDError{}, err)
+		_, err = redisService.GetOrLoad(req, "a1", load)
+		assert.IsType(t, &errors.NotFoundPostIDError{}, err)
+
+		assert.Equal(t, 1, loads)
+		assert.Equal(t, uint64(1), redisService.Stats().NegativeHits)
+		assert.Equal(t, 5*time.Second, backend.ttls["GET-/accounts/comments?post_id=p1-a1"])
+	})
+
+	t.Run("does not cache other errors", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, 5*time.Second, time.Second, logging.Discard())
+		loads := 0
+		load := func(ctx context.Context) (any, []string, error) {
+			loads++
+			return nil, nil, &errors.UnauthorizedAccountIDError{}
+		}
+
+		req := httptest.NewRequest("GET", "/accounts", nil)
+		redisService.GetOrLoad(req, "a1", load)
+		redisService.GetOrLoad(req, "a1", load)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("invalidating the tags of a not found error drops it", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, 5*time.Second, time.Second, logging.Discard())
+		loads := 0
+		load := func(ctx context.Context) (any, []string, error) {
+			loads++
+			return nil, []string{CommentsOfPostTag("p1")}, &errors.NotFoundPostIDError{}
+		}
+
+		req := httptest.NewRequest("GET", "/accounts/comments?post_id=p1", nil)
+		redisService.GetOrLoad(req, "a1", load)
+		assert.Nil(t, redisService.Invalidate(context.Background(), CommentsOfPostTag("p1")))
+		redisService.GetOrLoad(req, "a1", load)
+
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("a request going away does not cancel the load", func(t *testing.T) {
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard())
+		release := make(chan struct{})
+		loaded := make(chan error, 1)
+		var loads int32
+		load := func(ctx context.Context) (any, []string, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			loaded <- ctx.Err()
+			return "body", nil, nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := redisService.GetOrLoad(httptest.NewRequest("GET", "/accounts", nil).WithContext(ctx), "a1", load)
+		assert.Equal(t, context.Canceled, err)
+
+		close(release)
+		assert.Nil(t, <-loaded)
+		assert.Eventually(t, func() bool {
+			res, err := redisService.GetOrLoad(httptest.NewRequest("GET", "/accounts", nil), "a1", load)
+			return err == nil && string(res.Body) == `"body"`
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("etag of the stored body", func(t *testing.T) {
+		clock := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard()).(*RedisService)
+		redisService.now = func() time.Time { return clock }
+		load := func(ctx context.Context) (any, []string, error) {
+			return map[string]string{"id": "p1"}, nil, nil
+		}
+		req := httptest.NewRequest("GET", "/posts", nil)
+
+		loaded, err := redisService.GetOrLoad(req, "a1", load)
+		assert.Nil(t, err)
+		clock = clock.Add(10 * time.Second)
+		cached, err := redisService.GetOrLoad(req, "a1", load)
+		assert.Nil(t, err)
+
+		assert.Equal(t, loaded.Body, cached.Body)
+		assert.Equal(t, utils.NewETag(cached.Body), cached.ETag)
+		assert.Equal(t, loaded.ETag, cached.ETag)
+		assert.True(t, cached.LastModified.Equal(time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("refreshes early close to expiry", func(t *testing.T) {
+		clock := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		redisService := NewRedisService(newBackendFake(), time.Minute, nil, time.Second, time.Second, logging.Discard()).(*RedisService)
+		redisService.now = func() time.Time { return clock }
+		loads := 0
+		load := func(ctx context.Context) (any, []string, error) {
+			loads++
+			clock = clock.Add(time.Second)
+			return "value", nil, nil
+		}
+		req := httptest.NewRequest("GET", "/accounts", nil)
+
+		redisService.GetOrLoad(req, "a1", load)
+
+		redisService.random = func() float64 { return 0.5 }
+		clock = clock.Add(30 * time.Second)
+		redisService.GetOrLoad(req, "a1", load)
+		assert.Equal(t, 1, loads)
+
+		clock = clock.Add(29*time.Second + 500*time.Millisecond)
+		redisService.GetOrLoad(req, "a1", load)
+		assert.Equal(t, 2, loads)
+		assert.Equal(t, uint64(1), redisService.Stats().EarlyRefreshes)
+	})
+}
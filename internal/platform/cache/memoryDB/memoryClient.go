@@ -0,0 +1,157 @@
+package memoryDB
+
+import (
+	"container/list"
+	"context"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/utils/errors"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process cache holding at most capacity keys. The least
+// recently used key is evicted first and keys expire after their TTL. It is
+// meant for single-instance runs, tests, and as the local tier of tieredDB.
+type Memory struct {
+	mu       sync.Mutex
+	capacity int
+	now      func() time.Time
+	items    map[string]*list.Element
+	order    *list.List
+	tags     map[string]map[string]struct{}
+}
+
+type entry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+	tags      map[string]struct{}
+}
+
+func NewMemory(capacity int, now func() time.Time) cache.Backend {
+	return &Memory{
+		capacity: capacity,
+		now:      now,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+		tags:     map[string]map[string]struct{}{},
+	}
+}
+
+func (m *Memory) InsertInDatabase(ctx context.Context, key string, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if element, found := m.items[key]; found {
+		e := element.Value.(*entry)
+		e.value = value
+		e.expiresAt = m.now().Add(ttl)
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.items[key] = m.order.PushFront(&entry{
+		key:       key,
+		value:     value,
+		expiresAt: m.now().Add(ttl),
+		tags:      map[string]struct{}{},
+	})
+
+	for m.order.Len() > m.capacity {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+func (m *Memory) FindInDatabase(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, found := m.items[key]
+	if !found {
+		return "", &errors.CacheNotFoundError{}
+	}
+
+	e := element.Value.(*entry)
+	if !m.now().Before(e.expiresAt) {
+		m.remove(element)
+		return "", &errors.CacheNotFoundError{}
+	}
+
+	m.order.MoveToFront(element)
+	return e.value, nil
+}
+
+func (m *Memory) DeleteInDatabase(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if element, found := m.items[key]; found {
+			m.remove(element)
+		}
+	}
+	return nil
+}
+
+// AddKeyToTags tags a stored key. Tags are dropped with the key, so ttl is not
+// used.
+func (m *Memory) AddKeyToTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, found := m.items[key]
+	if !found {
+		return nil
+	}
+
+	e := element.Value.(*entry)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = map[string]struct{}{}
+		}
+		m.tags[tag][key] = struct{}{}
+		e.tags[tag] = struct{}{}
+	}
+	return nil
+}
+
+func (m *Memory) FindKeysByTags(ctx context.Context, tags ...string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (m *Memory) DeleteTags(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if element, found := m.items[key]; found {
+				m.remove(element)
+			}
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
+
+func (m *Memory) remove(element *list.Element) {
+	e := element.Value.(*entry)
+	for tag := range e.tags {
+		delete(m.tags[tag], e.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+	delete(m.items, e.key)
+	m.order.Remove(element)
+}
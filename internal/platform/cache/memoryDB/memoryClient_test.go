@@ -0,0 +1,84 @@
+package memoryDB
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/utils/errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestMemory(t *testing.T) {
+	t.Run("evicts the least recently used key", func(t *testing.T) {
+		memory := NewMemory(2, time.Now)
+
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "a", "1", time.Minute))
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "b", "2", time.Minute))
+		_, err := memory.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "c", "3", time.Minute))
+
+		_, err = memory.FindInDatabase(context.Background(), "b")
+		assert.IsType(t, &errors.CacheNotFoundError{}, err)
+		value, err := memory.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+	})
+
+	t.Run("expires after ttl", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)}
+		memory := NewMemory(10, clock.Now)
+
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "a", "1", time.Minute))
+		clock.now = clock.now.Add(59 * time.Second)
+		_, err := memory.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+
+		clock.now = clock.now.Add(time.Second)
+		_, err = memory.FindInDatabase(context.Background(), "a")
+		assert.IsType(t, &errors.CacheNotFoundError{}, err)
+	})
+
+	t.Run("deletes tagged keys", func(t *testing.T) {
+		memory := NewMemory(10, time.Now)
+
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "a", "1", time.Minute))
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "b", "2", time.Minute))
+		assert.Nil(t, memory.AddKeyToTags(context.Background(), "a", time.Minute, "post:1", "posts:1"))
+		assert.Nil(t, memory.AddKeyToTags(context.Background(), "b", time.Minute, "posts:2"))
+
+		keys, err := memory.FindKeysByTags(context.Background(), "post:1")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"a"}, keys)
+
+		assert.Nil(t, memory.DeleteTags(context.Background(), "posts:1"))
+		_, err = memory.FindInDatabase(context.Background(), "a")
+		assert.IsType(t, &errors.CacheNotFoundError{}, err)
+		_, err = memory.FindInDatabase(context.Background(), "b")
+		assert.Nil(t, err)
+
+		keys, err = memory.FindKeysByTags(context.Background(), "post:1")
+		assert.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("evicted keys leave their tags", func(t *testing.T) {
+		memory := NewMemory(1, time.Now)
+
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "a", "1", time.Minute))
+		assert.Nil(t, memory.AddKeyToTags(context.Background(), "a", time.Minute, "post:1"))
+		assert.Nil(t, memory.InsertInDatabase(context.Background(), "b", "2", time.Minute))
+
+		keys, err := memory.FindKeysByTags(context.Background(), "post:1")
+		assert.Nil(t, err)
+		assert.Empty(t, keys)
+	})
+}
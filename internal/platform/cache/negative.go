@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"reflect"
+	"social_network_project/internal/utils/errors"
+)
+
+// negativeErrors are the not found errors whose result is cached, so repeated
+// lookups of a missing id do not reach the database. Services return them only
+// when the row does not exist, through errors.NotFound, never when the
+// database fails.
+var negativeErrors = map[string]func() error{}
+
+func init() {
+	RegisterNegativeError(func() error { return &errors.NotFoundAccountIDError{} })
+	RegisterNegativeError(func() error { return &errors.NotFoundPostIDError{} })
+	RegisterNegativeError(func() error { return &errors.NotFoundCommentIDError{} })
+}
+
+func RegisterNegativeError(newError func() error) {
+	negativeErrors[reflect.TypeOf(newError()).String()] = newError
+}
+
+func negativeErrorName(err error) (string, bool) {
+	name := reflect.TypeOf(err).String()
+	_, found := negativeErrors[name]
+	return name, found
+}
+
+func newNegativeError(name string) error {
+	newError, found := negativeErrors[name]
+	if !found {
+		return &errors.CacheNotFoundError{}
+	}
+	return newError()
+}
@@ -1,47 +1,68 @@
 package redisDB
 
 import (
+	"context"
+	"github.com/go-redis/redis/extra/redisotel/v8"
 	"github.com/go-redis/redis/v8"
-	"log"
-	"os"
+	"social_network_project/internal/platform/cache"
 	"social_network_project/internal/utils/errors"
 	"time"
 )
 
-
 type RedisClient interface {
+	cache.Backend
 	ConnectToDatabase() error
-	InsertInDatabase(key string, value string) error
-	FindInDatabase(key string) (string, error)
+	Conn() *redis.Client
+	Close() error
+}
+
+type Config struct {
+	Addr     string
+	Password string
 }
 
 type Redis struct {
 	Client *redis.Client
+	config Config
 }
 
-func NewRedis() RedisClient {
-	return &Redis{}
+func NewRedis(config Config) RedisClient {
+	return &Redis{config: config}
 }
 
 func (r *Redis) ConnectToDatabase() error {
 
 	client := redis.NewClient(&redis.Options{
-		Addr:     os.Getenv("REDIS_HOST") + ":" + os.Getenv("REDIS_PORT"),
-		Password: os.Getenv("REDIS_PASSWORD"),
+		Addr:     r.config.Addr,
+		Password: r.config.Password,
 		DB:       0,
 	})
 
 	_, err := client.Ping(client.Context()).Result()
 	if err != nil {
+		client.Close()
 		return err
 	}
-	log.Println("Redis Connected")
+	client.AddHook(redisotel.NewTracingHook())
 	r.Client = client
 	return nil
 }
 
-func (r *Redis) InsertInDatabase(key string, value string) error {
-	err := r.Client.Set(r.Client.Context(), key, value, 5*time.Minute).Err()
+// Conn returns the connected client, for the other features keeping their
+// state in Redis.
+func (r *Redis) Conn() *redis.Client {
+	return r.Client
+}
+
+func (r *Redis) Close() error {
+	if r.Client == nil {
+		return nil
+	}
+	return r.Client.Close()
+}
+
+func (r *Redis) InsertInDatabase(ctx context.Context, key string, value string, ttl time.Duration) error {
+	err := r.Client.Set(ctx, key, value, ttl).Err()
 	if err != nil {
 		return err
 	}
@@ -49,10 +70,59 @@ func (r *Redis) InsertInDatabase(key string, value string) error {
 	return nil
 }
 
-func (r *Redis) FindInDatabase(key string) (string, error) {
-	val, err := r.Client.Get(r.Client.Context(), key).Result()
+func (r *Redis) FindInDatabase(ctx context.Context, key string) (string, error) {
+	val, err := r.Client.Get(ctx, key).Result()
 	if err != nil {
 		return "", &errors.CacheNotFoundError{}
 	}
 	return val, nil
 }
+
+// AddKeyToTags records key in the set of every tag, so that deleting a tag
+// deletes every key stored under it. The sets expire after ttl, which must be
+// at least the TTL of the keys they hold.
+func (r *Redis) AddKeyToTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			pipe.SAdd(ctx, tagKey(tag), key)
+			pipe.Expire(ctx, tagKey(tag), ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) DeleteInDatabase(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.Client.Del(ctx, keys...).Err()
+}
+
+func (r *Redis) FindKeysByTags(ctx context.Context, tags ...string) ([]string, error) {
+	var keys []string
+	for _, tag := range tags {
+		members, err := r.Client.SMembers(ctx, tagKey(tag)).Result()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, members...)
+	}
+	return keys, nil
+}
+
+func (r *Redis) DeleteTags(ctx context.Context, tags ...string) error {
+	keys, err := r.FindKeysByTags(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	return r.DeleteInDatabase(ctx, keys...)
+}
+
+func tagKey(tag string) string {
+	return "tag-" + tag
+}
@@ -0,0 +1,41 @@
+package cache
+
+// Tags name what a cached response was built from. Entity tags cover every
+// entry that shows the entity; collection tags cover the lists an entity is
+// added to or removed from.
+
+func AccountTag(accountID string) string {
+	return "account:" + accountID
+}
+
+func FollowsTag(accountID string) string {
+	return "follows:" + accountID
+}
+
+func PostTag(postID string) string {
+	return "post:" + postID
+}
+
+func PostsTag(accountID string) string {
+	return "posts:" + accountID
+}
+
+func FeedTag(accountID string) string {
+	return "feed:" + accountID
+}
+
+func CommentTag(commentID string) string {
+	return "comment:" + commentID
+}
+
+func CommentsOfAccountTag(accountID string) string {
+	return "comments:account:" + accountID
+}
+
+func CommentsOfPostTag(postID string) string {
+	return "comments:post:" + postID
+}
+
+func CommentsOfCommentTag(commentID string) string {
+	return "comments:comment:" + commentID
+}
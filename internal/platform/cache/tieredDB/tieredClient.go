@@ -0,0 +1,102 @@
+package tieredDB
+
+import (
+	"context"
+	"social_network_project/internal/platform/cache"
+	"time"
+)
+
+// Tiered keeps hot keys in a local backend in front of a shared remote one.
+// Local copies live for at most localTTL: invalidations made by this instance
+// drop them at once, but writes handled by other instances are only seen once
+// the local copy expires.
+type Tiered struct {
+	local    cache.Backend
+	remote   cache.Backend
+	localTTL time.Duration
+}
+
+func NewTiered(local, remote cache.Backend, localTTL time.Duration) cache.Backend {
+	return &Tiered{
+		local:    local,
+		remote:   remote,
+		localTTL: localTTL,
+	}
+}
+
+func (t *Tiered) InsertInDatabase(ctx context.Context, key string, value string, ttl time.Duration) error {
+	err := t.remote.InsertInDatabase(ctx, key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	return t.local.InsertInDatabase(ctx, key, value, t.localTTLFor(ttl))
+}
+
+func (t *Tiered) FindInDatabase(ctx context.Context, key string) (string, error) {
+	value, err := t.local.FindInDatabase(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	value, err = t.remote.FindInDatabase(ctx, key)
+	if err != nil {
+		return "", err
+	}
+
+	err = t.local.InsertInDatabase(ctx, key, value, t.localTTL)
+	if err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func (t *Tiered) DeleteInDatabase(ctx context.Context, keys ...string) error {
+	err := t.remote.DeleteInDatabase(ctx, keys...)
+	if err != nil {
+		return err
+	}
+
+	return t.local.DeleteInDatabase(ctx, keys...)
+}
+
+func (t *Tiered) AddKeyToTags(ctx context.Context, key string, ttl time.Duration, tags ...string) error {
+	err := t.remote.AddKeyToTags(ctx, key, ttl, tags...)
+	if err != nil {
+		return err
+	}
+
+	return t.local.AddKeyToTags(ctx, key, ttl, tags...)
+}
+
+func (t *Tiered) FindKeysByTags(ctx context.Context, tags ...string) ([]string, error) {
+	return t.remote.FindKeysByTags(ctx, tags...)
+}
+
+// DeleteTags also drops the local copies read through from the remote
+// backend, which were stored locally without their tags.
+func (t *Tiered) DeleteTags(ctx context.Context, tags ...string) error {
+	keys, err := t.remote.FindKeysByTags(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	err = t.local.DeleteInDatabase(ctx, keys...)
+	if err != nil {
+		return err
+	}
+
+	err = t.local.DeleteTags(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	return t.remote.DeleteTags(ctx, tags...)
+}
+
+func (t *Tiered) localTTLFor(ttl time.Duration) time.Duration {
+	if ttl < t.localTTL {
+		return ttl
+	}
+	return t.localTTL
+}
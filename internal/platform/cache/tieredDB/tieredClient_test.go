@@ -0,0 +1,69 @@
+package tieredDB
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/platform/cache/memoryDB"
+	"social_network_project/internal/utils/errors"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestTiered(t *testing.T) {
+	t.Run("reads through and serves locally", func(t *testing.T) {
+		remote := memoryDB.NewMemory(10, time.Now)
+		tiered := NewTiered(memoryDB.NewMemory(10, time.Now), remote, time.Minute)
+
+		assert.Nil(t, remote.InsertInDatabase(context.Background(), "a", "1", time.Hour))
+		value, err := tiered.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+
+		assert.Nil(t, remote.DeleteInDatabase(context.Background(), "a"))
+		value, err = tiered.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+	})
+
+	t.Run("local copies expire after the local ttl", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)}
+		remote := memoryDB.NewMemory(10, clock.Now)
+		tiered := NewTiered(memoryDB.NewMemory(10, clock.Now), remote, 10*time.Second)
+
+		assert.Nil(t, tiered.InsertInDatabase(context.Background(), "a", "1", time.Hour))
+		assert.Nil(t, remote.InsertInDatabase(context.Background(), "a", "2", time.Hour))
+
+		value, err := tiered.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Equal(t, "1", value)
+
+		clock.now = clock.now.Add(10 * time.Second)
+		value, err = tiered.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("invalidation drops read-through copies", func(t *testing.T) {
+		remote := memoryDB.NewMemory(10, time.Now)
+		other := NewTiered(memoryDB.NewMemory(10, time.Now), remote, time.Minute)
+		tiered := NewTiered(memoryDB.NewMemory(10, time.Now), remote, time.Minute)
+
+		assert.Nil(t, other.InsertInDatabase(context.Background(), "a", "1", time.Hour))
+		assert.Nil(t, other.AddKeyToTags(context.Background(), "a", time.Hour, "post:1"))
+
+		_, err := tiered.FindInDatabase(context.Background(), "a")
+		assert.Nil(t, err)
+
+		assert.Nil(t, tiered.DeleteTags(context.Background(), "post:1"))
+		_, err = tiered.FindInDatabase(context.Background(), "a")
+		assert.IsType(t, &errors.CacheNotFoundError{}, err)
+	})
+}
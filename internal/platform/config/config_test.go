@@ -0,0 +1,163 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := values[name]
+		return value, found
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+
+	t.Run("defaults", func(t *testing.T) {
+		config, err := Load(nil, env(nil))
+		assert.Nil(t, err)
+		assert.Equal(t, Default(), config)
+		assert.Equal(t, 5*time.Minute, config.Cache.RouteTTL(config.Cache.TTLPosts))
+	})
+
+	t.Run("file, environment and flags override in order", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+server:
+  port: 9000
+redis:
+  host: redis.internal
+  port: 6380
+cache:
+  ttl: 2m
+  ttl_posts: 30s
+`)
+
+		config, err := Load([]string{"-redis.port", "6381"}, env(map[string]string{
+			"CONFIG_FILE": path,
+			"API_PORT":    "9001",
+			"REDIS_PORT":  "6390",
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, 9001, config.Server.Port)
+		assert.Equal(t, "redis.internal", config.Redis.Host)
+		assert.Equal(t, 6381, config.Redis.Port)
+		assert.Equal(t, 2*time.Minute, config.Cache.TTL)
+		assert.Equal(t, 30*time.Second, config.Cache.RouteTTL(config.Cache.TTLPosts))
+		assert.Equal(t, 2*time.Minute, config.Cache.RouteTTL(config.Cache.TTLFeed))
+	})
+
+	t.Run("toml file given by flag", func(t *testing.T) {
+		path := writeFile(t, "config.toml", `
+[message_broker]
+backend = "memory"
+
+[rate_limit]
+auth = 5
+auth_window = "10s"
+`)
+
+		config, err := Load([]string{"-config", path}, env(nil))
+		assert.Nil(t, err)
+		assert.Equal(t, "memory", config.MessageBroker.Backend)
+		assert.Equal(t, 5, config.RateLimit.AuthLimit)
+		assert.Equal(t, 10*time.Second, config.RateLimit.AuthWindow)
+	})
+
+	t.Run("oidc providers from file and environment", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+oidc:
+  google:
+    issuer: https://accounts.google.com
+    client_id: file-client
+    redirect_url: https://example.com/auth/oidc/google/callback
+`)
+
+		config, err := Load(nil, env(map[string]string{
+			"CONFIG_FILE":              path,
+			"OIDC_PROVIDERS":           "google,gitlab",
+			"OIDC_GOOGLE_CLIENT_ID":    "env-client",
+			"OIDC_GITLAB_ISSUER":       "https://gitlab.com",
+			"OIDC_GITLAB_CLIENT_ID":    "gitlab-client",
+			"OIDC_GITLAB_SCOPES":       "openid email",
+			"OIDC_GITLAB_REDIRECT_URL": "https://example.com/auth/oidc/gitlab/callback",
+		}))
+		assert.Nil(t, err)
+		assert.Equal(t, OIDCProvider{
+			Issuer:      "https://accounts.google.com",
+			ClientID:    "env-client",
+			RedirectURL: "https://example.com/auth/oidc/google/callback",
+			Scopes:      []string{"openid", "email", "profile"},
+		}, config.OIDC["google"])
+		assert.Equal(t, []string{"openid", "email"}, config.OIDC["gitlab"].Scopes)
+	})
+
+	t.Run("reports every problem at once", func(t *testing.T) {
+		path := writeFile(t, "config.yaml", `
+redis:
+  hots: redis.internal
+cache:
+  backend: disk
+`)
+
+		_, err := Load([]string{"-jwt.token_ttl", "forever"}, env(map[string]string{
+			"CONFIG_FILE":            path,
+			"API_PORT":               "http",
+			"SERVER_TRUSTED_PROXIES": "10.0.0.0/8,proxy",
+			"RABBITMQ_URL":           "localhost:5672",
+			"SMTP_HOST":              "smtp.mail.com",
+			"OIDC_PROVIDERS":         "google",
+			"TRACING_EXPORTER":       "jaeger",
+			"OUTBOX_BATCH_SIZE":      "0",
+			"WEBHOOK_MAX_BACKOFF":    "1s",
+		}))
+		assert.Equal(t, &Error{Problems: []string{
+			"file " + path + ": redis.hots: unknown setting",
+			`env API_PORT: "http" is not a whole number`,
+			`flag -jwt.token_ttl: "forever" is not a duration such as 30s or 5m`,
+			`cache.backend: "disk" is not one of redis, memory, tiered`,
+			"mail.from: is required",
+			`message_broker.rabbitmq_url: "localhost:5672" is not an amqp:// or amqps:// URL`,
+			"oidc.google.client_id: is required",
+			`oidc.google.issuer: "" is not an http or https URL`,
+			"oidc.google.redirect_url: is required",
+			"outbox.batch_size: must be greater than 0",
+			`server.trusted_proxies: "proxy" is not an IP or CIDR`,
+			`tracing.exporter: "jaeger" is not one of none, otlp, stdout`,
+			"webhook.max_backoff: must not be shorter than webhook.base_backoff",
+		}}, err)
+	})
+
+	t.Run("key rotation of 0 disables it", func(t *testing.T) {
+		config, err := Load(nil, env(map[string]string{"JWT_KEY_ROTATION": "0"}))
+		assert.Nil(t, err)
+		assert.Equal(t, time.Duration(0), config.JWT.KeyRotation)
+
+		_, err = Load(nil, env(map[string]string{"JWT_KEY_ROTATION": "30m"}))
+		assert.Equal(t, &Error{Problems: []string{"jwt.key_rotation: must be 0 or longer than jwt.token_ttl"}}, err)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := Load([]string{"-port", "80"}, env(nil))
+		assert.NotNil(t, err)
+	})
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	assert.Nil(t, LoadDotEnv(filepath.Join(t.TempDir(), ".env")))
+
+	path := writeFile(t, ".env", "CONFIG_TEST_DOTENV=from-file\n")
+	t.Setenv("CONFIG_TEST_DOTENV", "")
+	os.Unsetenv("CONFIG_TEST_DOTENV")
+	assert.Nil(t, LoadDotEnv(path))
+	assert.Equal(t, "from-file", os.Getenv("CONFIG_TEST_DOTENV"))
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the file of settings when no -config flag is given.
+const FileEnv = "CONFIG_FILE"
+
+// Error lists every problem found loading a configuration, so they can all
+// be fixed at once.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// LoadDotEnv adds the variables of the .env file at path to the environment,
+// keeping those already set. A missing file is not an error.
+func LoadDotEnv(path string) error {
+	err := godotenv.Load(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Load builds the configuration from its defaults, overridden by the YAML or
+// TOML file named by -config or CONFIG_FILE, then by the environment read
+// through lookupEnv, then by the flags in args, and validates it.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	config := Default()
+	all := settings(reflect.ValueOf(config).Elem(), "", "")
+
+	flags := flag.NewFlagSet("social_network", flag.ContinueOnError)
+	file := flags.String("config", "", "YAML or TOML file of settings (env "+FileEnv+")")
+	for _, s := range all {
+		flags.String(s.path, format(s.value), "env "+s.env)
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	setFlags := map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+
+	var problems []string
+
+	path := *file
+	if _, found := setFlags["config"]; !found {
+		path, _ = lookupEnv(FileEnv)
+	}
+	if path != "" {
+		problems = append(problems, loadFile(config, all, path)...)
+	}
+
+	problems = append(problems, loadEnv(config, all, lookupEnv)...)
+
+	for _, s := range all {
+		raw, found := setFlags[s.path]
+		if !found {
+			continue
+		}
+		err = set(s.value, raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("flag -%s: %v", s.path, err))
+		}
+	}
+
+	problems = append(problems, config.validate()...)
+	if len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+	return config, nil
+}
+
+func loadFile(config *Config, all []setting, path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("file %s: %v", path, err)}
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &values)
+	case ".toml":
+		err = toml.Unmarshal(content, &values)
+	default:
+		return []string{fmt.Sprintf("file %s: not a .yaml, .yml or .toml file", path)}
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("file %s: %v", path, err)}
+	}
+
+	flat := map[string]interface{}{}
+	flatten(values, "", flat)
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	byPath := map[string]setting{}
+	for _, s := range all {
+		byPath[s.path] = s
+	}
+
+	var problems []string
+	for _, key := range keys {
+		raw := rawString(flat[key])
+
+		if strings.HasPrefix(key, "oidc.") {
+			parts := strings.SplitN(key, ".", 3)
+			if len(parts) != 3 {
+				problems = append(problems, fmt.Sprintf("file %s: %s is not a provider setting", path, key))
+				continue
+			}
+			err = setProvider(config, parts[1], parts[2], raw)
+		} else if s, found := byPath[key]; found {
+			err = set(s.value, raw)
+		} else {
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("file %s: %s: %v", path, key, err))
+		}
+	}
+	return problems
+}
+
+func loadEnv(config *Config, all []setting, lookupEnv func(string) (string, bool)) []string {
+	var problems []string
+	for _, s := range all {
+		raw, found := lookupEnv(s.env)
+		if !found {
+			continue
+		}
+		err := set(s.value, raw)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("env %s: %v", s.env, err))
+		}
+	}
+
+	names, _ := lookupEnv("OIDC_PROVIDERS")
+	for _, name := range splitList(names) {
+		if _, found := config.OIDC[name]; !found {
+			config.OIDC[name] = defaultOIDCProvider()
+		}
+	}
+	for name := range config.OIDC {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		for _, field := range settings(reflect.ValueOf(OIDCProvider{}), "", prefix) {
+			raw, found := lookupEnv(field.env)
+			if !found {
+				continue
+			}
+			err := setProvider(config, name, field.path, raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("env %s: %v", field.env, err))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func setProvider(config *Config, name, path, raw string) error {
+	provider, found := config.OIDC[name]
+	if !found {
+		provider = defaultOIDCProvider()
+	}
+	for _, s := range settings(reflect.ValueOf(&provider).Elem(), "", "") {
+		if s.path == path {
+			err := set(s.value, raw)
+			if err != nil {
+				return err
+			}
+			config.OIDC[name] = provider
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown setting")
+}
+
+// setting is a field of the configuration with the names it is given by.
+type setting struct {
+	path  string
+	env   string
+	value reflect.Value
+}
+
+// settings lists the fields of v and of the structs within it, leaving maps
+// out. With envPrefix, fields are named in the environment by it followed by
+// their config tag in upper case instead of by their env tag.
+func settings(v reflect.Value, prefix, envPrefix string) []setting {
+	var all []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := prefix + field.Tag.Get("config")
+
+		switch field.Type.Kind() {
+		case reflect.Map:
+			continue
+		case reflect.Struct:
+			all = append(all, settings(v.Field(i), path+".", envPrefix)...)
+			continue
+		}
+
+		env := field.Tag.Get("env")
+		if envPrefix != "" {
+			env = envPrefix + strings.ToUpper(field.Tag.Get("config"))
+		}
+		all = append(all, setting{path: path, env: env, value: v.Field(i)})
+	}
+	return all
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func set(value reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if value.Type() == durationType {
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", raw)
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Int:
+		number, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		value.SetInt(int64(number))
+	case reflect.Bool:
+		boolean, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		value.SetBool(boolean)
+	case reflect.Slice:
+		value.Set(reflect.ValueOf(splitList(raw)))
+	}
+	return nil
+}
+
+func format(value reflect.Value) string {
+	if value.Type() == durationType {
+		return time.Duration(value.Int()).String()
+	}
+	if value.Kind() == reflect.Slice {
+		return strings.Join(value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// splitList splits a list separated by commas or spaces.
+func splitList(raw string) []string {
+	return strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// flatten names the values of nested tables by their path, "redis.host".
+func flatten(values map[string]interface{}, prefix string, flat map[string]interface{}) {
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(nested, prefix+key+".", flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+}
+
+func rawString(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
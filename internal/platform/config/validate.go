@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"social_network_project/internal/platform/keyring"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/platform/tracing"
+	"sort"
+	"strings"
+	"time"
+)
+
+// problems collects what is wrong with a configuration.
+type problems []string
+
+func (p *problems) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p *problems) oneOf(path, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	*p = append(*p, fmt.Sprintf("%s: %q is not one of %s", path, value, strings.Join(allowed, ", ")))
+}
+
+func (p *problems) required(path, value string) {
+	p.check(strings.TrimSpace(value) != "", "%s: is required", path)
+}
+
+func (p *problems) port(path string, value int) {
+	p.check(value > 0 && value <= 65535, "%s: %d is not a port", path, value)
+}
+
+func (p *problems) positive(path string, value time.Duration) {
+	p.check(value > 0, "%s: must be longer than 0", path)
+}
+
+func (p *problems) notNegative(path string, value int) {
+	p.check(value >= 0, "%s: must not be negative", path)
+}
+
+func (c *Config) validate() []string {
+	var p problems
+
+	p.port("server.port", c.Server.Port)
+	p.required("server.token_header", c.Server.TokenHeader)
+	p.positive("server.read_header_timeout", c.Server.ReadHeaderTimeout)
+	p.positive("server.read_timeout", c.Server.ReadTimeout)
+	p.positive("server.write_timeout", c.Server.WriteTimeout)
+	p.positive("server.idle_timeout", c.Server.IdleTimeout)
+	p.port("server.metrics_port", c.Server.MetricsPort)
+	p.check(c.Server.MetricsPort != c.Server.Port, "server.metrics_port: must not be server.port")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		p.check(err == nil || net.ParseIP(proxy) != nil, "server.trusted_proxies: %q is not an IP or CIDR", proxy)
+	}
+	p.positive("lifecycle.connect_timeout", c.Lifecycle.ConnectTimeout)
+	p.positive("lifecycle.health_timeout", c.Lifecycle.HealthTimeout)
+	p.check(c.Lifecycle.HealthCacheTTL >= 0, "lifecycle.health_cache_ttl: must not be negative")
+	p.check(c.Lifecycle.DrainDelay >= 0, "lifecycle.drain_delay: must not be negative")
+	p.positive("lifecycle.shutdown_timeout", c.Lifecycle.ShutdownTimeout)
+	p.oneOf("log.level", c.Log.Level, "debug", "info", "warn", "error")
+	p.oneOf("log.format", c.Log.Format, logging.FORMAT_JSON, logging.FORMAT_TEXT)
+
+	p.required("postgres.host", c.Postgres.Host)
+	p.port("postgres.port", c.Postgres.Port)
+	p.required("postgres.user", c.Postgres.User)
+	p.required("postgres.name", c.Postgres.Name)
+
+	p.oneOf("cache.backend", c.Cache.Backend, "redis", "memory", "tiered")
+	p.oneOf("rate_limit.backend", c.RateLimit.Backend, "redis", "memory")
+	p.oneOf("session.backend", c.Session.Backend, "redis", "memory")
+	p.oneOf("message_broker.backend", c.MessageBroker.Backend, "rabbitmq", "memory")
+
+	if c.Cache.Backend != "memory" || c.RateLimit.Backend != "memory" || c.Session.Backend != "memory" {
+		p.required("redis.host", c.Redis.Host)
+		p.port("redis.port", c.Redis.Port)
+	}
+
+	if c.Cache.Backend == "memory" || c.Cache.Backend == "tiered" {
+		p.check(c.Cache.MemoryCapacity > 0, "cache.memory_capacity: must be greater than 0")
+	}
+	if c.Cache.Backend == "tiered" {
+		p.positive("cache.local_ttl", c.Cache.LocalTTL)
+	}
+	p.positive("cache.ttl", c.Cache.TTL)
+	for path, ttl := range map[string]time.Duration{
+		"cache.ttl_accounts": c.Cache.TTLAccounts,
+		"cache.ttl_follows":  c.Cache.TTLFollows,
+		"cache.ttl_posts":    c.Cache.TTLPosts,
+		"cache.ttl_feed":     c.Cache.TTLFeed,
+		"cache.ttl_comments": c.Cache.TTLComments,
+	} {
+		p.check(ttl >= 0, "%s: must not be negative", path)
+	}
+	p.check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl: must not be negative")
+
+	for _, policy := range []struct {
+		name   string
+		limit  int
+		window time.Duration
+	}{
+		{"auth", c.RateLimit.AuthLimit, c.RateLimit.AuthWindow},
+		{"write", c.RateLimit.WriteLimit, c.RateLimit.WriteWindow},
+		{"read", c.RateLimit.ReadLimit, c.RateLimit.ReadWindow},
+	} {
+		p.notNegative("rate_limit."+policy.name, policy.limit)
+		if policy.limit > 0 {
+			p.positive("rate_limit."+policy.name+"_window", policy.window)
+		}
+	}
+
+	if c.MessageBroker.Backend == "rabbitmq" {
+		rabbitURL, err := url.Parse(c.MessageBroker.RabbitMQURL)
+		p.check(err == nil && (rabbitURL.Scheme == "amqp" || rabbitURL.Scheme == "amqps") && rabbitURL.Host != "",
+			"message_broker.rabbitmq_url: %q is not an amqp:// or amqps:// URL", c.MessageBroker.RabbitMQURL)
+	}
+
+	p.positive("notification.aggregation_window", c.Notification.AggregationWindow)
+	p.positive("notification.dedupe_window", c.Notification.DedupeWindow)
+	p.positive("notification.flush_interval", c.Notification.FlushInterval)
+	p.positive("outbox.poll_interval", c.Outbox.PollInterval)
+	p.check(c.Outbox.BatchSize > 0, "outbox.batch_size: must be greater than 0")
+	p.positive("outbox.retention", c.Outbox.Retention)
+	p.positive("webhook.interval", c.Webhook.Interval)
+	p.check(c.Webhook.BatchSize > 0, "webhook.batch_size: must be greater than 0")
+	p.positive("webhook.timeout", c.Webhook.Timeout)
+	p.check(c.Webhook.MaxAttempts > 0, "webhook.max_attempts: must be greater than 0")
+	p.check(c.Webhook.MaxConsecutiveFailures > 0, "webhook.max_consecutive_failures: must be greater than 0")
+	p.positive("webhook.base_backoff", c.Webhook.BaseBackoff)
+	p.check(c.Webhook.MaxBackoff >= c.Webhook.BaseBackoff, "webhook.max_backoff: must not be shorter than webhook.base_backoff")
+	if c.Mail.Host != "" {
+		p.port("mail.port", c.Mail.Port)
+		p.required("mail.from", c.Mail.From)
+	}
+
+	p.required("jwt.keys_dir", c.JWT.KeysDir)
+	p.oneOf("jwt.algorithm", c.JWT.Algorithm, keyring.ALGORITHM_RS256, keyring.ALGORITHM_EDDSA)
+	p.required("jwt.issuer", c.JWT.Issuer)
+	p.required("jwt.audience", c.JWT.Audience)
+	p.positive("jwt.token_ttl", c.JWT.TokenTTL)
+	// A key_rotation of 0 disables rotation.
+	if c.JWT.KeyRotation != 0 {
+		p.check(c.JWT.KeyRotation > c.JWT.TokenTTL, "jwt.key_rotation: must be 0 or longer than jwt.token_ttl")
+	}
+
+	p.positive("login.failure_window", c.Login.FailureWindow)
+	p.notNegative("login.delay_after", c.Login.DelayAfter)
+	p.notNegative("login.ip_delay_after", c.Login.IPDelayAfter)
+	p.check(c.Login.BaseDelay >= 0, "login.base_delay: must not be negative")
+	p.check(c.Login.MaxDelay >= c.Login.BaseDelay, "login.max_delay: must not be shorter than login.base_delay")
+	p.notNegative("login.lockout_after", c.Login.LockoutAfter)
+	if c.Login.LockoutAfter > 0 {
+		p.positive("login.lockout_duration", c.Login.LockoutDuration)
+	}
+
+	p.required("totp.issuer", c.TOTP.Issuer)
+
+	p.oneOf("tracing.exporter", c.Tracing.Exporter, tracing.EXPORTER_NONE, tracing.EXPORTER_OTLP, tracing.EXPORTER_STDOUT)
+	if c.Tracing.Exporter == tracing.EXPORTER_OTLP {
+		p.required("tracing.endpoint", c.Tracing.Endpoint)
+	}
+
+	for name, provider := range c.OIDC {
+		prefix := "oidc." + name + "."
+		issuer, err := url.Parse(provider.Issuer)
+		p.check(err == nil && (issuer.Scheme == "https" || issuer.Scheme == "http") && issuer.Host != "",
+			"%sissuer: %q is not an http or https URL", prefix, provider.Issuer)
+		p.required(prefix+"client_id", provider.ClientID)
+		p.required(prefix+"redirect_url", provider.RedirectURL)
+		p.check(len(provider.Scopes) > 0, "%sscopes: is required", prefix)
+	}
+
+	// Problems of the maps above come in no order.
+	sort.Strings(p)
+	return p
+}
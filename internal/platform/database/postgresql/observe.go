@@ -0,0 +1,31 @@
+package postgresql
+
+import (
+	"context"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"social_network_project/internal/platform/metrics"
+	"social_network_project/internal/platform/tracing"
+)
+
+// Observe starts the span of a query of repository and times it, until the
+// function it returns is called:
+//
+//	ctx, end := postgresql.Observe(ctx, "post", "InsertPost")
+//	defer end()
+//
+// The statements of the query must be run with the context returned.
+func Observe(ctx context.Context, repository, query string) (context.Context, func()) {
+	ctx, span := tracing.Tracer.Start(ctx, repository+"."+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(query),
+		))
+	observe := metrics.ObserveQuery(repository, query)
+
+	return ctx, func() {
+		observe()
+		span.End()
+	}
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	STATUS_UP       = "up"
+	STATUS_DOWN     = "down"
+	STATUS_DRAINING = "draining"
+)
+
+// Check is a dependency the API needs to serve requests. Stats, when set,
+// describes it for operators, such as the state of its connection pool.
+type Check struct {
+	Name  string
+	Ping  func(ctx context.Context) error
+	Stats func() interface{}
+}
+
+// Result is the outcome of a Check.
+type Result struct {
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	LatencyMS float64     `json:"latency_ms"`
+	Error     string      `json:"error,omitempty"`
+	Stats     interface{} `json:"stats,omitempty"`
+}
+
+// Report is the state of the API and of each of its dependencies.
+type Report struct {
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+	Checks    []Result  `json:"checks"`
+}
+
+// Health tells whether the API can serve requests, pinging each dependency
+// for at most timeout. The results are kept for cacheTTL, so that frequent
+// probes do not ping the dependencies on every request. It stops being ready
+// once draining, so that no new traffic is sent to an instance shutting down.
+type Health struct {
+	checks    []Check
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	startedAt time.Time
+	now       func() time.Time
+	draining  int32
+
+	mu        sync.Mutex
+	results   []Result
+	checkedAt time.Time
+}
+
+func NewHealth(timeout, cacheTTL time.Duration, now func() time.Time, checks ...Check) *Health {
+	return &Health{
+		checks:    checks,
+		timeout:   timeout,
+		cacheTTL:  cacheTTL,
+		startedAt: now(),
+		now:       now,
+	}
+}
+
+// Drain makes the API report not ready from now on.
+func (h *Health) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+func (h *Health) Draining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// Ready pings every dependency at once and reports whether they are all up
+// and the API is not draining.
+func (h *Health) Ready(ctx context.Context) (bool, Report) {
+	report := h.Status(ctx)
+	return report.Status == STATUS_UP, report
+}
+
+// Status pings every dependency at once, unless they were pinged less than
+// cacheTTL ago, and reports how each one is doing.
+func (h *Health) Status(ctx context.Context) Report {
+	results := h.check(ctx)
+
+	status := STATUS_UP
+	for _, result := range results {
+		if result.Status != STATUS_UP {
+			status = STATUS_DOWN
+		}
+	}
+	if h.Draining() {
+		status = STATUS_DRAINING
+	}
+
+	now := h.now()
+	return Report{
+		Status:    status,
+		StartedAt: h.startedAt.UTC(),
+		Uptime:    now.Sub(h.startedAt).Round(time.Second).String(),
+		Checks:    results,
+	}
+}
+
+// check returns the results of the last run while they are fresh, or runs every
+// check. Concurrent callers wait for a single run, which a caller going away
+// does not cut short.
+func (h *Health) check(ctx context.Context) []Result {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.results != nil && h.now().Sub(h.checkedAt) < h.cacheTTL {
+		return h.results
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	results := make([]Result, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	h.results = results
+	h.checkedAt = h.now()
+	return results
+}
+
+func (h *Health) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ping(ctx, check.Ping)
+	latency := time.Since(start)
+
+	result := Result{
+		Name:      check.Name,
+		Status:    STATUS_UP,
+		LatencyMS: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = STATUS_DOWN
+		result.Error = err.Error()
+	}
+	if check.Stats != nil {
+		result.Stats = check.Stats()
+	}
+	return result
+}
+
+// ping gives up on pings ignoring ctx once it is done.
+func ping(ctx context.Context, p func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- p(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
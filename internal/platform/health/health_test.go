@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	up := Check{
+		Name: "postgres",
+		Ping: func(ctx context.Context) error { return nil },
+		Stats: func() interface{} {
+			return map[string]int{"open_connections": 2}
+		},
+	}
+	down := Check{
+		Name: "redis",
+		Ping: func(ctx context.Context) error { return errors.New("connection refused") },
+	}
+	hanging := Check{
+		Name: "rabbitmq",
+		Ping: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	}
+
+	t.Run("ready when every dependency is up", func(t *testing.T) {
+		health := NewHealth(time.Second, 0, clock, up)
+		now = now.Add(90 * time.Second)
+
+		ready, report := health.Ready(context.Background())
+		assert.True(t, ready)
+		assert.Equal(t, STATUS_UP, report.Status)
+		assert.Equal(t, "1m30s", report.Uptime)
+		assert.Equal(t, "postgres", report.Checks[0].Name)
+		assert.Equal(t, STATUS_UP, report.Checks[0].Status)
+		assert.Equal(t, map[string]int{"open_connections": 2}, report.Checks[0].Stats)
+	})
+
+	t.Run("not ready when a dependency is down or too slow", func(t *testing.T) {
+		health := NewHealth(20*time.Millisecond, 0, clock, up, down, hanging)
+
+		start := time.Now()
+		ready, report := health.Ready(context.Background())
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+		assert.False(t, ready)
+		assert.Equal(t, STATUS_DOWN, report.Status)
+		assert.Equal(t, []string{STATUS_UP, STATUS_DOWN, STATUS_DOWN},
+			[]string{report.Checks[0].Status, report.Checks[1].Status, report.Checks[2].Status})
+		assert.Equal(t, "connection refused", report.Checks[1].Error)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[2].Error)
+	})
+
+	t.Run("not ready once draining", func(t *testing.T) {
+		health := NewHealth(time.Second, 0, clock, up)
+		health.Drain()
+
+		ready, report := health.Ready(context.Background())
+		assert.False(t, ready)
+		assert.Equal(t, STATUS_DRAINING, report.Status)
+		assert.Equal(t, STATUS_UP, report.Checks[0].Status)
+	})
+
+	t.Run("answers from the last run for the cache ttl", func(t *testing.T) {
+		pings := 0
+		counted := Check{
+			Name: "postgres",
+			Ping: func(ctx context.Context) error {
+				pings++
+				return nil
+			},
+		}
+		health := NewHealth(time.Second, 5*time.Second, clock, counted)
+
+		health.Status(context.Background())
+		now = now.Add(4 * time.Second)
+		health.Status(context.Background())
+		assert.Equal(t, 1, pings)
+
+		now = now.Add(time.Second)
+		health.Status(context.Background())
+		assert.Equal(t, 2, pings)
+	})
+
+	t.Run("a caller going away does not fail the checks", func(t *testing.T) {
+		health := NewHealth(time.Second, 5*time.Second, clock, up)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ready, _ := health.Ready(ctx)
+		assert.True(t, ready)
+	})
+}
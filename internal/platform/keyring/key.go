@@ -0,0 +1,173 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ALGORITHM_RS256 = "RS256"
+	ALGORITHM_EDDSA = "EdDSA"
+)
+
+const rsaBits = 2048
+
+// kidTime starts the kid of the keys the keyring generates, so that when a
+// key was generated survives copying its file.
+const kidTime = "20060102T150405Z"
+
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private is nil for keys that only verify.
+	Private   crypto.Signer
+	Public    crypto.PublicKey
+	CreatedAt time.Time
+}
+
+func loadKeys(dir string) (map[string]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*Key, len(paths))
+	for _, path := range paths {
+		key, err := loadKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys[key.ID] = key
+	}
+
+	return keys, nil
+}
+
+func loadKey(path string) (*Key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("keyring: %s is not a PEM file", path)
+	}
+
+	id := strings.TrimSuffix(filepath.Base(path), ".pem")
+	key := &Key{
+		ID:        id,
+		CreatedAt: createdAt(id, info),
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: %s: %w", path, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("keyring: %s: unsupported key", path)
+		}
+		key.Private = signer
+		key.Public = signer.Public()
+	case "RSA PRIVATE KEY":
+		parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: %s: %w", path, err)
+		}
+		key.Private = parsed
+		key.Public = parsed.Public()
+	case "PUBLIC KEY":
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("keyring: %s: %w", path, err)
+		}
+		key.Public = parsed
+	default:
+		return nil, fmt.Errorf("keyring: %s: unsupported PEM block %s", path, block.Type)
+	}
+
+	switch key.Public.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("keyring: %s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	return key, nil
+}
+
+// createdAt is when the key of kid was generated. Keys named otherwise, added
+// by hand, count from when their file was last modified.
+func createdAt(kid string, info os.FileInfo) time.Time {
+	stamp, _, _ := strings.Cut(kid, "-")
+	created, err := time.Parse(kidTime, stamp)
+	if err != nil {
+		return info.ModTime()
+	}
+	return created
+}
+
+// generate writes a new private key of the configured algorithm to the
+// directory and reloads it.
+func (k *Keyring) generate() error {
+	var private crypto.Signer
+	var err error
+	switch k.config.Algorithm {
+	case ALGORITHM_RS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaBits)
+	case ALGORITHM_EDDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("keyring: unsupported algorithm %q", k.config.Algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+	kid := k.now().UTC().Format(kidTime) + "-" + hex.EncodeToString(suffix)
+
+	file, err := os.OpenFile(filepath.Join(k.config.Dir, kid+".pem"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	err = pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return k.Reload()
+}
@@ -0,0 +1,238 @@
+package keyring
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reloadAfter limits how often a token signed with an unknown key makes the
+// keyring read its directory again, looking for a key added by another
+// instance of the API.
+const reloadAfter = 10 * time.Second
+
+// publishAhead is how long a new key is published in the JWKS, and found by
+// the other instances, before it starts signing.
+const publishAhead = 10 * time.Minute
+
+type Config struct {
+	// Dir holds one PEM file per key, named after its kid. Private keys sign
+	// and verify, public keys only verify.
+	Dir string
+	// Algorithm of the keys the keyring generates: RS256 or EdDSA.
+	Algorithm string
+	Issuer    string
+	Audience  string
+	// TokenTTL is the lifetime of session tokens. Retired keys are kept that
+	// long so the tokens they signed stay valid.
+	TokenTTL time.Duration
+	// RotateEvery is the age at which a new signing key is generated. Zero
+	// disables rotation.
+	RotateEvery time.Duration
+	// Logger reports the failures of RotateKeys. Nil logs to slog.Default.
+	Logger *slog.Logger
+}
+
+// Keyring signs tokens with its newest published private key and verifies
+// them with any key it holds, chosen by the kid header.
+type Keyring struct {
+	config Config
+	now    func() time.Time
+
+	mu         sync.RWMutex
+	keys       map[string]*Key
+	signing    *Key
+	reloadedAt time.Time
+}
+
+// New loads the keys in config.Dir, generating a first one when there is no
+// private key to sign with.
+func New(config Config) (*Keyring, error) {
+	err := os.MkdirAll(config.Dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	k := &Keyring{
+		config: config,
+		now:    time.Now,
+	}
+	err = k.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	if k.signingKey() == nil {
+		err = k.generate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) TokenTTL() time.Duration {
+	return k.config.TokenTTL
+}
+
+// IssueToken signs a token for subject with the standard claims, valid for
+// ttl. Claims are added to them.
+func (k *Keyring) IssueToken(subject string, ttl time.Duration, claims jwt.MapClaims) (string, error) {
+	key := k.signingKey()
+	if key == nil {
+		return "", fmt.Errorf("keyring: no key to sign with in %s", k.config.Dir)
+	}
+
+	now := time.Now()
+	tokenClaims := jwt.MapClaims{
+		"iss": k.config.Issuer,
+		"aud": k.config.Audience,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"jti": uuid.New().String(),
+	}
+	for name, value := range claims {
+		tokenClaims[name] = value
+	}
+
+	token := jwt.NewWithClaims(key.Method, tokenClaims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Parse verifies the signature of tokenString and its standard claims: the
+// issuer and audience of this keyring, a subject, an id and the times it was
+// issued at and expires at.
+func (k *Keyring) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, k.keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyIssuer(k.config.Issuer, true) {
+		return nil, fmt.Errorf("keyring: invalid issuer")
+	}
+	if !claims.VerifyAudience(k.config.Audience, true) {
+		return nil, fmt.Errorf("keyring: invalid audience")
+	}
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, true) {
+		return nil, fmt.Errorf("keyring: token expired or issued in the future")
+	}
+	if sub, ok := claims["sub"].(string); !ok || sub == "" {
+		return nil, fmt.Errorf("keyring: missing subject")
+	}
+	if jti, ok := claims["jti"].(string); !ok || jti == "" {
+		return nil, fmt.Errorf("keyring: missing token id")
+	}
+
+	return claims, nil
+}
+
+// DecodeTokenAndReturnID returns the account a session token, sent with or
+// without its Bearer prefix, was issued to.
+func (k *Keyring) DecodeTokenAndReturnID(token string) (string, error) {
+	accountID, _, err := k.DecodeSessionToken(token)
+	return accountID, err
+}
+
+// DecodeSessionToken returns the account a session token was issued to and
+// the session it names in its sid claim.
+func (k *Keyring) DecodeSessionToken(token string) (accountID string, sessionID string, err error) {
+	claims, err := k.Parse(strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return "", "", err
+	}
+	// Tokens issued for a single purpose, like the two-factor challenge, do
+	// not give access to the API.
+	if _, found := claims["purpose"]; found {
+		return "", "", fmt.Errorf("token issued for %v", claims["purpose"])
+	}
+
+	sessionID, _ = claims["sid"].(string)
+	return claims["sub"].(string), sessionID, nil
+}
+
+func (k *Keyring) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key := k.find(kid)
+	if key == nil && k.reloadable() {
+		err := k.Reload()
+		if err != nil {
+			return nil, err
+		}
+		key = k.find(kid)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("keyring: unknown key %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("keyring: key %q does not sign with %s", kid, token.Method.Alg())
+	}
+
+	return key.Public, nil
+}
+
+func (k *Keyring) find(kid string) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+func (k *Keyring) signingKey() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.signing
+}
+
+func (k *Keyring) reloadable() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.now().Sub(k.reloadedAt) >= reloadAfter
+}
+
+// Reload reads the keys in the directory again. The newest private key
+// published for publishAhead becomes the signing key, or the newest one when
+// none is that old yet.
+func (k *Keyring) Reload() error {
+	keys, err := loadKeys(k.config.Dir)
+	if err != nil {
+		return err
+	}
+
+	published := k.now().Add(-publishAhead)
+	var signing, newest *Key
+	for _, key := range keys {
+		if key.Private == nil {
+			continue
+		}
+		if newest == nil || key.CreatedAt.After(newest.CreatedAt) {
+			newest = key
+		}
+		if !key.CreatedAt.After(published) && (signing == nil || key.CreatedAt.After(signing.CreatedAt)) {
+			signing = key
+		}
+	}
+	if signing == nil {
+		signing = newest
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.signing = signing
+	k.reloadedAt = k.now()
+	return nil
+}
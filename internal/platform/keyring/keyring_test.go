@@ -0,0 +1,191 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newKeyring(t *testing.T, algorithm string) *Keyring {
+	keys, err := New(Config{
+		Dir:         t.TempDir(),
+		Algorithm:   algorithm,
+		Issuer:      "social_network",
+		Audience:    "social_network_api",
+		TokenTTL:    time.Hour,
+		RotateEvery: 24 * time.Hour,
+	})
+	assert.Nil(t, err)
+	return keys
+}
+
+// age moves the clock of the keyring forward by d, as if every key was
+// generated that long ago.
+func age(t *testing.T, keys *Keyring, d time.Duration) {
+	now := keys.now()
+	keys.now = func() time.Time {
+		return now.Add(d)
+	}
+	assert.Nil(t, keys.Reload())
+}
+
+func TestKeyring(t *testing.T) {
+
+	t.Run("signs and verifies with RS256 and EdDSA", func(t *testing.T) {
+		for _, algorithm := range []string{ALGORITHM_RS256, ALGORITHM_EDDSA} {
+			keys := newKeyring(t, algorithm)
+
+			token, err := keys.IssueToken("a1", time.Hour, nil)
+			assert.Nil(t, err)
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+			assert.Nil(t, err)
+			assert.Equal(t, algorithm, parsed.Method.Alg())
+			assert.Equal(t, keys.signingKey().ID, parsed.Header["kid"])
+
+			claims, err := keys.Parse(token)
+			assert.Nil(t, err)
+			assert.Equal(t, "a1", claims["sub"])
+			assert.Equal(t, "social_network", claims["iss"])
+			assert.NotEmpty(t, claims["jti"])
+
+			id, err := keys.DecodeTokenAndReturnID("Bearer " + token)
+			assert.Nil(t, err)
+			assert.Equal(t, "a1", id)
+		}
+	})
+
+	t.Run("rejects tokens of another audience, expired or for a purpose", func(t *testing.T) {
+		keys := newKeyring(t, ALGORITHM_EDDSA)
+
+		config := keys.config
+		config.Audience = "another_api"
+		other, err := New(config)
+		assert.Nil(t, err)
+		token, err := other.IssueToken("a1", time.Hour, nil)
+		assert.Nil(t, err)
+		_, err = keys.Parse(token)
+		assert.NotNil(t, err)
+
+		token, err = keys.IssueToken("a1", -time.Minute, nil)
+		assert.Nil(t, err)
+		_, err = keys.Parse(token)
+		assert.NotNil(t, err)
+
+		token, err = keys.IssueToken("a1", time.Hour, jwt.MapClaims{"purpose": "2fa"})
+		assert.Nil(t, err)
+		_, err = keys.Parse(token)
+		assert.Nil(t, err)
+		_, err = keys.DecodeTokenAndReturnID(token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("rejects tokens signed by unknown keys", func(t *testing.T) {
+		keys := newKeyring(t, ALGORITHM_EDDSA)
+		stranger := newKeyring(t, ALGORITHM_EDDSA)
+
+		token, err := stranger.IssueToken("a1", time.Hour, nil)
+		assert.Nil(t, err)
+		_, err = keys.Parse(token)
+		assert.NotNil(t, err)
+
+		hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "a1"})
+		hmac.Header["kid"] = keys.signingKey().ID
+		token, err = hmac.SignedString([]byte("secret"))
+		assert.Nil(t, err)
+		_, err = keys.Parse(token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("verifies with public keys it cannot sign with", func(t *testing.T) {
+		keys := newKeyring(t, ALGORITHM_RS256)
+
+		public, private, err := ed25519.GenerateKey(rand.Reader)
+		assert.Nil(t, err)
+		der, err := x509.MarshalPKIXPublicKey(public)
+		assert.Nil(t, err)
+		err = os.WriteFile(filepath.Join(keys.config.Dir, "external.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+		assert.Nil(t, err)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{
+			"iss": "social_network",
+			"aud": "social_network_api",
+			"sub": "a1",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+			"jti": "j1",
+		})
+		token.Header["kid"] = "external"
+		tokenString, err := token.SignedString(private)
+		assert.Nil(t, err)
+
+		keys.reloadedAt = time.Time{}
+		claims, err := keys.Parse(tokenString)
+		assert.Nil(t, err)
+		assert.Equal(t, "a1", claims["sub"])
+		assert.NotEqual(t, "external", keys.signingKey().ID)
+		assert.Len(t, keys.JWKS().Keys, 2)
+	})
+
+	t.Run("reads when a key was generated from its kid", func(t *testing.T) {
+		keys := newKeyring(t, ALGORITHM_EDDSA)
+		first := keys.signingKey()
+
+		// Copying the keys to another instance changes when their files
+		// were modified, not when the keys were generated.
+		path := filepath.Join(keys.config.Dir, first.ID+".pem")
+		old := time.Now().Add(-48 * time.Hour)
+		assert.Nil(t, os.Chtimes(path, old, old))
+		assert.Nil(t, keys.Rotate())
+
+		assert.Equal(t, first.CreatedAt, keys.signingKey().CreatedAt)
+		assert.Len(t, keys.JWKS().Keys, 1)
+	})
+
+	t.Run("rotates the signing key and retires the old one", func(t *testing.T) {
+		keys := newKeyring(t, ALGORITHM_EDDSA)
+		first := keys.signingKey().ID
+		oldToken, err := keys.IssueToken("a1", time.Hour, nil)
+		assert.Nil(t, err)
+
+		assert.Nil(t, keys.Rotate())
+		assert.Equal(t, first, keys.signingKey().ID)
+
+		age(t, keys, 24*time.Hour)
+		assert.Nil(t, keys.Rotate())
+		assert.Equal(t, first, keys.signingKey().ID)
+		assert.Len(t, keys.JWKS().Keys, 2)
+
+		age(t, keys, publishAhead)
+		second := keys.signingKey().ID
+		assert.NotEqual(t, first, second)
+
+		_, err = keys.Parse(oldToken)
+		assert.Nil(t, err)
+
+		age(t, keys, time.Hour+checkEvery)
+		assert.Nil(t, keys.Rotate())
+		assert.Equal(t, second, keys.signingKey().ID)
+		assert.Len(t, keys.JWKS().Keys, 1)
+		assert.Nil(t, keys.find(first))
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	keys := newKeyring(t, ALGORITHM_RS256)
+
+	set := keys.JWKS()
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, "RSA", set.Keys[0].Kty)
+	assert.Equal(t, "RS256", set.Keys[0].Alg)
+	assert.Equal(t, "sig", set.Keys[0].Use)
+	assert.Equal(t, "AQAB", set.Keys[0].E)
+	assert.NotEmpty(t, set.Keys[0].N)
+}
@@ -0,0 +1,94 @@
+package keyring
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkEvery is how often RotateKeys looks at the age of the signing key. It
+// is also how late another instance can notice a new key, so retired keys are
+// kept that much longer.
+const checkEvery = time.Minute
+
+// RotateKeys reloads the keys every checkEvery until ctx is done,
+// rotating them when rotation is enabled, so keys added to the directory are
+// picked up without a restart.
+func (k *Keyring) RotateKeys(ctx context.Context) {
+	for {
+		var err error
+		if k.config.RotateEvery > 0 {
+			err = k.Rotate()
+		} else {
+			err = k.Reload()
+		}
+		if err != nil {
+			k.config.Logger.Error("reloading keys", "dir", k.config.Dir, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(checkEvery):
+		}
+	}
+}
+
+// Rotate generates the next key once the newest one is RotateEvery old, and
+// deletes the private keys older than the signing key once every token they
+// signed has expired. Keys that only verify are never deleted.
+func (k *Keyring) Rotate() error {
+	err := k.Reload()
+	if err != nil {
+		return err
+	}
+
+	now := k.now()
+	newest := k.newestKey()
+	if newest == nil || now.Sub(newest.CreatedAt) >= k.config.RotateEvery {
+		err = k.generate()
+		if err != nil {
+			return err
+		}
+	}
+
+	signing := k.signingKey()
+	if now.Sub(signing.CreatedAt) < publishAhead+k.config.TokenTTL+checkEvery {
+		return nil
+	}
+
+	k.mu.RLock()
+	var retired []string
+	for _, key := range k.keys {
+		if key.Private != nil && key.CreatedAt.Before(signing.CreatedAt) {
+			retired = append(retired, key.ID)
+		}
+	}
+	k.mu.RUnlock()
+	if len(retired) == 0 {
+		return nil
+	}
+
+	for _, kid := range retired {
+		err = os.Remove(filepath.Join(k.config.Dir, kid+".pem"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return k.Reload()
+}
+
+func (k *Keyring) newestKey() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var newest *Key
+	for _, key := range k.keys {
+		if key.Private != nil && (newest == nil || key.CreatedAt.After(newest.CreatedAt)) {
+			newest = key
+		}
+	}
+	return newest
+}
@@ -0,0 +1,108 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Backoff is how long Retry waits between attempts: Initial after the first
+// failure, doubling after each one up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Retry calls connect until it succeeds, waiting between attempts as backoff
+// says, so that the API can start before the services it depends on. It gives
+// up with the last error of connect once ctx is done. Attempts are logged to
+// logger.
+func Retry(ctx context.Context, logger *slog.Logger, name string, backoff Backoff, connect func() error) error {
+	wait := backoff.Initial
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			logger.Info("connected", "name", name, "attempt", attempt)
+			return nil
+		}
+
+		logger.Warn("connecting failed", "name", name, "attempt", attempt, "retry_in", wait, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("connecting to %s: %w", name, err)
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+		if wait > backoff.Max {
+			wait = backoff.Max
+		}
+	}
+}
+
+// Workers runs the background loops of the API, such as consumers and
+// pollers, and waits for them to stop.
+type Workers struct {
+	wg sync.WaitGroup
+}
+
+// Go runs run in its own goroutine. It must return soon after ctx is done.
+func (w *Workers) Go(ctx context.Context, run func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		run(ctx)
+	}()
+}
+
+// Wait waits for every worker to return, or for ctx to be done. It reports
+// whether they all returned.
+func (w *Workers) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Closers closes connections in the reverse of the order they were opened,
+// so that nothing is closed before what still uses it.
+type Closers struct {
+	// Logger reports the connections closed. Nil logs to slog.Default.
+	Logger *slog.Logger
+
+	names   []string
+	closers []func() error
+}
+
+func (c *Closers) Add(name string, close func() error) {
+	c.names = append(c.names, name)
+	c.closers = append(c.closers, close)
+}
+
+// Close closes every connection added, logging those failing to close.
+func (c *Closers) Close() {
+	logger := c.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		err := c.closers[i]()
+		if err != nil {
+			logger.Error("closing failed", "name", c.names[i], "error", err)
+			continue
+		}
+		logger.Info("closed", "name", c.names[i])
+	}
+	c.names, c.closers = nil, nil
+}
@@ -0,0 +1,83 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/platform/logging"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	backoff := Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond}
+
+	t.Run("retries until connected", func(t *testing.T) {
+		attempts := 0
+		err := Retry(context.Background(), logging.Discard(), "postgres", backoff, func() error {
+			attempts++
+			if attempts < 4 {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, 4, attempts)
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		refused := errors.New("connection refused")
+		err := Retry(ctx, logging.Discard(), "redis", backoff, func() error {
+			return refused
+		})
+
+		assert.ErrorIs(t, err, refused)
+		assert.Equal(t, "connecting to redis: connection refused", err.Error())
+	})
+}
+
+func TestWorkers(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+
+	var workers Workers
+	stopped := make(chan string, 2)
+	for _, name := range []string{"consumer", "relay"} {
+		name := name
+		workers.Go(ctx, func(ctx context.Context) {
+			<-ctx.Done()
+			stopped <- name
+		})
+	}
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.False(t, workers.Wait(waitCtx))
+
+	stop()
+	assert.True(t, workers.Wait(context.Background()))
+	assert.ElementsMatch(t, []string{"consumer", "relay"}, []string{<-stopped, <-stopped})
+}
+
+func TestClosers(t *testing.T) {
+	var closed []string
+	closers := Closers{Logger: logging.Discard()}
+	for _, name := range []string{"postgres", "redis", "rabbitmq"} {
+		name := name
+		closers.Add(name, func() error {
+			closed = append(closed, name)
+			if name == "redis" {
+				return errors.New("already closed")
+			}
+			return nil
+		})
+	}
+
+	closers.Close()
+	closers.Close()
+
+	assert.Equal(t, []string{"rabbitmq", "redis", "postgres"}, closed)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_TEXT = "text"
+)
+
+// RequestIDHeader carries the id of a request in HTTP requests, responses and
+// the messages published while handling it.
+const RequestIDHeader = "X-Request-ID"
+
+type Config struct {
+	Level  string
+	Format string
+}
+
+// New returns a logger writing to w records of config.Level and above, each
+// with the request id and the trace of the context it is logged with.
+func New(w io.Writer, config Config) (*slog.Logger, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(config.Level))
+	if err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case FORMAT_JSON:
+		handler = slog.NewJSONHandler(w, options)
+	case FORMAT_TEXT:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", config.Format)
+	}
+
+	return slog.New(&contextHandler{handler}), nil
+}
+
+// Discard returns a logger that writes nothing, for components built without
+// one such as in tests.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the id of the request it belongs to. An
+// empty id leaves ctx as it is.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the id of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds to every record the request id and the trace of its
+// context, so that the lines of a request can be found together.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", span.TraceID().String()),
+			slog.String("span_id", span.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("adds the request id and the trace of the context", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Config{Level: "info", Format: FORMAT_JSON})
+		assert.Nil(t, err)
+
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(WithRequestID(context.Background(), "req-1"), "span")
+		defer span.End()
+		logger.With("queue", "notification").InfoContext(ctx, "message received")
+
+		var record map[string]interface{}
+		assert.Nil(t, json.Unmarshal(out.Bytes(), &record))
+		assert.Equal(t, "message received", record["msg"])
+		assert.Equal(t, "notification", record["queue"])
+		assert.Equal(t, "req-1", record["request_id"])
+		assert.Equal(t, span.SpanContext().TraceID().String(), record["trace_id"])
+		assert.Equal(t, span.SpanContext().SpanID().String(), record["span_id"])
+	})
+	t.Run("leaves out what the context does not carry", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Config{Level: "info", Format: FORMAT_TEXT})
+		assert.Nil(t, err)
+
+		logger.InfoContext(WithRequestID(context.Background(), ""), "listening")
+		assert.NotContains(t, out.String(), "request_id")
+		assert.NotContains(t, out.String(), "trace_id")
+	})
+	t.Run("drops records under the level", func(t *testing.T) {
+		var out bytes.Buffer
+		logger, err := New(&out, Config{Level: "WARN", Format: FORMAT_TEXT})
+		assert.Nil(t, err)
+
+		logger.Info("connected")
+		logger.Warn("connecting failed")
+		assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+		assert.Contains(t, out.String(), "connecting failed")
+	})
+	t.Run("unknown level or format", func(t *testing.T) {
+		_, err := New(&bytes.Buffer{}, Config{Level: "verbose", Format: FORMAT_JSON})
+		assert.NotNil(t, err)
+		_, err = New(&bytes.Buffer{}, Config{Level: "info", Format: "logfmt"})
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+)
+
+// Mailer sends an email to the owner of an account.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// New returns the mailer sending through the SMTP server of config, or, when
+// no host is set, one that only logs that an email was not sent.
+func New(config Config, logger *slog.Logger) Mailer {
+	if config.Host == "" {
+		return &logMailer{logger: logger}
+	}
+	return &smtpMailer{config: config}
+}
+
+type smtpMailer struct {
+	config Config
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string) error {
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	address := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	err := smtp.SendMail(address, auth, m.config.From, []string{to}, message(m.config.From, to, subject, body))
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	return nil
+}
+
+func message(from, to, subject, body string) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body + "\r\n")
+	return []byte(b.String())
+}
+
+// logMailer stands in when no SMTP server is configured. It never logs the
+// body, which can hold a code that must only reach the recipient.
+type logMailer struct {
+	logger *slog.Logger
+}
+
+func (m *logMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.logger.WarnContext(ctx, "email not sent, no SMTP server configured", "recipient", to, "subject", subject)
+	return nil
+}
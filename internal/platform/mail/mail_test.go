@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("without a host only logs the recipient and subject", func(t *testing.T) {
+		var out bytes.Buffer
+		mailer := New(Config{}, slog.New(slog.NewTextHandler(&out, nil)))
+
+		assert.Nil(t, mailer.Send(context.Background(), "ana@mail.com", "Your account was locked", "Unlock it with the code 5f2b1c"))
+		assert.Contains(t, out.String(), "ana@mail.com")
+		assert.NotContains(t, out.String(), "5f2b1c")
+	})
+	t.Run("with a host sends through it", func(t *testing.T) {
+		assert.IsType(t, &smtpMailer{}, New(Config{Host: "localhost", Port: 25}, slog.Default()))
+	})
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "From: no-reply@mail.com\r\nTo: ana@mail.com\r\nSubject: Locked\r\n"+
+		"Content-Type: text/plain; charset=utf-8\r\n\r\nUnlock it\r\n",
+		string(message("no-reply@mail.com", "ana@mail.com", "Locked", "Unlock it")))
+}
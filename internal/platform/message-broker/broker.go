@@ -0,0 +1,88 @@
+package messagebroker
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// MAX_ATTEMPTS is how many times a message is handled before the broker gives
+// up on it and moves it to the dead letter queue of its queue.
+const MAX_ATTEMPTS = 5
+
+// HEADER_ATTEMPTS is the header counting the failed attempts of a message.
+const HEADER_ATTEMPTS = "x-attempts"
+
+type Message struct {
+	Body        []byte
+	Headers     map[string]string
+	Redelivered bool
+}
+
+// Attempts returns how many times handling message failed before.
+func (m *Message) Attempts() int {
+	attempts, err := strconv.Atoi(m.Headers[HEADER_ATTEMPTS])
+	if err != nil {
+		return 0
+	}
+	return attempts
+}
+
+// Retry returns a copy of message to deliver again, with one more failed
+// attempt counted, and whether it is out of attempts and goes to the dead
+// letter queue instead.
+func Retry(message *Message) (*Message, bool) {
+	headers := map[string]string{}
+	for key, value := range message.Headers {
+		headers[key] = value
+	}
+	attempts := message.Attempts() + 1
+	headers[HEADER_ATTEMPTS] = strconv.Itoa(attempts)
+
+	return &Message{Body: message.Body, Headers: headers, Redelivered: true}, attempts >= MAX_ATTEMPTS
+}
+
+// RetryDelay is how long a message waits before it is delivered again after
+// its attempts-th failure: one second, doubling after each failure up to a
+// minute.
+func RetryDelay(attempts int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempts && delay < time.Minute; i++ {
+		delay *= 2
+	}
+	if delay > time.Minute {
+		delay = time.Minute
+	}
+	return delay
+}
+
+// DeadLetterQueue names the queue keeping the messages of queue that failed
+// MAX_ATTEMPTS times, for an operator to inspect and publish again.
+func DeadLetterQueue(queue string) string {
+	return queue + ".dead"
+}
+
+// Handler processes one message. Returning an error asks the broker to
+// deliver the message again later, up to MAX_ATTEMPTS times in all; after
+// that it is moved to the dead letter queue, so that no message is dropped.
+// The handler reports its errors, brokers do not log them.
+type Handler func(message *Message) error
+
+type Publisher interface {
+	Publish(queue string, message *Message) error
+}
+
+type Subscriber interface {
+	// Subscribe blocks, passing every message of queue to handler until ctx
+	// is done or the broker is closed. A message being handled is finished
+	// first; those not handled yet stay in the queue.
+	Subscribe(ctx context.Context, queue string, handler Handler) error
+}
+
+type Broker interface {
+	Publisher
+	Subscriber
+	// Ping checks the broker can be reached.
+	Ping(ctx context.Context) error
+	Close() error
+}
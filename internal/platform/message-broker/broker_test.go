@@ -0,0 +1,29 @@
+package messagebroker
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	message := &Message{Body: []byte("1"), Headers: map[string]string{"type": "post.created"}}
+
+	for attempts := 1; attempts < MAX_ATTEMPTS; attempts++ {
+		var dead bool
+		message, dead = Retry(message)
+		assert.False(t, dead)
+		assert.Equal(t, attempts, message.Attempts())
+	}
+	message, dead := Retry(message)
+	assert.True(t, dead)
+	assert.Equal(t, MAX_ATTEMPTS, message.Attempts())
+	assert.Equal(t, "post.created", message.Headers["type"])
+	assert.True(t, message.Redelivered)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Second, RetryDelay(1))
+	assert.Equal(t, 4*time.Second, RetryDelay(3))
+	assert.Equal(t, time.Minute, RetryDelay(20))
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"sync"
+)
+
+// Memory is an in-process broker for local runs and tests. Messages are kept
+// in memory only and are lost when the process exits. A failed message is
+// delivered again at once, without the delay of RetryDelay.
+type Memory struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queues map[string][]*messagebroker.Message
+	closed bool
+}
+
+func NewMemory() messagebroker.Broker {
+	m := &Memory{
+		queues: map[string][]*messagebroker.Message{},
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *Memory) Publish(queue string, message *messagebroker.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queues[queue] = append(m.queues[queue], &messagebroker.Message{
+		Body:    message.Body,
+		Headers: message.Headers,
+	})
+	m.cond.Broadcast()
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, queue string, handler messagebroker.Handler) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.mu.Lock()
+			m.cond.Broadcast()
+			m.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	for {
+		message, ok := m.next(ctx, queue)
+		if !ok {
+			return nil
+		}
+
+		err := handler(message)
+		if err != nil {
+			retry, dead := messagebroker.Retry(message)
+			if dead {
+				m.requeue(messagebroker.DeadLetterQueue(queue), retry)
+			} else {
+				m.requeue(queue, retry)
+			}
+		}
+	}
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return errors.New("broker closed")
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	m.cond.Broadcast()
+	return nil
+}
+
+func (m *Memory) next(ctx context.Context, queue string) (*messagebroker.Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for len(m.queues[queue]) == 0 && !m.closed && ctx.Err() == nil {
+		m.cond.Wait()
+	}
+	if m.closed || ctx.Err() != nil {
+		return nil, false
+	}
+
+	message := m.queues[queue][0]
+	m.queues[queue] = m.queues[queue][1:]
+	return message, true
+}
+
+func (m *Memory) requeue(queue string, message *messagebroker.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queues[queue] = append(m.queues[queue], message)
+	m.cond.Broadcast()
+}
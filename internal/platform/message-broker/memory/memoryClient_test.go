@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	t.Run("delivers published messages in order", func(t *testing.T) {
+		broker := NewMemory()
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("1")})
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("2")})
+
+		var received []string
+		err := broker.Subscribe(context.Background(), "queue", func(message *messagebroker.Message) error {
+			received = append(received, string(message.Body))
+			if len(received) == 2 {
+				broker.Close()
+			}
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, received)
+	})
+	t.Run("redelivers a failed message until it runs out of attempts", func(t *testing.T) {
+		broker := NewMemory()
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("1"), Headers: map[string]string{"type": "post.created"}})
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("2")})
+
+		var received []string
+		var attempts []int
+		err := broker.Subscribe(context.Background(), "queue", func(message *messagebroker.Message) error {
+			received = append(received, string(message.Body))
+			if string(message.Body) == "1" {
+				attempts = append(attempts, message.Attempts())
+				if message.Attempts() == messagebroker.MAX_ATTEMPTS-1 {
+					broker.Close()
+				}
+				return errors.New("failed")
+			}
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2", "1", "1", "1", "1"}, received)
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, attempts)
+	})
+	t.Run("moves a message out of attempts to the dead letter queue", func(t *testing.T) {
+		broker := NewMemory()
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("1"), Headers: map[string]string{"type": "post.created"}})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := broker.Subscribe(ctx, "queue", func(message *messagebroker.Message) error {
+			return errors.New("failed")
+		})
+		assert.Nil(t, err)
+
+		var dead []*messagebroker.Message
+		err = broker.Subscribe(context.Background(), messagebroker.DeadLetterQueue("queue"), func(message *messagebroker.Message) error {
+			dead = append(dead, message)
+			broker.Close()
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Len(t, dead, 1)
+		assert.Equal(t, "1", string(dead[0].Body))
+		assert.Equal(t, "post.created", dead[0].Headers["type"])
+		assert.Equal(t, messagebroker.MAX_ATTEMPTS, dead[0].Attempts())
+	})
+	t.Run("stops when the context is done", func(t *testing.T) {
+		broker := NewMemory()
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("1")})
+		broker.Publish("queue", &messagebroker.Message{Body: []byte("2")})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var received []string
+		err := broker.Subscribe(ctx, "queue", func(message *messagebroker.Message) error {
+			received = append(received, string(message.Body))
+			cancel()
+			return nil
+		})
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1"}, received)
+
+		err = broker.Subscribe(context.Background(), "queue", func(message *messagebroker.Message) error {
+			received = append(received, string(message.Body))
+			broker.Close()
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"1", "2"}, received)
+	})
+	t.Run("stops waiting when the context is done", func(t *testing.T) {
+		broker := NewMemory()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := broker.Subscribe(ctx, "queue", func(message *messagebroker.Message) error {
+			return nil
+		})
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"social_network_project/internal/platform/cache"
+)
+
+// RegisterCache exports the hits and misses of service, read from its stats
+// when scraped.
+func RegisterCache(service cache.RedisServiceClient) error {
+	counters := []struct {
+		name  string
+		help  string
+		value func(stats cache.Stats) uint64
+	}{
+		{"cache_hits_total", "Responses served from the cache.", func(s cache.Stats) uint64 { return s.Hits }},
+		{"cache_misses_total", "Responses not in the cache.", func(s cache.Stats) uint64 { return s.Misses }},
+		{"cache_coalesced_total", "Misses that waited for a load already running.", func(s cache.Stats) uint64 { return s.Coalesced }},
+		{"cache_negative_hits_total", "Lookups of missing ids served from the cache.", func(s cache.Stats) uint64 { return s.NegativeHits }},
+		{"cache_early_refreshes_total", "Entries refreshed before expiring.", func(s cache.Stats) uint64 { return s.EarlyRefreshes }},
+	}
+
+	for _, counter := range counters {
+		value := counter.value
+		err := Registry.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      counter.name,
+			Help:      counter.help,
+		}, func() float64 {
+			return float64(value(service.Stats()))
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "social_network"
+
+// Registry holds every metric of the API, along with those of the Go runtime
+// and of the process.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to answer HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time spent in each query of each repository.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "query"})
+
+	MessagesPublished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_published_total",
+		Help:      "Messages published to the broker by queue.",
+	}, []string{"queue"})
+
+	MessagesConsumed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_consumed_total",
+		Help:      "Messages handled from the broker by queue.",
+	}, []string{"queue"})
+
+	MessagesFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_failed_total",
+		Help:      "Messages whose handling failed by queue. They are delivered again once.",
+	}, []string{"queue"})
+
+	PostsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "posts_created_total",
+		Help:      "Posts created.",
+	})
+
+	CommentsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Comments created.",
+	})
+
+	FollowsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "follows_created_total",
+		Help:      "Follows created.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		QueryDuration,
+		MessagesPublished,
+		MessagesConsumed,
+		MessagesFailed,
+		PostsCreated,
+		CommentsCreated,
+		FollowsCreated,
+	)
+}
+
+// Handler serves the metrics of Registry in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveQuery times a query of repository until the function it returns is
+// called.
+func ObserveQuery(repository, query string) func() {
+	start := time.Now()
+	return func() {
+		QueryDuration.WithLabelValues(repository, query).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDB exports the connection pool stats of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
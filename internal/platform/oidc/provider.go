@@ -0,0 +1,295 @@
+package oidc
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refetchAfter limits how often an ID token signed with an unknown key makes
+// the provider fetch its JWKS again, looking for a key it rotated in.
+const refetchAfter = 10 * time.Second
+
+// signingMethods are the algorithms ID tokens may be signed with. Symmetric
+// algorithms and none are never accepted.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type Config struct {
+	// Name identifies the provider in the routes and the linked identities.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback route of the provider, registered with it.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Discovery is the part of the provider's discovery document the
+// authorization code flow needs.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token identifying its user.
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// Provider signs users in with an OpenID Connect provider through the
+// authorization code flow with PKCE. Its discovery document is fetched on
+// first use, so the API starts while the provider is unreachable.
+type Provider struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	discovery *Discovery
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL is where the user agent is sent to sign in, binding the sign in
+// to state, nonce and the PKCE challenge of verifier.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code, proving it with verifier, and
+// returns the ID token the provider issued with it.
+func (p *Provider) Exchange(code, verifier string) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("oidc: %s: token response: %w", p.config.Name, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: %s: token endpoint answered %d: %s %s", p.config.Name, res.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("oidc: %s: token response without id_token", p.config.Name)
+	}
+
+	return body.IDToken, nil
+}
+
+// VerifyIDToken verifies the signature of the ID token with the provider's
+// keys, that it was issued by the provider to this client, has not expired
+// and carries nonce.
+func (p *Provider) VerifyIDToken(rawIDToken, nonce string) (*Claims, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	parser := jwt.Parser{ValidMethods: signingMethods}
+	_, err = parser.ParseWithClaims(rawIDToken, claims, p.keyfunc)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %s: %w", p.config.Name, err)
+	}
+
+	now := p.now().Unix()
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, fmt.Errorf("oidc: %s: invalid issuer", p.config.Name)
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, fmt.Errorf("oidc: %s: invalid audience", p.config.Name)
+	}
+	// A token for several audiences must name this client as the party it was
+	// authorized for.
+	if azp, found := claims["azp"]; found && azp != p.config.ClientID {
+		return nil, fmt.Errorf("oidc: %s: invalid authorized party", p.config.Name)
+	}
+	if !claims.VerifyExpiresAt(now, true) || !claims.VerifyIssuedAt(now, true) {
+		return nil, fmt.Errorf("oidc: %s: token expired or issued in the future", p.config.Name)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); nonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("oidc: %s: invalid nonce", p.config.Name)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("oidc: %s: missing subject", p.config.Name)
+	}
+
+	idClaims := &Claims{Subject: subject}
+	idClaims.Email, _ = claims["email"].(string)
+	idClaims.Name, _ = claims["name"].(string)
+	idClaims.PreferredUsername, _ = claims["preferred_username"].(string)
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		idClaims.EmailVerified = verified
+	case string:
+		idClaims.EmailVerified = verified == "true"
+	}
+
+	return idClaims, nil
+}
+
+func (p *Provider) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, found := p.findKey(kid)
+	if !found && p.now().Sub(p.fetchedAt) >= refetchAfter {
+		err := p.fetchKeys()
+		if err != nil {
+			return nil, err
+		}
+		key, found = p.findKey(kid)
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	return key, nil
+}
+
+// findKey returns the key of kid, or the only key when the token names none.
+func (p *Provider) findKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, found := p.keys[kid]
+	return key, found
+}
+
+func (p *Provider) fetchKeys() error {
+	var set JWKSet
+	err := p.getJSON(p.discovery.JWKSURI, &set)
+	if err != nil {
+		return err
+	}
+
+	keys, err := set.PublicKeys()
+	if err != nil {
+		return fmt.Errorf("oidc: %s: %w", p.config.Name, err)
+	}
+	p.keys = keys
+	p.fetchedAt = p.now()
+	return nil
+}
+
+// discover fetches the discovery document once it is needed, and again after
+// a failure.
+func (p *Provider) discover() (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	err := p.getJSON(strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("oidc: %s: discovery document of issuer %q", p.config.Name, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: %s: incomplete discovery document", p.config.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+func (p *Provider) getJSON(endpoint string, v interface{}) error {
+	res, err := p.client.Get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: %s: %s answered %d", p.config.Name, endpoint, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,137 @@
+package oidc
+
+import (
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/url"
+	"social_network_project/internal/platform/oidc/oidctest"
+	"testing"
+	"time"
+)
+
+func newProvider(server *oidctest.Server) *Provider {
+	return NewProvider(Config{
+		Name:         "fake",
+		Issuer:       server.URL,
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/auth/oidc/fake/callback",
+	}, http.DefaultClient)
+}
+
+// signIn runs the authorization code flow up to the ID token.
+func signIn(t *testing.T, server *oidctest.Server, provider *Provider, nonce string) string {
+	verifier, err := NewVerifier()
+	assert.Nil(t, err)
+
+	authURL, err := provider.AuthCodeURL("state", nonce, verifier)
+	assert.Nil(t, err)
+	code, state := server.Authorize(t, authURL)
+	assert.Equal(t, "state", state)
+
+	idToken, err := provider.Exchange(code, verifier)
+	assert.Nil(t, err)
+	return idToken
+}
+
+func TestProvider(t *testing.T) {
+	server := oidctest.NewServer(t)
+	server.User = oidctest.User{Subject: "u1", Email: "ana@mail.com", EmailVerified: true, Name: "Ana", PreferredUsername: "ana"}
+
+	t.Run("signs in with the authorization code flow and PKCE", func(t *testing.T) {
+		provider := newProvider(server)
+
+		authURL, err := provider.AuthCodeURL("state", "nonce", "verifier")
+		assert.Nil(t, err)
+		parsed, err := url.Parse(authURL)
+		assert.Nil(t, err)
+		assert.Equal(t, server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+		assert.Equal(t, Challenge("verifier"), parsed.Query().Get("code_challenge"))
+		assert.Equal(t, "openid email profile", parsed.Query().Get("scope"))
+
+		claims, err := provider.VerifyIDToken(signIn(t, server, provider, "nonce"), "nonce")
+		assert.Nil(t, err)
+		assert.Equal(t, &Claims{Subject: "u1", Email: "ana@mail.com", EmailVerified: true, Name: "Ana", PreferredUsername: "ana"}, claims)
+	})
+
+	t.Run("rejects a code exchanged without its verifier", func(t *testing.T) {
+		provider := newProvider(server)
+
+		verifier, err := NewVerifier()
+		assert.Nil(t, err)
+		authURL, err := provider.AuthCodeURL("state", "nonce", verifier)
+		assert.Nil(t, err)
+		code, _ := server.Authorize(t, authURL)
+
+		_, err = provider.Exchange(code, "another-verifier")
+		assert.NotNil(t, err)
+		_, err = provider.Exchange(code, verifier)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("rejects ID tokens with another nonce", func(t *testing.T) {
+		provider := newProvider(server)
+
+		_, err := provider.VerifyIDToken(signIn(t, server, provider, "nonce"), "another-nonce")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("rejects ID tokens of another issuer, audience or expired", func(t *testing.T) {
+		provider := newProvider(server)
+		defer func() { server.Claims = nil }()
+
+		for _, change := range []func(claims jwt.MapClaims){
+			func(claims jwt.MapClaims) { claims["iss"] = "https://another.example.com" },
+			func(claims jwt.MapClaims) { claims["aud"] = "another_client" },
+			func(claims jwt.MapClaims) {
+				claims["aud"] = []string{oidctest.ClientID, "another_client"}
+				claims["azp"] = "another_client"
+			},
+			func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+			func(claims jwt.MapClaims) { delete(claims, "sub") },
+		} {
+			server.Claims = change
+			_, err := provider.VerifyIDToken(signIn(t, server, provider, "nonce"), "nonce")
+			assert.NotNil(t, err)
+		}
+	})
+
+	t.Run("rejects unsigned ID tokens", func(t *testing.T) {
+		provider := newProvider(server)
+		_, err := provider.discover()
+		assert.Nil(t, err)
+
+		unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+			"iss":   server.URL,
+			"aud":   oidctest.ClientID,
+			"sub":   "u1",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		})
+		idToken, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		assert.Nil(t, err)
+
+		_, err = provider.VerifyIDToken(idToken, "nonce")
+		assert.NotNil(t, err)
+	})
+
+	t.Run("fetches the keys again when the provider rotates them", func(t *testing.T) {
+		provider := newProvider(server)
+		_, err := provider.VerifyIDToken(signIn(t, server, provider, "nonce"), "nonce")
+		assert.Nil(t, err)
+
+		server.RotateKey(t)
+		provider.fetchedAt = time.Time{}
+		_, err = provider.VerifyIDToken(signIn(t, server, provider, "nonce"), "nonce")
+		assert.Nil(t, err)
+	})
+
+	t.Run("rejects a discovery document of another issuer", func(t *testing.T) {
+		provider := NewProvider(Config{Name: "fake", Issuer: server.URL + "/", ClientID: oidctest.ClientID}, http.DefaultClient)
+
+		_, err := provider.AuthCodeURL("state", "nonce", "verifier")
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limiter counts the requests made under a key and decides whether one more
+// is allowed by the policy.
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (*Result, error)
+}
+
+// Policy allows Limit requests per key in any sliding Window.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Window returns the start of the fixed window holding now and how far into it
+// now is.
+func Window(policy Policy, now time.Time) (start time.Time, elapsed time.Duration) {
+	start = now.Truncate(policy.Window)
+	return start, now.Sub(start)
+}
+
+// Weight is how much of the previous window still falls in the sliding
+// window ending elapsed into the current one.
+func Weight(policy Policy, elapsed time.Duration) float64 {
+	return 1 - float64(elapsed)/float64(policy.Window)
+}
+
+// Decide applies the sliding window counter: the requests of the previous
+// fixed window are weighted by how much of it the sliding window still
+// covers. current includes the request being decided when it was allowed.
+func Decide(policy Policy, elapsed time.Duration, previous, current int, allowed bool) *Result {
+	weight := Weight(policy, elapsed)
+	count := int(math.Floor(float64(previous)*weight)) + current
+	untilNextWindow := policy.Window - elapsed
+
+	result := &Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: policy.Limit - count,
+		Reset:     untilNextWindow,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if allowed {
+		return result
+	}
+
+	if current < policy.Limit && previous > 0 {
+		// Wait until enough of the previous window slides out.
+		needed := 1 - float64(policy.Limit-current)/float64(previous)
+		result.RetryAfter = time.Duration(needed*float64(policy.Window)) - elapsed
+	} else {
+		// Wait for the next window, then for enough of this one to slide out.
+		needed := 1 - float64(policy.Limit)/float64(current)
+		result.RetryAfter = untilNextWindow + time.Duration(needed*float64(policy.Window))
+	}
+	if result.RetryAfter < time.Second {
+		result.RetryAfter = time.Second
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	policy := Policy{Name: "auth", Limit: 10, Window: time.Minute}
+
+	t.Run("weights the previous window", func(t *testing.T) {
+		result := Decide(policy, 45*time.Second, 8, 3, true)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 5, result.Remaining)
+		assert.Equal(t, 15*time.Second, result.Reset)
+	})
+
+	t.Run("retry after the previous window slides out", func(t *testing.T) {
+		result := Decide(policy, 15*time.Second, 10, 4, false)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 9*time.Second, result.RetryAfter)
+	})
+
+	t.Run("retry after the next window when the current is full", func(t *testing.T) {
+		result := Decide(policy, 50*time.Second, 0, 20, false)
+		assert.Equal(t, 40*time.Second, result.RetryAfter)
+	})
+
+	t.Run("retry after at least a second", func(t *testing.T) {
+		result := Decide(policy, 59*time.Second+900*time.Millisecond, 0, 10, false)
+		assert.Equal(t, time.Second, result.RetryAfter)
+	})
+}
@@ -0,0 +1,79 @@
+package memoryLimiter
+
+import (
+	"context"
+	"social_network_project/internal/platform/ratelimit"
+	"sync"
+	"time"
+)
+
+// Memory keeps the counters in process. Each instance counts on its own, so it
+// is meant for local runs and tests.
+type Memory struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+type counter struct {
+	start    time.Time
+	window   time.Duration
+	previous int
+	current  int
+}
+
+func NewMemory(now func() time.Time) ratelimit.Limiter {
+	return &Memory{
+		now:       now,
+		counters:  map[string]*counter{},
+		lastSweep: now(),
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, policy ratelimit.Policy) (*ratelimit.Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	start, elapsed := ratelimit.Window(policy, now)
+	m.sweep(now, policy.Window)
+
+	c, found := m.counters[key]
+	if !found {
+		c = &counter{start: start, window: policy.Window}
+		m.counters[key] = c
+	}
+	switch {
+	case c.start.Equal(start):
+	case c.start.Add(policy.Window).Equal(start):
+		c.previous, c.current = c.current, 0
+		c.start = start
+	default:
+		c.previous, c.current = 0, 0
+		c.start = start
+	}
+
+	weight := ratelimit.Weight(policy, elapsed)
+	allowed := float64(c.previous)*weight+float64(c.current) < float64(policy.Limit)
+	if allowed {
+		c.current++
+	}
+
+	return ratelimit.Decide(policy, elapsed, c.previous, c.current, allowed), nil
+}
+
+// sweep drops the counters that no longer affect any decision, at most once
+// per window.
+func (m *Memory) sweep(now time.Time, window time.Duration) {
+	if now.Sub(m.lastSweep) < window {
+		return
+	}
+	m.lastSweep = now
+
+	for key, c := range m.counters {
+		if !now.Before(c.start.Add(2 * c.window)) {
+			delete(m.counters, key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package memoryLimiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/platform/ratelimit"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestMemory(t *testing.T) {
+	policy := ratelimit.Policy{Name: "write", Limit: 3, Window: time.Minute}
+
+	t.Run("limits each key", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)}
+		limiter := NewMemory(clock.Now)
+
+		for i := 2; i >= 0; i-- {
+			result, err := limiter.Allow(context.Background(), "a1", policy)
+			assert.Nil(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, i, result.Remaining)
+		}
+
+		result, err := limiter.Allow(context.Background(), "a1", policy)
+		assert.Nil(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, time.Minute, result.Reset)
+
+		result, err = limiter.Allow(context.Background(), "a2", policy)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("previous window slides out", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2022, 8, 1, 12, 0, 30, 0, time.UTC)}
+		limiter := NewMemory(clock.Now)
+		for i := 0; i < 3; i++ {
+			limiter.Allow(context.Background(), "a1", policy)
+		}
+
+		clock.now = time.Date(2022, 8, 1, 12, 1, 10, 0, time.UTC)
+		result, _ := limiter.Allow(context.Background(), "a1", policy)
+		assert.True(t, result.Allowed)
+		result, _ = limiter.Allow(context.Background(), "a1", policy)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 10*time.Second, result.RetryAfter)
+
+		clock.now = clock.now.Add(result.RetryAfter + time.Millisecond)
+		result, _ = limiter.Allow(context.Background(), "a1", policy)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("forgets idle keys", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)}
+		limiter := NewMemory(clock.Now).(*Memory)
+		limiter.Allow(context.Background(), "a1", policy)
+
+		clock.now = clock.now.Add(2 * time.Minute)
+		limiter.Allow(context.Background(), "a2", policy)
+
+		assert.NotContains(t, limiter.counters, "a1")
+		assert.Contains(t, limiter.counters, "a2")
+	})
+}
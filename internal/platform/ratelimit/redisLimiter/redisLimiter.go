@@ -0,0 +1,63 @@
+package redisLimiter
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"social_network_project/internal/platform/ratelimit"
+	"strconv"
+	"time"
+)
+
+// allowScript reads the counters of the current and previous windows and
+// counts the request only when the weighted sum is under the limit, so that
+// concurrent requests from every instance see a consistent count.
+var allowScript = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+local previous = tonumber(redis.call("GET", KEYS[2]) or "0")
+local weight = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+
+if previous * weight + current >= limit then
+	return {0, previous, current}
+end
+
+current = redis.call("INCR", KEYS[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return {1, previous, current}
+`)
+
+// Redis shares the counters between every instance of the API.
+type Redis struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func NewRedis(client *redis.Client, now func() time.Time) ratelimit.Limiter {
+	return &Redis{
+		client: client,
+		now:    now,
+	}
+}
+
+func (r *Redis) Allow(ctx context.Context, key string, policy ratelimit.Policy) (*ratelimit.Result, error) {
+	start, elapsed := ratelimit.Window(policy, r.now())
+	keys := []string{
+		windowKey(key, start),
+		windowKey(key, start.Add(-policy.Window)),
+	}
+
+	values, err := allowScript.Run(ctx, r.client, keys,
+		ratelimit.Weight(policy, elapsed),
+		policy.Limit,
+		(2 * policy.Window).Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return ratelimit.Decide(policy, elapsed, int(values[1]), int(values[2]), values[0] == 1), nil
+}
+
+func windowKey(key string, start time.Time) string {
+	return "ratelimit-" + key + "-" + strconv.FormatInt(start.Unix(), 10)
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXPORTER_NONE   = "none"
+	EXPORTER_OTLP   = "otlp"
+	EXPORTER_STDOUT = "stdout"
+)
+
+// ServiceName names the API in its spans unless OTEL_SERVICE_NAME is set.
+const ServiceName = "social_network_api"
+
+// Tracer starts the spans of the API. It follows the provider installed by
+// Setup, and records nothing before or without one.
+var Tracer = otel.Tracer("social_network_project")
+
+type Config struct {
+	Exporter string
+	Endpoint string
+	Insecure bool
+}
+
+// Setup installs the tracer provider exporting to config.Exporter and the W3C
+// trace context propagator. The function it returns flushes the spans not
+// exported yet and stops the provider.
+func Setup(ctx context.Context, config Config) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case EXPORTER_NONE:
+		return func(ctx context.Context) error { return nil }, nil
+	case EXPORTER_OTLP:
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case EXPORTER_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		err = fmt.Errorf("unknown exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	serviceResource, err := resource.Merge(
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Inject writes the trace context of ctx into headers, to be carried with a
+// message to its consumer.
+func Inject(ctx context.Context, headers map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(headers))
+}
+
+// Extract returns ctx carrying the trace context found in headers.
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// StartConsumer starts the span of handling a message of queue, as a child of
+// the span that published it.
+func StartConsumer(queue string, headers map[string]string) (context.Context, trace.Span) {
+	return Tracer.Start(Extract(context.Background(), headers), queue+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("rabbitmq"),
+			semconv.MessagingDestinationKey.String(queue),
+			semconv.MessagingOperationProcess,
+		))
+}
+
+// StartProducer starts the span of publishing a message to queue.
+func StartProducer(ctx context.Context, queue string) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, queue+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("rabbitmq"),
+			semconv.MessagingDestinationKey.String(queue),
+		))
+}
+
+// End ends span, marking it failed with err when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
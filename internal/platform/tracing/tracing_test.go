@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	t.Run("none exports nothing", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), Config{Exporter: EXPORTER_NONE})
+		assert.Nil(t, err)
+		assert.Nil(t, shutdown(context.Background()))
+	})
+	t.Run("unknown exporter", func(t *testing.T) {
+		_, err := Setup(context.Background(), Config{Exporter: "zipkin"})
+		assert.NotNil(t, err)
+	})
+}
+
+func TestPropagation(t *testing.T) {
+	_, err := Setup(context.Background(), Config{Exporter: EXPORTER_NONE})
+	assert.Nil(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	t.Run("the consumer continues the trace of the producer", func(t *testing.T) {
+		ctx, producer := StartProducer(context.Background(), "queue")
+		headers := map[string]string{}
+		Inject(ctx, headers)
+		End(producer, nil)
+
+		_, consumer := StartConsumer("queue", headers)
+		End(consumer, errors.New("failed"))
+
+		spans := recorder.Ended()
+		assert.Len(t, spans, 2)
+		assert.Contains(t, headers, "traceparent")
+		assert.Equal(t, "queue send", spans[0].Name())
+		assert.Equal(t, "queue process", spans[1].Name())
+		assert.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+		assert.Equal(t, spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID())
+		assert.Equal(t, codes.Error, spans[1].Status().Code)
+	})
+	t.Run("a message without headers starts a new trace", func(t *testing.T) {
+		_, consumer := StartConsumer("queue", nil)
+		End(consumer, nil)
+
+		spans := recorder.Ended()
+		assert.False(t, spans[len(spans)-1].Parent().IsValid())
+		assert.Equal(t, codes.Unset, spans[len(spans)-1].Status().Code)
+	})
+}
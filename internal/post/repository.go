@@ -1,20 +1,24 @@
 package post
 
 import (
+	"context"
 	"database/sql"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/database/postgresql"
 	"strings"
 	"time"
 )
 
 type PostRepository interface {
-	InsertPost(post *Post) error
-	FindPostsByAccountID(accountID, page *string) ([]interface{}, error)
-	UpdatePostDataByID(postID, accountID, content *string) error
-	FindPostByID(id *string) (*PostResponse, error)
-	ExistsPostByID(id *string) (*bool, error)
-	RemovePostByID(postID, accountID *string) error
-	ExistsPostByPostIDAndAccountID(postID, accountID *string) (*bool, error)
-	FindPostByAccountFollowingByAccountID(accountID *string, page *string) ([]interface{}, error)
+	InsertPost(ctx context.Context, post *Post, message *outbox.Outbox) error
+	FindPostsByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error)
+	UpdatePostDataByID(ctx context.Context, postID, accountID, content *string, version *int) (bool, error)
+	FindPostByID(ctx context.Context, id *string) (*PostResponse, error)
+	ExistsPostByID(ctx context.Context, id *string) (*bool, error)
+	RemovePostByID(ctx context.Context, postID, accountID *string, entry *audit.Audit) error
+	ExistsPostByPostIDAndAccountID(ctx context.Context, postID, accountID *string) (*bool, error)
+	FindPostByAccountFollowingByAccountID(ctx context.Context, accountID *string, page *string) ([]interface{}, error)
 }
 
 type PostRepositoryStruct struct {
@@ -25,21 +29,36 @@ func NewPostRepository(postgresDB *sql.DB) PostRepository {
 	return &PostRepositoryStruct{postgresDB}
 }
 
-func (p *PostRepositoryStruct) InsertPost(post *Post) error {
+func (p *PostRepositoryStruct) InsertPost(ctx context.Context, post *Post, message *outbox.Outbox) error {
+	ctx, end := postgresql.Observe(ctx, "post", "InsertPost")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		INSERT INTO post (id, account_id, content, created_at, updated_at, removed)
 		VALUES ($1, $2, $3, $4, $5, $6)`
 
-	row := p.Db.QueryRow(sqlStatement, post.ID, post.AccountID, post.Content, post.CreatedAt,
+	_, err = tx.ExecContext(ctx, sqlStatement, post.ID, post.AccountID, post.Content, post.CreatedAt,
 		post.UpdatedAt, post.Removed)
-	if row.Err() != nil {
-		return row.Err()
+	if err != nil {
+		return err
+	}
+
+	err = outbox.InsertOutbox(ctx, tx, message)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (p *PostRepositoryStruct) FindPostsByAccountID(accountID, page *string) ([]interface{}, error) {
+func (p *PostRepositoryStruct) FindPostsByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "FindPostsByAccountID")
+	defer end()
 	sqlStatement := `
 	SELECT post.id, post.account_id, post.content, post.created_at, post.updated_at, 
 	(
@@ -55,7 +74,7 @@ func (p *PostRepositoryStruct) FindPostsByAccountID(accountID, page *string) ([]
 	OFFSET ($2 - 1) * 10
 	FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(sqlStatement, accountID, page)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID, page)
 	if err != nil {
 		return nil, err
 	}
@@ -84,26 +103,37 @@ func (p *PostRepositoryStruct) FindPostsByAccountID(accountID, page *string) ([]
 	return list, nil
 }
 
-func (p *PostRepositoryStruct) UpdatePostDataByID(postID, accountID, content *string) error {
+// UpdatePostDataByID reports whether the post was updated. With a version,
+// only a post still at that version is.
+func (p *PostRepositoryStruct) UpdatePostDataByID(ctx context.Context, postID, accountID, content *string, version *int) (bool, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "UpdatePostDataByID")
+	defer end()
 	sqlStatement := `
 		UPDATE post 
-		SET content = $1, updated_at = $2
+		SET content = $1, updated_at = $2, version = version + 1
 		WHERE id = $3
 		AND account_id = $4  
-		AND removed = false`
+		AND removed = false
+		AND ($5::INT IS NULL OR version = $5)`
 
 	updateTime := time.Now().UTC().Format("2006-01-02")
 
-	row := p.Db.QueryRow(sqlStatement, content, updateTime, postID, accountID)
-	if row.Err() != nil {
-		return row.Err()
+	result, err := p.Db.ExecContext(ctx, sqlStatement, content, updateTime, postID, accountID, version)
+	if err != nil {
+		return false, err
 	}
 
-	return nil
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
 
+	return affected > 0, nil
 }
 
-func (p *PostRepositoryStruct) FindPostByID(id *string) (*PostResponse, error) {
+func (p *PostRepositoryStruct) FindPostByID(ctx context.Context, id *string) (*PostResponse, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "FindPostByID")
+	defer end()
 	sqlStatement := `
 	SELECT post.id, post.account_id, post.content, post.created_at, post.updated_at, 
 	(
@@ -111,20 +141,15 @@ func (p *PostRepositoryStruct) FindPostByID(id *string) (*PostResponse, error) {
 	) AS like,
 	(
 		SELECT count(1) FROM interaction i WHERE i.post_id = post.id AND i."type" = 'DISLIKE' 
-	) AS dislike
+	) AS dislike,
+	post.version
 	FROM post
 	WHERE post.id = $1
 	AND post.removed = false
 	GROUP BY post.id;`
 
-	rows, err := p.Db.Query(sqlStatement, id)
-	if err != nil {
-		return nil, err
-	}
-
-	rows.Next()
 	var post PostResponse
-	err = rows.Scan(
+	err := p.Db.QueryRowContext(ctx, sqlStatement, id).Scan(
 		&post.ID,
 		&post.AccountID,
 		&post.Content,
@@ -132,6 +157,7 @@ func (p *PostRepositoryStruct) FindPostByID(id *string) (*PostResponse, error) {
 		&post.UpdatedAt,
 		&post.Like,
 		&post.Dislike,
+		&post.Version,
 	)
 	if err != nil {
 		return nil, err
@@ -140,13 +166,15 @@ func (p *PostRepositoryStruct) FindPostByID(id *string) (*PostResponse, error) {
 	return &post, nil
 }
 
-func (p *PostRepositoryStruct) ExistsPostByID(id *string) (*bool, error) {
+func (p *PostRepositoryStruct) ExistsPostByID(ctx context.Context, id *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "ExistsPostByID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM post
 		WHERE id = $1
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, id)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id)
 	if err != nil {
 		return nil, err
 	}
@@ -155,29 +183,48 @@ func (p *PostRepositoryStruct) ExistsPostByID(id *string) (*bool, error) {
 	return &next, nil
 }
 
-func (p *PostRepositoryStruct) RemovePostByID(postID, accountID *string) error {
+// RemovePostByID removes the post of accountID. A post removed by a moderator
+// comes with the entry of the audit log, stored in the same transaction.
+func (p *PostRepositoryStruct) RemovePostByID(ctx context.Context, postID, accountID *string, entry *audit.Audit) error {
+	ctx, end := postgresql.Observe(ctx, "post", "RemovePostByID")
+	defer end()
+	tx, err := p.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	sqlStatement := `
 		UPDATE post 
 		SET removed = true
 		WHERE id = $1
 		AND account_id = $2`
 
-	row := p.Db.QueryRow(sqlStatement, postID, accountID)
-	if row.Err() != nil {
-		return row.Err()
+	_, err = tx.ExecContext(ctx, sqlStatement, postID, accountID)
+	if err != nil {
+		return err
+	}
+
+	if entry != nil {
+		err = audit.InsertAudit(ctx, tx, entry)
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (p *PostRepositoryStruct) ExistsPostByPostIDAndAccountID(postID, accountID *string) (*bool, error) {
+func (p *PostRepositoryStruct) ExistsPostByPostIDAndAccountID(ctx context.Context, postID, accountID *string) (*bool, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "ExistsPostByPostIDAndAccountID")
+	defer end()
 	sqlStatement := `
 		SELECT id
 		FROM post
 		WHERE id = $1
 		AND account_id = $2
 		AND removed = false`
-	rows, err := p.Db.Query(sqlStatement, postID, accountID)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, postID, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +233,9 @@ func (p *PostRepositoryStruct) ExistsPostByPostIDAndAccountID(postID, accountID
 	return &next, nil
 }
 
-func (p *PostRepositoryStruct) FindPostByAccountFollowingByAccountID(accountID, page *string) ([]interface{}, error) {
+func (p *PostRepositoryStruct) FindPostByAccountFollowingByAccountID(ctx context.Context, accountID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "post", "FindPostByAccountFollowingByAccountID")
+	defer end()
 	sqlStatement := `
 	SELECT post.id, post.account_id, post.content, post.created_at, post.updated_at, 
 	(
@@ -204,7 +253,7 @@ func (p *PostRepositoryStruct) FindPostByAccountFollowingByAccountID(accountID,
 	OFFSET ($2 - 1) * 10
 	FETCH NEXT 10 ROWS ONLY;`
 
-	rows, err := p.Db.Query(sqlStatement, accountID, page)
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID, page)
 	if err != nil {
 		return nil, err
 	}
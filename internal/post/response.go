@@ -8,4 +8,5 @@ type PostResponse struct {
 	UpdatedAt string `json:"updated_at"`
 	Like      int `json:"like"`
 	Dislike   int `json:"dislike"`
+	Version   int `json:"-"`
 }
@@ -1,49 +1,68 @@
 package service
 
 import (
+	"context"
+	"log/slog"
 	"social_network_project/internal/account"
-	"social_network_project/internal/notification"
-	"social_network_project/internal/notification/service"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/event"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/cache"
+	"social_network_project/internal/platform/metrics"
 	"social_network_project/internal/post"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils"
 	"social_network_project/internal/utils/errors"
 )
 
 type PostsServiceClient interface {
-	InsertPost(post *post.Post) error
-	FindPostsByAccountID(accountID, idToGet, page *string) ([]interface{}, error)
-	UpdatePostDataByID(post *post.Post) (*post.PostResponse, error)
-	RemovePostByID(post *post.Post) (*post.PostResponse, error)
-	FindPostByAccountFollowingByAccountID(accountID *string, page *string) ([]interface{}, error)
+	InsertPost(ctx context.Context, post *post.Post) error
+	FindPostsByAccountID(ctx context.Context, accountID, idToGet, page *string) ([]interface{}, error)
+	FindPostByID(ctx context.Context, id *string) (*post.PostResponse, error)
+	UpdatePostDataByID(ctx context.Context, post *post.Post, ifMatch string) (*post.PostResponse, error)
+	RemovePostByID(ctx context.Context, post *post.Post) (*post.PostResponse, error)
+	FindPostByAccountFollowingByAccountID(ctx context.Context, accountID *string, page *string) ([]interface{}, error)
 }
 
 type PostsService struct {
 	repositoryPost    post.PostRepository
 	repositoryAccount account.AccountRepository
-	rabbitControl     service.NotificationServiceClient
+	cache             cache.Invalidator
+	logger            *slog.Logger
 }
 
-func NewPostsService(_repositoryPost post.PostRepository, _repositoryAccount account.AccountRepository, rabbitmq service.NotificationServiceClient) PostsServiceClient {
+func NewPostsService(_repositoryPost post.PostRepository, _repositoryAccount account.AccountRepository, _cache cache.Invalidator, logger *slog.Logger) PostsServiceClient {
 	return &PostsService{
-		repositoryPost:   _repositoryPost,
+		repositoryPost:    _repositoryPost,
 		repositoryAccount: _repositoryAccount,
-		rabbitControl:     rabbitmq,
+		cache:             _cache,
+		logger:            logger,
 	}
 }
 
-func (p PostsService) InsertPost(post *post.Post) error {
+func (p PostsService) InsertPost(ctx context.Context, post *post.Post) error {
 
-	err := p.repositoryPost.InsertPost(post)
+	message, err := outbox.NewOutbox(ctx, event.New(post.AccountID, &event.PostCreated{
+		PostID:    post.ID,
+		AccountID: post.AccountID,
+	}))
 	if err != nil {
-		return &errors.NotFoundAccountIDError{}
+		return err
 	}
 
-	p.rabbitControl.SendMessage(notification.CreateNotificationJson("Post", post.AccountID))
+	err = p.repositoryPost.InsertPost(ctx, post, message)
+	if err != nil {
+		return err
+	}
+	metrics.PostsCreated.Inc()
+
+	p.invalidate(ctx, p.postsTags(ctx, post.AccountID)...)
 	return nil
 }
 
-func (p PostsService) FindPostsByAccountID(accountID, idToGet, page *string) ([]interface{}, error) {
+func (p PostsService) FindPostsByAccountID(ctx context.Context, accountID, idToGet, page *string) ([]interface{}, error) {
 
-	existID, err := p.repositoryAccount.ExistsAccountByID(accountID)
+	existID, err := p.repositoryAccount.ExistsAccountByID(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +71,7 @@ func (p PostsService) FindPostsByAccountID(accountID, idToGet, page *string) ([]
 	}
 
 	if *idToGet != "" {
-		existID, err := p.repositoryAccount.ExistsAccountByID(idToGet)
+		existID, err := p.repositoryAccount.ExistsAccountByID(ctx, idToGet)
 		if err != nil {
 			return nil, err
 		}
@@ -60,15 +79,24 @@ func (p PostsService) FindPostsByAccountID(accountID, idToGet, page *string) ([]
 			return nil, &errors.NotFoundAccountIDError{}
 		}
 
-		return p.repositoryPost.FindPostsByAccountID(idToGet, page)
+		return p.repositoryPost.FindPostsByAccountID(ctx, idToGet, page)
+	}
+
+	return p.repositoryPost.FindPostsByAccountID(ctx, accountID, page)
+}
+
+func (p PostsService) FindPostByID(ctx context.Context, id *string) (*post.PostResponse, error) {
+	found, err := p.repositoryPost.FindPostByID(ctx, id)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.NotFoundPostIDError{})
 	}
 
-	return p.repositoryPost.FindPostsByAccountID(accountID, page)
+	return found, nil
 }
 
-func (p PostsService) UpdatePostDataByID(post *post.Post) (*post.PostResponse, error) {
+func (p PostsService) UpdatePostDataByID(ctx context.Context, post *post.Post, ifMatch string) (*post.PostResponse, error) {
 
-	exist, err := p.repositoryPost.ExistsPostByID(&post.ID)
+	exist, err := p.repositoryPost.ExistsPostByID(ctx, &post.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +104,7 @@ func (p PostsService) UpdatePostDataByID(post *post.Post) (*post.PostResponse, e
 		return nil, &errors.NotFoundPostIDError{}
 	}
 
-	exist, err = p.repositoryPost.ExistsPostByPostIDAndAccountID(&post.ID, &post.AccountID)
+	exist, err = p.repositoryPost.ExistsPostByPostIDAndAccountID(ctx, &post.ID, &post.AccountID)
 	if err != nil {
 		return nil, err
 	}
@@ -84,45 +112,67 @@ func (p PostsService) UpdatePostDataByID(post *post.Post) (*post.PostResponse, e
 		return nil, &errors.UnauthorizedAccountIDError{}
 	}
 
-	err = p.repositoryPost.UpdatePostDataByID(&post.ID, &post.AccountID, &post.Content)
+	var version *int
+	if ifMatch != "" {
+		current, err := p.repositoryPost.FindPostByID(ctx, &post.ID)
+		if err != nil {
+			return nil, errors.NotFound(err, &errors.NotFoundPostIDError{})
+		}
+		err = utils.CheckIfMatch(ifMatch, current)
+		if err != nil {
+			return nil, err
+		}
+		version = &current.Version
+	}
+
+	updated, err := p.repositoryPost.UpdatePostDataByID(ctx, &post.ID, &post.AccountID, &post.Content, version)
 	if err != nil {
 		return nil, err
 	}
+	if !updated && version != nil {
+		return nil, &errors.PreconditionFailedError{}
+	}
+	p.invalidate(ctx, cache.PostTag(post.ID))
 
-	postUpdated, err := p.repositoryPost.FindPostByID(&post.ID)
+	postUpdated, err := p.repositoryPost.FindPostByID(ctx, &post.ID)
 	if err != nil {
-		return nil, &errors.NotFoundPostIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundPostIDError{})
 	}
 
 	return postUpdated, nil
 }
 
-func (p PostsService) RemovePostByID(post *post.Post) (*post.PostResponse, error) {
+func (p PostsService) RemovePostByID(ctx context.Context, post *post.Post) (*post.PostResponse, error) {
 
-	postToRemoved, err := p.repositoryPost.FindPostByID(&post.ID)
+	postToRemoved, err := p.repositoryPost.FindPostByID(ctx, &post.ID)
 	if err != nil {
-		return nil, &errors.NotFoundPostIDError{}
+		return nil, errors.NotFound(err, &errors.NotFoundPostIDError{})
 	}
 
-	exist, err := p.repositoryPost.ExistsPostByPostIDAndAccountID(&post.ID, &post.AccountID)
+	exist, err := p.repositoryPost.ExistsPostByPostIDAndAccountID(ctx, &post.ID, &post.AccountID)
 	if err != nil {
 		return nil, err
 	}
+	var entry *audit.Audit
 	if !*exist {
-		return nil, &errors.UnauthorizedAccountIDError{}
+		entry, err = p.moderation(ctx, post.AccountID, postToRemoved)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = p.repositoryPost.RemovePostByID(&post.ID, &post.AccountID)
+	err = p.repositoryPost.RemovePostByID(ctx, &post.ID, &postToRemoved.AccountID, entry)
 	if err != nil {
 		return nil, err
 	}
+	p.invalidate(ctx, append(p.postsTags(ctx, postToRemoved.AccountID), cache.PostTag(post.ID))...)
 
 	return postToRemoved, nil
 }
 
-func (p PostsService) FindPostByAccountFollowingByAccountID(accountID *string, page *string) ([]interface{}, error) {
+func (p PostsService) FindPostByAccountFollowingByAccountID(ctx context.Context, accountID *string, page *string) ([]interface{}, error) {
 
-	existID, err := p.repositoryAccount.ExistsAccountByID(accountID)
+	existID, err := p.repositoryAccount.ExistsAccountByID(ctx, accountID)
 	if err != nil {
 		return nil, err
 	}
@@ -130,5 +180,44 @@ func (p PostsService) FindPostByAccountFollowingByAccountID(accountID *string, p
 		return nil, &errors.NotFoundAccountIDError{}
 	}
 
-	return p.repositoryPost.FindPostByAccountFollowingByAccountID(accountID, page)
+	return p.repositoryPost.FindPostByAccountFollowingByAccountID(ctx, accountID, page)
+}
+
+// moderation returns the audit entry of accountID removing a post of someone
+// else, or UnauthorizedAccountIDError when its role does not allow it.
+func (p PostsService) moderation(ctx context.Context, accountID string, postToRemoved *post.PostResponse) (*audit.Audit, error) {
+	actor, err := p.repositoryAccount.FindAccountByID(ctx, &accountID)
+	if err != nil {
+		return nil, errors.NotFound(err, &errors.UnauthorizedAccountIDError{})
+	}
+	if !rbac.Can(actor.Role, rbac.PERMISSION_REMOVE_ANY_POST) {
+		return nil, &errors.UnauthorizedAccountIDError{}
+	}
+
+	return audit.NewAudit(accountID, audit.ACTION_REMOVE_POST, audit.TARGET_POST, postToRemoved.ID, map[string]string{
+		"account_id": postToRemoved.AccountID,
+	}), nil
+}
+
+// postsTags lists the tags of the post lists an account's post appears in:
+// its own list and the feed of each follower.
+func (p PostsService) postsTags(ctx context.Context, accountID string) []string {
+	tags := []string{cache.PostsTag(accountID)}
+
+	followerIDs, err := p.repositoryAccount.FindAccountFollowerIDsByAccountID(ctx, &accountID)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "finding followers to invalidate their feed", "account_id", accountID, "error", err)
+	}
+	for _, followerID := range followerIDs {
+		tags = append(tags, cache.FeedTag(followerID))
+	}
+
+	return tags
+}
+
+func (p PostsService) invalidate(ctx context.Context, tags ...string) {
+	err := p.cache.Invalidate(ctx, tags...)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "invalidating cache", "tags", tags, "error", err)
+	}
 }
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/account"
+	"social_network_project/internal/audit"
+	"social_network_project/internal/outbox"
+	"social_network_project/internal/platform/logging"
+	"social_network_project/internal/post"
+	"social_network_project/internal/rbac"
+	"social_network_project/internal/utils"
+	"social_network_project/internal/utils/errors"
+	"testing"
+)
+
+type postRepositoryFake struct {
+	post.PostRepository
+	posts   map[string]*post.PostResponse
+	removed []string
+	entries []*audit.Audit
+	// raced is the number of updates landing between the If-Match check and
+	// the next update.
+	raced int
+	// err fails every insert, as a database that cannot be reached does.
+	err error
+}
+
+func (p *postRepositoryFake) FindPostByID(ctx context.Context, id *string) (*post.PostResponse, error) {
+	found, ok := p.posts[*id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (p *postRepositoryFake) InsertPost(ctx context.Context, post *post.Post, message *outbox.Outbox) error {
+	return p.err
+}
+
+func (p *postRepositoryFake) ExistsPostByID(ctx context.Context, id *string) (*bool, error) {
+	_, ok := p.posts[*id]
+	return &ok, nil
+}
+
+func (p *postRepositoryFake) UpdatePostDataByID(ctx context.Context, postID, accountID, content *string, version *int) (bool, error) {
+	found := p.posts[*postID]
+	found.Version += p.raced
+	if version != nil && *version != found.Version {
+		return false, nil
+	}
+	found.Content = *content
+	found.Version++
+	return true, nil
+}
+
+func (p *postRepositoryFake) ExistsPostByPostIDAndAccountID(ctx context.Context, postID, accountID *string) (*bool, error) {
+	found, ok := p.posts[*postID]
+	exist := ok && found.AccountID == *accountID
+	return &exist, nil
+}
+
+func (p *postRepositoryFake) RemovePostByID(ctx context.Context, postID, accountID *string, entry *audit.Audit) error {
+	p.removed = append(p.removed, *postID+"/"+*accountID)
+	if entry != nil {
+		p.entries = append(p.entries, entry)
+	}
+	return nil
+}
+
+type accountRepositoryFake struct {
+	account.AccountRepository
+	roles map[string]string
+}
+
+func (a *accountRepositoryFake) FindAccountByID(ctx context.Context, id *string) (*account.Account, error) {
+	return &account.Account{ID: *id, Role: a.roles[*id]}, nil
+}
+
+func (a *accountRepositoryFake) FindAccountFollowerIDsByAccountID(ctx context.Context, id *string) ([]string, error) {
+	return nil, nil
+}
+
+type invalidatorFake struct{}
+
+func (i invalidatorFake) Invalidate(ctx context.Context, tags ...string) error {
+	return nil
+}
+
+func TestPostsService_InsertPost(t *testing.T) {
+	t.Run("returns the error of a failing database as is", func(t *testing.T) {
+		failure := fmt.Errorf("connection refused")
+		posts := &postRepositoryFake{err: failure}
+		postsService := NewPostsService(posts, &accountRepositoryFake{}, invalidatorFake{}, logging.Discard())
+
+		err := postsService.InsertPost(context.Background(), &post.Post{ID: "p1", AccountID: "owner", Content: "hello"})
+		assert.Equal(t, failure, err)
+	})
+}
+
+func TestPostsService_RemovePostByID(t *testing.T) {
+
+	newService := func() (*postRepositoryFake, PostsServiceClient) {
+		posts := &postRepositoryFake{posts: map[string]*post.PostResponse{
+			"p1": {ID: "p1", AccountID: "owner"},
+		}}
+		accounts := &accountRepositoryFake{roles: map[string]string{
+			"owner":     rbac.ROLE_USER,
+			"user":      rbac.ROLE_USER,
+			"moderator": rbac.ROLE_MODERATOR,
+		}}
+		return posts, NewPostsService(posts, accounts, invalidatorFake{}, logging.Discard())
+	}
+
+	t.Run("owner removes the post without an audit entry", func(t *testing.T) {
+		posts, postsService := newService()
+
+		_, err := postsService.RemovePostByID(context.Background(), &post.Post{ID: "p1", AccountID: "owner"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"p1/owner"}, posts.removed)
+		assert.Empty(t, posts.entries)
+	})
+
+	t.Run("moderator removes the post of someone else with an audit entry", func(t *testing.T) {
+		posts, postsService := newService()
+
+		_, err := postsService.RemovePostByID(context.Background(), &post.Post{ID: "p1", AccountID: "moderator"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"p1/owner"}, posts.removed)
+		assert.Len(t, posts.entries, 1)
+		assert.Equal(t, "moderator", posts.entries[0].ActorID)
+		assert.Equal(t, audit.ACTION_REMOVE_POST, posts.entries[0].Action)
+		assert.Equal(t, "owner", posts.entries[0].Details["account_id"])
+	})
+
+	t.Run("user cannot remove the post of someone else", func(t *testing.T) {
+		posts, postsService := newService()
+
+		_, err := postsService.RemovePostByID(context.Background(), &post.Post{ID: "p1", AccountID: "user"})
+		assert.IsType(t, &errors.UnauthorizedAccountIDError{}, err)
+		assert.Empty(t, posts.removed)
+	})
+}
+
+func TestPostsService_UpdatePostDataByID(t *testing.T) {
+
+	newService := func() (*postRepositoryFake, PostsServiceClient, string) {
+		current := &post.PostResponse{ID: "p1", AccountID: "owner", Content: "first"}
+		etag, err := utils.ETagOf(current)
+		assert.Nil(t, err)
+		posts := &postRepositoryFake{posts: map[string]*post.PostResponse{"p1": current}}
+		return posts, NewPostsService(posts, &accountRepositoryFake{}, invalidatorFake{}, logging.Discard()), etag
+	}
+
+	t.Run("updates the post matching If-Match", func(t *testing.T) {
+		posts, postsService, etag := newService()
+
+		updated, err := postsService.UpdatePostDataByID(context.Background(), &post.Post{ID: "p1", AccountID: "owner", Content: "second"}, etag)
+		assert.Nil(t, err)
+		assert.Equal(t, "second", updated.Content)
+		assert.Equal(t, 1, posts.posts["p1"].Version)
+	})
+
+	t.Run("refuses an update racing another one after the check", func(t *testing.T) {
+		posts, postsService, etag := newService()
+		posts.raced = 1
+
+		_, err := postsService.UpdatePostDataByID(context.Background(), &post.Post{ID: "p1", AccountID: "owner", Content: "second"}, etag)
+		assert.IsType(t, &errors.PreconditionFailedError{}, err)
+		assert.Equal(t, "first", posts.posts["p1"].Content)
+	})
+}
@@ -0,0 +1,47 @@
+package rbac
+
+const (
+	ROLE_USER      = "user"
+	ROLE_MODERATOR = "moderator"
+	ROLE_ADMIN     = "admin"
+)
+
+type Permission string
+
+const (
+	PERMISSION_REMOVE_ANY_POST    Permission = "remove:any_post"
+	PERMISSION_REMOVE_ANY_COMMENT Permission = "remove:any_comment"
+	PERMISSION_READ_AUDIT_LOG     Permission = "read:audit_log"
+	PERMISSION_ASSIGN_ROLES       Permission = "assign:roles"
+	PERMISSION_READ_STATUS        Permission = "read:status"
+)
+
+// permissions lists what each role may do beyond acting on its own content.
+// Users have no permission of their own.
+var permissions = map[string][]Permission{
+	ROLE_MODERATOR: {
+		PERMISSION_REMOVE_ANY_POST,
+		PERMISSION_REMOVE_ANY_COMMENT,
+		PERMISSION_READ_AUDIT_LOG,
+	},
+	ROLE_ADMIN: {
+		PERMISSION_REMOVE_ANY_POST,
+		PERMISSION_REMOVE_ANY_COMMENT,
+		PERMISSION_READ_AUDIT_LOG,
+		PERMISSION_ASSIGN_ROLES,
+		PERMISSION_READ_STATUS,
+	},
+}
+
+func Can(role string, permission Permission) bool {
+	for _, p := range permissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidRole(role string) bool {
+	return role == ROLE_USER || role == ROLE_MODERATOR || role == ROLE_ADMIN
+}
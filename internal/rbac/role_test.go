@@ -0,0 +1,29 @@
+package rbac
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+
+	t.Run("users have no permission", func(t *testing.T) {
+		assert.False(t, Can(ROLE_USER, PERMISSION_REMOVE_ANY_POST))
+		assert.False(t, Can(ROLE_USER, PERMISSION_ASSIGN_ROLES))
+		assert.False(t, Can("", PERMISSION_READ_AUDIT_LOG))
+	})
+
+	t.Run("moderators remove content but do not assign roles", func(t *testing.T) {
+		assert.True(t, Can(ROLE_MODERATOR, PERMISSION_REMOVE_ANY_POST))
+		assert.True(t, Can(ROLE_MODERATOR, PERMISSION_REMOVE_ANY_COMMENT))
+		assert.True(t, Can(ROLE_MODERATOR, PERMISSION_READ_AUDIT_LOG))
+		assert.False(t, Can(ROLE_MODERATOR, PERMISSION_ASSIGN_ROLES))
+		assert.False(t, Can(ROLE_MODERATOR, PERMISSION_READ_STATUS))
+	})
+
+	t.Run("admins can do everything", func(t *testing.T) {
+		assert.True(t, Can(ROLE_ADMIN, PERMISSION_REMOVE_ANY_POST))
+		assert.True(t, Can(ROLE_ADMIN, PERMISSION_ASSIGN_ROLES))
+		assert.True(t, Can(ROLE_ADMIN, PERMISSION_READ_STATUS))
+	})
+}
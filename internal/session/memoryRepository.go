@@ -0,0 +1,93 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type SessionRepositoryMemory struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	now      func() time.Time
+}
+
+func NewSessionRepositoryMemory(now func() time.Time) SessionRepository {
+	return &SessionRepositoryMemory{
+		sessions: map[string]*Session{},
+		now:      now,
+	}
+}
+
+func (m *SessionRepositoryMemory) InsertSession(ctx context.Context, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *session
+	m.sessions[session.ID] = &copied
+	return nil
+}
+
+func (m *SessionRepositoryMemory) FindSessionByID(ctx context.Context, id string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := m.find(id)
+	if found == nil {
+		return nil, nil
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (m *SessionRepositoryMemory) FindSessionsByAccountID(ctx context.Context, accountID string) ([]*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var sessions []*Session
+	for id, found := range m.sessions {
+		if found.AccountID != accountID || m.find(id) == nil {
+			continue
+		}
+		copied := *found
+		sessions = append(sessions, &copied)
+	}
+	sortByLastSeen(sessions)
+
+	return sessions, nil
+}
+
+func (m *SessionRepositoryMemory) TouchSession(ctx context.Context, id string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if found := m.find(id); found != nil {
+		found.LastSeenAt = at
+	}
+	return nil
+}
+
+func (m *SessionRepositoryMemory) DeleteSessions(ctx context.Context, accountID string, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		if found, ok := m.sessions[id]; ok && found.AccountID == accountID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// find drops the session when it expired.
+func (m *SessionRepositoryMemory) find(id string) *Session {
+	found, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	if found.Expired(m.now()) {
+		delete(m.sessions, id)
+		return nil
+	}
+	return found
+}
@@ -0,0 +1,41 @@
+package session
+
+import (
+	"sort"
+	"time"
+)
+
+// Session is a sign in of an account on a device. Every token issued by a
+// sign in names its session, and stops working once the session is deleted.
+type Session struct {
+	ID         string
+	AccountID  string
+	UserAgent  string
+	IP         string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (s *Session) Expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+func (s *Session) ToResponse(currentID string) SessionResponse {
+	return SessionResponse{
+		ID:         s.ID,
+		UserAgent:  s.UserAgent,
+		IP:         s.IP,
+		CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+		LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+		ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+		Current:    s.ID == currentID,
+	}
+}
+
+// sortByLastSeen puts the sessions used most recently first.
+func sortByLastSeen(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+}
@@ -0,0 +1,154 @@
+package session
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// touchScript updates the last seen time only of a session still there, so a
+// request racing its deletion does not bring it back without its expiry.
+var touchScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HSET", KEYS[1], "last_seen_at", ARGV[1])
+end
+return 0
+`)
+
+// SessionRepositoryRedis keeps each session in a hash expiring with it, and
+// the ids of the sessions of an account in a sorted set scored by their
+// expiry.
+type SessionRepositoryRedis struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func NewSessionRepositoryRedis(client *redis.Client, now func() time.Time) SessionRepository {
+	return &SessionRepositoryRedis{
+		client: client,
+		now:    now,
+	}
+}
+
+func (r *SessionRepositoryRedis) InsertSession(ctx context.Context, session *Session) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionKey(session.ID), map[string]interface{}{
+			"account_id":   session.AccountID,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt.UnixMilli(),
+			"last_seen_at": session.LastSeenAt.UnixMilli(),
+			"expires_at":   session.ExpiresAt.UnixMilli(),
+		})
+		pipe.PExpireAt(ctx, sessionKey(session.ID), session.ExpiresAt)
+		pipe.ZAdd(ctx, accountKey(session.AccountID), &redis.Z{
+			Score:  float64(session.ExpiresAt.UnixMilli()),
+			Member: session.ID,
+		})
+		// Sessions all last as long, so the newest expires last and the set
+		// lives as long as it.
+		pipe.PExpireAt(ctx, accountKey(session.AccountID), session.ExpiresAt)
+		return nil
+	})
+	return err
+}
+
+func (r *SessionRepositoryRedis) FindSessionByID(ctx context.Context, id string) (*Session, error) {
+	values, err := r.client.HGetAll(ctx, sessionKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.parse(id, values), nil
+}
+
+func (r *SessionRepositoryRedis) FindSessionsByAccountID(ctx context.Context, accountID string) ([]*Session, error) {
+	err := r.client.ZRemRangeByScore(ctx, accountKey(accountID), "-inf", strconv.FormatInt(r.now().UnixMilli(), 10)).Err()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := r.client.ZRange(ctx, accountKey(accountID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make([]*redis.StringStringMapCmd, len(ids))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			commands[i] = pipe.HGetAll(ctx, sessionKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for i, command := range commands {
+		found := r.parse(ids[i], command.Val())
+		if found != nil && found.AccountID == accountID {
+			sessions = append(sessions, found)
+		}
+	}
+	sortByLastSeen(sessions)
+
+	return sessions, nil
+}
+
+func (r *SessionRepositoryRedis) TouchSession(ctx context.Context, id string, at time.Time) error {
+	return touchScript.Run(ctx, r.client, []string{sessionKey(id)}, at.UnixMilli()).Err()
+}
+
+func (r *SessionRepositoryRedis) DeleteSessions(ctx context.Context, accountID string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	keys := make([]string, len(ids))
+	members := make([]interface{}, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+		members[i] = id
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.ZRem(ctx, accountKey(accountID), members...)
+		return nil
+	})
+	return err
+}
+
+// parse returns nil for a session that is gone.
+func (r *SessionRepositoryRedis) parse(id string, values map[string]string) *Session {
+	if len(values) == 0 {
+		return nil
+	}
+
+	found := &Session{
+		ID:         id,
+		AccountID:  values["account_id"],
+		UserAgent:  values["user_agent"],
+		IP:         values["ip"],
+		CreatedAt:  parseMilli(values["created_at"]),
+		LastSeenAt: parseMilli(values["last_seen_at"]),
+		ExpiresAt:  parseMilli(values["expires_at"]),
+	}
+	if found.Expired(r.now()) {
+		return nil
+	}
+	return found
+}
+
+func parseMilli(value string) time.Time {
+	milli, _ := strconv.ParseInt(value, 10, 64)
+	return time.UnixMilli(milli).UTC()
+}
+
+func sessionKey(id string) string {
+	return "session-" + id
+}
+
+func accountKey(accountID string) string {
+	return "sessions-" + accountID
+}
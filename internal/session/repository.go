@@ -0,0 +1,17 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// SessionRepository keeps the sessions until they expire. Sessions are checked
+// on every request, so they live in Redis, or in memory for a single instance.
+type SessionRepository interface {
+	InsertSession(ctx context.Context, session *Session) error
+	// FindSessionByID returns nil when the session expired or was deleted.
+	FindSessionByID(ctx context.Context, id string) (*Session, error)
+	FindSessionsByAccountID(ctx context.Context, accountID string) ([]*Session, error)
+	TouchSession(ctx context.Context, id string, at time.Time) error
+	DeleteSessions(ctx context.Context, accountID string, ids ...string) error
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"social_network_project/internal/session"
+	"social_network_project/internal/utils/errors"
+	"time"
+)
+
+// touchEvery limits how often requests of a session update when it was last
+// seen.
+const touchEvery = time.Minute
+
+// maxUserAgent is how much of the User-Agent header a session keeps.
+const maxUserAgent = 255
+
+type SessionServiceClient interface {
+	CreateSession(ctx context.Context, accountID, ip, userAgent string) (*session.Session, error)
+	ValidateSession(ctx context.Context, accountID, sessionID string) (bool, error)
+	FindSessions(ctx context.Context, accountID, currentID string) ([]session.SessionResponse, error)
+	DeleteSession(ctx context.Context, accountID, sessionID string) error
+	DeleteOtherSessions(ctx context.Context, accountID, currentID string) error
+	DeleteAllSessions(ctx context.Context, accountID string) error
+}
+
+type SessionService struct {
+	repository session.SessionRepository
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+// NewSessionService creates sessions lasting ttl, as long as the tokens issued
+// for them.
+func NewSessionService(_repository session.SessionRepository, ttl time.Duration) SessionServiceClient {
+	return &SessionService{
+		repository: _repository,
+		ttl:        ttl,
+		now:        time.Now,
+	}
+}
+
+func (s *SessionService) CreateSession(ctx context.Context, accountID, ip, userAgent string) (*session.Session, error) {
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+
+	now := s.now().UTC()
+	created := &session.Session{
+		ID:         uuid.New().String(),
+		AccountID:  accountID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+
+	err := s.repository.InsertSession(ctx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// ValidateSession reports whether the session is still there and belongs to
+// the account, and records that it was seen.
+func (s *SessionService) ValidateSession(ctx context.Context, accountID, sessionID string) (bool, error) {
+	found, err := s.repository.FindSessionByID(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if found == nil || found.AccountID != accountID {
+		return false, nil
+	}
+
+	now := s.now().UTC()
+	if now.Sub(found.LastSeenAt) >= touchEvery {
+		err = s.repository.TouchSession(ctx, sessionID, now)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// FindSessions lists the sessions of the account, marking the current one.
+func (s *SessionService) FindSessions(ctx context.Context, accountID, currentID string) ([]session.SessionResponse, error) {
+	sessions, err := s.repository.FindSessionsByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]session.SessionResponse, 0, len(sessions))
+	for _, found := range sessions {
+		responses = append(responses, found.ToResponse(currentID))
+	}
+	return responses, nil
+}
+
+// DeleteSession signs the account out of the session; its tokens stop working
+// right away.
+func (s *SessionService) DeleteSession(ctx context.Context, accountID, sessionID string) error {
+	found, err := s.repository.FindSessionByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if found == nil || found.AccountID != accountID {
+		return &errors.NotFoundSessionIDError{}
+	}
+
+	return s.repository.DeleteSessions(ctx, accountID, sessionID)
+}
+
+// DeleteOtherSessions signs the account out everywhere but the current
+// session.
+func (s *SessionService) DeleteOtherSessions(ctx context.Context, accountID, currentID string) error {
+	return s.deleteSessions(ctx, accountID, currentID)
+}
+
+// DeleteAllSessions signs the account out everywhere.
+func (s *SessionService) DeleteAllSessions(ctx context.Context, accountID string) error {
+	return s.deleteSessions(ctx, accountID, "")
+}
+
+// deleteSessions deletes the sessions of the account but keepID.
+func (s *SessionService) deleteSessions(ctx context.Context, accountID, keepID string) error {
+	sessions, err := s.repository.FindSessionsByAccountID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	for _, found := range sessions {
+		if found.ID != keepID {
+			ids = append(ids, found.ID)
+		}
+	}
+
+	return s.repository.DeleteSessions(ctx, accountID, ids...)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/session"
+	"social_network_project/internal/utils/errors"
+	"testing"
+	"time"
+)
+
+func TestSessionService(t *testing.T) {
+
+	newService := func() (*SessionService, *time.Time) {
+		clock := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+		now := func() time.Time { return clock }
+		sessionService := NewSessionService(session.NewSessionRepositoryMemory(now), time.Hour).(*SessionService)
+		sessionService.now = now
+		return sessionService, &clock
+	}
+
+	t.Run("validates sessions of their account until they expire", func(t *testing.T) {
+		sessionService, clock := newService()
+
+		created, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+
+		valid, err := sessionService.ValidateSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		assert.True(t, valid)
+		valid, err = sessionService.ValidateSession(context.Background(), "a2", created.ID)
+		assert.Nil(t, err)
+		assert.False(t, valid)
+
+		*clock = clock.Add(time.Hour)
+		valid, err = sessionService.ValidateSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("records when a session was last seen", func(t *testing.T) {
+		sessionService, clock := newService()
+
+		created, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+
+		*clock = clock.Add(30 * time.Second)
+		_, err = sessionService.ValidateSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		found, err := sessionService.repository.FindSessionByID(context.Background(), created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created.CreatedAt, found.LastSeenAt)
+
+		*clock = clock.Add(time.Minute)
+		_, err = sessionService.ValidateSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		found, err = sessionService.repository.FindSessionByID(context.Background(), created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, *clock, found.LastSeenAt)
+	})
+
+	t.Run("lists the sessions of the account marking the current one", func(t *testing.T) {
+		sessionService, clock := newService()
+
+		first, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		*clock = clock.Add(time.Minute)
+		second, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.2", "Mozilla/5.0")
+		assert.Nil(t, err)
+		_, err = sessionService.CreateSession(context.Background(), "a2", "10.0.0.3", "Mozilla/5.0")
+		assert.Nil(t, err)
+
+		sessions, err := sessionService.FindSessions(context.Background(), "a1", first.ID)
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 2)
+		assert.Equal(t, second.ID, sessions[0].ID)
+		assert.Equal(t, "Mozilla/5.0", sessions[0].UserAgent)
+		assert.False(t, sessions[0].Current)
+		assert.True(t, sessions[1].Current)
+	})
+
+	t.Run("deletes a session of the account only", func(t *testing.T) {
+		sessionService, _ := newService()
+
+		created, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+
+		err = sessionService.DeleteSession(context.Background(), "a2", created.ID)
+		assert.IsType(t, &errors.NotFoundSessionIDError{}, err)
+
+		err = sessionService.DeleteSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		valid, err := sessionService.ValidateSession(context.Background(), "a1", created.ID)
+		assert.Nil(t, err)
+		assert.False(t, valid)
+	})
+
+	t.Run("signs out everywhere but the current session", func(t *testing.T) {
+		sessionService, _ := newService()
+
+		current, err := sessionService.CreateSession(context.Background(), "a1", "10.0.0.1", "curl/7.84.0")
+		assert.Nil(t, err)
+		for i := 0; i < 3; i++ {
+			_, err = sessionService.CreateSession(context.Background(), "a1", "10.0.0.2", "Mozilla/5.0")
+			assert.Nil(t, err)
+		}
+
+		err = sessionService.DeleteOtherSessions(context.Background(), "a1", current.ID)
+		assert.Nil(t, err)
+		sessions, err := sessionService.FindSessions(context.Background(), "a1", current.ID)
+		assert.Nil(t, err)
+		assert.Len(t, sessions, 1)
+		assert.Equal(t, current.ID, sessions[0].ID)
+	})
+}
@@ -0,0 +1,67 @@
+package token
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Prefix starts every personal access token, telling them apart from the
+// JWTs issued by POST /auth.
+const Prefix = "snp_"
+
+const (
+	SCOPE_READ_PROFILE       = "read:profile"
+	SCOPE_WRITE_PROFILE      = "write:profile"
+	SCOPE_READ_FOLLOWS       = "read:follows"
+	SCOPE_WRITE_FOLLOWS      = "write:follows"
+	SCOPE_READ_POSTS         = "read:posts"
+	SCOPE_WRITE_POSTS        = "write:posts"
+	SCOPE_READ_COMMENTS      = "read:comments"
+	SCOPE_WRITE_COMMENTS     = "write:comments"
+	SCOPE_WRITE_INTERACTIONS = "write:interactions"
+	SCOPE_READ_WEBHOOKS      = "read:webhooks"
+	SCOPE_WRITE_WEBHOOKS     = "write:webhooks"
+	SCOPE_READ_STATUS        = "read:status"
+)
+
+type Token struct {
+	ID         string `validate:"required"`
+	AccountID  string
+	Name       string `validate:"required,lte=64"`
+	Prefix     string
+	TokenHash  string
+	Scopes     []string `validate:"required,min=1,dive,oneof=read:profile write:profile read:follows write:follows read:posts write:posts read:comments write:comments write:interactions read:webhooks write:webhooks read:status"`
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Token) Expired(now time.Time) bool {
+	return t.ExpiresAt.Valid && !now.Before(t.ExpiresAt.Time)
+}
+
+func (t *Token) ToResponse() TokenResponse {
+	response := TokenResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Prefix:    t.Prefix,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt.Format(time.RFC3339),
+	}
+	if t.ExpiresAt.Valid {
+		response.ExpiresAt = t.ExpiresAt.Time.Format(time.RFC3339)
+	}
+	if t.LastUsedAt.Valid {
+		response.LastUsedAt = t.LastUsedAt.Time.Format(time.RFC3339)
+	}
+	return response
+}
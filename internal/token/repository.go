@@ -0,0 +1,152 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"social_network_project/internal/platform/database/postgresql"
+	"time"
+)
+
+type TokenRepository interface {
+	InsertToken(ctx context.Context, token *Token) error
+	FindTokensByAccountID(ctx context.Context, accountID *string) ([]interface{}, error)
+	FindTokenByHash(ctx context.Context, tokenHash *string) (*Token, error)
+	RevokeTokenByID(ctx context.Context, id, accountID *string) (*Token, error)
+	TouchToken(ctx context.Context, id *string, at time.Time) error
+}
+
+type TokenRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewTokenRepository(postgresDB *sql.DB) TokenRepository {
+	return &TokenRepositoryStruct{postgresDB}
+}
+
+func (p *TokenRepositoryStruct) InsertToken(ctx context.Context, token *Token) error {
+	ctx, end := postgresql.Observe(ctx, "token", "InsertToken")
+	defer end()
+	sqlStatement := `
+		INSERT INTO access_token (id, account_id, name, prefix, token_hash, scopes, expires_at, last_used_at, created_at, revoked)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULL, $8, false)`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, token.ID, token.AccountID, token.Name, token.Prefix, token.TokenHash,
+		pq.Array(token.Scopes), token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *TokenRepositoryStruct) FindTokensByAccountID(ctx context.Context, accountID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "token", "FindTokensByAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT id, account_id, name, prefix, token_hash, scopes, expires_at, last_used_at, created_at
+		FROM access_token
+		WHERE account_id = $1
+		AND revoked = false
+		ORDER BY created_at`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []interface{}{}
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, token.ToResponse())
+	}
+
+	return list, nil
+}
+
+// FindTokenByHash returns nil when no token of an existing account has the
+// hash or it was revoked.
+func (p *TokenRepositoryStruct) FindTokenByHash(ctx context.Context, tokenHash *string) (*Token, error) {
+	ctx, end := postgresql.Observe(ctx, "token", "FindTokenByHash")
+	defer end()
+	sqlStatement := `
+		SELECT access_token.id, access_token.account_id, access_token.name, access_token.prefix, access_token.token_hash,
+		access_token.scopes, access_token.expires_at, access_token.last_used_at, access_token.created_at
+		FROM access_token
+		INNER JOIN account ON account.id = access_token.account_id
+		WHERE access_token.token_hash = $1
+		AND access_token.revoked = false
+		AND account.deleted = false`
+
+	token, err := scanToken(p.Db.QueryRowContext(ctx, sqlStatement, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// RevokeTokenByID returns the revoked token, or nil when the account has no
+// such token.
+func (p *TokenRepositoryStruct) RevokeTokenByID(ctx context.Context, id, accountID *string) (*Token, error) {
+	ctx, end := postgresql.Observe(ctx, "token", "RevokeTokenByID")
+	defer end()
+	sqlStatement := `
+		UPDATE access_token
+		SET revoked = true
+		WHERE id = $1
+		AND account_id = $2
+		AND revoked = false
+		RETURNING id, account_id, name, prefix, token_hash, scopes, expires_at, last_used_at, created_at`
+
+	token, err := scanToken(p.Db.QueryRowContext(ctx, sqlStatement, id, accountID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// TouchToken records the token was used at, at most once a minute, so that
+// authenticating does not write on every request.
+func (p *TokenRepositoryStruct) TouchToken(ctx context.Context, id *string, at time.Time) error {
+	ctx, end := postgresql.Observe(ctx, "token", "TouchToken")
+	defer end()
+	sqlStatement := `
+		UPDATE access_token
+		SET last_used_at = $2
+		WHERE id = $1
+		AND (last_used_at IS NULL OR last_used_at < $3)`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, id, at, at.Add(-time.Minute))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var token Token
+	err := row.Scan(&token.ID, &token.AccountID, &token.Name, &token.Prefix, &token.TokenHash,
+		pq.Array(&token.Scopes), &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,8 @@
+package token
+
+type TokenRequest struct {
+	Id            string   `json:"id,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"social_network_project/internal/token"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"strings"
+	"time"
+)
+
+type TokenServiceClient interface {
+	CreateToken(ctx context.Context, tokenToCreate *token.Token, expiresInDays int) (*token.TokenResponse, error)
+	FindTokensByAccountID(ctx context.Context, accountID *string) ([]interface{}, error)
+	RevokeTokenByID(ctx context.Context, id, accountID *string) (*token.TokenResponse, error)
+	Authenticate(ctx context.Context, rawToken string) (*token.Token, error)
+}
+
+type TokenService struct {
+	repository token.TokenRepository
+	now        func() time.Time
+}
+
+func NewTokenService(_repository token.TokenRepository) TokenServiceClient {
+	return &TokenService{
+		repository: _repository,
+		now:        time.Now,
+	}
+}
+
+// CreateToken returns the token itself only this once; only its hash is
+// stored. A token created without expiresInDays never expires.
+func (t *TokenService) CreateToken(ctx context.Context, tokenToCreate *token.Token, expiresInDays int) (*token.TokenResponse, error) {
+
+	secret, err := crypto.NewToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rawToken := token.Prefix + secret
+
+	now := t.now().UTC()
+	tokenToCreate.Prefix = rawToken[:len(token.Prefix)+8]
+	tokenToCreate.TokenHash = crypto.HashToken(rawToken)
+	tokenToCreate.CreatedAt = now
+	if expiresInDays > 0 {
+		tokenToCreate.ExpiresAt = sql.NullTime{Time: now.AddDate(0, 0, expiresInDays), Valid: true}
+	}
+
+	err = t.repository.InsertToken(ctx, tokenToCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := tokenToCreate.ToResponse()
+	response.Token = rawToken
+	return &response, nil
+}
+
+func (t *TokenService) FindTokensByAccountID(ctx context.Context, accountID *string) ([]interface{}, error) {
+	return t.repository.FindTokensByAccountID(ctx, accountID)
+}
+
+func (t *TokenService) RevokeTokenByID(ctx context.Context, id, accountID *string) (*token.TokenResponse, error) {
+
+	revoked, err := t.repository.RevokeTokenByID(ctx, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked == nil {
+		return nil, &errors.NotFoundTokenIDError{}
+	}
+
+	response := revoked.ToResponse()
+	return &response, nil
+}
+
+// Authenticate returns the token of rawToken, or nil when it is unknown,
+// revoked or expired.
+func (t *TokenService) Authenticate(ctx context.Context, rawToken string) (*token.Token, error) {
+
+	if !strings.HasPrefix(rawToken, token.Prefix) {
+		return nil, nil
+	}
+
+	tokenHash := crypto.HashToken(rawToken)
+	found, err := t.repository.FindTokenByHash(ctx, &tokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	now := t.now().UTC()
+	if found == nil || found.Expired(now) {
+		return nil, nil
+	}
+
+	err = t.repository.TouchToken(ctx, &found.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
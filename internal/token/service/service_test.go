@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/token"
+	"social_network_project/internal/utils/crypto"
+	"social_network_project/internal/utils/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tokenRepositoryFake struct {
+	token.TokenRepository
+	tokens  map[string]*token.Token
+	touched []string
+}
+
+func (f *tokenRepositoryFake) InsertToken(ctx context.Context, t *token.Token) error {
+	f.tokens[t.TokenHash] = t
+	return nil
+}
+
+func (f *tokenRepositoryFake) FindTokenByHash(ctx context.Context, tokenHash *string) (*token.Token, error) {
+	return f.tokens[*tokenHash], nil
+}
+
+func (f *tokenRepositoryFake) RevokeTokenByID(ctx context.Context, id, accountID *string) (*token.Token, error) {
+	for hash, t := range f.tokens {
+		if t.ID == *id && t.AccountID == *accountID {
+			delete(f.tokens, hash)
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *tokenRepositoryFake) TouchToken(ctx context.Context, id *string, at time.Time) error {
+	f.touched = append(f.touched, *id)
+	return nil
+}
+
+func TestTokenService(t *testing.T) {
+
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	repository := &tokenRepositoryFake{tokens: map[string]*token.Token{}}
+	tokenService := &TokenService{repository: repository, now: func() time.Time { return now }}
+
+	t.Run("creates a token stored only by its hash", func(t *testing.T) {
+		created, err := tokenService.CreateToken(context.Background(), &token.Token{
+			ID:        "t1",
+			AccountID: "a1",
+			Name:      "deploy script",
+			Scopes:    []string{token.SCOPE_READ_POSTS},
+		}, 30)
+		assert.Nil(t, err)
+
+		assert.True(t, strings.HasPrefix(created.Token, token.Prefix))
+		assert.True(t, strings.HasPrefix(created.Token, created.Prefix))
+		assert.Equal(t, now.AddDate(0, 0, 30).Format(time.RFC3339), created.ExpiresAt)
+
+		stored := repository.tokens[crypto.HashToken(created.Token)]
+		assert.NotNil(t, stored)
+		assert.NotEqual(t, created.Token, stored.TokenHash)
+
+		authenticated, err := tokenService.Authenticate(context.Background(), created.Token)
+		assert.Nil(t, err)
+		assert.Equal(t, "a1", authenticated.AccountID)
+		assert.Equal(t, []string{"t1"}, repository.touched)
+	})
+
+	t.Run("rejects unknown and expired tokens", func(t *testing.T) {
+		created, err := tokenService.CreateToken(context.Background(), &token.Token{ID: "t2", AccountID: "a1", Name: "old"}, 1)
+		assert.Nil(t, err)
+
+		authenticated, err := tokenService.Authenticate(context.Background(), token.Prefix+"unknown")
+		assert.Nil(t, err)
+		assert.Nil(t, authenticated)
+
+		tokenService.now = func() time.Time { return now.AddDate(0, 0, 1) }
+		defer func() { tokenService.now = func() time.Time { return now } }()
+
+		authenticated, err = tokenService.Authenticate(context.Background(), created.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, authenticated)
+	})
+
+	t.Run("revokes a token of the account", func(t *testing.T) {
+		created, err := tokenService.CreateToken(context.Background(), &token.Token{ID: "t3", AccountID: "a1", Name: "ci"}, 0)
+		assert.Nil(t, err)
+		assert.Empty(t, created.ExpiresAt)
+
+		id, other := "t3", "a2"
+		_, err = tokenService.RevokeTokenByID(context.Background(), &id, &other)
+		assert.IsType(t, &errors.NotFoundTokenIDError{}, err)
+
+		account := "a1"
+		_, err = tokenService.RevokeTokenByID(context.Background(), &id, &account)
+		assert.Nil(t, err)
+
+		authenticated, err := tokenService.Authenticate(context.Background(), created.Token)
+		assert.Nil(t, err)
+		assert.Nil(t, authenticated)
+	})
+}
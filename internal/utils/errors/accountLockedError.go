@@ -0,0 +1,28 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type AccountLockedError struct {
+	Path       string
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("Account locked after too many failed sign in attempts, use the unlock code sent to you or retry later" + e.Path)
+}
+
+func (e *AccountLockedError) Code() string {
+	return "account_locked"
+}
+
+func (e *AccountLockedError) StatusCode() int {
+	return http.StatusLocked
+}
+
+func (e *AccountLockedError) RetryIn() time.Duration {
+	return e.RetryAfter
+}
@@ -0,0 +1,21 @@
+package errors
+
+import "net/http"
+
+// BadRequestError is a request missing or with an invalid parameter, told in
+// Message.
+type BadRequestError struct {
+	Message string
+}
+
+func (e *BadRequestError) Error() string {
+	return e.Message
+}
+
+func (e *BadRequestError) Code() string {
+	return "bad_request"
+}
+
+func (e *BadRequestError) StatusCode() int {
+	return http.StatusBadRequest
+}
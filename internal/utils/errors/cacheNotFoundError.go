@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type CacheNotFoundError struct {
 	Path string
@@ -9,3 +12,11 @@ type CacheNotFoundError struct {
 func (e *CacheNotFoundError) Error() string {
 	return fmt.Sprintf("Not found in cache" + e.Path)
 }
+
+func (e *CacheNotFoundError) Code() string {
+	return "cache_not_found"
+}
+
+func (e *CacheNotFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ConflictAlreadyFollowError struct {
 	Path string
@@ -9,3 +12,11 @@ type ConflictAlreadyFollowError struct {
 func (e *ConflictAlreadyFollowError) Error() string {
 	return fmt.Sprintf("Already follow" + e.Path)
 }
+
+func (e *ConflictAlreadyFollowError) Code() string {
+	return "already_following"
+}
+
+func (e *ConflictAlreadyFollowError) StatusCode() int {
+	return http.StatusConflict
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ConflictAlreadyUnfollowError struct {
 	Path string
@@ -9,3 +12,11 @@ type ConflictAlreadyUnfollowError struct {
 func (e *ConflictAlreadyUnfollowError) Error() string {
 	return fmt.Sprintf("Already unfollow" + e.Path)
 }
+
+func (e *ConflictAlreadyUnfollowError) Code() string {
+	return "already_unfollowed"
+}
+
+func (e *ConflictAlreadyUnfollowError) StatusCode() int {
+	return http.StatusConflict
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ConflictAlreadyWriteError struct {
 	Path string
@@ -9,3 +12,11 @@ type ConflictAlreadyWriteError struct {
 func (e *ConflictAlreadyWriteError) Error() string {
 	return fmt.Sprintf("Already interacted" + e.Path)
 }
+
+func (e *ConflictAlreadyWriteError) Code() string {
+	return "already_interacted"
+}
+
+func (e *ConflictAlreadyWriteError) StatusCode() int {
+	return http.StatusConflict
+}
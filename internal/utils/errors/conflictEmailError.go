@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ConflictEmailError struct {
 	Path string
@@ -9,3 +12,11 @@ type ConflictEmailError struct {
 func (e *ConflictEmailError) Error() string {
 	return fmt.Sprintf("Email already exists" + e.Path)
 }
+
+func (e *ConflictEmailError) Code() string {
+	return "email_taken"
+}
+
+func (e *ConflictEmailError) StatusCode() int {
+	return http.StatusConflict
+}
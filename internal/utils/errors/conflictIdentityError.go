@@ -0,0 +1,25 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ConflictIdentityError is returned when a provider identity would sign in to
+// an account it is not linked to: the account of the same email, which must
+// link the provider itself, or another account than the one linking it.
+type ConflictIdentityError struct {
+	Path string
+}
+
+func (e *ConflictIdentityError) Error() string {
+	return fmt.Sprintf("The provider account is not linked to this account, sign in to link it" + e.Path)
+}
+
+func (e *ConflictIdentityError) Code() string {
+	return "identity_not_linked"
+}
+
+func (e *ConflictIdentityError) StatusCode() int {
+	return http.StatusConflict
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type ConflictLastAdminError struct {
+	Path string
+}
+
+func (e *ConflictLastAdminError) Error() string {
+	return fmt.Sprintf("The last admin cannot be demoted" + e.Path)
+}
+
+func (e *ConflictLastAdminError) Code() string {
+	return "last_admin"
+}
+
+func (e *ConflictLastAdminError) StatusCode() int {
+	return http.StatusConflict
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type ConflictOwnRoleError struct {
+	Path string
+}
+
+func (e *ConflictOwnRoleError) Error() string {
+	return fmt.Sprintf("You cannot change your own role" + e.Path)
+}
+
+func (e *ConflictOwnRoleError) Code() string {
+	return "own_role"
+}
+
+func (e *ConflictOwnRoleError) StatusCode() int {
+	return http.StatusConflict
+}
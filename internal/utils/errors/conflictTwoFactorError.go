@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type ConflictTwoFactorError struct {
+	Path string
+}
+
+func (e *ConflictTwoFactorError) Error() string {
+	return fmt.Sprintf("Two-factor authentication is already enabled" + e.Path)
+}
+
+func (e *ConflictTwoFactorError) Code() string {
+	return "two_factor_enabled"
+}
+
+func (e *ConflictTwoFactorError) StatusCode() int {
+	return http.StatusConflict
+}
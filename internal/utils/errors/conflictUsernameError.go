@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type ConflictUsernameError struct {
 	Path string
@@ -9,3 +12,11 @@ type ConflictUsernameError struct {
 func (e *ConflictUsernameError) Error() string {
 	return fmt.Sprintf("User already exists" + e.Path)
 }
+
+func (e *ConflictUsernameError) Code() string {
+	return "username_taken"
+}
+
+func (e *ConflictUsernameError) StatusCode() int {
+	return http.StatusConflict
+}
@@ -0,0 +1,23 @@
+package errors
+
+import "time"
+
+// Error is a domain error answered to clients: Code names it in a stable,
+// machine readable way and StatusCode is the HTTP status answering it. Any
+// other error is answered as an internal error.
+type Error interface {
+	error
+	Code() string
+	StatusCode() int
+}
+
+// Detailed errors add details to their answer, like the invalid fields of a
+// request.
+type Detailed interface {
+	Details() interface{}
+}
+
+// Retryable errors tell the client how long to wait before retrying.
+type Retryable interface {
+	RetryIn() time.Duration
+}
@@ -0,0 +1,23 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ForbiddenPermissionError names in Path the permission the role is missing.
+type ForbiddenPermissionError struct {
+	Path string
+}
+
+func (e *ForbiddenPermissionError) Error() string {
+	return fmt.Sprintf("Role does not grant the permission " + e.Path)
+}
+
+func (e *ForbiddenPermissionError) Code() string {
+	return "missing_permission"
+}
+
+func (e *ForbiddenPermissionError) StatusCode() int {
+	return http.StatusForbidden
+}
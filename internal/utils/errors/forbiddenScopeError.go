@@ -0,0 +1,27 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ForbiddenScopeError names in Path the scope the token is missing, or is
+// empty when the route only accepts a signed in session.
+type ForbiddenScopeError struct {
+	Path string
+}
+
+func (e *ForbiddenScopeError) Error() string {
+	if e.Path == "" {
+		return "Personal access tokens are not accepted here, sign in"
+	}
+	return fmt.Sprintf("Token does not grant the scope " + e.Path)
+}
+
+func (e *ForbiddenScopeError) Code() string {
+	return "missing_scope"
+}
+
+func (e *ForbiddenScopeError) StatusCode() int {
+	return http.StatusForbidden
+}
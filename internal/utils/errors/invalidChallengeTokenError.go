@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidChallengeTokenError struct {
+	Path string
+}
+
+func (e *InvalidChallengeTokenError) Error() string {
+	return fmt.Sprintf("Invalid or expired challenge token" + e.Path)
+}
+
+func (e *InvalidChallengeTokenError) Code() string {
+	return "invalid_challenge_token"
+}
+
+func (e *InvalidChallengeTokenError) StatusCode() int {
+	return http.StatusUnauthorized
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidCredentialsError struct {
+	Path string
+}
+
+func (e *InvalidCredentialsError) Error() string {
+	return fmt.Sprintf("Invalid email or password" + e.Path)
+}
+
+func (e *InvalidCredentialsError) Code() string {
+	return "invalid_credentials"
+}
+
+func (e *InvalidCredentialsError) StatusCode() int {
+	return http.StatusUnauthorized
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidOIDCLoginError struct {
+	Path string
+}
+
+func (e *InvalidOIDCLoginError) Error() string {
+	return fmt.Sprintf("Sign in with the provider failed" + e.Path)
+}
+
+func (e *InvalidOIDCLoginError) Code() string {
+	return "oidc_login_failed"
+}
+
+func (e *InvalidOIDCLoginError) StatusCode() int {
+	return http.StatusUnauthorized
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidTokenError struct {
+	Path string
+}
+
+func (e *InvalidTokenError) Error() string {
+	return fmt.Sprintf("Token Invalid" + e.Path)
+}
+
+func (e *InvalidTokenError) Code() string {
+	return "invalid_token"
+}
+
+func (e *InvalidTokenError) StatusCode() int {
+	return http.StatusUnauthorized
+}
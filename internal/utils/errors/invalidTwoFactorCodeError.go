@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidTwoFactorCodeError struct {
+	Path string
+}
+
+func (e *InvalidTwoFactorCodeError) Error() string {
+	return fmt.Sprintf("Invalid two-factor code" + e.Path)
+}
+
+func (e *InvalidTwoFactorCodeError) Code() string {
+	return "invalid_two_factor_code"
+}
+
+func (e *InvalidTwoFactorCodeError) StatusCode() int {
+	return http.StatusUnauthorized
+}
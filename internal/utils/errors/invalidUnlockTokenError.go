@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidUnlockTokenError struct {
+	Path string
+}
+
+func (e *InvalidUnlockTokenError) Error() string {
+	return fmt.Sprintf("Invalid or expired unlock code" + e.Path)
+}
+
+func (e *InvalidUnlockTokenError) Code() string {
+	return "invalid_unlock_token"
+}
+
+func (e *InvalidUnlockTokenError) StatusCode() int {
+	return http.StatusBadRequest
+}
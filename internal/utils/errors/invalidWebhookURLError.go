@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type InvalidWebhookURLError struct {
+	Path string
+}
+
+func (e *InvalidWebhookURLError) Error() string {
+	return fmt.Sprintf("Webhook URL must be a public address" + e.Path)
+}
+
+func (e *InvalidWebhookURLError) Code() string {
+	return "invalid_webhook_url"
+}
+
+func (e *InvalidWebhookURLError) StatusCode() int {
+	return http.StatusBadRequest
+}
@@ -0,0 +1,16 @@
+package errors
+
+import (
+	"database/sql"
+	errors2 "errors"
+)
+
+// NotFound returns notFound when err tells that the row looked up does not
+// exist, and err otherwise, so that a failing database is not answered as a
+// missing resource.
+func NotFound(err error, notFound Error) error {
+	if errors2.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	return err
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type NotFoundAccountIDError struct {
 	Path string
@@ -9,3 +12,11 @@ type NotFoundAccountIDError struct {
 func (e *NotFoundAccountIDError) Error() string {
 	return fmt.Sprintf("Account ID does not exist" + e.Path)
 }
+
+func (e *NotFoundAccountIDError) Code() string {
+	return "account_not_found"
+}
+
+func (e *NotFoundAccountIDError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type NotFoundCommentIDError struct {
 	Path string
@@ -9,3 +12,11 @@ type NotFoundCommentIDError struct {
 func (e *NotFoundCommentIDError) Error() string {
 	return fmt.Sprintf("Comment ID does not exist" + e.Path)
 }
+
+func (e *NotFoundCommentIDError) Code() string {
+	return "comment_not_found"
+}
+
+func (e *NotFoundCommentIDError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -1,11 +0,0 @@
-package errors
-
-import "fmt"
-
-type NotFoundEmailError struct {
-	Path string
-}
-
-func (e *NotFoundEmailError) Error() string {
-	return fmt.Sprintf("Incorrect email" + e.Path)
-}
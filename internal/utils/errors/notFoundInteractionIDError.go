@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type NotFoundInteractionIDError struct {
 	Path string
@@ -9,3 +12,11 @@ type NotFoundInteractionIDError struct {
 func (e *NotFoundInteractionIDError) Error() string {
 	return fmt.Sprintf("Interaction ID does not exist" + e.Path)
 }
+
+func (e *NotFoundInteractionIDError) Code() string {
+	return "interaction_not_found"
+}
+
+func (e *NotFoundInteractionIDError) StatusCode() int {
+	return http.StatusNotFound
+}
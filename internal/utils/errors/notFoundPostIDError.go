@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type NotFoundPostIDError struct {
 	Path string
@@ -9,3 +12,11 @@ type NotFoundPostIDError struct {
 func (e *NotFoundPostIDError) Error() string {
 	return fmt.Sprintf("Post ID does not exist" + e.Path)
 }
+
+func (e *NotFoundPostIDError) Code() string {
+	return "post_not_found"
+}
+
+func (e *NotFoundPostIDError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundProviderError struct {
+	Path string
+}
+
+func (e *NotFoundProviderError) Error() string {
+	return fmt.Sprintf("Provider not found" + e.Path)
+}
+
+func (e *NotFoundProviderError) Code() string {
+	return "provider_not_found"
+}
+
+func (e *NotFoundProviderError) StatusCode() int {
+	return http.StatusNotFound
+}
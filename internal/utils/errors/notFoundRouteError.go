@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundRouteError struct {
+	Path string
+}
+
+func (e *NotFoundRouteError) Error() string {
+	return fmt.Sprintf("Route not found" + e.Path)
+}
+
+func (e *NotFoundRouteError) Code() string {
+	return "route_not_found"
+}
+
+func (e *NotFoundRouteError) StatusCode() int {
+	return http.StatusNotFound
+}
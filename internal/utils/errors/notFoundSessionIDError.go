@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundSessionIDError struct {
+	Path string
+}
+
+func (e *NotFoundSessionIDError) Error() string {
+	return fmt.Sprintf("Session ID not found" + e.Path)
+}
+
+func (e *NotFoundSessionIDError) Code() string {
+	return "session_not_found"
+}
+
+func (e *NotFoundSessionIDError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundTokenIDError struct {
+	Path string
+}
+
+func (e *NotFoundTokenIDError) Error() string {
+	return fmt.Sprintf("Token ID does not exist" + e.Path)
+}
+
+func (e *NotFoundTokenIDError) Code() string {
+	return "token_not_found"
+}
+
+func (e *NotFoundTokenIDError) StatusCode() int {
+	return http.StatusNotFound
+}
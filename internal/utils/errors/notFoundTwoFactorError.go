@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundTwoFactorError struct {
+	Path string
+}
+
+func (e *NotFoundTwoFactorError) Error() string {
+	return fmt.Sprintf("Two-factor authentication is not enabled" + e.Path)
+}
+
+func (e *NotFoundTwoFactorError) Code() string {
+	return "two_factor_not_enabled"
+}
+
+func (e *NotFoundTwoFactorError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type NotFoundWebhookIDError struct {
+	Path string
+}
+
+func (e *NotFoundWebhookIDError) Error() string {
+	return fmt.Sprintf("Webhook ID does not exist" + e.Path)
+}
+
+func (e *NotFoundWebhookIDError) Code() string {
+	return "webhook_not_found"
+}
+
+func (e *NotFoundWebhookIDError) StatusCode() int {
+	return http.StatusNotFound
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type PreconditionFailedError struct {
+	Path string
+}
+
+func (e *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("Resource was modified, get it again and retry" + e.Path)
+}
+
+func (e *PreconditionFailedError) Code() string {
+	return "precondition_failed"
+}
+
+func (e *PreconditionFailedError) StatusCode() int {
+	return http.StatusPreconditionFailed
+}
@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type ServiceUnavailableError struct {
+	Path string
+}
+
+func (e *ServiceUnavailableError) Error() string {
+	return fmt.Sprintf("Service Unavailable" + e.Path)
+}
+
+func (e *ServiceUnavailableError) Code() string {
+	return "service_unavailable"
+}
+
+func (e *ServiceUnavailableError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,28 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type TooManyLoginAttemptsError struct {
+	Path       string
+	RetryAfter time.Duration
+}
+
+func (e *TooManyLoginAttemptsError) Error() string {
+	return fmt.Sprintf("Too many failed sign in attempts, retry later" + e.Path)
+}
+
+func (e *TooManyLoginAttemptsError) Code() string {
+	return "too_many_login_attempts"
+}
+
+func (e *TooManyLoginAttemptsError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (e *TooManyLoginAttemptsError) RetryIn() time.Duration {
+	return e.RetryAfter
+}
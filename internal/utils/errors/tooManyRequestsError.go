@@ -0,0 +1,28 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type TooManyRequestsError struct {
+	Path       string
+	RetryAfter time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	return fmt.Sprintf("Too many requests, retry later" + e.Path)
+}
+
+func (e *TooManyRequestsError) Code() string {
+	return "rate_limited"
+}
+
+func (e *TooManyRequestsError) StatusCode() int {
+	return http.StatusTooManyRequests
+}
+
+func (e *TooManyRequestsError) RetryIn() time.Duration {
+	return e.RetryAfter
+}
@@ -1,6 +1,9 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 type UnauthorizedAccountIDError struct {
 	Path string
@@ -9,3 +12,11 @@ type UnauthorizedAccountIDError struct {
 func (e *UnauthorizedAccountIDError) Error() string {
 	return fmt.Sprintf("Unauthorized ID" + e.Path)
 }
+
+func (e *UnauthorizedAccountIDError) Code() string {
+	return "not_owner"
+}
+
+func (e *UnauthorizedAccountIDError) StatusCode() int {
+	return http.StatusForbidden
+}
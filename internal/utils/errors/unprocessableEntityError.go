@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type UnprocessableEntityError struct {
+	Path string
+}
+
+func (e *UnprocessableEntityError) Error() string {
+	return fmt.Sprintf("Unprocessable Entity" + e.Path)
+}
+
+func (e *UnprocessableEntityError) Code() string {
+	return "unprocessable_entity"
+}
+
+func (e *UnprocessableEntityError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
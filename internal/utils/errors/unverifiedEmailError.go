@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type UnverifiedEmailError struct {
+	Path string
+}
+
+func (e *UnverifiedEmailError) Error() string {
+	return fmt.Sprintf("The provider has not verified the email" + e.Path)
+}
+
+func (e *UnverifiedEmailError) Code() string {
+	return "unverified_email"
+}
+
+func (e *UnverifiedEmailError) StatusCode() int {
+	return http.StatusForbidden
+}
@@ -0,0 +1,25 @@
+package errors
+
+import "net/http"
+
+// ValidationError holds in Fields what is wrong with each invalid field of a
+// request.
+type ValidationError struct {
+	Fields interface{}
+}
+
+func (e *ValidationError) Error() string {
+	return "Invalid fields"
+}
+
+func (e *ValidationError) Code() string {
+	return "validation_failed"
+}
+
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e *ValidationError) Details() interface{} {
+	return e.Fields
+}
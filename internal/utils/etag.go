@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"social_network_project/internal/utils/errors"
+	"strings"
+)
+
+// NewETag returns a strong entity tag of a response body.
+func NewETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// ETagOf returns the entity tag of obj rendered as a JSON response.
+func ETagOf(obj any) (string, error) {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return NewETag(body), nil
+}
+
+// MatchETag reports whether an If-Match or If-None-Match header value lists
+// etag. If-None-Match uses the weak comparison, where W/ prefixes are ignored.
+func MatchETag(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIfMatch fails with PreconditionFailedError when an If-Match header was
+// sent and does not list the entity tag of current.
+func CheckIfMatch(ifMatch string, current any) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	etag, err := ETagOf(current)
+	if err != nil {
+		return err
+	}
+	if !MatchETag(ifMatch, etag, false) {
+		return &errors.PreconditionFailedError{}
+	}
+	return nil
+}
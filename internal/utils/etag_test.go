@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"social_network_project/internal/utils/errors"
+	"testing"
+)
+
+func TestMatchETag(t *testing.T) {
+	etag := NewETag([]byte(`{"id":"1"}`))
+
+	t.Run("strong", func(t *testing.T) {
+		assert.True(t, MatchETag(etag, etag, false))
+		assert.True(t, MatchETag(`"other", `+etag, etag, false))
+		assert.True(t, MatchETag("*", etag, false))
+		assert.False(t, MatchETag("W/"+etag, etag, false))
+		assert.False(t, MatchETag(`"other"`, etag, false))
+	})
+	t.Run("weak", func(t *testing.T) {
+		assert.True(t, MatchETag("W/"+etag, etag, true))
+		assert.False(t, MatchETag(`W/"other"`, etag, true))
+	})
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	current := map[string]string{"id": "1"}
+	etag, err := ETagOf(current)
+	assert.Nil(t, err)
+	assert.Equal(t, NewETag([]byte(`{"id":"1"}`)), etag)
+
+	assert.Nil(t, CheckIfMatch("", current))
+	assert.Nil(t, CheckIfMatch(etag, current))
+	assert.IsType(t, &errors.PreconditionFailedError{}, CheckIfMatch(`"stale"`, current))
+}
@@ -0,0 +1,95 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Codes follow RFC 6238 with the parameters authenticator apps assume:
+// HMAC-SHA1, 6 digits and 30 second steps.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random 160 bit secret in base32.
+func NewSecret() (string, error) {
+	bytes := make([]byte, 20)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(bytes), nil
+}
+
+// URI returns the otpauth URI that authenticator apps read from a QR code.
+func URI(issuer, accountName, secret string) string {
+	label := url.PathEscape(issuer + ":" + accountName)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period.Seconds())))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Step returns the time step t falls in.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// Code returns the code of secret for time step step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return generate(key, uint64(step), Digits), nil
+}
+
+// Validate returns the time step code matches, looking skew steps before and
+// after t to allow for clock drift, or false when it matches none.
+func Validate(secret, code string, t time.Time, skew int) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	current := Step(t)
+	for i := -skew; i <= skew; i++ {
+		step := current + int64(i)
+		if hmac.Equal([]byte(generate(key, uint64(step), Digits)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generate is the HOTP value of RFC 4226 for counter.
+func generate(key []byte, counter uint64, digits int) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
@@ -0,0 +1,63 @@
+package totp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	// Test vectors of RFC 6238 appendix B for SHA1.
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+
+	for unix, code := range vectors {
+		assert.Equal(t, code, generate(key, uint64(Step(time.Unix(unix, 0))), 8))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	t.Run("accepts the current step and the skew", func(t *testing.T) {
+		code, err := Code(secret, Step(now))
+		assert.Nil(t, err)
+		assert.Equal(t, "050471", code)
+
+		step, valid := Validate(secret, code, now, 1)
+		assert.True(t, valid)
+		assert.Equal(t, Step(now), step)
+
+		_, valid = Validate(secret, code, now.Add(Period), 1)
+		assert.True(t, valid)
+		_, valid = Validate(secret, code, now.Add(2*Period), 1)
+		assert.False(t, valid)
+	})
+
+	t.Run("rejects malformed codes", func(t *testing.T) {
+		_, valid := Validate(secret, "05047", now, 1)
+		assert.False(t, valid)
+		_, valid = Validate(secret, "", now, 1)
+		assert.False(t, valid)
+	})
+}
+
+func TestNewSecretAndURI(t *testing.T) {
+	secret, err := NewSecret()
+	assert.Nil(t, err)
+	assert.Equal(t, 32, len(secret))
+
+	uri := URI("social_network", "ana@mail.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/social_network:ana@mail.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=social_network")
+}
@@ -5,33 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"github.com/golang-jwt/jwt/v4"
 	"io"
 	"io/ioutil"
-	"log"
-	"os"
-	"strconv"
+	"sort"
 	"strings"
 )
 
-func GetStringEnvOrElse(envName string, defaultValue string) string {
-	value, found := os.LookupEnv(envName)
-	if !found {
-		value = defaultValue
-	}
-	return value
-}
-
-func GetIntEnvOrElse(envName string, defaultValue int) (value int) {
-	valueStr, found := os.LookupEnv(envName)
-	if !found {
-		value = defaultValue
-	} else {
-		value, _ = strconv.Atoi(valueStr)
-	}
-	return value
-}
-
 func NewNullString(s string) sql.NullString {
 	if len(s) == 0 {
 		return sql.NullString{}
@@ -42,11 +21,21 @@ func NewNullString(s string) sql.NullString {
 	}
 }
 
+func NewNullInt64(i int64) sql.NullInt64 {
+	if i == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{
+		Int64: i,
+		Valid: true,
+	}
+}
+
 func ReadBodyAndReturnMapBody(body io.ReadCloser) (map[string]interface{}, error) {
 
 	bodyByte, err := ioutil.ReadAll(body)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var mapBody map[string]interface{}
@@ -65,30 +54,21 @@ func StringNullable(str interface{}) string {
 	return str.(string)
 }
 
-func DecodeTokenAndReturnID(token string) (string, error) {
-
-	tokenStr := strings.ReplaceAll(token, "Bearer ", "")
-	tokenDecode := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(tokenStr, tokenDecode, func(tokenStr *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
-	})
-	if err != nil {
-		return "", err
-	}
-	id := tokenDecode["id"].(string)
-
-	return id, nil
-}
-
 func TransformMapInQueryParams(query map[string][]string) string {
 	if len(query) == 0 {
 		return ""
 	}
 
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	queryContent := new(bytes.Buffer)
 	fmt.Fprintf(queryContent, "?")
-	for key, value := range query {
-		fmt.Fprintf(queryContent, "%s=%s&", key, strings.Join(value, ""))
+	for _, key := range keys {
+		fmt.Fprintf(queryContent, "%s=%s&", key, strings.Join(query[key], ","))
 	}
 
 	return queryContent.String()[:len(queryContent.String())-1]
@@ -3,43 +3,13 @@ package utils
 import (
 	"database/sql"
 	"encoding/json"
-	"github.com/golang-jwt/jwt/v4"
 	"io"
-	"os"
-	"social_network_project/internal/auth"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestGetStringEnvOrElse(t *testing.T) {
-	t.Run("found", func(t *testing.T) {
-		os.Setenv("TEST", "value")
-		value := GetStringEnvOrElse("TEST", "not used")
-		assert.Equal(t, "value", value)
-	})
-	t.Run("not found", func(t *testing.T) {
-		os.Unsetenv("TEST")
-		value := GetStringEnvOrElse("TEST", "value")
-		assert.Equal(t, "value", value)
-	})
-}
-
-func TestGetIntEnvOrElse(t *testing.T) {
-	t.Run("found", func(t *testing.T) {
-		os.Setenv("TEST", "1")
-		value := GetIntEnvOrElse("TEST", 1)
-		assert.Equal(t, 1, value)
-	})
-	t.Run("not found", func(t *testing.T) {
-		os.Unsetenv("TEST")
-		value := GetIntEnvOrElse("TEST", 1)
-		assert.Equal(t, 1, value)
-	})
-}
-
 func TestReadBodyAndReturnMapBody(t *testing.T) {
 
 	body := `{"Message": "Hello World"}`
@@ -83,33 +53,16 @@ func TestStringNullable(t *testing.T) {
 
 }
 
-func TestDecodeTokenAndReturnID(t *testing.T) {
-	idString := "6c08496b-b721-4e06-b0b7-1905524c9da2"
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id":  idString,
-		"exp": time.Now().Add(time.Hour * 1).Unix(),
+func TestTransformMapInQueryParams(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		assert.Equal(t, "", TransformMapInQueryParams(map[string][]string{}))
 	})
-
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_TOKEN_KEY")))
-	assert.Nil(t, err)
-
-	tokenStructExpected := auth.AuthResponse{
-		Token: tokenString,
-	}
-
-	id, err := DecodeTokenAndReturnID(tokenStructExpected.Token)
-	assert.Nil(t, err)
-
-	tokenDecodeExpected := jwt.MapClaims{}
-	_, err = jwt.ParseWithClaims(tokenStructExpected.Token, tokenDecodeExpected, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_TOKEN_KEY")), nil
+	t.Run("sorted by key", func(t *testing.T) {
+		query := map[string][]string{
+			"page":       {"2"},
+			"account_id": {"6c08496b"},
+			"tag":        {"a", "b"},
+		}
+		assert.Equal(t, "?account_id=6c08496b&page=2&tag=a,b", TransformMapInQueryParams(query))
 	})
-	assert.Nil(t, err)
-
-	idExpected := tokenDecodeExpected["id"].(string)
-
-	idTest := *id
-
-	assert.Equal(t, idExpected, idTest)
 }
@@ -0,0 +1,90 @@
+package validate
+
+import (
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldError tells what is wrong with a field of a request: its path as sent
+// in the request body, the rule it broke with the parameters of that rule,
+// and a message for people.
+type FieldError struct {
+	Field   string            `json:"field"`
+	Rule    string            `json:"rule"`
+	Params  map[string]string `json:"params,omitempty"`
+	Message string            `json:"message"`
+}
+
+// FieldErrors turns the errors of validator.Struct into a FieldError for
+// each failure, with messages in language. Rules without a message of their
+// own get a generic one, so new tags need no code here.
+func FieldErrors(err error, language string) []FieldError {
+	failures, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
+	}
+
+	fieldErrors := make([]FieldError, 0, len(failures))
+	for _, failure := range failures {
+		fieldError := FieldError{
+			Field: fieldPath(failure.Namespace()),
+			Rule:  failure.Tag(),
+		}
+		if failure.Param() != "" {
+			fieldError.Params = map[string]string{"value": failure.Param()}
+		}
+		fieldError.Message = message(language, fieldError, kindOf(failure.Kind()))
+
+		fieldErrors = append(fieldErrors, fieldError)
+	}
+
+	return fieldErrors
+}
+
+// fieldPath drops the struct name from a namespace such as
+// "Webhook.EventTypes[1]" and names its fields as the request body does,
+// "event_types[1]".
+func fieldPath(namespace string) string {
+	parts := strings.Split(namespace, ".")
+	if len(parts) > 1 {
+		parts = parts[1:]
+	}
+	for i, part := range parts {
+		parts[i] = snakeCase(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// kindOf groups kinds by how a limit on them reads: characters of a string,
+// items of a list or the value of a number.
+func kindOf(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return ""
+}
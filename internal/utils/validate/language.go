@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Language picks the language of the catalog most preferred by an
+// Accept-Language header, such as "pt-BR,pt;q=0.9,en;q=0.8", matching on the
+// primary subtag. It is DefaultLanguage when none matches.
+func Language(acceptLanguage string) string {
+	language := DefaultLanguage
+	best := 0.0
+
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(strings.TrimSpace(tag), ";")
+		primary := strings.ToLower(strings.SplitN(parts[0], "-", 2)[0])
+
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				parsed, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					parsed = 0
+				}
+				quality = parsed
+			}
+		}
+
+		if _, found := catalog[primary]; found && quality > best {
+			language = primary
+			best = quality
+		}
+	}
+
+	return language
+}
@@ -0,0 +1,97 @@
+package validate
+
+import "strings"
+
+// DefaultLanguage is used when a request asks for no language this catalog
+// has.
+const DefaultLanguage = "en"
+
+// catalog holds the messages of each language by rule. A rule followed by a
+// kind, "gte.string", reads better for that kind than the rule alone. The
+// "default" message covers rules with none of their own.
+var catalog = map[string]map[string]string{
+	"en": {
+		"default":    "{field} is invalid",
+		"required":   "{field} is required",
+		"lowercase":  "{field} must be lowercase",
+		"email":      "{field} must be a valid email",
+		"url":        "{field} must be a valid URL",
+		"startswith": "{field} must start with {value}",
+		"oneof":      "{field} must be one of: {value}",
+		"len":        "{field} must be {value}",
+		"len.string": "{field} must be {value} characters long",
+		"len.items":  "{field} must have {value} items",
+		"gte":        "{field} must be {value} or greater",
+		"gte.string": "{field} must be at least {value} characters long",
+		"gte.items":  "{field} must have at least {value} items",
+		"min":        "{field} must be {value} or greater",
+		"min.string": "{field} must be at least {value} characters long",
+		"min.items":  "{field} must have at least {value} items",
+		"gt":         "{field} must be greater than {value}",
+		"gt.string":  "{field} must be longer than {value} characters",
+		"gt.items":   "{field} must have more than {value} items",
+		"lte":        "{field} must be {value} or less",
+		"lte.string": "{field} must be at most {value} characters long",
+		"lte.items":  "{field} must have at most {value} items",
+		"max":        "{field} must be {value} or less",
+		"max.string": "{field} must be at most {value} characters long",
+		"max.items":  "{field} must have at most {value} items",
+		"lt":         "{field} must be less than {value}",
+		"lt.string":  "{field} must be shorter than {value} characters",
+		"lt.items":   "{field} must have fewer than {value} items",
+	},
+	"pt": {
+		"default":    "{field} é inválido",
+		"required":   "{field} é obrigatório",
+		"lowercase":  "{field} deve estar em minúsculas",
+		"email":      "{field} deve ser um email válido",
+		"url":        "{field} deve ser uma URL válida",
+		"startswith": "{field} deve começar com {value}",
+		"oneof":      "{field} deve ser um de: {value}",
+		"len":        "{field} deve ser {value}",
+		"len.string": "{field} deve ter {value} caracteres",
+		"len.items":  "{field} deve ter {value} itens",
+		"gte":        "{field} deve ser {value} ou maior",
+		"gte.string": "{field} deve ter pelo menos {value} caracteres",
+		"gte.items":  "{field} deve ter pelo menos {value} itens",
+		"min":        "{field} deve ser {value} ou maior",
+		"min.string": "{field} deve ter pelo menos {value} caracteres",
+		"min.items":  "{field} deve ter pelo menos {value} itens",
+		"gt":         "{field} deve ser maior que {value}",
+		"gt.string":  "{field} deve ter mais de {value} caracteres",
+		"gt.items":   "{field} deve ter mais de {value} itens",
+		"lte":        "{field} deve ser {value} ou menor",
+		"lte.string": "{field} deve ter no máximo {value} caracteres",
+		"lte.items":  "{field} deve ter no máximo {value} itens",
+		"max":        "{field} deve ser {value} ou menor",
+		"max.string": "{field} deve ter no máximo {value} caracteres",
+		"max.items":  "{field} deve ter no máximo {value} itens",
+		"lt":         "{field} deve ser menor que {value}",
+		"lt.string":  "{field} deve ter menos de {value} caracteres",
+		"lt.items":   "{field} deve ter menos de {value} itens",
+	},
+}
+
+// message renders the message of the rule broken by fieldError in language,
+// falling back to English for a message the language lacks.
+func message(language string, fieldError FieldError, kind string) string {
+	keys := []string{fieldError.Rule + "." + kind, fieldError.Rule, "default"}
+
+	var template string
+	for _, messages := range []map[string]string{catalog[language], catalog[DefaultLanguage]} {
+		for _, key := range keys {
+			if found, ok := messages[key]; ok {
+				template = found
+				break
+			}
+		}
+		if template != "" {
+			break
+		}
+	}
+
+	return strings.NewReplacer(
+		"{field}", fieldError.Field,
+		"{value}", fieldError.Params["value"],
+	).Replace(template)
+}
@@ -8,12 +8,13 @@ import (
 	model3 "social_network_project/internal/comment"
 	model2 "social_network_project/internal/interaction"
 	entities2 "social_network_project/internal/post"
+	"social_network_project/internal/token"
 	"social_network_project/internal/utils"
 	"testing"
 	"time"
 )
 
-func TestRequestValidate(t *testing.T) {
+func TestFieldErrors(t *testing.T) {
 	t.Run("Long username, Long name, Long description, Invalid email, Password only lowercase", func(t *testing.T) {
 		validate := validator.New()
 
@@ -26,9 +27,13 @@ func TestRequestValidate(t *testing.T) {
 			Password:    "23042L",
 		}
 		err := validate.Struct(account)
-		var expectedListString []string
-		expectedListString = append(expectedListString, "Long username", "Long name", "Long description", "Invalid email", "Password only lowercase")
-		assert.Equal(t, expectedListString, RequestAccountValidate(err))
+		assert.Equal(t, []FieldError{
+			{Field: "username", Rule: "lte", Params: map[string]string{"value": "12"}, Message: "username must be at most 12 characters long"},
+			{Field: "name", Rule: "lte", Params: map[string]string{"value": "24"}, Message: "name must be at most 24 characters long"},
+			{Field: "description", Rule: "lte", Params: map[string]string{"value": "140"}, Message: "description must be at most 140 characters long"},
+			{Field: "email", Rule: "email", Message: "email must be a valid email"},
+			{Field: "password", Rule: "lowercase", Message: "password must be lowercase"},
+		}, FieldErrors(err, "en"))
 	})
 	t.Run("Username only lowercase, Short name, Short password", func(t *testing.T) {
 		validate := validator.New()
@@ -42,9 +47,11 @@ func TestRequestValidate(t *testing.T) {
 			Password:    "23042",
 		}
 		err := validate.Struct(account)
-		var expectedListString []string
-		expectedListString = append(expectedListString, "Username only lowercase", "Short name", "Short password")
-		assert.Equal(t, expectedListString, RequestAccountValidate(err))
+		assert.Equal(t, []FieldError{
+			{Field: "username", Rule: "lowercase", Message: "username must be lowercase"},
+			{Field: "name", Rule: "gte", Params: map[string]string{"value": "3"}, Message: "name must be at least 3 characters long"},
+			{Field: "password", Rule: "gte", Params: map[string]string{"value": "6"}, Message: "password must be at least 6 characters long"},
+		}, FieldErrors(err, "en"))
 	})
 	t.Run("Short username, Long password", func(t *testing.T) {
 		validate := validator.New()
@@ -57,14 +64,15 @@ func TestRequestValidate(t *testing.T) {
 			Password:    "230423434343443434",
 		}
 		err := validate.Struct(account)
-		var expectedListString []string
-		expectedListString = append(expectedListString, "Short username", "Long password")
-		assert.Equal(t, expectedListString, RequestAccountValidate(err))
+		assert.Equal(t, []FieldError{
+			{Field: "username", Rule: "gte", Params: map[string]string{"value": "3"}, Message: "username deve ter pelo menos 3 caracteres"},
+			{Field: "password", Rule: "lte", Params: map[string]string{"value": "15"}, Message: "password deve ter no máximo 15 caracteres"},
+		}, FieldErrors(err, "pt"))
 	})
 
 }
 
-func TestRequestPostValidate(t *testing.T) {
+func TestFieldErrorsPost(t *testing.T) {
 	validate := validator.New()
 
 	var post = &entities2.Post{
@@ -77,16 +85,14 @@ func TestRequestPostValidate(t *testing.T) {
 	}
 
 	err := validate.Struct(post)
-	var expectedListString1 []string
-	expectedListString1 = append(expectedListString1, "Add ID", "Add content")
-
-	listString1 := RequestPostValidate(err)
-
-	assert.Equal(t, expectedListString1, listString1)
+	assert.Equal(t, []FieldError{
+		{Field: "id", Rule: "required", Message: "id is required"},
+		{Field: "content", Rule: "required", Message: "content is required"},
+	}, FieldErrors(err, "en"))
 
 }
 
-func TestRequestCommentValidate(t *testing.T) {
+func TestFieldErrorsComment(t *testing.T) {
 	validate := validator.New()
 
 	var comment = &model3.Comment{
@@ -101,16 +107,14 @@ func TestRequestCommentValidate(t *testing.T) {
 	}
 
 	err := validate.Struct(comment)
-	var expectedListString1 []string
-	expectedListString1 = append(expectedListString1, "Add ID", "Add content")
-
-	listString1 := RequestCommentValidate(err)
-
-	assert.Equal(t, expectedListString1, listString1)
+	assert.Equal(t, []FieldError{
+		{Field: "id", Rule: "required", Message: "id é obrigatório"},
+		{Field: "content", Rule: "required", Message: "content é obrigatório"},
+	}, FieldErrors(err, "pt"))
 
 }
 
-func TestRequestInteractionValidate(t *testing.T) {
+func TestFieldErrorsInteraction(t *testing.T) {
 	validate := validator.New()
 
 	var interaction = &model2.Interaction{
@@ -125,11 +129,51 @@ func TestRequestInteractionValidate(t *testing.T) {
 	}
 
 	err := validate.Struct(interaction)
-	var expectedListString1 []string
-	expectedListString1 = append(expectedListString1, "Add ID", "Incorrect type, insert LIKE or DISLIKE")
+	assert.Equal(t, []FieldError{
+		{Field: "id", Rule: "required", Message: "id is required"},
+		{Field: "type", Rule: "lte", Params: map[string]string{"value": "1"}, Message: "type must be 1 or less"},
+	}, FieldErrors(err, "en"))
 
-	listString1 := RequestInteractionValidate(err)
+}
 
-	assert.Equal(t, expectedListString1, listString1)
+func TestFieldErrorsToken(t *testing.T) {
+	validate := validator.New()
+
+	var accessToken = &token.Token{
+		ID:        uuid.New().String(),
+		AccountID: "f981d822-7efb-4e66-aa84-99f517820ca3",
+		Name:      "",
+		Scopes:    []string{"read:posts", "admin"},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := validate.Struct(accessToken)
+	fieldErrors := FieldErrors(err, "en")
+	assert.Len(t, fieldErrors, 2)
+	assert.Equal(t, FieldError{Field: "name", Rule: "required", Message: "name is required"}, fieldErrors[0])
+	assert.Equal(t, "scopes[1]", fieldErrors[1].Field)
+	assert.Equal(t, "oneof", fieldErrors[1].Rule)
+	assert.Contains(t, fieldErrors[1].Message, "scopes[1] must be one of: read:profile")
+
+}
+
+func TestFieldErrorsUnknownRule(t *testing.T) {
+	validate := validator.New()
+
+	var request = struct {
+		CallbackURL string `validate:"hostname"`
+	}{CallbackURL: "not a host"}
+
+	err := validate.Struct(request)
+	assert.Equal(t, []FieldError{
+		{Field: "callback_url", Rule: "hostname", Message: "callback_url é inválido"},
+	}, FieldErrors(err, "pt"))
+}
 
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "en", Language(""))
+	assert.Equal(t, "en", Language("fr-FR, de;q=0.8"))
+	assert.Equal(t, "pt", Language("pt-BR"))
+	assert.Equal(t, "pt", Language("fr;q=1, en;q=0.5, pt-PT;q=0.9"))
+	assert.Equal(t, "en", Language("pt;q=0.2, EN-us"))
 }
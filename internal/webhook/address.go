@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned when a webhook URL points at an address of
+// the network the API runs in.
+var ErrForbiddenAddress = errors.New("webhook: address is not public")
+
+// forbiddenNetworks are the ranges not covered by the net.IP methods used in
+// PublicIP: "this network", shared address space and benchmarking.
+var forbiddenNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("198.18.0.0/15"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// PublicIP reports whether ip may receive deliveries: it is none of the
+// loopback, private, link-local, multicast or unspecified addresses, through
+// which a webhook would reach the API's own network, such as the cloud
+// metadata service at 169.254.169.254.
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL returns ErrForbiddenAddress unless every address the host of
+// rawURL resolves to is public. Deliveries check the address again when they
+// connect, as the name can resolve elsewhere by then.
+func CheckURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !PublicIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if !PublicIP(address.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client sending deliveries. It refuses to connect to
+// addresses that are not public, whatever the URL resolved to when the
+// webhook was saved, and does not follow redirects, which could lead there.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
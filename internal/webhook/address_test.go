@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicIP(t *testing.T) {
+	for _, address := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1",
+		"169.254.169.254", "fe80::1", "fd00::1", "0.0.0.0", "100.64.0.1", "::ffff:127.0.0.1"} {
+		assert.False(t, PublicIP(net.ParseIP(address)), address)
+	}
+	for _, address := range []string{"93.184.216.34", "2606:2800:220:1::1"} {
+		assert.True(t, PublicIP(net.ParseIP(address)), address)
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	assert.Nil(t, CheckURL(context.Background(), "https://93.184.216.34/hooks"))
+	assert.Equal(t, ErrForbiddenAddress, CheckURL(context.Background(), "http://169.254.169.254/latest/meta-data"))
+	assert.Equal(t, ErrForbiddenAddress, CheckURL(context.Background(), "http://[::1]:8080/"))
+	assert.Equal(t, ErrForbiddenAddress, CheckURL(context.Background(), "http://localhost:8080/"))
+}
+
+func TestNewClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	// The server listens on loopback, as an internal service would.
+	_, err := NewClient(time.Second).Post(server.URL, "application/json", strings.NewReader("{}"))
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	DELIVERY_STATUS_PENDING   = "PENDING"
+	DELIVERY_STATUS_SUCCEEDED = "SUCCEEDED"
+	DELIVERY_STATUS_FAILED    = "FAILED"
+)
+
+type Webhook struct {
+	ID                  string `validate:"required"`
+	AccountID           string
+	URL                 string `validate:"required,url,startswith=http"`
+	Secret              string
+	EventTypes          []string `validate:"required,min=1,dive,oneof=post.created comment.created interaction.created account.followed"`
+	Active              bool
+	ConsecutiveFailures int
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+func (w *Webhook) ToResponse() WebhookResponse {
+	return WebhookResponse{
+		ID:                  w.ID,
+		AccountID:           w.AccountID,
+		URL:                 w.URL,
+		EventTypes:          w.EventTypes,
+		Active:              w.Active,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		CreatedAt:           w.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:           w.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+type Delivery struct {
+	ID             string
+	WebhookID      string
+	EventID        string
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int
+	ResponseStatus sql.NullInt64
+	Error          sql.NullString
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+
+	URL    string
+	Secret string
+}
+
+func (d *Delivery) ToResponse() DeliveryResponse {
+	return DeliveryResponse{
+		ID:             d.ID,
+		WebhookID:      d.WebhookID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: int(d.ResponseStatus.Int64),
+		Error:          d.Error.String,
+		NextAttemptAt:  d.NextAttemptAt.Format(time.RFC3339),
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      d.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,351 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"social_network_project/internal/platform/database/postgresql"
+	"time"
+)
+
+type WebhookRepository interface {
+	InsertWebhook(ctx context.Context, webhook *Webhook) error
+	FindWebhooksByAccountID(ctx context.Context, accountID *string) ([]interface{}, error)
+	FindWebhookByIDAndAccountID(ctx context.Context, id, accountID *string) (*Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *Webhook) error
+	RemoveWebhookByID(ctx context.Context, id, accountID *string) error
+	FindActiveWebhooksByAccountIDsAndEventType(ctx context.Context, accountIDs []string, eventType string) ([]*Webhook, error)
+	InsertDelivery(ctx context.Context, delivery *Delivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	RecordWebhookSuccess(ctx context.Context, id *string) error
+	RecordWebhookFailure(ctx context.Context, id *string, maxFailures int) error
+	FindDeliveriesByWebhookID(ctx context.Context, webhookID, page *string) ([]interface{}, error)
+}
+
+type WebhookRepositoryStruct struct {
+	Db *sql.DB
+}
+
+func NewWebhookRepository(postgresDB *sql.DB) WebhookRepository {
+	return &WebhookRepositoryStruct{postgresDB}
+}
+
+func (p *WebhookRepositoryStruct) InsertWebhook(ctx context.Context, webhook *Webhook) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "InsertWebhook")
+	defer end()
+	sqlStatement := `
+		INSERT INTO webhook (id, account_id, url, secret, event_types, active, consecutive_failures, created_at, updated_at, removed)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, false)`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, webhook.ID, webhook.AccountID, webhook.URL, webhook.Secret, pq.Array(webhook.EventTypes),
+		webhook.Active, webhook.ConsecutiveFailures, webhook.CreatedAt, webhook.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) FindWebhooksByAccountID(ctx context.Context, accountID *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "webhook", "FindWebhooksByAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT id, account_id, url, secret, event_types, active, consecutive_failures, created_at, updated_at
+		FROM webhook
+		WHERE account_id = $1
+		AND removed = false
+		ORDER BY created_at`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []interface{}{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, webhook.ToResponse())
+	}
+
+	return list, nil
+}
+
+func (p *WebhookRepositoryStruct) FindWebhookByIDAndAccountID(ctx context.Context, id, accountID *string) (*Webhook, error) {
+	ctx, end := postgresql.Observe(ctx, "webhook", "FindWebhookByIDAndAccountID")
+	defer end()
+	sqlStatement := `
+		SELECT id, account_id, url, secret, event_types, active, consecutive_failures, created_at, updated_at
+		FROM webhook
+		WHERE id = $1
+		AND account_id = $2
+		AND removed = false`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rows.Next()
+	return scanWebhook(rows)
+}
+
+func (p *WebhookRepositoryStruct) UpdateWebhook(ctx context.Context, webhook *Webhook) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "UpdateWebhook")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook
+		SET url = $1, event_types = $2, active = $3, consecutive_failures = $4, updated_at = $5
+		WHERE id = $6
+		AND account_id = $7
+		AND removed = false`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, webhook.URL, pq.Array(webhook.EventTypes), webhook.Active,
+		webhook.ConsecutiveFailures, webhook.UpdatedAt, webhook.ID, webhook.AccountID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) RemoveWebhookByID(ctx context.Context, id, accountID *string) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "RemoveWebhookByID")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook
+		SET removed = true
+		WHERE id = $1
+		AND account_id = $2`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, id, accountID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) FindActiveWebhooksByAccountIDsAndEventType(ctx context.Context, accountIDs []string, eventType string) ([]*Webhook, error) {
+	ctx, end := postgresql.Observe(ctx, "webhook", "FindActiveWebhooksByAccountIDsAndEventType")
+	defer end()
+	sqlStatement := `
+		SELECT id, account_id, url, secret, event_types, active, consecutive_failures, created_at, updated_at
+		FROM webhook
+		WHERE account_id = ANY($1)
+		AND $2 = ANY(event_types)
+		AND active = true
+		AND removed = false`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, pq.Array(accountIDs), eventType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, webhook)
+	}
+
+	return list, nil
+}
+
+func (p *WebhookRepositoryStruct) InsertDelivery(ctx context.Context, delivery *Delivery) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "InsertDelivery")
+	defer end()
+	sqlStatement := `
+		INSERT INTO webhook_delivery (id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, delivery.ID, delivery.WebhookID, delivery.EventID, delivery.EventType, delivery.Payload,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDueDeliveries returns pending deliveries of active webhooks whose next
+// attempt is due, pushing their next attempt lease into the future so other
+// dispatchers skip them while they are being sent.
+func (p *WebhookRepositoryStruct) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*Delivery, error) {
+	ctx, end := postgresql.Observe(ctx, "webhook", "ClaimDueDeliveries")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook_delivery
+		SET next_attempt_at = $2
+		FROM webhook
+		WHERE webhook.id = webhook_delivery.webhook_id
+		AND webhook_delivery.id IN (
+			SELECT wd.id
+			FROM webhook_delivery wd
+			INNER JOIN webhook w ON w.id = wd.webhook_id
+			WHERE wd.status = 'PENDING'
+			AND wd.next_attempt_at <= $1
+			AND w.active = true
+			AND w.removed = false
+			ORDER BY wd.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF wd SKIP LOCKED
+		)
+		RETURNING webhook_delivery.id, webhook_delivery.webhook_id, webhook_delivery.event_id, webhook_delivery.event_type,
+		webhook_delivery.payload, webhook_delivery.status, webhook_delivery.attempts, webhook_delivery.next_attempt_at,
+		webhook_delivery.created_at, webhook.url, webhook.secret`
+
+	now := time.Now().UTC()
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Delivery
+	for rows.Next() {
+		var delivery Delivery
+		err = rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, &delivery)
+	}
+
+	return list, nil
+}
+
+func (p *WebhookRepositoryStruct) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "UpdateDelivery")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook_delivery
+		SET status = $1, attempts = $2, response_status = $3, error = $4, next_attempt_at = $5, updated_at = $6
+		WHERE id = $7`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, delivery.Status, delivery.Attempts, delivery.ResponseStatus, delivery.Error,
+		delivery.NextAttemptAt, delivery.UpdatedAt, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) RecordWebhookSuccess(ctx context.Context, id *string) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "RecordWebhookSuccess")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook
+		SET consecutive_failures = 0
+		WHERE id = $1`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) RecordWebhookFailure(ctx context.Context, id *string, maxFailures int) error {
+	ctx, end := postgresql.Observe(ctx, "webhook", "RecordWebhookFailure")
+	defer end()
+	sqlStatement := `
+		UPDATE webhook
+		SET consecutive_failures = consecutive_failures + 1,
+		active = active AND consecutive_failures + 1 < $2
+		WHERE id = $1`
+
+	_, err := p.Db.ExecContext(ctx, sqlStatement, id, maxFailures)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *WebhookRepositoryStruct) FindDeliveriesByWebhookID(ctx context.Context, webhookID, page *string) ([]interface{}, error) {
+	ctx, end := postgresql.Observe(ctx, "webhook", "FindDeliveriesByWebhookID")
+	defer end()
+	sqlStatement := `
+		SELECT id, webhook_id, event_id, event_type, payload, status, attempts, response_status, error,
+		next_attempt_at, created_at, updated_at
+		FROM webhook_delivery
+		WHERE webhook_id = $1
+		Order By created_at DESC
+		OFFSET ($2 - 1) * 10
+		FETCH NEXT 10 ROWS ONLY;`
+
+	rows, err := p.Db.QueryContext(ctx, sqlStatement, webhookID, page)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []interface{}{}
+	var delivery Delivery
+	for rows.Next() {
+		err = rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.ResponseStatus,
+			&delivery.Error,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, delivery.ToResponse())
+	}
+
+	return list, nil
+}
+
+func scanWebhook(rows *sql.Rows) (*Webhook, error) {
+	var webhook Webhook
+	err := rows.Scan(
+		&webhook.ID,
+		&webhook.AccountID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.EventTypes),
+		&webhook.Active,
+		&webhook.ConsecutiveFailures,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
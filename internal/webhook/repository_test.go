@@ -0,0 +1,31 @@
+package webhook
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestClaimDueDeliveries(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+
+	lease := time.Now().UTC().Add(5 * time.Minute)
+	mock.ExpectQuery("UPDATE webhook_delivery").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "event_id", "event_type", "payload", "status", "attempts",
+			"next_attempt_at", "created_at", "url", "secret"}).
+			AddRow("d1", "w1", "e1", "post.created", "{}", DELIVERY_STATUS_PENDING, 2, lease, time.Now(), "https://example.com/hooks", "secret"))
+
+	repository := NewWebhookRepository(db)
+	deliveries, err := repository.ClaimDueDeliveries(context.Background(), 10, 5*time.Minute)
+
+	assert.Nil(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.Equal(t, lease, deliveries[0].NextAttemptAt)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,7 @@
+package webhook
+
+type WebhookRequest struct {
+	Id         string   `json:"id,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	EventTypes []string `json:"event_types,omitempty"`
+}
@@ -0,0 +1,27 @@
+package webhook
+
+type WebhookResponse struct {
+	ID                  string   `json:"id"`
+	AccountID           string   `json:"account_id"`
+	URL                 string   `json:"url"`
+	Secret              string   `json:"secret,omitempty"`
+	EventTypes          []string `json:"event_types"`
+	Active              bool     `json:"active"`
+	ConsecutiveFailures int      `json:"consecutive_failures"`
+	CreatedAt           string   `json:"created_at"`
+	UpdatedAt           string   `json:"updated_at"`
+}
+
+type DeliveryResponse struct {
+	ID             string `json:"id"`
+	WebhookID      string `json:"webhook_id"`
+	EventID        string `json:"event_id"`
+	EventType      string `json:"event_type"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	ResponseStatus int    `json:"response_status,omitempty"`
+	Error          string `json:"error,omitempty"`
+	NextAttemptAt  string `json:"next_attempt_at"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"social_network_project/internal/comment"
+	"social_network_project/internal/event"
+	"social_network_project/internal/platform/logging"
+	messagebroker "social_network_project/internal/platform/message-broker"
+	"social_network_project/internal/platform/tracing"
+	"social_network_project/internal/post"
+	"social_network_project/internal/utils"
+	"social_network_project/internal/utils/errors"
+	"social_network_project/internal/webhook"
+	"strconv"
+	"time"
+)
+
+const WebhookQueue = "WebhookQueue"
+
+const deliveryLease = 5 * time.Minute
+
+// DeliveryPolicy sends the deliveries due every Interval, BatchSize at a time,
+// giving up on a request after Timeout. A failed delivery is retried after
+// BaseBackoff, doubling up to MaxBackoff, until MaxAttempts, and a webhook is
+// disabled after MaxConsecutiveFailures failed deliveries in a row.
+type DeliveryPolicy struct {
+	Interval               time.Duration
+	BatchSize              int
+	Timeout                time.Duration
+	MaxAttempts            int
+	MaxConsecutiveFailures int
+	BaseBackoff            time.Duration
+	MaxBackoff             time.Duration
+}
+
+type WebhookServiceClient interface {
+	CreateWebhook(ctx context.Context, webhook *webhook.Webhook) (*webhook.WebhookResponse, error)
+	FindWebhooksByAccountID(ctx context.Context, accountID *string) ([]interface{}, error)
+	UpdateWebhook(ctx context.Context, webhook *webhook.Webhook) (*webhook.WebhookResponse, error)
+	RemoveWebhookByID(ctx context.Context, id, accountID *string) (*webhook.WebhookResponse, error)
+	FindDeliveriesByWebhookID(ctx context.Context, id, accountID, page *string) ([]interface{}, error)
+	PingWebhook(ctx context.Context, id, accountID *string) (*webhook.DeliveryResponse, error)
+	HandleEvent(ctx context.Context, envelope *event.Envelope) error
+	ConsumerMessage(ctx context.Context)
+	DeliverMessages(ctx context.Context)
+	DeliverPendingMessages(ctx context.Context) (int, error)
+}
+
+type WebhookService struct {
+	repositoryWebhook webhook.WebhookRepository
+	repositoryPost    post.PostRepository
+	repositoryComment comment.CommentRepository
+	broker            messagebroker.Subscriber
+	client            *http.Client
+	policy            DeliveryPolicy
+	logger            *slog.Logger
+}
+
+func NewWebhookService(_repositoryWebhook webhook.WebhookRepository, _repositoryPost post.PostRepository, _repositoryComment comment.CommentRepository,
+	_broker messagebroker.Subscriber, policy DeliveryPolicy, logger *slog.Logger) WebhookServiceClient {
+	return &WebhookService{
+		repositoryWebhook: _repositoryWebhook,
+		repositoryPost:    _repositoryPost,
+		repositoryComment: _repositoryComment,
+		broker:            _broker,
+		client:            webhook.NewClient(policy.Timeout),
+		policy:            policy,
+		logger:            logger,
+	}
+}
+
+// CreateWebhook saves a webhook with a new secret. Its URL must resolve to
+// public addresses only.
+func (w *WebhookService) CreateWebhook(ctx context.Context, webhookToCreate *webhook.Webhook) (*webhook.WebhookResponse, error) {
+
+	err := w.checkURL(ctx, webhookToCreate.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhookToCreate.Secret = secret
+
+	err = w.repositoryWebhook.InsertWebhook(ctx, webhookToCreate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := webhookToCreate.ToResponse()
+	response.Secret = webhookToCreate.Secret
+	return &response, nil
+}
+
+func (w *WebhookService) FindWebhooksByAccountID(ctx context.Context, accountID *string) ([]interface{}, error) {
+	return w.repositoryWebhook.FindWebhooksByAccountID(ctx, accountID)
+}
+
+// UpdateWebhook replaces the url and event types of a webhook and enables it
+// again if it was disabled after repeated failures.
+func (w *WebhookService) UpdateWebhook(ctx context.Context, webhook *webhook.Webhook) (*webhook.WebhookResponse, error) {
+
+	webhookToUpdate, err := w.repositoryWebhook.FindWebhookByIDAndAccountID(ctx, &webhook.ID, &webhook.AccountID)
+	if err != nil {
+		return nil, &errors.NotFoundWebhookIDError{}
+	}
+	err = w.checkURL(ctx, webhook.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookToUpdate.URL = webhook.URL
+	webhookToUpdate.EventTypes = webhook.EventTypes
+	webhookToUpdate.Active = true
+	webhookToUpdate.ConsecutiveFailures = 0
+	webhookToUpdate.UpdatedAt = webhook.UpdatedAt
+
+	err = w.repositoryWebhook.UpdateWebhook(ctx, webhookToUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	response := webhookToUpdate.ToResponse()
+	return &response, nil
+}
+
+func (w *WebhookService) RemoveWebhookByID(ctx context.Context, id, accountID *string) (*webhook.WebhookResponse, error) {
+
+	webhookToRemove, err := w.repositoryWebhook.FindWebhookByIDAndAccountID(ctx, id, accountID)
+	if err != nil {
+		return nil, &errors.NotFoundWebhookIDError{}
+	}
+
+	err = w.repositoryWebhook.RemoveWebhookByID(ctx, id, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := webhookToRemove.ToResponse()
+	return &response, nil
+}
+
+func (w *WebhookService) FindDeliveriesByWebhookID(ctx context.Context, id, accountID, page *string) ([]interface{}, error) {
+
+	_, err := w.repositoryWebhook.FindWebhookByIDAndAccountID(ctx, id, accountID)
+	if err != nil {
+		return nil, &errors.NotFoundWebhookIDError{}
+	}
+
+	return w.repositoryWebhook.FindDeliveriesByWebhookID(ctx, id, page)
+}
+
+// PingWebhook sends a webhook.ping event right away and reports the result.
+// Pings are not retried and do not count towards disabling the webhook. The
+// ping is saved already claimed, so that the dispatcher does not send it too.
+func (w *WebhookService) PingWebhook(ctx context.Context, id, accountID *string) (*webhook.DeliveryResponse, error) {
+
+	webhookToPing, err := w.repositoryWebhook.FindWebhookByIDAndAccountID(ctx, id, accountID)
+	if err != nil {
+		return nil, &errors.NotFoundWebhookIDError{}
+	}
+
+	envelope := event.New(*accountID, &event.WebhookPing{WebhookID: webhookToPing.ID})
+	delivery, err := w.newDelivery(webhookToPing, envelope)
+	if err != nil {
+		return nil, err
+	}
+	delivery.NextAttemptAt = delivery.CreatedAt.Add(deliveryLease)
+
+	err = w.repositoryWebhook.InsertDelivery(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.attempt(ctx, delivery, false)
+	if err != nil {
+		return nil, err
+	}
+
+	response := delivery.ToResponse()
+	return &response, nil
+}
+
+// HandleEvent schedules one delivery per active webhook subscribed to the
+// event type and owned by an account the event concerns. Deliveries are
+// unique per webhook and event, so handling an event twice is harmless.
+func (w *WebhookService) HandleEvent(ctx context.Context, envelope *event.Envelope) error {
+
+	webhooks, err := w.repositoryWebhook.FindActiveWebhooksByAccountIDsAndEventType(ctx, w.relatedAccountIDs(ctx, envelope), string(envelope.Type))
+	if err != nil {
+		return err
+	}
+
+	for _, webhookToDeliver := range webhooks {
+		delivery, err := w.newDelivery(webhookToDeliver, envelope)
+		if err != nil {
+			return err
+		}
+
+		err = w.repositoryWebhook.InsertDelivery(ctx, delivery)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConsumerMessage turns events into deliveries until ctx is done.
+func (w *WebhookService) ConsumerMessage(ctx context.Context) {
+	w.logger.Info("consuming messages", "queue", WebhookQueue)
+
+	err := w.broker.Subscribe(ctx, WebhookQueue, func(message *messagebroker.Message) error {
+		messageCtx, span := tracing.StartConsumer(WebhookQueue, message.Headers)
+		messageCtx = logging.WithRequestID(messageCtx, message.Headers[logging.RequestIDHeader])
+		w.logger.DebugContext(messageCtx, "message received", "queue", WebhookQueue, "redelivered", message.Redelivered)
+
+		envelope, err := event.Decode(message.Body)
+		if err == nil {
+			err = w.HandleEvent(messageCtx, envelope)
+		}
+		tracing.End(span, err)
+		if err != nil {
+			w.logger.ErrorContext(messageCtx, "handling message", "queue", WebhookQueue, "redelivered", message.Redelivered, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		w.logger.Error("consuming messages", "queue", WebhookQueue, "error", err)
+	}
+}
+
+// DeliverMessages sends the due deliveries every interval until ctx is done.
+// A batch being sent is finished first.
+func (w *WebhookService) DeliverMessages(ctx context.Context) {
+	ticker := time.NewTicker(w.policy.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			delivered, err := w.DeliverPendingMessages(context.Background())
+			if err != nil {
+				w.logger.Error("delivering webhooks", "error", err)
+				break
+			}
+			if delivered < w.policy.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (w *WebhookService) DeliverPendingMessages(ctx context.Context) (int, error) {
+
+	deliveries, err := w.repositoryWebhook.ClaimDueDeliveries(ctx, w.policy.BatchSize, deliveryLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		err = w.attempt(ctx, delivery, true)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+func (w *WebhookService) attempt(ctx context.Context, delivery *webhook.Delivery, retry bool) error {
+
+	now := time.Now().UTC()
+	statusCode, errSend := w.send(delivery, now)
+
+	delivery.Attempts++
+	delivery.UpdatedAt = now
+	delivery.ResponseStatus = utils.NewNullInt64(int64(statusCode))
+	delivery.Error = utils.NewNullString("")
+
+	if errSend == nil {
+		delivery.Status = webhook.DELIVERY_STATUS_SUCCEEDED
+		err := w.repositoryWebhook.UpdateDelivery(ctx, delivery)
+		if err != nil {
+			return err
+		}
+		if !retry {
+			return nil
+		}
+		return w.repositoryWebhook.RecordWebhookSuccess(ctx, &delivery.WebhookID)
+	}
+
+	// The delivery log is shown to the owner of the webhook, so it does not
+	// tell why a request failed, which would show what the API can reach.
+	w.logger.WarnContext(ctx, "delivering webhook", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID, "error", errSend)
+	delivery.Error = utils.NewNullString("request failed")
+	if statusCode != 0 {
+		delivery.Error = utils.NewNullString(fmt.Sprintf("unexpected status %d", statusCode))
+	}
+	delivery.Status = webhook.DELIVERY_STATUS_FAILED
+	if retry && delivery.Attempts < w.policy.MaxAttempts {
+		delivery.Status = webhook.DELIVERY_STATUS_PENDING
+		delivery.NextAttemptAt = now.Add(webhook.Backoff(delivery.Attempts, w.policy.BaseBackoff, w.policy.MaxBackoff))
+	}
+
+	err := w.repositoryWebhook.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		return err
+	}
+	if !retry {
+		return nil
+	}
+	return w.repositoryWebhook.RecordWebhookFailure(ctx, &delivery.WebhookID, w.policy.MaxConsecutiveFailures)
+}
+
+func (w *WebhookService) send(delivery *webhook.Delivery, now time.Time) (int, error) {
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "social-network-webhooks")
+	req.Header.Set("X-Webhook-ID", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", webhook.Sign(delivery.Secret, timestamp, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// checkURL answers InvalidWebhookURLError for URLs that do not resolve to
+// public addresses only.
+func (w *WebhookService) checkURL(ctx context.Context, rawURL string) error {
+	err := webhook.CheckURL(ctx, rawURL)
+	if err != nil {
+		w.logger.InfoContext(ctx, "rejecting webhook url", "url", rawURL, "error", err)
+		return &errors.InvalidWebhookURLError{}
+	}
+	return nil
+}
+
+func (w *WebhookService) newDelivery(webhookToDeliver *webhook.Webhook, envelope *event.Envelope) (*webhook.Delivery, error) {
+
+	payload, err := event.Encode(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &webhook.Delivery{
+		ID:            uuid.New().String(),
+		WebhookID:     webhookToDeliver.ID,
+		EventID:       envelope.ID,
+		EventType:     string(envelope.Type),
+		Payload:       string(payload),
+		Status:        webhook.DELIVERY_STATUS_PENDING,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		URL:           webhookToDeliver.URL,
+		Secret:        webhookToDeliver.Secret,
+	}, nil
+}
+
+// relatedAccountIDs lists the accounts whose webhooks receive the event: the
+// actor, and the owner of the post, comment or account the event points at.
+func (w *WebhookService) relatedAccountIDs(ctx context.Context, envelope *event.Envelope) []string {
+
+	accountIDs := []string{envelope.ActorID}
+
+	switch e := envelope.Payload.(type) {
+	case *event.CommentCreated:
+		accountIDs = append(accountIDs, w.postOwner(ctx, e.PostID), w.commentOwner(ctx, e.ParentCommentID))
+	case *event.InteractionCreated:
+		accountIDs = append(accountIDs, w.postOwner(ctx, e.PostID), w.commentOwner(ctx, e.CommentID))
+	case *event.AccountFollowed:
+		accountIDs = append(accountIDs, e.FollowedAccountID)
+	}
+
+	var list []string
+	seen := map[string]bool{"": true}
+	for _, id := range accountIDs {
+		if !seen[id] {
+			seen[id] = true
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+func (w *WebhookService) postOwner(ctx context.Context, postID string) string {
+	if postID == "" {
+		return ""
+	}
+	post, err := w.repositoryPost.FindPostByID(ctx, &postID)
+	if err != nil {
+		return ""
+	}
+	return post.AccountID
+}
+
+func (w *WebhookService) commentOwner(ctx context.Context, commentID string) string {
+	if commentID == "" {
+		return ""
+	}
+	comment, err := w.repositoryComment.FindCommentByID(ctx, &commentID)
+	if err != nil {
+		return ""
+	}
+	return comment.AccountID
+}
@@ -0,0 +1,42 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+func NewSecret() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// Sign returns the value of the X-Webhook-Signature header. The timestamp is
+// part of the signed content so receivers can reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait before the next attempt once attempts deliveries
+// failed: base doubled on every failure, capped at max.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= max {
+			return max
+		}
+	}
+	return wait
+}
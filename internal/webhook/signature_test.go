@@ -0,0 +1,29 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event_id":"1"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1660000000." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, expected, Sign("secret", 1660000000, body))
+	assert.NotEqual(t, expected, Sign("secret", 1660000001, body))
+	assert.NotEqual(t, expected, Sign("other", 1660000000, body))
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1, 30*time.Second, time.Hour))
+	assert.Equal(t, time.Minute, Backoff(2, 30*time.Second, time.Hour))
+	assert.Equal(t, 4*time.Minute, Backoff(4, 30*time.Second, time.Hour))
+	assert.Equal(t, time.Hour, Backoff(20, 30*time.Second, time.Hour))
+}
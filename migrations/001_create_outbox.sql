@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id           UUID PRIMARY KEY,
+    type         VARCHAR(32) NOT NULL,
+    aggregate_id UUID NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    published_at TIMESTAMP,
+    attempts     INT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (created_at) WHERE published_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS notification_processed (
+    message_id   UUID PRIMARY KEY,
+    processed_at TIMESTAMP NOT NULL
+);
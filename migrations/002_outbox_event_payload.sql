@@ -0,0 +1,20 @@
+-- Pending rows only carry a type and an aggregate id, which the typed event
+-- envelope cannot be rebuilt from: a post row holds the author rather than the
+-- post, a follow row only the followed account. Rather than lose them, the
+-- migration refuses to run until the previous release has published them.
+BEGIN;
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'outbox' AND column_name = 'aggregate_id')
+        AND EXISTS (SELECT 1 FROM outbox WHERE published_at IS NULL) THEN
+        RAISE EXCEPTION 'outbox has unpublished messages: run the previous release until its relay has published them, then apply this migration';
+    END IF;
+END
+$$;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS aggregate_id;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS payload TEXT NOT NULL DEFAULT '';
+ALTER TABLE outbox ALTER COLUMN payload DROP DEFAULT;
+
+COMMIT;
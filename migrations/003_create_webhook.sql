@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS webhook (
+    id                   UUID PRIMARY KEY,
+    account_id           UUID NOT NULL REFERENCES account (id),
+    url                  TEXT NOT NULL,
+    secret               VARCHAR(64) NOT NULL,
+    event_types          TEXT[] NOT NULL,
+    active               BOOLEAN NOT NULL DEFAULT true,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    created_at           TIMESTAMP NOT NULL,
+    updated_at           TIMESTAMP NOT NULL,
+    removed              BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS webhook_account_idx ON webhook (account_id) WHERE removed = false;
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id              UUID PRIMARY KEY,
+    webhook_id      UUID NOT NULL REFERENCES webhook (id),
+    event_id        UUID NOT NULL,
+    event_type      VARCHAR(32) NOT NULL,
+    payload         TEXT NOT NULL,
+    status          VARCHAR(16) NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    response_status INT,
+    error           TEXT,
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    updated_at      TIMESTAMP NOT NULL,
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'PENDING';
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS login_attempt (
+    id         UUID PRIMARY KEY,
+    account_id UUID REFERENCES account (id),
+    ip         VARCHAR(45) NOT NULL,
+    succeeded  BOOLEAN NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_attempt_account_idx ON login_attempt (account_id, created_at);
+CREATE INDEX IF NOT EXISTS login_attempt_ip_idx ON login_attempt (ip, created_at);
+
+CREATE TABLE IF NOT EXISTS account_lockout (
+    account_id        UUID PRIMARY KEY REFERENCES account (id),
+    locked_until      TIMESTAMP NOT NULL,
+    unlock_token_hash VARCHAR(64),
+    created_at        TIMESTAMP NOT NULL,
+    unlocked_at       TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS account_lockout_token_idx ON account_lockout (unlock_token_hash);
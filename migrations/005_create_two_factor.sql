@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS account_two_factor (
+    account_id     UUID PRIMARY KEY REFERENCES account (id),
+    secret         VARCHAR(64) NOT NULL,
+    enabled        BOOLEAN NOT NULL DEFAULT false,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at     TIMESTAMP NOT NULL,
+    updated_at     TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS account_recovery_code (
+    id         UUID PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES account (id),
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS account_recovery_code_account_idx ON account_recovery_code (account_id) WHERE used_at IS NULL;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS access_token (
+    id           UUID PRIMARY KEY,
+    account_id   UUID NOT NULL REFERENCES account (id),
+    name         VARCHAR(64) NOT NULL,
+    prefix       VARCHAR(16) NOT NULL,
+    token_hash   VARCHAR(64) NOT NULL UNIQUE,
+    scopes       TEXT[] NOT NULL,
+    expires_at   TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at   TIMESTAMP NOT NULL,
+    revoked      BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS access_token_account_idx ON access_token (account_id) WHERE revoked = false;
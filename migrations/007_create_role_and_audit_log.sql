@@ -0,0 +1,14 @@
+ALTER TABLE account ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id          UUID PRIMARY KEY,
+    actor_id    UUID NOT NULL REFERENCES account (id),
+    action      VARCHAR(32) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id   UUID NOT NULL,
+    details     TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_idx ON audit_log (created_at DESC);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS account_identity (
+    provider   VARCHAR(32) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    account_id UUID NOT NULL REFERENCES account (id),
+    email      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS account_identity_account_idx ON account_identity (account_id);
@@ -0,0 +1,3 @@
+-- The id of the request that produced a message, carried to its consumers so
+-- their log lines can be found with the request's.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Notifications waiting for their digest to be sent, so that a restart does
+-- not lose them.
+CREATE TABLE IF NOT EXISTS notification_pending (
+    event_id        UUID NOT NULL,
+    recipient_id    UUID NOT NULL,
+    kind            VARCHAR(16) NOT NULL,
+    recipient_email TEXT NOT NULL,
+    target_type     VARCHAR(16) NOT NULL,
+    target_id       TEXT NOT NULL,
+    actor_id        UUID NOT NULL,
+    actor_name      TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (event_id, recipient_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS notification_pending_created_idx ON notification_pending (created_at);
+CREATE INDEX IF NOT EXISTS notification_pending_follow_idx ON notification_pending (recipient_id, actor_id) WHERE kind = 'FOLLOW';
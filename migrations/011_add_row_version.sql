@@ -0,0 +1,5 @@
+-- Every update of a post, a comment or an account bumps its version, so that
+-- an update made with If-Match only applies to the row it was checked against.
+ALTER TABLE post ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+ALTER TABLE comment ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
+ALTER TABLE account ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 0;
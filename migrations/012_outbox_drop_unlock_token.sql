@@ -0,0 +1,5 @@
+-- account.locked events used to carry the unlock code in plain text. The
+-- notices still pending are sent with a new code.
+UPDATE outbox
+SET payload = (payload::jsonb #- '{payload,unlock_token}')::text
+WHERE type = 'account.locked';
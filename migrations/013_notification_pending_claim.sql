@@ -0,0 +1,14 @@
+-- Pending notifications are sent by whichever instance claims their digest
+-- first. A claim lapses at claimed_until, so that the digest of an instance
+-- stopped while sending it is sent by another one.
+ALTER TABLE notification_pending ADD COLUMN IF NOT EXISTS claimed_until TIMESTAMP;
+
+-- Follows already notified, so that unfollowing and following again within
+-- the dedupe window does not notify twice, whichever instance handles it. One
+-- row is kept per follower and followed account.
+CREATE TABLE IF NOT EXISTS notification_follow_sent (
+    recipient_id UUID NOT NULL,
+    actor_id     UUID NOT NULL,
+    sent_at      TIMESTAMP NOT NULL,
+    PRIMARY KEY (recipient_id, actor_id)
+);
@@ -0,0 +1,2 @@
+-- Published messages are deleted once older than the retention, oldest first.
+CREATE INDEX IF NOT EXISTS outbox_published_idx ON outbox (published_at) WHERE published_at IS NOT NULL;